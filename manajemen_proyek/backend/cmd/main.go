@@ -3,13 +3,18 @@ package main
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/unipro/project-management/config"
 	"github.com/unipro/project-management/internal/handlers"
 	"github.com/unipro/project-management/internal/middleware"
 	"github.com/unipro/project-management/pkg/database"
+	"github.com/unipro/project-management/pkg/evm"
 	jwtPkg "github.com/unipro/project-management/pkg/jwt"
+	"github.com/unipro/project-management/pkg/loginguard"
+	"github.com/unipro/project-management/pkg/mail"
+	"github.com/unipro/project-management/pkg/recyclebin"
 )
 
 func main() {
@@ -42,6 +47,29 @@ func main() {
 		log.Fatalf("❌ Failed to seed users: %v", err)
 	}
 	
+	// Seed phase templates and give older projects their phases
+	if err := database.SeedPhaseTemplates(); err != nil {
+		log.Fatalf("❌ Failed to seed phase templates: %v", err)
+	}
+	if err := database.MigrateProjectPhases(); err != nil {
+		log.Fatalf("❌ Failed to migrate project phases: %v", err)
+	}
+	if err := database.MigrateProjectBudgets(); err != nil {
+		log.Fatalf("❌ Failed to migrate project budgets: %v", err)
+	}
+	if err := database.SeedCostCodes(); err != nil {
+		log.Fatalf("❌ Failed to seed cost codes: %v", err)
+	}
+	if err := database.MigrateBudgetLines(); err != nil {
+		log.Fatalf("❌ Failed to migrate budget lines: %v", err)
+	}
+	
+	// Purge recycle bin records past their retention period daily
+	recyclebin.StartPurger(database.GetDB(), 24*time.Hour)
+
+	// Keep this week's EVM snapshot of every active project current
+	evm.StartSnapshotter(database.GetDB(), 24*time.Hour)
+	
 	// Initialize JWT
 	jwtPkg.Initialize(cfg.JWT.Secret)
 	
@@ -54,6 +82,7 @@ func main() {
 	
 	// CORS middleware - must be first
 	router.Use(corsMiddleware())
+	router.Use(middleware.RequestID())
 	
 	// Serve static files
 	router.Static("/static", "./static")
@@ -75,10 +104,28 @@ func main() {
 	
 	// Initialize handlers
 	db := database.GetDB()
-	authHandler := handlers.NewAuthHandler(db, cfg)
+	mailer := mail.NewSender(cfg.Mail)
+	loginGuard := loginguard.NewFromConfig(cfg.Login, db)
+	authHandler := handlers.NewAuthHandler(db, cfg, mailer, loginGuard)
 	projectHandler := handlers.NewProjectHandler(db)
 	dashboardHandler := handlers.NewDashboardHandler(db)
 	reportHandler := handlers.NewReportHandler(db)
+	userHandler := handlers.NewUserHandler(db, loginGuard)
+	invitationHandler := handlers.NewInvitationHandler(db, cfg)
+	roleHandler := handlers.NewRoleHandler(db)
+	auditHandler := handlers.NewAuditHandler(db)
+	delegationHandler := handlers.NewDelegationHandler(db)
+	costHandler := handlers.NewCostHandler(db)
+	evmHandler := handlers.NewEVMHandler(db)
+	taskHandler := handlers.NewTaskHandler(db)
+	billingHandler := handlers.NewBillingHandler(db)
+	changeOrderHandler := handlers.NewChangeOrderHandler(db)
+	budgetHandler := handlers.NewBudgetHandler(db)
+	templateHandler := handlers.NewProjectTemplateHandler(db)
+	recycleBinHandler := handlers.NewRecycleBinHandler(db)
+	documentHandler := handlers.NewDocumentHandler(db, cfg.Upload)
+	riskHandler := handlers.NewRiskHandler(db)
+	subcontractHandler := handlers.NewSubcontractHandler(db)
 	
 	// API v1 routes group
 	v1 := router.Group("/api/v1")
@@ -89,6 +136,12 @@ func main() {
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.POST("/password/forgot", authHandler.ForgotPassword)
+			auth.POST("/password/reset", authHandler.ResetPassword)
+			auth.POST("/2fa/verify", authHandler.VerifyTwoFactor)
+			auth.POST("/2fa/enroll", authHandler.StartTwoFactorEnrollment)
+			auth.POST("/2fa/enroll/confirm", authHandler.ConfirmTwoFactorEnrollment)
+			auth.GET("/invitations/:token", invitationHandler.GetInvitationByToken)
 		}
 		
 		// Protected routes (auth required)
@@ -97,6 +150,18 @@ func main() {
 		{
 			// Current user info
 			protected.GET("/me", authHandler.Me)
+			protected.PUT("/me/password", authHandler.ChangePassword)
+			protected.GET("/me/2fa", authHandler.GetTwoFactorStatus)
+			protected.POST("/me/2fa/setup", authHandler.SetupTwoFactor)
+			protected.POST("/me/2fa/enable", authHandler.EnableTwoFactor)
+			protected.POST("/me/2fa/disable", authHandler.DisableTwoFactor)
+			protected.POST("/me/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+
+			// Session management
+			protected.POST("/auth/logout", authHandler.Logout)
+			protected.GET("/auth/sessions", authHandler.GetSessions)
+			protected.DELETE("/auth/sessions", authHandler.RevokeAllSessions)
+			protected.DELETE("/auth/sessions/:id", authHandler.RevokeSession)
 			
 			// Dashboard - role-specific metrics
 			protected.GET("/dashboard", dashboardHandler.GetDashboard)
@@ -107,13 +172,45 @@ func main() {
 				projects.GET("", projectHandler.GetAllProjects)
 				projects.GET("/:id", projectHandler.GetProjectByID)
 				
-				// Only managers and directors can create/update/delete
-				projects.POST("", middleware.RequireRole("director", "manager"), projectHandler.CreateProject)
-				projects.PUT("/:id", middleware.RequireRole("director", "manager"), projectHandler.UpdateProject)
-				projects.DELETE("/:id", middleware.RequireRole("director", "manager"), projectHandler.DeleteProject)
+				// Creating, updating and deleting projects requires write access
+				projects.POST("", middleware.RequirePermission("projects", "write"), projectHandler.CreateProject)
+				projects.PUT("/:id", middleware.RequirePermission("projects", "write"), projectHandler.UpdateProject)
+				projects.DELETE("/:id", middleware.RequirePermission("projects", "write"), projectHandler.DeleteProject)
+				projects.POST("/:id/clone", middleware.RequirePermission("projects", "write"), templateHandler.CloneProject)
+				projects.POST("/:id/template", middleware.RequirePermission("projects", "write"), templateHandler.SaveProjectAsTemplate)
 				
-				// Tim lapangan can update progress
-				projects.PATCH("/:id/progress", middleware.RequireRole("director", "manager", "tim_lapangan"), projectHandler.UpdateProgress)
+				// Field teams can update progress
+				projects.PATCH("/:id/progress", middleware.RequirePermission("projects", "update_progress"), projectHandler.UpdateProgress)
+				
+				// Construction phases
+				projects.GET("/:id/phases", projectHandler.GetProjectPhases)
+				projects.POST("/:id/phases", middleware.RequirePermission("projects", "write"), projectHandler.CreateProjectPhase)
+				projects.PUT("/:id/phases/:phaseId", middleware.RequirePermission("projects", "write"), projectHandler.UpdateProjectPhase)
+				projects.DELETE("/:id/phases/:phaseId", middleware.RequirePermission("projects", "write"), projectHandler.DeleteProjectPhase)
+				projects.PATCH("/:id/status", middleware.RequirePermission("projects", "write"), projectHandler.UpdateProjectStatus)
+				projects.GET("/:id/health", projectHandler.GetProjectHealth)
+				projects.GET("/:id/progress/history", projectHandler.GetProgressHistory)
+				projects.GET("/:id/s-curve", projectHandler.GetSCurve)
+				
+				// Work breakdown structure and scheduling
+				projects.GET("/:id/tasks", taskHandler.GetProjectTasks)
+				projects.GET("/:id/tasks/:taskId", taskHandler.GetTask)
+				projects.POST("/:id/tasks", middleware.RequirePermission("projects", "write"), taskHandler.CreateTask)
+				projects.PUT("/:id/tasks/:taskId", middleware.RequirePermission("projects", "write"), taskHandler.UpdateTask)
+				projects.DELETE("/:id/tasks/:taskId", middleware.RequirePermission("projects", "write"), taskHandler.DeleteTask)
+				projects.PATCH("/:id/tasks/:taskId/progress", middleware.RequirePermission("projects", "update_progress"), taskHandler.UpdateTaskProgress)
+				projects.POST("/:id/dependencies", middleware.RequirePermission("projects", "write"), taskHandler.CreateTaskDependency)
+				projects.DELETE("/:id/dependencies/:dependencyId", middleware.RequirePermission("projects", "write"), taskHandler.DeleteTaskDependency)
+				projects.POST("/:id/schedule", middleware.RequirePermission("projects", "write"), taskHandler.RescheduleProject)
+				projects.GET("/:id/gantt", taskHandler.GetGantt)
+				
+				// Project membership
+				projects.GET("/:id/members", projectHandler.GetProjectMembers)
+				projects.POST("/:id/members", middleware.RequirePermission("projects", "write"), projectHandler.AddProjectMember)
+				projects.DELETE("/:id/members/:userId", middleware.RequirePermission("projects", "write"), projectHandler.RemoveProjectMember)
+				
+				// Change history of the project and everything in it
+				projects.GET("/:id/history", middleware.RequirePermission("audit", "read"), auditHandler.GetProjectHistory)
 				
 				// Project-specific BOM routes
 				projects.GET("/:id/bom", handlers.GetBOMByProject)
@@ -122,6 +219,55 @@ func main() {
 				// Project-specific Material Usage routes
 				projects.GET("/:id/material-usage", handlers.GetMaterialUsageByProject)
 				projects.GET("/:id/material-usage/stats", handlers.GetMaterialUsageStats)
+				
+				// Cost ledger
+				projects.GET("/:id/costs", middleware.RequirePermission("costs", "read"), costHandler.GetProjectCosts)
+				projects.POST("/:id/costs", middleware.RequirePermission("costs", "write"), costHandler.CreateCostEntry)
+				projects.POST("/:id/costs/recalculate", middleware.RequirePermission("costs", "write"), costHandler.RecalculateProjectCost)
+				projects.GET("/:id/budget", middleware.RequirePermission("costs", "read"), budgetHandler.GetProjectBudget)
+				projects.GET("/:id/budget/report", middleware.RequirePermission("costs", "read"), budgetHandler.GetBudgetReport)
+				projects.POST("/:id/budget/lines", middleware.RequirePermission("costs", "write"), budgetHandler.CreateBudgetLine)
+				projects.PUT("/:id/budget/lines/:lineId", middleware.RequirePermission("costs", "write"), budgetHandler.UpdateBudgetLine)
+				projects.DELETE("/:id/budget/lines/:lineId", middleware.RequirePermission("costs", "write"), budgetHandler.DeleteBudgetLine)
+				projects.POST("/:id/budget/sync-bom", middleware.RequirePermission("costs", "write"), budgetHandler.SyncBudgetFromBOM)
+				
+				// Earned value management
+				projects.GET("/:id/evm", middleware.RequirePermission("costs", "read"), evmHandler.GetProjectEVM)
+				projects.GET("/:id/evm/trend", middleware.RequirePermission("costs", "read"), evmHandler.GetProjectEVMTrend)
+				
+				// Customer billing (termin)
+				projects.GET("/:id/contract", middleware.RequirePermission("billing", "read"), billingHandler.GetProjectContract)
+				projects.PUT("/:id/contract", middleware.RequirePermission("billing", "write"), billingHandler.SaveProjectContract)
+				projects.GET("/:id/billing/milestones", middleware.RequirePermission("billing", "read"), billingHandler.GetBillingMilestones)
+				projects.POST("/:id/billing/milestones", middleware.RequirePermission("billing", "write"), billingHandler.CreateBillingMilestone)
+				projects.PUT("/:id/billing/milestones/:milestoneId", middleware.RequirePermission("billing", "write"), billingHandler.UpdateBillingMilestone)
+				projects.DELETE("/:id/billing/milestones/:milestoneId", middleware.RequirePermission("billing", "write"), billingHandler.DeleteBillingMilestone)
+				projects.POST("/:id/billing/milestones/:milestoneId/invoice", middleware.RequirePermission("billing", "write"), billingHandler.InvoiceMilestone)
+				projects.GET("/:id/billing/summary", middleware.RequirePermission("billing", "read"), billingHandler.GetBillingSummary)
+				
+				// Change orders revising budget, schedule and BOM
+				projects.GET("/:id/change-orders", changeOrderHandler.GetProjectChangeOrders)
+				projects.POST("/:id/change-orders", middleware.RequirePermission("projects", "write"), changeOrderHandler.CreateChangeOrder)
+				projects.GET("/:id/change-orders/log", changeOrderHandler.GetChangeOrderLog)
+				projects.GET("/:id/change-orders/log/pdf", changeOrderHandler.DownloadChangeOrderLogPDF)
+				
+				// Document repository
+				projects.GET("/:id/document-folders", middleware.RequirePermission("documents", "read"), documentHandler.GetDocumentFolders)
+				projects.POST("/:id/document-folders", middleware.RequirePermission("documents", "write"), documentHandler.CreateDocumentFolder)
+				projects.PUT("/:id/document-folders/:folderId", middleware.RequirePermission("documents", "write"), documentHandler.UpdateDocumentFolder)
+				projects.DELETE("/:id/document-folders/:folderId", middleware.RequirePermission("documents", "write"), documentHandler.DeleteDocumentFolder)
+				projects.GET("/:id/documents", middleware.RequirePermission("documents", "read"), documentHandler.GetProjectDocuments)
+				projects.POST("/:id/documents", middleware.RequirePermission("documents", "write"), documentHandler.CreateDocument)
+				
+				// Risk and issue register
+				projects.GET("/:id/risks", middleware.RequirePermission("risks", "read"), riskHandler.GetProjectRisks)
+				projects.POST("/:id/risks", middleware.RequirePermission("risks", "write"), riskHandler.CreateRisk)
+				projects.GET("/:id/risks/summary", middleware.RequirePermission("risks", "read"), riskHandler.GetProjectRiskSummary)
+				
+				// Subcontracts
+				projects.GET("/:id/subcontracts", middleware.RequirePermission("subcontracts", "read"), subcontractHandler.GetProjectSubcontracts)
+				projects.POST("/:id/subcontracts", middleware.RequirePermission("subcontracts", "write"), subcontractHandler.CreateSubcontract)
+				projects.GET("/:id/subcontracts/summary", middleware.RequirePermission("subcontracts", "read"), subcontractHandler.GetProjectSubcontractSummary)
 			}
 			
 			// Approvals routes
@@ -133,6 +279,112 @@ func main() {
 				approvals.PUT("/:id/status", handlers.UpdateApprovalStatus)
 			}
 			
+			// Invoice and payment routes
+			invoices := protected.Group("/invoices")
+			{
+				invoices.GET("", middleware.RequirePermission("billing", "read"), billingHandler.GetInvoices)
+				invoices.GET("/:id", middleware.RequirePermission("billing", "read"), billingHandler.GetInvoiceByID)
+				invoices.GET("/:id/pdf", middleware.RequirePermission("billing", "read"), billingHandler.DownloadInvoicePDF)
+				invoices.POST("/:id/issue", middleware.RequirePermission("billing", "write"), billingHandler.IssueInvoice)
+				invoices.POST("/:id/cancel", middleware.RequirePermission("billing", "write"), billingHandler.CancelInvoice)
+				invoices.POST("/:id/payments", middleware.RequirePermission("billing", "write"), billingHandler.CreatePayment)
+			}
+			protected.DELETE("/payments/:id", middleware.RequirePermission("billing", "write"), billingHandler.DeletePayment)
+			
+			// Change order routes, approvals are checked against the stage
+			changeOrders := protected.Group("/change-orders")
+			{
+				changeOrders.GET("", changeOrderHandler.GetChangeOrders)
+				changeOrders.GET("/:id", changeOrderHandler.GetChangeOrderByID)
+				changeOrders.PUT("/:id", middleware.RequirePermission("projects", "write"), changeOrderHandler.UpdateChangeOrder)
+				changeOrders.DELETE("/:id", middleware.RequirePermission("projects", "write"), changeOrderHandler.DeleteChangeOrder)
+				changeOrders.POST("/:id/submit", middleware.RequirePermission("projects", "write"), changeOrderHandler.SubmitChangeOrder)
+				changeOrders.POST("/:id/approve", changeOrderHandler.ApproveChangeOrder)
+				changeOrders.POST("/:id/reject", changeOrderHandler.RejectChangeOrder)
+				changeOrders.GET("/:id/documents", middleware.RequirePermission("documents", "read"), documentHandler.GetChangeOrderDocuments)
+			}
+			
+			// Document routes, files are only served through the download endpoints
+			documents := protected.Group("/documents")
+			{
+				documents.GET("/:id", middleware.RequirePermission("documents", "read"), documentHandler.GetDocumentByID)
+				documents.PUT("/:id", middleware.RequirePermission("documents", "write"), documentHandler.UpdateDocument)
+				documents.DELETE("/:id", middleware.RequirePermission("documents", "delete"), documentHandler.DeleteDocument)
+				documents.GET("/:id/download", middleware.RequirePermission("documents", "read"), documentHandler.DownloadDocument)
+				documents.GET("/:id/versions", middleware.RequirePermission("documents", "read"), documentHandler.GetDocumentVersions)
+				documents.POST("/:id/versions", middleware.RequirePermission("documents", "write"), documentHandler.UploadDocumentVersion)
+				documents.GET("/:id/versions/:versionId/download", middleware.RequirePermission("documents", "read"), documentHandler.DownloadDocumentVersion)
+				documents.POST("/:id/links", middleware.RequirePermission("documents", "write"), documentHandler.CreateDocumentLink)
+				documents.DELETE("/:id/links/:linkId", middleware.RequirePermission("documents", "write"), documentHandler.DeleteDocumentLink)
+			}
+			
+			// Risk and issue register routes, entries are closed through their status
+			risks := protected.Group("/risks")
+			{
+				risks.GET("/:id", middleware.RequirePermission("risks", "read"), riskHandler.GetRiskByID)
+				risks.PUT("/:id", middleware.RequirePermission("risks", "write"), riskHandler.UpdateRisk)
+				risks.DELETE("/:id", middleware.RequirePermission("risks", "delete"), riskHandler.DeleteRisk)
+				risks.POST("/:id/status", middleware.RequirePermission("risks", "write"), riskHandler.UpdateRiskStatus)
+			}
+			
+			// Recycle bin routes
+			recycleBin := protected.Group("/recycle-bin")
+			{
+				recycleBin.GET("", middleware.RequirePermission("recycle_bin", "read"), recycleBinHandler.GetRecycleBin)
+				recycleBin.POST("/purge-expired", middleware.RequirePermission("recycle_bin", "purge"), recycleBinHandler.PurgeExpired)
+				recycleBin.POST("/:type/:id/restore", middleware.RequirePermission("recycle_bin", "restore"), recycleBinHandler.RestoreRecord)
+				recycleBin.DELETE("/:type/:id", middleware.RequirePermission("recycle_bin", "purge"), recycleBinHandler.PurgeRecord)
+			}
+			
+			// Project template routes
+			projectTemplates := protected.Group("/project-templates")
+			{
+				projectTemplates.GET("", templateHandler.GetProjectTemplates)
+				projectTemplates.GET("/:id", templateHandler.GetProjectTemplateByID)
+				projectTemplates.DELETE("/:id", middleware.RequirePermission("projects", "write"), templateHandler.DeleteProjectTemplate)
+				projectTemplates.POST("/:id/projects", middleware.RequirePermission("projects", "write"), templateHandler.CreateProjectFromTemplate)
+			}
+			
+			// Portfolio earned value routes
+			evmRoutes := protected.Group("/evm")
+			{
+				evmRoutes.GET("/portfolio", middleware.RequirePermission("costs", "read"), evmHandler.GetPortfolioEVM)
+				evmRoutes.POST("/snapshots", middleware.RequirePermission("costs", "write"), evmHandler.RecordEVMSnapshots)
+			}
+			
+			// Company-wide settings routes
+			settings := protected.Group("/settings")
+			{
+				settings.GET("/phase-templates", projectHandler.GetPhaseTemplates)
+				settings.PUT("/phase-templates/:projectType", middleware.RequirePermission("settings", "manage"), projectHandler.ReplacePhaseTemplates)
+				settings.GET("/health", projectHandler.GetHealthSettings)
+				settings.PUT("/health", middleware.RequirePermission("settings", "manage"), projectHandler.UpdateHealthSettings)
+				settings.POST("/health/reevaluate", middleware.RequirePermission("settings", "manage"), projectHandler.ReevaluateStatuses)
+				settings.GET("/cost-codes", budgetHandler.GetCostCodes)
+				settings.POST("/cost-codes", middleware.RequirePermission("settings", "manage"), budgetHandler.CreateCostCode)
+				settings.PUT("/cost-codes/:id", middleware.RequirePermission("settings", "manage"), budgetHandler.UpdateCostCode)
+				settings.DELETE("/cost-codes/:id", middleware.RequirePermission("settings", "manage"), budgetHandler.DeleteCostCode)
+				settings.GET("/recycle-bin", recycleBinHandler.GetRecycleBinSettings)
+				settings.PUT("/recycle-bin", middleware.RequirePermission("settings", "manage"), recycleBinHandler.UpdateRecycleBinSettings)
+			}
+			
+			// Cost entries routes
+			costs := protected.Group("/costs")
+			costs.Use(middleware.RequirePermission("costs", "write"))
+			{
+				costs.PUT("/:id", costHandler.UpdateCostEntry)
+				costs.DELETE("/:id", costHandler.DeleteCostEntry)
+				costs.POST("/recalculate", costHandler.RecalculateAllCosts)
+			}
+			
+			// Delegations routes
+			delegations := protected.Group("/delegations")
+			{
+				delegations.GET("", delegationHandler.GetDelegations)
+				delegations.POST("", delegationHandler.CreateDelegation)
+				delegations.DELETE("/:id", delegationHandler.RevokeDelegation)
+			}
+			
 			// Notifications routes
 			notifications := protected.Group("/notifications")
 			{
@@ -149,18 +401,20 @@ func main() {
 				// Daily reports
 				reports.GET("/daily", reportHandler.GetDailyReports)
 				reports.GET("/daily/:id", reportHandler.GetDailyReportByID)
-				reports.POST("/daily", middleware.RequireRole("tim_lapangan", "manager", "director"), reportHandler.CreateDailyReport)
+				reports.POST("/daily", middleware.RequirePermission("daily_reports", "write"), reportHandler.CreateDailyReport)
 				reports.PUT("/daily/:id", reportHandler.UpdateDailyReport)
 				reports.DELETE("/daily/:id", reportHandler.DeleteDailyReport)
 				
 				// Photo uploads for daily reports
-				reports.POST("/daily/:id/photos", middleware.RequireRole("tim_lapangan", "manager", "director"), reportHandler.UploadDailyReportPhotos)
+				reports.POST("/daily/:id/photos", middleware.RequirePermission("daily_reports", "write"), reportHandler.UploadDailyReportPhotos)
 				reports.GET("/daily/:id/photos", reportHandler.GetDailyReportPhotos)
+				reports.GET("/daily/:id/documents", middleware.RequirePermission("documents", "read"), documentHandler.GetDailyReportDocuments)
+				reports.POST("/daily/:id/issues", middleware.RequirePermission("risks", "write"), riskHandler.RaiseDailyReportIssue)
 				
 				// Weekly reports
 				reports.GET("/weekly", reportHandler.GetWeeklyReports)
 				reports.GET("/weekly/:id", reportHandler.GetWeeklyReportByID)
-				reports.POST("/weekly/generate", middleware.RequireRole("manager", "director"), reportHandler.GenerateWeeklyReport)
+				reports.POST("/weekly/generate", middleware.RequirePermission("reports", "write"), reportHandler.GenerateWeeklyReport)
 				reports.GET("/weekly/:id/pdf", reportHandler.DownloadWeeklyReportPDF)
 			}
 			
@@ -179,40 +433,125 @@ func main() {
 				purchaseRequests.POST("/:id/approve", handlers.ApprovePurchaseRequest)
 				purchaseRequests.POST("/:id/reject", handlers.RejectPurchaseRequest)
 				purchaseRequests.POST("/:id/comments", handlers.AddPRComment)
+				purchaseRequests.GET("/:id/documents", middleware.RequirePermission("documents", "read"), documentHandler.GetPurchaseRequestDocuments)
 			}
 			
+			// Subcontractor routes
+			subcontractors := protected.Group("/subcontractors")
+			{
+				subcontractors.GET("", middleware.RequirePermission("subcontracts", "read"), subcontractHandler.GetSubcontractors)
+				subcontractors.GET("/:id", middleware.RequirePermission("subcontracts", "read"), subcontractHandler.GetSubcontractorByID)
+				subcontractors.POST("", middleware.RequirePermission("subcontracts", "write"), subcontractHandler.CreateSubcontractor)
+				subcontractors.PUT("/:id", middleware.RequirePermission("subcontracts", "write"), subcontractHandler.UpdateSubcontractor)
+				subcontractors.DELETE("/:id", middleware.RequirePermission("subcontracts", "write"), subcontractHandler.DeleteSubcontractor)
+			}
+			
+			// Subcontract routes
+			subcontracts := protected.Group("/subcontracts")
+			{
+				subcontracts.GET("", middleware.RequirePermission("subcontracts", "read"), subcontractHandler.GetSubcontracts)
+				subcontracts.GET("/:id", middleware.RequirePermission("subcontracts", "read"), subcontractHandler.GetSubcontractByID)
+				subcontracts.PUT("/:id", middleware.RequirePermission("subcontracts", "write"), subcontractHandler.UpdateSubcontract)
+				subcontracts.DELETE("/:id", middleware.RequirePermission("subcontracts", "write"), subcontractHandler.DeleteSubcontract)
+				subcontracts.POST("/:id/status", middleware.RequirePermission("subcontracts", "write"), subcontractHandler.UpdateSubcontractStatus)
+				subcontracts.GET("/:id/certificates", middleware.RequirePermission("subcontracts", "read"), subcontractHandler.GetSubcontractCertificates)
+				subcontracts.POST("/:id/certificates", middleware.RequirePermission("subcontracts", "write"), subcontractHandler.CreatePaymentCertificate)
+			}
+			
+			// Subcontract payment certificate routes
+			paymentCertificates := protected.Group("/payment-certificates")
+			{
+				paymentCertificates.GET("", middleware.RequirePermission("subcontracts", "read"), subcontractHandler.GetPaymentCertificates)
+				paymentCertificates.GET("/:id", middleware.RequirePermission("subcontracts", "read"), subcontractHandler.GetPaymentCertificateByID)
+				paymentCertificates.PUT("/:id", middleware.RequirePermission("subcontracts", "write"), subcontractHandler.UpdatePaymentCertificate)
+				paymentCertificates.DELETE("/:id", middleware.RequirePermission("subcontracts", "write"), subcontractHandler.DeletePaymentCertificate)
+				paymentCertificates.POST("/:id/submit", middleware.RequirePermission("subcontracts", "write"), subcontractHandler.SubmitPaymentCertificate)
+				paymentCertificates.POST("/:id/approve", subcontractHandler.ApprovePaymentCertificate)
+				paymentCertificates.POST("/:id/reject", subcontractHandler.RejectPaymentCertificate)
+				paymentCertificates.POST("/:id/payments", middleware.RequirePermission("subcontracts", "pay"), subcontractHandler.CreateSubcontractPayment)
+			}
+			protected.DELETE("/subcontract-payments/:id", middleware.RequirePermission("subcontracts", "pay"), subcontractHandler.DeleteSubcontractPayment)
+			
 			// Materials routes
 			materials := protected.Group("/materials")
 			{
 				materials.GET("", handlers.GetAllMaterials)
 				materials.GET("/low-stock", handlers.GetLowStockMaterials)
 				materials.GET("/:id", handlers.GetMaterialByID)
-				materials.POST("", middleware.RequireRole("purchasing", "manager", "director"), handlers.CreateMaterial)
-				materials.PUT("/:id", middleware.RequireRole("purchasing", "manager", "director"), handlers.UpdateMaterial)
-				materials.DELETE("/:id", middleware.RequireRole("director", "manager"), handlers.DeleteMaterial)
-				materials.PATCH("/:id/stock", middleware.RequireRole("purchasing", "manager", "director"), handlers.UpdateMaterialStock)
+				materials.POST("", middleware.RequirePermission("materials", "write"), handlers.CreateMaterial)
+				materials.PUT("/:id", middleware.RequirePermission("materials", "write"), handlers.UpdateMaterial)
+				materials.DELETE("/:id", middleware.RequirePermission("materials", "delete"), handlers.DeleteMaterial)
+				materials.PATCH("/:id/stock", middleware.RequirePermission("materials", "adjust_stock"), handlers.UpdateMaterialStock)
+				materials.GET("/:id/history", middleware.RequirePermission("materials", "read"), auditHandler.GetMaterialHistory)
 			}
 			
 			// BOM (Bill of Materials) routes
 			bom := protected.Group("/bom")
 			{
 				bom.GET("/:id", handlers.GetBOMByID)
-				bom.POST("", middleware.RequireRole("cost_control", "manager", "director"), handlers.CreateBOM)
-				bom.PUT("/:id", middleware.RequireRole("cost_control", "manager", "director"), handlers.UpdateBOM)
-				bom.DELETE("/:id", middleware.RequireRole("director", "manager"), handlers.DeleteBOM)
-				bom.POST("/import", middleware.RequireRole("cost_control", "manager", "director"), handlers.ImportBOMFromTemplate)
+				bom.POST("", middleware.RequirePermission("bom", "write"), handlers.CreateBOM)
+				bom.PUT("/:id", middleware.RequirePermission("bom", "write"), handlers.UpdateBOM)
+				bom.DELETE("/:id", middleware.RequirePermission("bom", "delete"), handlers.DeleteBOM)
+				bom.POST("/import", middleware.RequirePermission("bom", "write"), handlers.ImportBOMFromTemplate)
 			}
 			
 			// Material Usage routes
 			materialUsage := protected.Group("/material-usage")
 			{
 				materialUsage.GET("/:id", handlers.GetMaterialUsageByID)
-				materialUsage.POST("", middleware.RequireRole("tim_lapangan", "manager", "director"), handlers.CreateMaterialUsage)
-				materialUsage.PUT("/:id", middleware.RequireRole("tim_lapangan", "manager", "director"), handlers.UpdateMaterialUsage)
-				materialUsage.DELETE("/:id", middleware.RequireRole("manager", "director"), handlers.DeleteMaterialUsage)
+				materialUsage.POST("", middleware.RequirePermission("material_usage", "write"), handlers.CreateMaterialUsage)
+				materialUsage.PUT("/:id", middleware.RequirePermission("material_usage", "write"), handlers.UpdateMaterialUsage)
+				materialUsage.DELETE("/:id", middleware.RequirePermission("material_usage", "delete"), handlers.DeleteMaterialUsage)
 			}
 			
-			// TODO: Users management routes
+			// Users management routes (admin only)
+			users := protected.Group("/users")
+			users.Use(middleware.RequirePermission("users", "manage"))
+			{
+				users.GET("", userHandler.GetAllUsers)
+				users.GET("/:id", userHandler.GetUserByID)
+				users.POST("", userHandler.CreateUser)
+				users.PUT("/:id", userHandler.UpdateUser)
+				users.PATCH("/:id/role", userHandler.ChangeUserRole)
+				users.PATCH("/:id/deactivate", userHandler.DeactivateUser)
+				users.PATCH("/:id/reactivate", userHandler.ReactivateUser)
+				users.POST("/:id/reset-password", userHandler.ResetUserPassword)
+				users.POST("/:id/force-logout", userHandler.ForceLogout)
+				users.POST("/:id/reset-2fa", userHandler.ResetTwoFactor)
+				users.GET("/:id/lockout", userHandler.GetUserLockout)
+				users.POST("/:id/unlock", userHandler.UnlockUser)
+			}
+
+			// Login attempt log
+			protected.GET("/login-attempts", middleware.RequirePermission("users", "manage"), userHandler.GetLoginAttempts)
+
+			// Roles & permissions routes (admin only)
+			roles := protected.Group("/roles")
+			roles.Use(middleware.RequirePermission("roles", "manage"))
+			{
+				roles.GET("", roleHandler.GetAllRoles)
+				roles.GET("/:id", roleHandler.GetRoleByID)
+				roles.POST("", roleHandler.CreateRole)
+				roles.PUT("/:id", roleHandler.UpdateRole)
+			}
+			protected.GET("/permissions/catalog", middleware.RequirePermission("roles", "manage"), roleHandler.GetPermissionCatalog)
+
+			// Audit trail routes
+			auditLogs := protected.Group("/audit")
+			auditLogs.Use(middleware.RequirePermission("audit", "read"))
+			{
+				auditLogs.GET("", auditHandler.GetAuditLogs)
+				auditLogs.GET("/:entity/:entityId", auditHandler.GetEntityHistory)
+			}
+
+			// Invitation routes (admin only)
+			invitations := protected.Group("/invitations")
+			invitations.Use(middleware.RequirePermission("users", "manage"))
+			{
+				invitations.GET("", invitationHandler.GetInvitations)
+				invitations.POST("", invitationHandler.CreateInvitation)
+				invitations.DELETE("/:id", invitationHandler.RevokeInvitation)
+			}
 		}
 	}
 	
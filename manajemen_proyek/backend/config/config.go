@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -12,6 +14,9 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	JWT      JWTConfig
+	Auth     AuthConfig
+	Mail     MailConfig
+	Login    LoginGuardConfig
 	Upload   UploadConfig
 	CORS     CORSConfig
 }
@@ -32,13 +37,48 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	Secret string
-	Expiry time.Duration
+	Secret        string
+	Expiry        time.Duration // Access token lifetime
+	RefreshExpiry time.Duration // Refresh token (session) lifetime
+}
+
+type AuthConfig struct {
+	AllowOpenRegistration bool          // Allow /auth/register without an invitation
+	DefaultRole           string        // Role given to users registering without an invitation
+	InviteExpiry          time.Duration // Default lifetime of an invitation
+	PasswordResetExpiry   time.Duration // Lifetime of a password reset token
+	PasswordResetURL      string        // Frontend page that accepts ?token=
+	TwoFactorIssuer       string        // Issuer shown in authenticator apps
+	TwoFactorRoles        []string      // Roles that must use two-factor authentication
+	ChallengeExpiry       time.Duration // Lifetime of the login challenge between password and code
+}
+
+type LoginGuardConfig struct {
+	Store              string        // "memory" for a single instance, "database" when running several
+	MaxAccountFailures int           // Failed logins before an account is locked
+	MaxIPFailures      int           // Failed logins before a client IP is locked
+	FreeFailures       int           // Failed logins allowed before delays start
+	BaseDelay          time.Duration // First delay, doubled for each further failure
+	MaxDelay           time.Duration
+	LockoutDuration    time.Duration
+	FailureWindow      time.Duration // Failures older than this are forgotten
+}
+
+type MailConfig struct {
+	Driver   string // "smtp" or "log"
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	LogDir   string // Where the log driver writes .eml files, empty to only log
 }
 
 type UploadConfig struct {
-	Path        string
-	MaxFileSize int64
+	Path            string
+	MaxFileSize     int64
+	DocumentPath    string // Project documents, kept out of the public uploads directory
+	MaxDocumentSize int64
 }
 
 type CORSConfig struct {
@@ -53,9 +93,33 @@ func LoadConfig() *Config {
 	}
 
 	// Parse JWT expiry
-	jwtExpiry, err := time.ParseDuration(getEnv("JWT_EXPIRY", "24h"))
+	jwtExpiry, err := time.ParseDuration(getEnv("JWT_EXPIRY", "15m"))
+	if err != nil {
+		jwtExpiry = 15 * time.Minute
+	}
+
+	// Parse refresh token expiry
+	refreshExpiry, err := time.ParseDuration(getEnv("JWT_REFRESH_EXPIRY", "720h"))
+	if err != nil {
+		refreshExpiry = 720 * time.Hour
+	}
+
+	// Parse invitation expiry
+	inviteExpiry, err := time.ParseDuration(getEnv("INVITE_EXPIRY", "72h"))
+	if err != nil {
+		inviteExpiry = 72 * time.Hour
+	}
+
+	// Parse password reset expiry
+	resetExpiry, err := time.ParseDuration(getEnv("PASSWORD_RESET_EXPIRY", "1h"))
+	if err != nil {
+		resetExpiry = time.Hour
+	}
+
+	// Parse two-factor challenge expiry
+	challengeExpiry, err := time.ParseDuration(getEnv("TWO_FACTOR_CHALLENGE_EXPIRY", "5m"))
 	if err != nil {
-		jwtExpiry = 24 * time.Hour
+		challengeExpiry = 5 * time.Minute
 	}
 
 	return &Config{
@@ -73,12 +137,45 @@ func LoadConfig() *Config {
 			TimeZone: getEnv("DB_TIMEZONE", "Asia/Jakarta"),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-secret-key"),
-			Expiry: jwtExpiry,
+			Secret:        getEnv("JWT_SECRET", "your-secret-key"),
+			Expiry:        jwtExpiry,
+			RefreshExpiry: refreshExpiry,
+		},
+		Auth: AuthConfig{
+			AllowOpenRegistration: getEnv("ALLOW_OPEN_REGISTRATION", "false") == "true",
+			DefaultRole:           getEnv("DEFAULT_REGISTRATION_ROLE", "tim_lapangan"),
+			InviteExpiry:          inviteExpiry,
+			PasswordResetExpiry:   resetExpiry,
+			PasswordResetURL:      getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+			TwoFactorIssuer:       getEnv("TWO_FACTOR_ISSUER", "Unipro"),
+			TwoFactorRoles:        splitList(os.Getenv("TWO_FACTOR_REQUIRED_ROLES")),
+			ChallengeExpiry:       challengeExpiry,
+		},
+		Login: LoginGuardConfig{
+			Store:              getEnv("LOGIN_GUARD_STORE", "memory"),
+			MaxAccountFailures: getEnvInt("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+			MaxIPFailures:      getEnvInt("LOGIN_MAX_IP_FAILURES", 20),
+			FreeFailures:       getEnvInt("LOGIN_FREE_FAILURES", 2),
+			BaseDelay:          getEnvDuration("LOGIN_BASE_DELAY", time.Second),
+			MaxDelay:           getEnvDuration("LOGIN_MAX_DELAY", 30*time.Second),
+			LockoutDuration:    getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+			FailureWindow:      getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		},
+		Mail: MailConfig{
+			Driver:   getEnv("MAIL_DRIVER", "log"),
+			Host:     getEnv("SMTP_HOST", "localhost"),
+			Port:     getEnv("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     getEnv("MAIL_FROM", "no-reply@unipro.com"),
+			LogDir:   os.Getenv("MAIL_LOG_DIR"),
 		},
 		Upload: UploadConfig{
 			Path:        getEnv("UPLOAD_PATH", "./uploads"),
 			MaxFileSize: 10485760, // 10MB default
+
+			DocumentPath:    getEnv("DOCUMENT_PATH", "./storage/documents"),
+			MaxDocumentSize: int64(getEnvInt("DOCUMENT_MAX_SIZE_MB", 50)) << 20,
 		},
 		CORS: CORSConfig{
 			AllowedOrigins: getEnv("ALLOWED_ORIGINS", "http://localhost:3000"),
@@ -94,3 +191,43 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// getEnvInt reads an integer env value, falling back to the default when it
+// is missing or malformed
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvDuration reads a duration env value, falling back to the default
+// when it is missing or malformed
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// splitList parses a comma separated env value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// RequiresTwoFactor checks if the role is forced to use two-factor authentication
+func (a AuthConfig) RequiresTwoFactor(role string) bool {
+	for _, r := range a.TwoFactorRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/unipro/project-management/internal/models"
 	"github.com/unipro/project-management/pkg/database"
+	"github.com/unipro/project-management/pkg/listquery"
 )
 
 // CreateApproval creates a new approval request
@@ -26,7 +27,7 @@ func CreateApproval(c *gin.Context) {
 	}
 
 	// Get requester ID from context (set by auth middleware)
-	requesterID, _ := c.Get("userID")
+	requesterID, _ := c.Get("user_id")
 
 	approval := models.Approval{
 		Title:       input.Title,
@@ -39,32 +40,62 @@ func CreateApproval(c *gin.Context) {
 		ApproverID:  input.ApproverID,
 	}
 
-	if err := database.DB.Create(&approval).Error; err != nil {
+	if err := database.DB.WithContext(c).Create(&approval).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create approval"})
 		return
 	}
 
 	// Load relations
-	database.DB.Preload("Requester").Preload("Approver").Preload("Project").First(&approval, approval.ID)
+	database.DB.WithContext(c).Preload("Requester").Preload("Approver").Preload("Project").First(&approval, approval.ID)
 
 	// Create notification for approver
-	go createApprovalNotification(approval.ID, approval.ApproverID, approval.Title, approval.RequesterID)
+	go createApprovalNotification(approval.ID, approval.ApproverID, approval.Title, approval.RequesterID, approval.Amount)
 
 	c.JSON(http.StatusCreated, approval)
 }
 
-// GetApprovals returns all approvals (with filters)
+// approvalListSpec whitelists the filters and sort keys of the approval list
+var approvalListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"type":         {Column: "type"},
+		"status":       {Column: "status"},
+		"project_id":   {Column: "project_id", Type: listquery.Number},
+		"requester_id": {Column: "requester_id", Type: listquery.Number},
+		"approver_id":  {Column: "approver_id", Type: listquery.Number},
+		"amount":       {Column: "amount", Op: listquery.Range, Type: listquery.Number},
+		"created_at":   {Column: "created_at", Op: listquery.Range, Type: listquery.Date},
+	},
+	Search: []string{"title", "description"},
+	Sorts: map[string]string{
+		"title":      "title",
+		"type":       "type",
+		"status":     "status",
+		"amount":     "amount",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetApprovals returns a page of approvals (with filters)
 func GetApprovals(c *gin.Context) {
-	userID, _ := c.Get("userID")
+	userID, _ := c.Get("user_id")
 	filter := c.Query("filter") // "pending", "approved", "rejected", "my_requests", "my_approvals"
 
-	query := database.DB.Preload("Requester").Preload("Approver").Preload("Project")
+	query := database.DB.WithContext(c).Preload("Requester").Preload("Approver").Preload("DecidedBy").Preload("Project")
 
 	switch filter {
 	case "my_requests":
 		query = query.Where("requester_id = ?", userID)
 	case "my_approvals":
 		query = query.Where("approver_id = ?", userID)
+	case "delegated":
+		// Pending approvals the user can decide on behalf of someone else
+		var delegatorIDs []uint
+		activeDelegationsQuery(database.DB.WithContext(c)).
+			Where("delegate_id = ? AND scope IN ?", userID,
+				[]models.DelegationScope{models.DelegationScopeAll, models.DelegationScopeApprovals}).
+			Pluck("delegator_id", &delegatorIDs)
+		query = query.Where("approver_id IN ? AND status = ?", append(delegatorIDs, 0), models.ApprovalStatusPending)
 	case "pending":
 		query = query.Where("status = ?", models.ApprovalStatusPending)
 	case "approved":
@@ -74,12 +105,12 @@ func GetApprovals(c *gin.Context) {
 	}
 
 	var approvals []models.Approval
-	if err := query.Order("created_at DESC").Find(&approvals).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch approvals"})
+	page, ok := findList(c, query, approvalListSpec, &approvals, "Failed to fetch approvals")
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, approvals)
+	c.JSON(http.StatusOK, listResponse(approvals, page))
 }
 
 // GetApprovalByID returns a single approval by ID
@@ -87,7 +118,7 @@ func GetApprovalByID(c *gin.Context) {
 	id := c.Param("id")
 
 	var approval models.Approval
-	if err := database.DB.Preload("Requester").Preload("Approver").Preload("Project").First(&approval, id).Error; err != nil {
+	if err := database.DB.WithContext(c).Preload("Requester").Preload("Approver").Preload("DecidedBy").Preload("Project").First(&approval, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Approval not found"})
 		return
 	}
@@ -98,7 +129,7 @@ func GetApprovalByID(c *gin.Context) {
 // UpdateApprovalStatus updates approval status (approve/reject)
 func UpdateApprovalStatus(c *gin.Context) {
 	id := c.Param("id")
-	userID, _ := c.Get("userID")
+	userID, _ := c.Get("user_id")
 
 	var input struct {
 		Status        models.ApprovalStatus `json:"status" binding:"required"`
@@ -111,15 +142,19 @@ func UpdateApprovalStatus(c *gin.Context) {
 	}
 
 	var approval models.Approval
-	if err := database.DB.First(&approval, id).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&approval, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Approval not found"})
 		return
 	}
 
-	// Check if user is the approver
+	// Check if user is the approver or holds a delegation from them
+	var delegation *models.Delegation
 	if approval.ApproverID != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to approve this request"})
-		return
+		delegation = findDelegationFrom(database.DB.WithContext(c), approval.ApproverID, userID.(uint), models.DelegationScopeApprovals, approval.Amount)
+		if delegation == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to approve this request"})
+			return
+		}
 	}
 
 	// Check if already processed
@@ -132,14 +167,19 @@ func UpdateApprovalStatus(c *gin.Context) {
 	approval.Status = input.Status
 	approval.ApprovalNotes = input.ApprovalNotes
 	approval.ApprovedAt = &now
+	if delegation != nil {
+		decidedBy := userID.(uint)
+		approval.DecidedByID = &decidedBy
+		approval.DelegationID = &delegation.ID
+	}
 
-	if err := database.DB.Save(&approval).Error; err != nil {
+	if err := database.DB.WithContext(c).Save(&approval).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update approval"})
 		return
 	}
 
 	// Load relations
-	database.DB.Preload("Requester").Preload("Approver").Preload("Project").First(&approval, approval.ID)
+	database.DB.WithContext(c).Preload("Requester").Preload("Approver").Preload("DecidedBy").Preload("Project").First(&approval, approval.ID)
 
 	// Create notification for requester
 	var notifType models.NotificationType
@@ -168,22 +208,25 @@ func UpdateApprovalStatus(c *gin.Context) {
 }
 
 // createApprovalNotification creates a notification for a new approval request
-func createApprovalNotification(approvalID, approverID uint, title string, requesterID uint) {
+func createApprovalNotification(approvalID, approverID uint, title string, requesterID uint, amount *float64) {
 	// Get requester name
 	var requester models.User
 	if err := database.DB.First(&requester, requesterID).Error; err != nil {
 		return
 	}
 
-	notification := models.Notification{
-		UserID:    approverID,
-		Title:     "Permintaan Approval Baru: " + title,
-		Message:   "Dari: " + requester.Name + ". Menunggu persetujuan Anda.",
-		Type:      models.NotificationTypeApprovalRequest,
-		RelatedID: &approvalID,
-		IsRead:    false,
-	}
+	// Route to the approver's delegates while the approver is away
+	for _, recipientID := range notificationRecipients(database.DB, approverID, models.DelegationScopeApprovals, amount) {
+		notification := models.Notification{
+			UserID:    recipientID,
+			Title:     "Permintaan Approval Baru: " + title,
+			Message:   "Dari: " + requester.Name + ". Menunggu persetujuan Anda.",
+			Type:      models.NotificationTypeApprovalRequest,
+			RelatedID: &approvalID,
+			IsRead:    false,
+		}
 
-	database.DB.Create(&notification)
+		database.DB.Create(&notification)
+	}
 }
 
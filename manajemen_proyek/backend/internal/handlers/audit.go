@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/listquery"
+	"gorm.io/gorm"
+)
+
+type AuditHandler struct {
+	DB *gorm.DB
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(db *gorm.DB) *AuditHandler {
+	return &AuditHandler{DB: db}
+}
+
+// GetAuditLogs returns audit entries filtered by entity, action, actor,
+// project, request and date range
+func (h *AuditHandler) GetAuditLogs(c *gin.Context) {
+	h.respondWithLogs(c, h.DB.WithContext(c).Model(&models.AuditLog{}))
+}
+
+// GetEntityHistory returns the audit trail of a single row
+func (h *AuditHandler) GetEntityHistory(c *gin.Context) {
+	query := h.DB.WithContext(c).Model(&models.AuditLog{}).
+		Where("entity_type = ? AND entity_id = ?", c.Param("entity"), c.Param("entityId"))
+
+	h.respondWithLogs(c, query)
+}
+
+// GetProjectHistory returns the audit trail of a project and of every row
+// belonging to it, such as BOM items, material usage and reports. Entries
+// carry full before and after values of billing, cost and subcontract rows,
+// so the route also requires audit read permission.
+func (h *AuditHandler) GetProjectHistory(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	query := h.DB.WithContext(c).Model(&models.AuditLog{}).Where("project_id = ?", projectID)
+
+	h.respondWithLogs(c, query)
+}
+
+// GetMaterialHistory returns the audit trail of a material, including
+// stock adjustments
+func (h *AuditHandler) GetMaterialHistory(c *gin.Context) {
+	materialID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
+		return
+	}
+
+	query := h.DB.WithContext(c).Model(&models.AuditLog{}).
+		Where("entity_type = ? AND entity_id = ?", models.Material{}.TableName(), strconv.FormatUint(materialID, 10))
+
+	h.respondWithLogs(c, query)
+}
+
+// auditLogListSpec whitelists the filters and sort keys shared by every
+// audit endpoint
+var auditLogListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"entity_type": {Column: "entity_type"},
+		"entity_id":   {Column: "entity_id"},
+		"actor_id":    {Column: "actor_id", Type: listquery.Number},
+		"project_id":  {Column: "project_id", Type: listquery.Number},
+		"request_id":  {Column: "request_id"},
+		"action":      {Column: "action"},
+		"created_at":  {Column: "created_at", Op: listquery.Range, Type: listquery.Date},
+	},
+	Sorts: map[string]string{
+		"created_at":  "created_at",
+		"entity_type": "entity_type",
+		"action":      "action",
+	},
+	DefaultSort: "-created_at",
+}
+
+// respondWithLogs applies the filters shared by every audit endpoint, then
+// writes one page of results newest first. from and to are kept as aliases
+// of created_at_from and created_at_to.
+func (h *AuditHandler) respondWithLogs(c *gin.Context, query *gorm.DB) {
+	if from := c.Query("from"); from != "" {
+		if t, err := time.Parse("2006-01-02", from); err == nil {
+			query = query.Where("created_at >= ?", t)
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if t, err := time.Parse("2006-01-02", to); err == nil {
+			query = query.Where("created_at < ?", t.AddDate(0, 0, 1))
+		}
+	}
+
+	var logs []models.AuditLog
+	page, ok := findList(c, query.Preload("Actor"), auditLogListSpec, &logs, "Failed to fetch audit logs")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(logs, page))
+}
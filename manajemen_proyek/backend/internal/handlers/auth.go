@@ -1,48 +1,64 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/unipro/project-management/config"
 	"github.com/unipro/project-management/internal/models"
-	jwtPkg "github.com/unipro/project-management/pkg/jwt"
+	"github.com/unipro/project-management/pkg/loginguard"
+	"github.com/unipro/project-management/pkg/mail"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// errInvitationUsed is returned when an invitation was consumed concurrently
+var errInvitationUsed = errors.New("invitation already used")
+
 type AuthHandler struct {
 	DB     *gorm.DB
 	Config *config.Config
+	Mailer mail.Sender
+	Guard  *loginguard.Guard
 }
 
 // LoginRequest represents login request body
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name"` // Optional label shown in the sessions list
 }
 
-// RegisterRequest represents registration request body
+// RegisterRequest represents registration request body. The role is taken
+// from the invitation, never from the request.
 type RegisterRequest struct {
-	Name     string `json:"name" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
-	Phone    string `json:"phone"`
-	Position string `json:"position"`
-	RoleID   uint   `json:"role_id" binding:"required"`
+	InviteToken string `json:"invite_token"`
+	Name        string `json:"name" binding:"required"`
+	Email       string `json:"email" binding:"omitempty,email"`
+	Password    string `json:"password" binding:"required,min=6"`
+	Phone       string `json:"phone"`
+	Position    string `json:"position"`
 }
 
 // LoginResponse represents login response
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refresh_token"`
+	User         *models.User `json:"user"`
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(db *gorm.DB, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, cfg *config.Config, mailer mail.Sender, guard *loginguard.Guard) *AuthHandler {
 	return &AuthHandler{
 		DB:     db,
 		Config: cfg,
+		Mailer: mailer,
+		Guard:  guard,
 	}
 }
 
@@ -57,10 +73,24 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// Refuse locked or throttled accounts and IPs before touching the password
+	decision, err := h.Guard.Check(req.Username, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error",
+		})
+		return
+	}
+	if !decision.Allowed {
+		h.refuseLogin(c, req.Username, nil, decision)
+		return
+	}
+
 	// Find user by email (username is email)
 	var user models.User
-	if err := h.DB.Preload("Role").Where("email = ?", req.Username).First(&user).Error; err != nil {
+	if err := h.DB.WithContext(c).Preload("Role").Where("email = ?", req.Username).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			h.failLogin(c, req.Username, nil)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid credentials",
 			})
@@ -72,24 +102,62 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Check if user is active
-	if !user.IsActive {
+	// Compare password
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		h.failLogin(c, req.Username, &user.ID)
 		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Account is deactivated",
+			"error": "Invalid credentials",
 		})
 		return
 	}
 
-	// Compare password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	// Check if user is active (after the password check so account status
+	// is not revealed to callers without valid credentials)
+	if !user.IsActive {
+		recordLoginAttempt(h.DB.WithContext(c), c, req.Username, &user.ID, false, models.LoginReasonInactive)
 		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Invalid credentials",
+			"error": "Account is deactivated",
 		})
 		return
 	}
 
-	// Generate JWT token
-	token, err := jwtPkg.GenerateToken(&user, h.Config.JWT.Expiry)
+	// A second factor is answered through /auth/2fa before any token is
+	// issued. Failures are only reset once it is answered, so a known
+	// password does not buy fresh code guesses.
+	if purpose := needsSecondFactor(h.Config, &user); purpose != "" {
+		// Wrong codes count across challenges, so a new login brings no
+		// fresh guesses
+		exhausted, err := challengeAttemptsExhausted(h.DB.WithContext(c), user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database error",
+			})
+			return
+		}
+		if exhausted {
+			challengeError(c, errChallengeAttempts)
+			return
+		}
+
+		recordLoginAttempt(h.DB.WithContext(c), c, req.Username, &user.ID, false, models.LoginReasonTwoFactorPending)
+
+		plain, challenge, err := createLoginChallenge(h.DB.WithContext(c), h.Config, &user, purpose, req.DeviceName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to start two-factor authentication",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, challengeResponse(plain, challenge))
+		return
+	}
+
+	if err := h.Guard.Succeed(req.Username); err != nil {
+		log.Printf("Failed to reset login failures for user %d: %v", user.ID, err)
+	}
+
+	// Start a session and issue the token pair
+	pair, err := issueSession(h.DB.WithContext(c), h.Config, c, &user, req.DeviceName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to generate token",
@@ -97,6 +165,8 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	recordLoginAttempt(h.DB.WithContext(c), c, req.Username, &user.ID, true, models.LoginReasonSuccess)
+
 	// Remove password from response
 	user.Password = ""
 
@@ -104,14 +174,16 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		"success": true,
 		"message": "Login successful",
 		"data": gin.H{
-			"access_token":  token,
-			"refresh_token": token, // For now, using same token
+			"access_token":  pair.AccessToken,
+			"refresh_token": pair.RefreshToken,
+			"expires_at":    pair.ExpiresAt,
 			"user":          &user,
 		},
 	})
 }
 
-// Register handles user registration
+// Register handles user registration through an invitation token, or
+// through the open path when it is enabled in config
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -122,24 +194,61 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	var invitation *models.Invitation
+	var role models.Role
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	if req.InviteToken != "" {
+		inv, err := findUsableInvitation(h.DB.WithContext(c), req.InviteToken)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invitation is invalid or has expired",
+			})
+			return
+		}
+
+		if email != "" && email != inv.Email {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Email does not match the invitation",
+			})
+			return
+		}
+
+		invitation = inv
+		email = inv.Email
+		role = *inv.Role
+	} else {
+		if !h.Config.Auth.AllowOpenRegistration {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Registration requires an invitation",
+			})
+			return
+		}
+
+		if email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Email is required",
+			})
+			return
+		}
+
+		if err := h.DB.WithContext(c).Where("name = ?", h.Config.Auth.DefaultRole).First(&role).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Default registration role not found",
+			})
+			return
+		}
+	}
+
 	// Check if email already exists
 	var existingUser models.User
-	if err := h.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if err := h.DB.WithContext(c).Where("email = ?", email).First(&existingUser).Error; err == nil {
 		c.JSON(http.StatusConflict, gin.H{
 			"error": "Email already registered",
 		})
 		return
 	}
 
-	// Check if role exists
-	var role models.Role
-	if err := h.DB.First(&role, req.RoleID).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid role ID",
-		})
-		return
-	}
-
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -152,15 +261,46 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	// Create user
 	user := models.User{
 		Name:     req.Name,
-		Email:    req.Email,
+		Email:    email,
 		Password: string(hashedPassword),
-		RoleID:   req.RoleID,
+		RoleID:   role.ID,
 		Phone:    req.Phone,
 		Position: req.Position,
 		IsActive: true,
 	}
 
-	if err := h.DB.Create(&user).Error; err != nil {
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		if invitation == nil {
+			return nil
+		}
+
+		// Conditional update so a token can only be consumed once, even
+		// when two registrations race
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+			Updates(map[string]interface{}{
+				"accepted_at": time.Now(),
+				"user_id":     user.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvitationUsed
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errInvitationUsed) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invitation is invalid or has expired",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to create user",
 		})
@@ -168,10 +308,23 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// Load role for response
-	h.DB.Preload("Role").First(&user, user.ID)
+	h.DB.WithContext(c).Preload("Role").First(&user, user.ID)
+
+	// Roles that require 2FA must enrol before receiving tokens
+	if needsSecondFactor(h.Config, &user) == models.ChallengeEnroll {
+		plain, challenge, err := createLoginChallenge(h.DB.WithContext(c), h.Config, &user, models.ChallengeEnroll, "")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to start two-factor authentication",
+			})
+			return
+		}
+		c.JSON(http.StatusCreated, challengeResponse(plain, challenge))
+		return
+	}
 
-	// Generate JWT token
-	token, err := jwtPkg.GenerateToken(&user, h.Config.JWT.Expiry)
+	// Start a session and issue the token pair
+	pair, err := issueSession(h.DB.WithContext(c), h.Config, c, &user, "")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to generate token",
@@ -183,36 +336,9 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	user.Password = ""
 
 	c.JSON(http.StatusCreated, LoginResponse{
-		Token: token,
-		User:  &user,
-	})
-}
-
-// RefreshToken generates a new token
-func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	// Get token from header
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Authorization header required",
-		})
-		return
-	}
-
-	// Extract token
-	token := authHeader[7:] // Remove "Bearer " prefix
-
-	// Refresh token
-	newToken, err := jwtPkg.RefreshToken(token, h.Config.JWT.Expiry)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Invalid or expired token",
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"token": newToken,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		User:         &user,
 	})
 }
 
@@ -221,7 +347,7 @@ func (h *AuthHandler) Me(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	
 	var user models.User
-	if err := h.DB.Preload("Role").First(&user, userID).Error; err != nil {
+	if err := h.DB.WithContext(c).Preload("Role").First(&user, userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "User not found",
 		})
@@ -232,7 +358,56 @@ func (h *AuthHandler) Me(c *gin.Context) {
 	user.Password = ""
 
 	c.JSON(http.StatusOK, gin.H{
-		"user": user,
+		"user":        user,
+		"permissions": user.Role.GetPermissions().Resolve(),
 	})
 }
 
+// failLogin counts a wrong password against the account and IP and records
+// the attempt
+func (h *AuthHandler) failLogin(c *gin.Context, email string, userID *uint) {
+	h.failAttempt(c, email, userID, models.LoginReasonInvalidCredentials)
+}
+
+// failAttempt counts a wrong password or authentication code against the
+// account and IP and records the attempt
+func (h *AuthHandler) failAttempt(c *gin.Context, email string, userID *uint, reason string) {
+	if err := h.Guard.Fail(email, c.ClientIP()); err != nil {
+		log.Printf("Failed to record login failure for %s: %v", email, err)
+	}
+	recordLoginAttempt(h.DB.WithContext(c), c, email, userID, false, reason)
+}
+
+// refuseLogin answers an attempt from a locked or throttled account or IP
+func (h *AuthHandler) refuseLogin(c *gin.Context, email string, userID *uint, decision loginguard.Decision) {
+	reason := models.LoginReasonThrottled
+	message := "Too many failed login attempts, please wait before trying again"
+	if decision.Locked {
+		reason = models.LoginReasonLocked
+		message = "Too many failed login attempts, login is temporarily locked"
+	}
+	recordLoginAttempt(h.DB.WithContext(c), c, email, userID, false, reason)
+
+	retryAfter := int(decision.RetryAfter.Seconds()) + 1
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       message,
+		"retry_after": retryAfter,
+	})
+}
+
+// recordLoginAttempt writes a login_attempts row. Errors are logged only so
+// auditing never blocks a login.
+func recordLoginAttempt(db *gorm.DB, c *gin.Context, email string, userID *uint, success bool, reason string) {
+	attempt := models.LoginAttempt{
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		UserID:    userID,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Success:   success,
+		Reason:    reason,
+	}
+	if err := db.Create(&attempt).Error; err != nil {
+		log.Printf("Failed to record login attempt for %s: %v", attempt.Email, err)
+	}
+}
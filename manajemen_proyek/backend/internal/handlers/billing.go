@@ -0,0 +1,1005 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/listquery"
+	"github.com/unipro/project-management/pkg/pdf"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ContractRequest represents project contract request body
+type ContractRequest struct {
+	ContractNumber  string   `json:"contract_number"`
+	ContractValue   float64  `json:"contract_value" binding:"required,gt=0"`
+	CustomerName    string   `json:"customer_name"`
+	CustomerAddress string   `json:"customer_address"`
+	CustomerTaxID   string   `json:"customer_tax_id"`
+	DownPaymentPct  float64  `json:"down_payment_pct"`
+	RetentionPct    float64  `json:"retention_pct"`
+	TaxPct          *float64 `json:"tax_pct"` // Defaults to 11% PPN
+	PaymentTermDays *int     `json:"payment_term_days"`
+}
+
+// MilestoneRequest represents billing milestone request body. Set either
+// percentage or amount, and optionally a progress threshold or task that
+// triggers the milestone.
+type MilestoneRequest struct {
+	Name              string               `json:"name" binding:"required"`
+	SortOrder         *int                 `json:"sort_order"`
+	Type              models.MilestoneType `json:"type"`
+	Percentage        *float64             `json:"percentage"`
+	Amount            *float64             `json:"amount"`
+	ProgressThreshold *float64             `json:"progress_threshold"`
+	TaskID            *uint                `json:"task_id"`
+}
+
+// IssueInvoiceRequest represents invoice issue request body
+type IssueInvoiceRequest struct {
+	IssueDate string `json:"issue_date"` // Defaults to today
+	Notes     string `json:"notes"`
+}
+
+// PaymentRequest represents invoice payment request body
+type PaymentRequest struct {
+	Amount      float64 `json:"amount" binding:"required,gt=0"`
+	PaymentDate string  `json:"payment_date" binding:"required"`
+	Method      string  `json:"method"`
+	Reference   string  `json:"reference"`
+	Notes       string  `json:"notes"`
+}
+
+type BillingHandler struct {
+	DB           *gorm.DB
+	pdfGenerator *pdf.InvoicePDFGenerator
+}
+
+// NewBillingHandler creates a new billing handler
+func NewBillingHandler(db *gorm.DB) *BillingHandler {
+	return &BillingHandler{
+		DB:           db,
+		pdfGenerator: pdf.NewInvoicePDFGenerator(),
+	}
+}
+
+// ===== CONTRACT =====
+
+// GetProjectContract returns the billing terms of a project
+func (h *BillingHandler) GetProjectContract(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var contract models.ProjectContract
+	if err := h.DB.WithContext(c).Where("project_id = ?", projectID).First(&contract).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project has no contract yet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": contract})
+}
+
+// SaveProjectContract creates or updates the billing terms of a project.
+// Invoices already created keep the terms they were billed with.
+func (h *BillingHandler) SaveProjectContract(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req ContractRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	for _, pct := range []float64{req.DownPaymentPct, req.RetentionPct} {
+		if pct < 0 || pct > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Percentages must be between 0 and 100"})
+			return
+		}
+	}
+	if req.TaxPct != nil && (*req.TaxPct < 0 || *req.TaxPct > 100) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Percentages must be between 0 and 100"})
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	var contract models.ProjectContract
+	err := h.DB.WithContext(c).Where("project_id = ?", projectID).First(&contract).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch contract"})
+		return
+	}
+	created := err == gorm.ErrRecordNotFound
+	if created {
+		contract = models.ProjectContract{ProjectID: projectID, TaxPct: 11, PaymentTermDays: 30}
+	}
+
+	contract.ContractNumber = strings.TrimSpace(req.ContractNumber)
+	contract.ContractValue = req.ContractValue
+	contract.CustomerName = strings.TrimSpace(req.CustomerName)
+	if contract.CustomerName == "" {
+		contract.CustomerName = project.Customer
+	}
+	contract.CustomerAddress = req.CustomerAddress
+	contract.CustomerTaxID = strings.TrimSpace(req.CustomerTaxID)
+	contract.DownPaymentPct = req.DownPaymentPct
+	contract.RetentionPct = req.RetentionPct
+	if req.TaxPct != nil {
+		contract.TaxPct = *req.TaxPct
+	}
+	if req.PaymentTermDays != nil && *req.PaymentTermDays >= 0 {
+		contract.PaymentTermDays = *req.PaymentTermDays
+	}
+
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&contract).Error; err != nil {
+			return err
+		}
+		// Milestones reached before the contract existed are billed now
+		return billReachedMilestones(tx, projectID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save contract"})
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{
+		"message": "Contract saved successfully",
+		"data":    contract,
+	})
+}
+
+// ===== MILESTONES =====
+
+// GetBillingMilestones returns the billing milestones of a project with the
+// value each bills under the contract
+func (h *BillingHandler) GetBillingMilestones(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var milestones []models.BillingMilestone
+	if err := h.DB.WithContext(c).Preload("Task").
+		Where("project_id = ?", projectID).
+		Order("sort_order ASC, id ASC").
+		Find(&milestones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch billing milestones"})
+		return
+	}
+
+	var contract models.ProjectContract
+	h.DB.WithContext(c).Where("project_id = ?", projectID).First(&contract)
+
+	totalPct := 0.0
+	values := make(map[uint]float64, len(milestones))
+	for _, milestone := range milestones {
+		values[milestone.ID] = milestone.Value(&contract)
+		if milestone.Type == models.MilestoneProgress && milestone.Percentage != nil {
+			totalPct += *milestone.Percentage
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":                   milestones,
+		"values":                 values,
+		"progress_billing_total": totalPct,
+	})
+}
+
+// CreateBillingMilestone adds a billing milestone to a project. It is
+// invoiced straight away when its trigger has already been met.
+func (h *BillingHandler) CreateBillingMilestone(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req MilestoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	milestone := models.BillingMilestone{ProjectID: projectID, Status: models.MilestonePending}
+	if !applyMilestoneRequest(c, h.DB.WithContext(c), &milestone, req) {
+		return
+	}
+
+	if req.SortOrder == nil {
+		var maxOrder int
+		h.DB.WithContext(c).Model(&models.BillingMilestone{}).
+			Where("project_id = ?", projectID).
+			Select("COALESCE(MAX(sort_order), 0)").
+			Scan(&maxOrder)
+		milestone.SortOrder = maxOrder + 1
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&milestone).Error; err != nil {
+			return err
+		}
+		return billReachedMilestones(tx, projectID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create billing milestone"})
+		return
+	}
+
+	h.DB.WithContext(c).First(&milestone, milestone.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Billing milestone created successfully",
+		"data":    milestone,
+	})
+}
+
+// UpdateBillingMilestone updates a milestone that has not been invoiced yet
+func (h *BillingHandler) UpdateBillingMilestone(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	milestone, ok := findBillingMilestone(c, h.DB.WithContext(c), projectID)
+	if !ok {
+		return
+	}
+	if milestone.Status == models.MilestoneInvoiced {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invoiced milestones cannot be changed, cancel the invoice first"})
+		return
+	}
+
+	var req MilestoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if !applyMilestoneRequest(c, h.DB.WithContext(c), &milestone, req) {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&milestone).Error; err != nil {
+			return err
+		}
+		return billReachedMilestones(tx, projectID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update billing milestone"})
+		return
+	}
+
+	h.DB.WithContext(c).First(&milestone, milestone.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Billing milestone updated successfully",
+		"data":    milestone,
+	})
+}
+
+// DeleteBillingMilestone removes a milestone that has not been invoiced yet
+func (h *BillingHandler) DeleteBillingMilestone(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	milestone, ok := findBillingMilestone(c, h.DB.WithContext(c), projectID)
+	if !ok {
+		return
+	}
+	if milestone.Status == models.MilestoneInvoiced {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invoiced milestones cannot be deleted, cancel the invoice first"})
+		return
+	}
+
+	if err := h.DB.WithContext(c).Delete(&milestone).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete billing milestone"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Billing milestone deleted successfully"})
+}
+
+// InvoiceMilestone creates the draft invoice of a milestone by hand, e.g.
+// for milestones without a trigger
+func (h *BillingHandler) InvoiceMilestone(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	milestone, ok := findBillingMilestone(c, h.DB.WithContext(c), projectID)
+	if !ok {
+		return
+	}
+	if milestone.Status == models.MilestoneInvoiced {
+		c.JSON(http.StatusConflict, gin.H{"error": "Milestone has already been invoiced"})
+		return
+	}
+
+	var contract models.ProjectContract
+	if err := h.DB.WithContext(c).Where("project_id = ?", projectID).First(&contract).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set up the project contract before invoicing"})
+		return
+	}
+
+	var invoice models.Invoice
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		invoice, err = createMilestoneInvoice(tx, &contract, &milestone, middleware.GetUserID(c))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invoice"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Invoice created successfully",
+		"data":    invoice,
+	})
+}
+
+// ===== INVOICES =====
+
+// invoiceListSpec whitelists the filters and sort keys of the invoice list
+var invoiceListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"project_id":   {Column: "project_id", Type: listquery.Number},
+		"milestone_id": {Column: "milestone_id", Type: listquery.Number},
+		"status":       {Column: "status"},
+		"type":         {Column: "type"},
+		"issue_date":   {Column: "issue_date", Op: listquery.Range, Type: listquery.Date},
+		"due_date":     {Column: "due_date", Op: listquery.Range, Type: listquery.Date},
+		"total_amount": {Column: "total_amount", Op: listquery.Range, Type: listquery.Number},
+	},
+	Search: []string{"invoice_number", "description"},
+	Sorts: map[string]string{
+		"invoice_number": "invoice_number",
+		"status":         "status",
+		"issue_date":     "issue_date",
+		"due_date":       "due_date",
+		"total_amount":   "total_amount",
+		"created_at":     "created_at",
+	},
+	DefaultSort:  "-created_at",
+	DefaultLimit: 20,
+	MaxLimit:     100,
+}
+
+// GetInvoices returns a page of invoices of the projects the user can
+// access, with optional project, status and overdue filters
+func (h *BillingHandler) GetInvoices(c *gin.Context) {
+	query := h.DB.WithContext(c).Preload("Project").
+		Scopes(scopeAccessibleProjects(h.DB.WithContext(c), c, "invoices.project_id"))
+
+	if c.Query("overdue") == "true" {
+		query = query.Where("invoices.status IN ? AND invoices.due_date < ?",
+			[]models.InvoiceStatus{models.InvoiceIssued, models.InvoicePartiallyPaid},
+			truncateDay(time.Now()))
+	}
+
+	var invoices []models.Invoice
+	page, ok := findList(c, query, invoiceListSpec, &invoices, "Failed to fetch invoices")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(invoices, page))
+}
+
+// GetInvoiceByID returns an invoice with its payments
+func (h *BillingHandler) GetInvoiceByID(c *gin.Context) {
+	invoice, ok := h.findInvoice(c, accessRead)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        invoice,
+		"outstanding": invoice.Outstanding(),
+		"overdue":     invoice.IsOverdue(time.Now()),
+	})
+}
+
+// IssueInvoice issues a draft invoice to the customer. The due date follows
+// the contract's payment terms.
+func (h *BillingHandler) IssueInvoice(c *gin.Context) {
+	invoice, ok := h.findInvoice(c, accessWrite)
+	if !ok {
+		return
+	}
+	if invoice.Status != models.InvoiceDraft {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft invoices can be issued"})
+		return
+	}
+
+	var req IssueInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	issueDate := truncateDay(time.Now())
+	if req.IssueDate != "" {
+		parsed, err := time.Parse("2006-01-02", req.IssueDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid issue date format. Use YYYY-MM-DD"})
+			return
+		}
+		issueDate = parsed
+	}
+
+	var contract models.ProjectContract
+	if err := h.DB.WithContext(c).Where("project_id = ?", invoice.ProjectID).First(&contract).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project has no contract"})
+		return
+	}
+
+	dueDate := issueDate.AddDate(0, 0, contract.PaymentTermDays)
+	invoice.IssueDate = &issueDate
+	invoice.DueDate = &dueDate
+	invoice.Status = models.InvoiceIssued
+	if req.Notes != "" {
+		invoice.Notes = req.Notes
+	}
+	invoice.PDFPath = ""
+
+	if err := h.DB.WithContext(c).Model(&invoice).
+		Select("issue_date", "due_date", "status", "notes", "pdf_path").
+		Updates(&invoice).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue invoice"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Invoice issued successfully",
+		"data":    invoice,
+	})
+}
+
+// CancelInvoice cancels an invoice without payments. Its milestone can then
+// be changed and invoiced again.
+func (h *BillingHandler) CancelInvoice(c *gin.Context) {
+	invoice, ok := h.findInvoice(c, accessWrite)
+	if !ok {
+		return
+	}
+	if invoice.Status == models.InvoiceCancelled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invoice is already cancelled"})
+		return
+	}
+	if len(invoice.Payments) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invoices with payments cannot be cancelled, delete the payments first"})
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&invoice).Update("status", models.InvoiceCancelled).Error; err != nil {
+			return err
+		}
+		if invoice.MilestoneID == nil {
+			return nil
+		}
+		return tx.Model(&models.BillingMilestone{}).
+			Where("id = ?", *invoice.MilestoneID).
+			Updates(map[string]interface{}{"status": models.MilestonePending, "reached_at": nil}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel invoice"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invoice cancelled successfully"})
+}
+
+// DownloadInvoicePDF generates and downloads the PDF of an invoice
+func (h *BillingHandler) DownloadInvoicePDF(c *gin.Context) {
+	invoice, ok := h.findInvoice(c, accessRead)
+	if !ok {
+		return
+	}
+
+	filename := strings.ReplaceAll(invoice.InvoiceNumber, "/", "-") + ".pdf"
+
+	// Serve the existing PDF while the invoice is unchanged
+	if invoice.PDFPath != "" {
+		c.FileAttachment("."+invoice.PDFPath, filename)
+		return
+	}
+
+	var contract models.ProjectContract
+	if err := h.DB.WithContext(c).Where("project_id = ?", invoice.ProjectID).First(&contract).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project has no contract"})
+		return
+	}
+
+	pdfPath, err := h.pdfGenerator.GenerateInvoicePDF(&invoice, &contract)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to generate PDF",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.DB.WithContext(c).Model(&invoice).Update("pdf_path", pdfPath)
+
+	c.FileAttachment("."+pdfPath, filename)
+}
+
+// ===== PAYMENTS =====
+
+// CreatePayment records a payment received against an issued invoice
+func (h *BillingHandler) CreatePayment(c *gin.Context) {
+	invoice, ok := h.findInvoice(c, accessWrite)
+	if !ok {
+		return
+	}
+	if invoice.Status != models.InvoiceIssued && invoice.Status != models.InvoicePartiallyPaid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payments can only be recorded against issued, unpaid invoices"})
+		return
+	}
+
+	var req PaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	paymentDate, err := time.Parse("2006-01-02", req.PaymentDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment date format. Use YYYY-MM-DD"})
+		return
+	}
+	if req.Amount > invoice.Outstanding()+0.005 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":       "Payment exceeds the outstanding balance",
+			"outstanding": invoice.Outstanding(),
+		})
+		return
+	}
+
+	payment := models.InvoicePayment{
+		InvoiceID:   invoice.ID,
+		Amount:      math.Round(req.Amount*100) / 100,
+		PaymentDate: paymentDate,
+		Method:      req.Method,
+		Reference:   req.Reference,
+		Notes:       req.Notes,
+		ReceivedBy:  middleware.GetUserID(c),
+	}
+
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		number, err := generateDocumentNumber(tx, &models.InvoicePayment{}, "RCP")
+		if err != nil {
+			return err
+		}
+		payment.ReceiptNumber = number
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		return refreshInvoicePaid(tx, invoice.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+		return
+	}
+
+	h.DB.WithContext(c).First(&invoice, invoice.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Payment recorded successfully",
+		"data":        payment,
+		"invoice":     invoice,
+		"outstanding": invoice.Outstanding(),
+	})
+}
+
+// DeletePayment removes a payment recorded by mistake
+func (h *BillingHandler) DeletePayment(c *gin.Context) {
+	var payment models.InvoicePayment
+	if err := h.DB.WithContext(c).First(&payment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+
+	var invoice models.Invoice
+	if err := h.DB.WithContext(c).First(&invoice, payment.InvoiceID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, invoice.ProjectID, accessWrite) {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&payment).Error; err != nil {
+			return err
+		}
+		return refreshInvoicePaid(tx, invoice.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete payment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment deleted successfully"})
+}
+
+// GetBillingSummary returns a project's revenue position next to its cost:
+// billed, received and outstanding amounts, retention held and the down
+// payment still to recover
+func (h *BillingHandler) GetBillingSummary(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	var contract models.ProjectContract
+	hasContract := h.DB.WithContext(c).Where("project_id = ?", projectID).First(&contract).Error == nil
+
+	var invoices []models.Invoice
+	if err := h.DB.WithContext(c).
+		Where("project_id = ? AND status NOT IN ?", projectID, []models.InvoiceStatus{models.InvoiceDraft, models.InvoiceCancelled}).
+		Find(&invoices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoices"})
+		return
+	}
+
+	var gross, downPaymentBilled, downPaymentRecovered, retention, retentionReleased float64
+	var taxTotal, billed, received, outstanding, overdue float64
+	now := time.Now()
+	for _, invoice := range invoices {
+		switch invoice.Type {
+		case models.MilestoneDownPayment:
+			downPaymentBilled += invoice.GrossAmount
+		case models.MilestoneRetentionRelease:
+			retentionReleased += invoice.GrossAmount
+		default:
+			gross += invoice.GrossAmount
+		}
+		downPaymentRecovered += invoice.DownPaymentDeduction
+		retention += invoice.RetentionAmount
+		taxTotal += invoice.TaxAmount
+		billed += invoice.TotalAmount
+		received += invoice.PaidAmount
+		outstanding += invoice.Outstanding()
+		if invoice.IsOverdue(now) {
+			overdue += invoice.Outstanding()
+		}
+	}
+
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	summary := gin.H{
+		"progress_billed":        round(gross),
+		"down_payment_billed":    round(downPaymentBilled),
+		"down_payment_recovered": round(downPaymentRecovered),
+		"down_payment_remaining": round(downPaymentBilled - downPaymentRecovered),
+		"retention_held":         round(retention - retentionReleased),
+		"tax_billed":             round(taxTotal),
+		"total_billed":           round(billed),
+		"total_received":         round(received),
+		"outstanding":            round(outstanding),
+		"overdue":                round(overdue),
+		"actual_cost":            project.ActualCost,
+		"gross_margin":           round(gross - project.ActualCost),
+	}
+	if hasContract {
+		summary["contract_value"] = contract.ContractValue
+		summary["unbilled"] = round(contract.ContractValue - gross)
+		if contract.ContractValue > 0 {
+			summary["billed_pct"] = round(gross / contract.ContractValue * 100)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": summary})
+}
+
+// findInvoice loads the :id invoice with its project and payments and checks
+// project access
+func (h *BillingHandler) findInvoice(c *gin.Context, write bool) (models.Invoice, bool) {
+	var invoice models.Invoice
+	if err := h.DB.WithContext(c).Preload("Project").Preload("Milestone").
+		Preload("Payments", func(db *gorm.DB) *gorm.DB { return db.Order("payment_date ASC, id ASC") }).
+		First(&invoice, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return invoice, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoice"})
+		return invoice, false
+	}
+
+	if !requireProjectAccess(h.DB.WithContext(c), c, invoice.ProjectID, write) {
+		return invoice, false
+	}
+	return invoice, true
+}
+
+// findBillingMilestone loads the :milestoneId milestone of a project
+func findBillingMilestone(c *gin.Context, db *gorm.DB, projectID uint) (models.BillingMilestone, bool) {
+	var milestone models.BillingMilestone
+	milestoneID, err := strconv.ParseUint(c.Param("milestoneId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid milestone ID"})
+		return milestone, false
+	}
+	if err := db.Where("id = ? AND project_id = ?", milestoneID, projectID).First(&milestone).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Billing milestone not found"})
+		return milestone, false
+	}
+	return milestone, true
+}
+
+// applyMilestoneRequest validates a milestone request and copies it onto the
+// milestone
+func applyMilestoneRequest(c *gin.Context, db *gorm.DB, milestone *models.BillingMilestone, req MilestoneRequest) bool {
+	if req.Type == "" {
+		req.Type = models.MilestoneProgress
+	}
+	switch req.Type {
+	case models.MilestoneDownPayment, models.MilestoneProgress, models.MilestoneRetentionRelease:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid milestone type. Use down_payment, progress or retention_release"})
+		return false
+	}
+
+	if req.Percentage != nil && req.Amount != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set either a percentage or an amount, not both"})
+		return false
+	}
+	if req.Type == models.MilestoneProgress && req.Percentage == nil && req.Amount == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Progress milestones need a percentage or an amount"})
+		return false
+	}
+	if req.Percentage != nil && (*req.Percentage <= 0 || *req.Percentage > 100) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Percentage must be between 0 and 100"})
+		return false
+	}
+	if req.Amount != nil && *req.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be greater than zero"})
+		return false
+	}
+	if req.ProgressThreshold != nil && (*req.ProgressThreshold < 0 || *req.ProgressThreshold > 100) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Progress threshold must be between 0 and 100"})
+		return false
+	}
+	if req.TaskID != nil {
+		if _, err := findProjectTask(db, milestone.ProjectID, *req.TaskID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Task not found in this project"})
+			return false
+		}
+	}
+
+	milestone.Name = strings.TrimSpace(req.Name)
+	milestone.Type = req.Type
+	milestone.Percentage = req.Percentage
+	milestone.Amount = req.Amount
+	milestone.ProgressThreshold = req.ProgressThreshold
+	milestone.TaskID = req.TaskID
+	if req.SortOrder != nil {
+		milestone.SortOrder = *req.SortOrder
+	}
+	return true
+}
+
+// billReachedMilestones creates draft invoices for the pending milestones of
+// a project whose trigger is met and notifies the project manager. Without a
+// contract the milestones are only marked reached and are billed once the
+// contract is saved.
+func billReachedMilestones(tx *gorm.DB, projectID uint) error {
+	var milestones []models.BillingMilestone
+	if err := tx.Preload("Task").
+		Where("project_id = ? AND status <> ?", projectID, models.MilestoneInvoiced).
+		Where("progress_threshold IS NOT NULL OR task_id IS NOT NULL").
+		Order("sort_order ASC, id ASC").
+		Find(&milestones).Error; err != nil {
+		return err
+	}
+	if len(milestones) == 0 {
+		return nil
+	}
+
+	var project models.Project
+	if err := tx.Select("id", "name", "progress", "manager_id").First(&project, projectID).Error; err != nil {
+		return err
+	}
+
+	var contract models.ProjectContract
+	err := tx.Where("project_id = ?", projectID).First(&contract).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	hasContract := err == nil
+
+	for i := range milestones {
+		milestone := &milestones[i]
+		if !milestone.IsReached(project.Progress, milestone.Task) {
+			continue
+		}
+
+		if !hasContract {
+			if milestone.Status == models.MilestonePending {
+				if err := tx.Model(milestone).Updates(map[string]interface{}{
+					"status":     models.MilestoneReached,
+					"reached_at": time.Now(),
+				}).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		invoice, err := createMilestoneInvoice(tx, &contract, milestone, 0)
+		if err != nil {
+			return err
+		}
+
+		if project.ManagerID != 0 {
+			notification := models.Notification{
+				UserID:    project.ManagerID,
+				Title:     "Termin Tercapai: " + milestone.Name,
+				Message:   fmt.Sprintf("Proyek %s. Draft invoice %s siap diterbitkan.", project.Name, invoice.InvoiceNumber),
+				Type:      models.NotificationTypeProjectUpdate,
+				RelatedID: &invoice.ID,
+			}
+			if err := tx.Create(&notification).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createMilestoneInvoice creates the draft invoice of a milestone and marks
+// the milestone invoiced
+func createMilestoneInvoice(tx *gorm.DB, contract *models.ProjectContract, milestone *models.BillingMilestone, userID uint) (models.Invoice, error) {
+	retentionHeld, err := projectRetentionHeld(tx, milestone.ProjectID)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+
+	invoice := models.NewMilestoneInvoice(contract, milestone, retentionHeld)
+	if userID != 0 {
+		invoice.CreatedBy = &userID
+	}
+	invoice.InvoiceNumber, err = generateDocumentNumber(tx, &models.Invoice{}, "INV")
+	if err != nil {
+		return invoice, err
+	}
+	if err := tx.Create(&invoice).Error; err != nil {
+		return invoice, err
+	}
+
+	updates := map[string]interface{}{"status": models.MilestoneInvoiced}
+	if milestone.ReachedAt == nil {
+		updates["reached_at"] = time.Now()
+	}
+	err = tx.Model(milestone).Updates(updates).Error
+	return invoice, err
+}
+
+// projectRetentionHeld returns the retention withheld on a project's
+// invoices less what has been released
+func projectRetentionHeld(tx *gorm.DB, projectID uint) (float64, error) {
+	var totals struct {
+		Withheld float64
+		Released float64
+	}
+	err := tx.Model(&models.Invoice{}).
+		Where("project_id = ? AND status <> ?", projectID, models.InvoiceCancelled).
+		Select("COALESCE(SUM(retention_amount), 0) AS withheld, "+
+			"COALESCE(SUM(CASE WHEN type = ? THEN gross_amount ELSE 0 END), 0) AS released",
+			models.MilestoneRetentionRelease).
+		Scan(&totals).Error
+	return math.Max(totals.Withheld-totals.Released, 0), err
+}
+
+// refreshInvoicePaid sums an invoice's payments into its paid amount and
+// status. The invoice row is locked so concurrent payments add up.
+func refreshInvoicePaid(tx *gorm.DB, invoiceID uint) error {
+	var invoice models.Invoice
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&invoice, invoiceID).Error; err != nil {
+		return err
+	}
+
+	var paid float64
+	if err := tx.Model(&models.InvoicePayment{}).
+		Where("invoice_id = ?", invoiceID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&paid).Error; err != nil {
+		return err
+	}
+
+	invoice.ApplyPaid(paid)
+	return tx.Model(&invoice).Updates(map[string]interface{}{
+		"paid_amount": invoice.PaidAmount,
+		"status":      invoice.Status,
+		"pdf_path":    "",
+	}).Error
+}
+
+// generateDocumentNumber returns the next PREFIX-YYYY-XXXX number of this
+// year for the model's table
+func generateDocumentNumber(tx *gorm.DB, model interface{}, prefix string) (string, error) {
+	now := time.Now()
+	yearStart := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+
+	var count int64
+	if err := tx.Unscoped().Model(model).Where("created_at >= ?", yearStart).Count(&count).Error; err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%d-%04d", prefix, now.Year(), count+1), nil
+}
@@ -6,17 +6,27 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/budget"
 	"github.com/unipro/project-management/pkg/database"
+	"github.com/unipro/project-management/pkg/recyclebin"
+	"gorm.io/gorm"
 )
 
 // GetBOMByProject returns all BOM items for a specific project
 func GetBOMByProject(c *gin.Context) {
-	projectID := c.Param("projectId")
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+
+	if !requireProjectAccess(database.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
 
 	var boms []models.BOM
-	if err := database.DB.Preload("Material").Preload("Project").
+	if err := database.DB.WithContext(c).Preload("Material").Preload("Project").Preload("Phase").
 		Where("project_id = ?", projectID).
-		Order("phase ASC, created_at ASC").
+		Order("phase_id ASC, created_at ASC").
 		Find(&boms).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BOM"})
 		return
@@ -35,11 +45,15 @@ func GetBOMByID(c *gin.Context) {
 	id := c.Param("id")
 
 	var bom models.BOM
-	if err := database.DB.Preload("Material").Preload("Project").First(&bom, id).Error; err != nil {
+	if err := database.DB.WithContext(c).Preload("Material").Preload("Project").Preload("Phase").First(&bom, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "BOM item not found"})
 		return
 	}
 
+	if !requireProjectAccess(database.DB.WithContext(c), c, bom.ProjectID, accessRead) {
+		return
+	}
+
 	bom.UpdateRemainingQty()
 
 	c.JSON(http.StatusOK, gin.H{"data": bom})
@@ -51,7 +65,7 @@ func CreateBOM(c *gin.Context) {
 		ProjectID     uint    `json:"project_id" binding:"required"`
 		MaterialID    uint    `json:"material_id" binding:"required"`
 		PlannedQty    float64 `json:"planned_qty" binding:"required"`
-		Phase         string  `json:"phase"`
+		PhaseID       *uint   `json:"phase_id"`
 		Notes         string  `json:"notes"`
 	}
 
@@ -62,21 +76,33 @@ func CreateBOM(c *gin.Context) {
 
 	// Verify project exists
 	var project models.Project
-	if err := database.DB.First(&project, input.ProjectID).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&project, input.ProjectID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
 		return
 	}
 
+	if !requireProjectAccess(database.DB.WithContext(c), c, project.ID, accessWrite) {
+		return
+	}
+
 	// Verify material exists
 	var material models.Material
-	if err := database.DB.First(&material, input.MaterialID).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&material, input.MaterialID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
 		return
 	}
 
+	// Verify phase belongs to the project
+	if input.PhaseID != nil {
+		if _, err := findProjectPhase(database.DB.WithContext(c), project.ID, *input.PhaseID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Phase not found in this project"})
+			return
+		}
+	}
+
 	// Check if BOM item already exists for this project and material
 	var existing models.BOM
-	if err := database.DB.Where("project_id = ? AND material_id = ?", input.ProjectID, input.MaterialID).
+	if err := database.DB.WithContext(c).Where("project_id = ? AND material_id = ?", input.ProjectID, input.MaterialID).
 		First(&existing).Error; err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "BOM item for this material already exists in this project",
@@ -95,17 +121,24 @@ func CreateBOM(c *gin.Context) {
 		RemainingQty:  input.PlannedQty,
 		EstimatedCost: estimatedCost,
 		ActualCost:    0,
-		Phase:         input.Phase,
+		PhaseID:       input.PhaseID,
 		Notes:         input.Notes,
 	}
 
-	if err := database.DB.Create(&bom).Error; err != nil {
+	// The BOM line feeds the materials budget of the project
+	err := database.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&bom).Error; err != nil {
+			return err
+		}
+		return budget.SyncBOM(tx, &bom)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create BOM item"})
 		return
 	}
 
 	// Load relations
-	database.DB.Preload("Material").Preload("Project").First(&bom, bom.ID)
+	database.DB.WithContext(c).Preload("Material").Preload("Project").Preload("Phase").First(&bom, bom.ID)
 
 	c.JSON(http.StatusCreated, gin.H{"data": bom})
 }
@@ -115,14 +148,18 @@ func UpdateBOM(c *gin.Context) {
 	id := c.Param("id")
 
 	var bom models.BOM
-	if err := database.DB.First(&bom, id).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&bom, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "BOM item not found"})
 		return
 	}
 
+	if !requireProjectAccess(database.DB.WithContext(c), c, bom.ProjectID, accessWrite) {
+		return
+	}
+
 	var input struct {
 		PlannedQty float64 `json:"planned_qty"`
-		Phase      string  `json:"phase"`
+		PhaseID    *uint   `json:"phase_id"`
 		Notes      string  `json:"notes"`
 	}
 
@@ -131,6 +168,14 @@ func UpdateBOM(c *gin.Context) {
 		return
 	}
 
+	// Verify phase belongs to the project
+	if input.PhaseID != nil {
+		if _, err := findProjectPhase(database.DB.WithContext(c), bom.ProjectID, *input.PhaseID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Phase not found in this project"})
+			return
+		}
+	}
+
 	// Update fields
 	if input.PlannedQty > 0 {
 		bom.PlannedQty = input.PlannedQty
@@ -138,21 +183,27 @@ func UpdateBOM(c *gin.Context) {
 
 		// Recalculate estimated cost
 		var material models.Material
-		if err := database.DB.First(&material, bom.MaterialID).Error; err == nil {
+		if err := database.DB.WithContext(c).First(&material, bom.MaterialID).Error; err == nil {
 			bom.EstimatedCost = bom.PlannedQty * material.UnitPrice
 		}
 	}
 
-	bom.Phase = input.Phase
+	bom.PhaseID = input.PhaseID
 	bom.Notes = input.Notes
 
-	if err := database.DB.Save(&bom).Error; err != nil {
+	err := database.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&bom).Error; err != nil {
+			return err
+		}
+		return budget.SyncBOM(tx, &bom)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update BOM item"})
 		return
 	}
 
 	// Load relations
-	database.DB.Preload("Material").Preload("Project").First(&bom, bom.ID)
+	database.DB.WithContext(c).Preload("Material").Preload("Project").Preload("Phase").First(&bom, bom.ID)
 
 	c.JSON(http.StatusOK, gin.H{"data": bom})
 }
@@ -162,11 +213,15 @@ func DeleteBOM(c *gin.Context) {
 	id := c.Param("id")
 
 	var bom models.BOM
-	if err := database.DB.First(&bom, id).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&bom, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "BOM item not found"})
 		return
 	}
 
+	if !requireProjectAccess(database.DB.WithContext(c), c, bom.ProjectID, accessWrite) {
+		return
+	}
+
 	// Check if material has been used
 	if bom.UsedQty > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -175,8 +230,11 @@ func DeleteBOM(c *gin.Context) {
 		return
 	}
 
-	// Soft delete
-	if err := database.DB.Delete(&bom).Error; err != nil {
+	// Soft delete, together with its budget line
+	err := database.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		return recyclebin.Delete(tx, recyclebin.BOMs, bom.ID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete BOM item"})
 		return
 	}
@@ -186,10 +244,17 @@ func DeleteBOM(c *gin.Context) {
 
 // CalculateBOMUsage calculates and updates usage statistics for project BOM
 func CalculateBOMUsage(c *gin.Context) {
-	projectID := c.Param("projectId")
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+
+	if !requireProjectAccess(database.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
 
 	var boms []models.BOM
-	if err := database.DB.Preload("Material").Where("project_id = ?", projectID).Find(&boms).Error; err != nil {
+	if err := database.DB.WithContext(c).Preload("Material").Where("project_id = ?", projectID).Find(&boms).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BOM"})
 		return
 	}
@@ -216,7 +281,7 @@ func CalculateBOMUsage(c *gin.Context) {
 	for i := range boms {
 		// Get material usages for this BOM item
 		var usages []models.MaterialUsage
-		database.DB.Where("project_id = ? AND material_id = ?", projectID, boms[i].MaterialID).Find(&usages)
+		database.DB.WithContext(c).Where("project_id = ? AND material_id = ?", projectID, boms[i].MaterialID).Find(&usages)
 
 		// Calculate total used quantity
 		usedQty := 0.0
@@ -231,7 +296,7 @@ func CalculateBOMUsage(c *gin.Context) {
 		boms[i].ActualCost = actualCost
 		boms[i].UpdateRemainingQty()
 
-		database.DB.Save(&boms[i])
+		database.DB.WithContext(c).Save(&boms[i])
 
 		// Accumulate totals
 		totalEstimated += boms[i].EstimatedCost
@@ -263,7 +328,7 @@ func ImportBOMFromTemplate(c *gin.Context) {
 		Items     []struct {
 			MaterialID uint    `json:"material_id" binding:"required"`
 			PlannedQty float64 `json:"planned_qty" binding:"required"`
-			Phase      string  `json:"phase"`
+			PhaseID    *uint   `json:"phase_id"`
 			Notes      string  `json:"notes"`
 		} `json:"items" binding:"required,min=1"`
 	}
@@ -275,12 +340,16 @@ func ImportBOMFromTemplate(c *gin.Context) {
 
 	// Verify project exists
 	var project models.Project
-	if err := database.DB.First(&project, input.ProjectID).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&project, input.ProjectID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
 		return
 	}
 
-	tx := database.DB.Begin()
+	if !requireProjectAccess(database.DB.WithContext(c), c, project.ID, accessWrite) {
+		return
+	}
+
+	tx := database.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -298,6 +367,14 @@ func ImportBOMFromTemplate(c *gin.Context) {
 			continue
 		}
 
+		// Verify phase belongs to the project
+		if item.PhaseID != nil {
+			if _, err := findProjectPhase(tx, input.ProjectID, *item.PhaseID); err != nil {
+				errors = append(errors, fmt.Sprintf("Phase ID %d not found in this project", *item.PhaseID))
+				continue
+			}
+		}
+
 		// Check if already exists
 		var existing models.BOM
 		if err := tx.Where("project_id = ? AND material_id = ?", input.ProjectID, item.MaterialID).
@@ -316,7 +393,7 @@ func ImportBOMFromTemplate(c *gin.Context) {
 			RemainingQty:  item.PlannedQty,
 			EstimatedCost: estimatedCost,
 			ActualCost:    0,
-			Phase:         item.Phase,
+			PhaseID:       item.PhaseID,
 			Notes:         item.Notes,
 		}
 
@@ -324,6 +401,11 @@ func ImportBOMFromTemplate(c *gin.Context) {
 			errors = append(errors, fmt.Sprintf("Failed to create BOM for material ID %d", item.MaterialID))
 			continue
 		}
+		if err := budget.SyncBOM(tx, &bom); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project budget"})
+			return
+		}
 
 		createdBOMs = append(createdBOMs, bom)
 	}
@@ -344,7 +426,7 @@ func ImportBOMFromTemplate(c *gin.Context) {
 
 	// Load relations for created BOMs
 	for i := range createdBOMs {
-		database.DB.Preload("Material").Preload("Project").First(&createdBOMs[i], createdBOMs[i].ID)
+		database.DB.WithContext(c).Preload("Material").Preload("Project").Preload("Phase").First(&createdBOMs[i], createdBOMs[i].ID)
 	}
 
 	response := gin.H{
@@ -0,0 +1,497 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/budget"
+	"gorm.io/gorm"
+)
+
+// CostCodeRequest represents cost code request body. Children take the type
+// of their parent.
+type CostCodeRequest struct {
+	Code        string              `json:"code" binding:"required"`
+	Name        string              `json:"name" binding:"required"`
+	Type        models.CostCodeType `json:"type"`
+	ParentID    *uint               `json:"parent_id"`
+	SortOrder   int                 `json:"sort_order"`
+	Description string              `json:"description"`
+}
+
+// BudgetLineRequest represents budget line request body. Give a quantity and
+// unit price, or an amount for lump sums.
+type BudgetLineRequest struct {
+	CostCodeID  uint    `json:"cost_code_id" binding:"required"`
+	PhaseID     *uint   `json:"phase_id"`
+	Description string  `json:"description" binding:"required"`
+	Quantity    float64 `json:"quantity"`
+	Unit        string  `json:"unit"`
+	UnitPrice   float64 `json:"unit_price"`
+	Amount      float64 `json:"amount"`
+	Notes       string  `json:"notes"`
+}
+
+type BudgetHandler struct {
+	DB *gorm.DB
+}
+
+// NewBudgetHandler creates a new budget handler
+func NewBudgetHandler(db *gorm.DB) *BudgetHandler {
+	return &BudgetHandler{DB: db}
+}
+
+// ===== COST CODES =====
+
+// GetCostCodes returns the cost code hierarchy as a flat list ordered by
+// code
+func (h *BudgetHandler) GetCostCodes(c *gin.Context) {
+	query := h.DB.WithContext(c).Model(&models.CostCode{})
+	if costType := c.Query("type"); costType != "" {
+		query = query.Where("type = ?", costType)
+	}
+
+	var codes []models.CostCode
+	if err := query.Order("code ASC").Find(&codes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cost codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": codes})
+}
+
+// CreateCostCode adds a cost code to the hierarchy
+func (h *BudgetHandler) CreateCostCode(c *gin.Context) {
+	var req CostCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var code models.CostCode
+	if !h.applyCostCodeRequest(c, &code, req) {
+		return
+	}
+
+	if err := h.DB.WithContext(c).Create(&code).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to create cost code",
+			"message": "Cost code " + code.Code + " may already exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Cost code created successfully",
+		"data":    code,
+	})
+}
+
+// UpdateCostCode updates a cost code. Its type can only change on roots and
+// carries over to its descendants.
+func (h *BudgetHandler) UpdateCostCode(c *gin.Context) {
+	var code models.CostCode
+	if err := h.DB.WithContext(c).First(&code, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cost code not found"})
+		return
+	}
+
+	var req CostCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if !h.applyCostCodeRequest(c, &code, req) {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&code).Error; err != nil {
+			return err
+		}
+		ids, err := costCodeSubtree(tx, code.ID)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.CostCode{}).Where("id IN ?", ids).Update("type", code.Type).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cost code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Cost code updated successfully",
+		"data":    code,
+	})
+}
+
+// DeleteCostCode removes a cost code without children or budget lines.
+// Costs already attributed to it keep pointing to it.
+func (h *BudgetHandler) DeleteCostCode(c *gin.Context) {
+	var code models.CostCode
+	if err := h.DB.WithContext(c).First(&code, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cost code not found"})
+		return
+	}
+
+	var children, lines int64
+	h.DB.WithContext(c).Model(&models.CostCode{}).Where("parent_id = ?", code.ID).Count(&children)
+	h.DB.WithContext(c).Model(&models.BudgetLine{}).Where("cost_code_id = ?", code.ID).Count(&lines)
+	if children > 0 || lines > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cost codes with children or budget lines cannot be deleted"})
+		return
+	}
+
+	if code.ParentID == nil {
+		var roots int64
+		h.DB.WithContext(c).Model(&models.CostCode{}).Where("parent_id IS NULL AND type = ?", code.Type).Count(&roots)
+		if roots <= 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The last root cost code of a type cannot be deleted"})
+			return
+		}
+	}
+
+	if err := h.DB.WithContext(c).Delete(&code).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cost code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cost code deleted successfully"})
+}
+
+// ===== BUDGET LINES =====
+
+// GetProjectBudget returns the budget lines of a project with their total
+// next to the project budget
+func (h *BudgetHandler) GetProjectBudget(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	query := h.DB.WithContext(c).Where("budget_lines.project_id = ?", projectID)
+	if phaseID := c.Query("phase_id"); phaseID != "" {
+		query = query.Where("budget_lines.phase_id = ?", phaseID)
+	}
+	if costCodeID := c.Query("cost_code_id"); costCodeID != "" {
+		query = query.Where("budget_lines.cost_code_id = ?", costCodeID)
+	}
+
+	var lines []models.BudgetLine
+	if err := query.Joins("CostCode").Preload("Phase").
+		Order(`"CostCode".code ASC, budget_lines.id ASC`).
+		Find(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch budget lines"})
+		return
+	}
+
+	var total float64
+	for _, line := range lines {
+		total += line.Amount
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": lines,
+		"summary": gin.H{
+			"project_budget": project.EstimatedCost,
+			"lines_total":    total,
+			"unallocated":    project.EstimatedCost - total,
+		},
+	})
+}
+
+// CreateBudgetLine adds a line to a project budget
+func (h *BudgetHandler) CreateBudgetLine(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req BudgetLineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	line := models.BudgetLine{ProjectID: projectID}
+	if !h.applyBudgetLineRequest(c, &line, req) {
+		return
+	}
+
+	if err := h.DB.WithContext(c).Create(&line).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create budget line"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("CostCode").Preload("Phase").First(&line, line.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Budget line created successfully",
+		"data":    line,
+	})
+}
+
+// UpdateBudgetLine updates a budget line. Lines from the BOM only take a new
+// cost code, description and notes; their quantity follows the BOM.
+func (h *BudgetHandler) UpdateBudgetLine(c *gin.Context) {
+	line, ok := h.findBudgetLine(c)
+	if !ok {
+		return
+	}
+
+	var req BudgetLineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if line.IsFromBOM() {
+		req.PhaseID = line.PhaseID
+		req.Quantity = line.Quantity
+		req.Unit = line.Unit
+		req.UnitPrice = line.UnitPrice
+		req.Amount = line.Amount
+	}
+	if !h.applyBudgetLineRequest(c, &line, req) {
+		return
+	}
+
+	if err := h.DB.WithContext(c).Omit("CostCode", "Phase").Save(&line).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update budget line"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("CostCode").Preload("Phase").First(&line, line.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Budget line updated successfully",
+		"data":    line,
+	})
+}
+
+// DeleteBudgetLine removes a budget line. Lines from the BOM go with their
+// BOM line.
+func (h *BudgetHandler) DeleteBudgetLine(c *gin.Context) {
+	line, ok := h.findBudgetLine(c)
+	if !ok {
+		return
+	}
+	if line.IsFromBOM() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Budget lines from the BOM are removed by deleting the BOM item"})
+		return
+	}
+
+	if err := h.DB.WithContext(c).Delete(&line).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete budget line"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Budget line deleted successfully"})
+}
+
+// SyncBudgetFromBOM brings the materials budget lines of a project back in
+// line with its BOM
+func (h *BudgetHandler) SyncBudgetFromBOM(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		return budget.SyncProjectBOM(tx, projectID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to sync budget from BOM",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Budget synced from BOM successfully"})
+}
+
+// GetBudgetReport returns budget versus actual versus committed cost per
+// cost code, optionally for one phase
+func (h *BudgetHandler) GetBudgetReport(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	var phaseID *uint
+	if value := c.Query("phase_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phase ID"})
+			return
+		}
+		if _, err := findProjectPhase(h.DB.WithContext(c), projectID, uint(id)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Phase not found in this project"})
+			return
+		}
+		phase := uint(id)
+		phaseID = &phase
+	}
+
+	report, err := budget.BuildReport(h.DB.WithContext(c), &project, phaseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build budget report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+// findBudgetLine loads the :lineId budget line of the :id project and checks
+// write access
+func (h *BudgetHandler) findBudgetLine(c *gin.Context) (models.BudgetLine, bool) {
+	var line models.BudgetLine
+
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return line, false
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return line, false
+	}
+
+	lineID, err := strconv.ParseUint(c.Param("lineId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid budget line ID"})
+		return line, false
+	}
+	if err := h.DB.WithContext(c).Where("id = ? AND project_id = ?", lineID, projectID).First(&line).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Budget line not found"})
+		return line, false
+	}
+	return line, true
+}
+
+// applyCostCodeRequest validates a cost code request and copies it onto the
+// cost code
+func (h *BudgetHandler) applyCostCodeRequest(c *gin.Context, code *models.CostCode, req CostCodeRequest) bool {
+	code.Code = strings.TrimSpace(req.Code)
+	code.Name = req.Name
+	code.SortOrder = req.SortOrder
+	code.Description = req.Description
+
+	if req.ParentID == nil {
+		if !models.IsValidCostCodeType(req.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cost code type"})
+			return false
+		}
+		code.ParentID = nil
+		code.Type = req.Type
+		return true
+	}
+
+	var parent models.CostCode
+	if err := h.DB.WithContext(c).First(&parent, *req.ParentID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent cost code not found"})
+		return false
+	}
+	if code.ID != 0 {
+		ids, err := costCodeSubtree(h.DB.WithContext(c), code.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check cost code hierarchy"})
+			return false
+		}
+		for _, id := range ids {
+			if id == parent.ID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A cost code cannot be moved under itself"})
+				return false
+			}
+		}
+	}
+
+	code.ParentID = &parent.ID
+	code.Type = parent.Type
+	return true
+}
+
+// applyBudgetLineRequest validates a budget line request and copies it onto
+// the line
+func (h *BudgetHandler) applyBudgetLineRequest(c *gin.Context, line *models.BudgetLine, req BudgetLineRequest) bool {
+	if err := h.DB.WithContext(c).First(&models.CostCode{}, req.CostCodeID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cost code not found"})
+		return false
+	}
+	if req.PhaseID != nil {
+		if _, err := findProjectPhase(h.DB.WithContext(c), line.ProjectID, *req.PhaseID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Phase not found in this project"})
+			return false
+		}
+	}
+	if req.Quantity < 0 || req.UnitPrice < 0 || req.Amount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity, unit price and amount cannot be negative"})
+		return false
+	}
+
+	line.CostCodeID = req.CostCodeID
+	line.PhaseID = req.PhaseID
+	line.Description = req.Description
+	line.Quantity = req.Quantity
+	line.Unit = req.Unit
+	line.UnitPrice = req.UnitPrice
+	line.Amount = req.Amount
+	line.Notes = req.Notes
+	line.Calculate()
+	return true
+}
+
+// costCodeSubtree returns the IDs of a cost code and all its descendants
+func costCodeSubtree(db *gorm.DB, rootID uint) ([]uint, error) {
+	ids := []uint{rootID}
+	frontier := []uint{rootID}
+	for len(frontier) > 0 {
+		var children []uint
+		if err := db.Model(&models.CostCode{}).Where("parent_id IN ?", frontier).Pluck("id", &children).Error; err != nil {
+			return nil, err
+		}
+		ids = append(ids, children...)
+		frontier = children
+	}
+	return ids, nil
+}
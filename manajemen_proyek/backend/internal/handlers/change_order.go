@@ -0,0 +1,781 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/budget"
+	"github.com/unipro/project-management/pkg/listquery"
+	"github.com/unipro/project-management/pkg/pdf"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errChangeOrderBOM is returned when an approved change order cannot be
+// applied to the current BOM
+var errChangeOrderBOM = errors.New("change order does not fit the current BOM")
+
+// ChangeOrderRequest represents change order request body. Cost impact
+// defaults to the cost of the items.
+type ChangeOrderRequest struct {
+	Title              string                   `json:"title" binding:"required"`
+	Description        string                   `json:"description"`
+	Reason             string                   `json:"reason" binding:"required"`
+	CostImpact         *float64                 `json:"cost_impact"`
+	ScheduleImpactDays int                      `json:"schedule_impact_days"`
+	Items              []ChangeOrderItemRequest `json:"items"`
+}
+
+// ChangeOrderItemRequest represents a BOM change of a change order. Unit
+// price defaults to the material's current price.
+type ChangeOrderItemRequest struct {
+	MaterialID     uint     `json:"material_id" binding:"required"`
+	QuantityChange float64  `json:"quantity_change" binding:"required"`
+	UnitPrice      *float64 `json:"unit_price"`
+	Notes          string   `json:"notes"`
+}
+
+// ChangeOrderDecisionRequest represents change order approve or reject
+// request body
+type ChangeOrderDecisionRequest struct {
+	Stage   string `json:"stage" binding:"required"`
+	Comment string `json:"comment"`
+}
+
+type ChangeOrderHandler struct {
+	DB           *gorm.DB
+	pdfGenerator *pdf.ChangeOrderPDFGenerator
+}
+
+// NewChangeOrderHandler creates a new change order handler
+func NewChangeOrderHandler(db *gorm.DB) *ChangeOrderHandler {
+	return &ChangeOrderHandler{
+		DB:           db,
+		pdfGenerator: pdf.NewChangeOrderPDFGenerator(),
+	}
+}
+
+// changeOrderListSpec whitelists the filters and sort keys of the change
+// order lists
+var changeOrderListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"project_id":    {Column: "project_id", Type: listquery.Number},
+		"requester_id":  {Column: "requester_id", Type: listquery.Number},
+		"status":        {Column: "status"},
+		"current_stage": {Column: "current_stage"},
+		"cost_impact":   {Column: "cost_impact", Op: listquery.Range, Type: listquery.Number},
+		"created_at":    {Column: "created_at", Op: listquery.Range, Type: listquery.Date},
+	},
+	Search: []string{"co_number", "title"},
+	Sorts: map[string]string{
+		"co_number":            "co_number",
+		"title":                "title",
+		"status":               "status",
+		"cost_impact":          "cost_impact",
+		"schedule_impact_days": "schedule_impact_days",
+		"submitted_at":         "submitted_at",
+		"created_at":           "created_at",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetProjectChangeOrders returns a page of the change orders of a project
+// with optional filters, in change order number order by default
+func (h *ChangeOrderHandler) GetProjectChangeOrders(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	query := h.DB.WithContext(c).Preload("Requester").Preload("Items.Material").
+		Where("project_id = ?", projectID)
+
+	spec := changeOrderListSpec
+	spec.DefaultSort = "co_number"
+
+	var changeOrders []models.ChangeOrder
+	page, ok := findList(c, query, spec, &changeOrders, "Failed to fetch change orders")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(changeOrders, page))
+}
+
+// GetChangeOrders returns a page of change orders of the projects the user
+// can access. The pending_approval filter lists those waiting at the user's
+// stages.
+func (h *ChangeOrderHandler) GetChangeOrders(c *gin.Context) {
+	query := h.DB.WithContext(c).Preload("Project").Preload("Requester").
+		Scopes(scopeAccessibleProjects(h.DB.WithContext(c), c, "change_orders.project_id"))
+
+	switch filter := c.Query("filter"); filter {
+	case "my_requests":
+		query = query.Where("requester_id = ?", middleware.GetUserID(c))
+	case "pending_approval":
+		query = query.Where("status = ? AND current_stage IN ?", models.ChangeOrderPending, h.actionableStages(c))
+	case "":
+	default:
+		query = query.Where("status = ?", filter)
+	}
+
+	var changeOrders []models.ChangeOrder
+	page, ok := findList(c, query, changeOrderListSpec, &changeOrders, "Failed to fetch change orders")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(changeOrders, page))
+}
+
+// GetChangeOrderByID returns a change order with its items and approvals
+func (h *ChangeOrderHandler) GetChangeOrderByID(c *gin.Context) {
+	changeOrder, ok := h.findChangeOrder(c, accessRead)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": changeOrder})
+}
+
+// CreateChangeOrder creates a draft change order for a project
+func (h *ChangeOrderHandler) CreateChangeOrder(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req ChangeOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	items, ok := h.changeOrderItems(c, req.Items)
+	if !ok {
+		return
+	}
+
+	changeOrder := models.ChangeOrder{
+		ProjectID:   projectID,
+		Status:      models.ChangeOrderDraft,
+		RequesterID: middleware.GetUserID(c),
+	}
+	applyChangeOrderRequest(&changeOrder, req, items)
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		// Lock the project so concurrent change orders get distinct numbers
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Project{}, projectID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Unscoped().Model(&models.ChangeOrder{}).Where("project_id = ?", projectID).Count(&count).Error; err != nil {
+			return err
+		}
+		changeOrder.CONumber = fmt.Sprintf("CO-%03d", count+1)
+
+		return tx.Create(&changeOrder).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create change order"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Requester").Preload("Items.Material").First(&changeOrder, changeOrder.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Change order created successfully",
+		"data":    changeOrder,
+	})
+}
+
+// UpdateChangeOrder replaces the details and items of a draft change order
+func (h *ChangeOrderHandler) UpdateChangeOrder(c *gin.Context) {
+	changeOrder, ok := h.findChangeOrder(c, accessWrite)
+	if !ok {
+		return
+	}
+	if changeOrder.Status != models.ChangeOrderDraft {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft change orders can be changed"})
+		return
+	}
+
+	var req ChangeOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	items, ok := h.changeOrderItems(c, req.Items)
+	if !ok {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("change_order_id = ?", changeOrder.ID).Delete(&models.ChangeOrderItem{}).Error; err != nil {
+			return err
+		}
+
+		applyChangeOrderRequest(&changeOrder, req, items)
+		for i := range changeOrder.Items {
+			changeOrder.Items[i].ChangeOrderID = changeOrder.ID
+		}
+		if len(changeOrder.Items) > 0 {
+			if err := tx.Create(&changeOrder.Items).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&changeOrder).
+			Select("title", "description", "reason", "cost_impact", "schedule_impact_days").
+			Updates(&changeOrder).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update change order"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Requester").Preload("Items.Material").First(&changeOrder, changeOrder.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Change order updated successfully",
+		"data":    changeOrder,
+	})
+}
+
+// DeleteChangeOrder deletes a draft change order
+func (h *ChangeOrderHandler) DeleteChangeOrder(c *gin.Context) {
+	changeOrder, ok := h.findChangeOrder(c, accessWrite)
+	if !ok {
+		return
+	}
+	if changeOrder.Status != models.ChangeOrderDraft {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft change orders can be deleted"})
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("change_order_id = ?", changeOrder.ID).Delete(&models.ChangeOrderItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&changeOrder).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete change order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Change order deleted successfully"})
+}
+
+// SubmitChangeOrder sends a draft change order into approval, starting at
+// the first stage
+func (h *ChangeOrderHandler) SubmitChangeOrder(c *gin.Context) {
+	changeOrder, ok := h.findChangeOrder(c, accessWrite)
+	if !ok {
+		return
+	}
+	if changeOrder.Status != models.ChangeOrderDraft {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Change order already submitted"})
+		return
+	}
+
+	now := time.Now()
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		for _, stage := range models.ChangeOrderStages {
+			approval := models.ChangeOrderApproval{
+				ChangeOrderID: changeOrder.ID,
+				Stage:         stage,
+				Status:        models.StageStatusPending,
+			}
+			if err := tx.Create(&approval).Error; err != nil {
+				return err
+			}
+		}
+
+		changeOrder.Status = models.ChangeOrderPending
+		changeOrder.CurrentStage = models.ChangeOrderStages[0]
+		changeOrder.SubmittedAt = &now
+		return tx.Model(&changeOrder).
+			Select("status", "current_stage", "submitted_at").
+			Updates(&changeOrder).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit change order"})
+		return
+	}
+
+	h.notifyStageApprovers(c, &changeOrder)
+
+	changeOrder, _ = h.findChangeOrder(c, accessRead)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Change order submitted for approval",
+		"data":    changeOrder,
+	})
+}
+
+// ApproveChangeOrder approves a change order at its current stage. The final
+// approval applies it to the project budget, schedule and BOM.
+func (h *ChangeOrderHandler) ApproveChangeOrder(c *gin.Context) {
+	h.decideChangeOrder(c, models.StageStatusApproved)
+}
+
+// RejectChangeOrder rejects a change order at its current stage
+func (h *ChangeOrderHandler) RejectChangeOrder(c *gin.Context) {
+	h.decideChangeOrder(c, models.StageStatusRejected)
+}
+
+// decideChangeOrder records an approver's decision at the current stage
+func (h *ChangeOrderHandler) decideChangeOrder(c *gin.Context, decision models.ApprovalHistoryStatus) {
+	var req ChangeOrderDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if decision == models.StageStatusRejected && strings.TrimSpace(req.Comment) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to reject a change order"})
+		return
+	}
+
+	var changeOrder models.ChangeOrder
+	if err := h.DB.WithContext(c).Preload("Items").First(&changeOrder, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Change order not found"})
+		return
+	}
+
+	// Approvers act through their role at the stage, or through a delegation
+	// from someone who holds it
+	stage := models.ApprovalStage(req.Stage)
+	delegation, ok := h.authorizeStage(c, &changeOrder, stage)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to decide at this stage"})
+		return
+	}
+	if delegation == nil && !requireProjectAccess(h.DB.WithContext(c), c, changeOrder.ProjectID, accessRead) {
+		return
+	}
+
+	if changeOrder.Status != models.ChangeOrderPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Change order is not pending approval"})
+		return
+	}
+	if changeOrder.CurrentStage != stage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Change order not at this approval stage"})
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	now := time.Now()
+	nextStage := changeOrder.NextStage()
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"status":      decision,
+			"approver_id": userID,
+			"comment":     req.Comment,
+			"approved_at": now,
+		}
+		if delegation != nil {
+			updates["on_behalf_of_id"] = delegation.DelegatorID
+			updates["delegation_id"] = delegation.ID
+		}
+		if err := tx.Model(&models.ChangeOrderApproval{}).
+			Where("change_order_id = ? AND stage = ?", changeOrder.ID, stage).
+			Updates(updates).Error; err != nil {
+			return err
+		}
+
+		switch {
+		case decision == models.StageStatusRejected:
+			changeOrder.Status = models.ChangeOrderRejected
+		case nextStage != nil:
+			changeOrder.CurrentStage = *nextStage
+		default:
+			changeOrder.Status = models.ChangeOrderApproved
+			changeOrder.ApprovedAt = &now
+			if err := tx.Model(&changeOrder).Select("status", "approved_at").Updates(&changeOrder).Error; err != nil {
+				return err
+			}
+			return applyChangeOrder(tx, &changeOrder)
+		}
+
+		return tx.Model(&changeOrder).Select("status", "current_stage").Updates(&changeOrder).Error
+	})
+	if err != nil {
+		if errors.Is(err, errChangeOrderBOM) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Change order cannot be applied",
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update change order"})
+		return
+	}
+
+	switch changeOrder.Status {
+	case models.ChangeOrderPending:
+		h.notifyStageApprovers(c, &changeOrder)
+	default:
+		h.notifyRequester(c, &changeOrder)
+	}
+
+	changeOrder, _ = h.findChangeOrder(c, accessRead)
+	c.JSON(http.StatusOK, gin.H{"data": changeOrder})
+}
+
+// GetChangeOrderLog returns the change order log of a project: every change
+// order with its impact, and the budget and end date before and after the
+// approved ones
+func (h *ChangeOrderHandler) GetChangeOrderLog(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	log, err := loadChangeOrderLog(h.DB.WithContext(c), projectID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build change order log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": log})
+}
+
+// DownloadChangeOrderLogPDF generates and downloads the change order log of
+// a project
+func (h *ChangeOrderHandler) DownloadChangeOrderLogPDF(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	log, err := loadChangeOrderLog(h.DB.WithContext(c), projectID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build change order log"})
+		return
+	}
+
+	pdfPath, err := h.pdfGenerator.GenerateChangeOrderLogPDF(log)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to generate PDF",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.FileAttachment("."+pdfPath, fmt.Sprintf("change-orders-project-%d.pdf", projectID))
+}
+
+// loadChangeOrderLog builds the change order log of a project
+func loadChangeOrderLog(db *gorm.DB, projectID uint) (*models.ChangeOrderLog, error) {
+	var project models.Project
+	if err := db.First(&project, projectID).Error; err != nil {
+		return nil, err
+	}
+
+	var changeOrders []models.ChangeOrder
+	if err := db.Where("project_id = ? AND status <> ?", projectID, models.ChangeOrderDraft).
+		Preload("Requester").Preload("Items.Material").
+		Preload("Approvals", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Approvals.Approver").Preload("Approvals.OnBehalfOf").
+		Order("co_number ASC").
+		Find(&changeOrders).Error; err != nil {
+		return nil, err
+	}
+
+	return models.NewChangeOrderLog(&project, changeOrders), nil
+}
+
+// findChangeOrder loads the :id change order with its items and approvals
+// and checks project access
+func (h *ChangeOrderHandler) findChangeOrder(c *gin.Context, write bool) (models.ChangeOrder, bool) {
+	var changeOrder models.ChangeOrder
+	if err := h.DB.WithContext(c).Preload("Project").Preload("Requester").Preload("Items.Material").
+		Preload("Approvals", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Approvals.Approver").Preload("Approvals.OnBehalfOf").
+		First(&changeOrder, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Change order not found"})
+			return changeOrder, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch change order"})
+		return changeOrder, false
+	}
+
+	if !requireProjectAccess(h.DB.WithContext(c), c, changeOrder.ProjectID, write) {
+		return changeOrder, false
+	}
+	return changeOrder, true
+}
+
+// changeOrderItems builds the items of a change order request, pricing them
+// at the material's current price unless a unit price is given
+func (h *ChangeOrderHandler) changeOrderItems(c *gin.Context, requests []ChangeOrderItemRequest) ([]models.ChangeOrderItem, bool) {
+	items := make([]models.ChangeOrderItem, 0, len(requests))
+	for _, req := range requests {
+		var material models.Material
+		if err := h.DB.WithContext(c).First(&material, req.MaterialID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Material %d not found", req.MaterialID)})
+			return nil, false
+		}
+
+		unitPrice := material.UnitPrice
+		if req.UnitPrice != nil {
+			if *req.UnitPrice < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unit price cannot be negative"})
+				return nil, false
+			}
+			unitPrice = *req.UnitPrice
+		}
+
+		item := models.ChangeOrderItem{
+			MaterialID:     material.ID,
+			QuantityChange: req.QuantityChange,
+			UnitPrice:      unitPrice,
+			Notes:          req.Notes,
+		}
+		item.Calculate()
+		items = append(items, item)
+	}
+	return items, true
+}
+
+// applyChangeOrderRequest copies a request onto a change order
+func applyChangeOrderRequest(changeOrder *models.ChangeOrder, req ChangeOrderRequest, items []models.ChangeOrderItem) {
+	changeOrder.Title = req.Title
+	changeOrder.Description = req.Description
+	changeOrder.Reason = req.Reason
+	changeOrder.ScheduleImpactDays = req.ScheduleImpactDays
+	changeOrder.Items = items
+	changeOrder.CostImpact = changeOrder.ItemsCost()
+	if req.CostImpact != nil {
+		changeOrder.CostImpact = math.Round(*req.CostImpact*100) / 100
+	}
+}
+
+// applyChangeOrder applies an approved change order to its project: the BOM
+// quantities change, the budget is recomputed from the original budget and
+// the approved change orders, and the end date and deadline move by the
+// schedule impact
+func applyChangeOrder(tx *gorm.DB, changeOrder *models.ChangeOrder) error {
+	var project models.Project
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&project, changeOrder.ProjectID).Error; err != nil {
+		return err
+	}
+
+	for i := range changeOrder.Items {
+		if err := applyChangeOrderItem(tx, project.ID, &changeOrder.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	budgetBefore := project.EstimatedCost
+	budget, err := projectBudget(tx, &project)
+	if err != nil {
+		return err
+	}
+	project.EstimatedCost = budget
+
+	if changeOrder.ScheduleImpactDays != 0 {
+		project.EndDate = project.EndDate.AddDate(0, 0, changeOrder.ScheduleImpactDays)
+		if project.Deadline != nil {
+			deadline := project.Deadline.AddDate(0, 0, changeOrder.ScheduleImpactDays)
+			project.Deadline = &deadline
+		}
+	}
+	project.UpdateStatus(models.LoadHealthSettings(tx))
+
+	if err := tx.Model(&project).Updates(map[string]interface{}{
+		"estimated_cost": project.EstimatedCost,
+		"end_date":       project.EndDate,
+		"deadline":       project.Deadline,
+		"status":         project.Status,
+	}).Error; err != nil {
+		return err
+	}
+
+	changeOrder.BudgetBefore = &budgetBefore
+	changeOrder.BudgetAfter = &budget
+	return tx.Model(changeOrder).Select("budget_before", "budget_after").Updates(changeOrder).Error
+}
+
+// applyChangeOrderItem changes the planned quantity of the item's material
+// in the project BOM, adding a line for materials not in it yet
+func applyChangeOrderItem(tx *gorm.DB, projectID uint, item *models.ChangeOrderItem) error {
+	var material models.Material
+	if err := tx.First(&material, item.MaterialID).Error; err != nil {
+		return err
+	}
+
+	var bom models.BOM
+	err := tx.Where("project_id = ? AND material_id = ?", projectID, item.MaterialID).First(&bom).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		if item.QuantityChange <= 0 {
+			return fmt.Errorf("%w: %s is not in the BOM to reduce", errChangeOrderBOM, material.Name)
+		}
+		bom = models.BOM{
+			ProjectID:  projectID,
+			MaterialID: item.MaterialID,
+			Notes:      "Added by change order",
+		}
+	case err != nil:
+		return err
+	}
+
+	plannedBefore := bom.PlannedQty
+	bom.PlannedQty += item.QuantityChange
+	if bom.PlannedQty < bom.UsedQty {
+		return fmt.Errorf("%w: %s would be planned below the %.2f already used", errChangeOrderBOM, material.Name, bom.UsedQty)
+	}
+	bom.UpdateRemainingQty()
+	bom.EstimatedCost = bom.PlannedQty * material.UnitPrice
+
+	if err := tx.Save(&bom).Error; err != nil {
+		return err
+	}
+	if err := budget.SyncBOM(tx, &bom); err != nil {
+		return err
+	}
+
+	item.BOMID = &bom.ID
+	item.PlannedQtyBefore = &plannedBefore
+	return tx.Model(item).Select("bom_id", "planned_qty_before").Updates(item).Error
+}
+
+// projectBudget returns a project's current budget: its original budget plus
+// the cost impact of its approved change orders
+func projectBudget(tx *gorm.DB, project *models.Project) (float64, error) {
+	var approved float64
+	if err := tx.Model(&models.ChangeOrder{}).
+		Where("project_id = ? AND status = ?", project.ID, models.ChangeOrderApproved).
+		Select("COALESCE(SUM(cost_impact), 0)").
+		Scan(&approved).Error; err != nil {
+		return 0, err
+	}
+	return math.Round((project.OriginalBudget+approved)*100) / 100, nil
+}
+
+// hasApprovedChangeOrders checks if a project's budget has been revised by
+// change orders
+func hasApprovedChangeOrders(db *gorm.DB, projectID uint) bool {
+	var count int64
+	db.Model(&models.ChangeOrder{}).
+		Where("project_id = ? AND status = ?", projectID, models.ChangeOrderApproved).
+		Count(&count)
+	return count > 0
+}
+
+// authorizeStage checks whether the current user may act at a change order
+// stage, directly through the stage role or through a change order
+// delegation from someone who holds it
+func (h *ChangeOrderHandler) authorizeStage(c *gin.Context, changeOrder *models.ChangeOrder, stage models.ApprovalStage) (*models.Delegation, bool) {
+	userRole, _ := c.Get("role")
+	if role, _ := userRole.(string); role == stage.ApproverRole() || role == string(stage) {
+		return nil, true
+	}
+
+	delegation := findStageDelegation(h.DB.WithContext(c), middleware.GetUserID(c), stage,
+		models.DelegationScopeChangeOrders, math.Abs(changeOrder.CostImpact))
+	return delegation, delegation != nil
+}
+
+// actionableStages returns the change order stages the current user can act
+// at, through their role or through delegations
+func (h *ChangeOrderHandler) actionableStages(c *gin.Context) []models.ApprovalStage {
+	userRole, _ := c.Get("role")
+	roleName, _ := userRole.(string)
+
+	stages := delegatedStages(h.DB.WithContext(c), middleware.GetUserID(c), models.DelegationScopeChangeOrders)
+	stages = append(stages, models.ApprovalStage(roleName))
+	for stage, role := range models.StageRoles {
+		if role == roleName {
+			stages = append(stages, stage)
+		}
+	}
+	return stages
+}
+
+// notifyStageApprovers notifies the approvers of a change order's current
+// stage, or their delegates when they are away
+func (h *ChangeOrderHandler) notifyStageApprovers(c *gin.Context, changeOrder *models.ChangeOrder) {
+	var users []models.User
+	h.DB.WithContext(c).Joins("JOIN roles ON users.role_id = roles.id").
+		Where("roles.name = ? AND users.is_active = ?", changeOrder.CurrentStage.ApproverRole(), true).
+		Find(&users)
+
+	amount := math.Abs(changeOrder.CostImpact)
+	recipients := map[uint]bool{}
+	for _, user := range users {
+		for _, recipientID := range notificationRecipients(h.DB.WithContext(c), user.ID, models.DelegationScopeChangeOrders, &amount) {
+			recipients[recipientID] = true
+		}
+	}
+
+	for recipientID := range recipients {
+		notification := models.Notification{
+			UserID:    recipientID,
+			Title:     fmt.Sprintf("Change Order Baru: %s %s", changeOrder.CONumber, changeOrder.Title),
+			Message:   fmt.Sprintf("Dampak biaya %.2f, dampak waktu %d hari. Menunggu approval %s.", changeOrder.CostImpact, changeOrder.ScheduleImpactDays, changeOrder.CurrentStage),
+			Type:      models.NotificationTypeApprovalRequest,
+			RelatedID: &changeOrder.ID,
+		}
+		h.DB.WithContext(c).Create(&notification)
+	}
+}
+
+// notifyRequester tells the requester a change order was approved or
+// rejected
+func (h *ChangeOrderHandler) notifyRequester(c *gin.Context, changeOrder *models.ChangeOrder) {
+	notification := models.Notification{
+		UserID:    changeOrder.RequesterID,
+		Title:     "Status Change Order",
+		Message:   fmt.Sprintf("Change Order %s '%s' telah disetujui.", changeOrder.CONumber, changeOrder.Title),
+		Type:      models.NotificationTypeApprovalApproved,
+		RelatedID: &changeOrder.ID,
+	}
+	if changeOrder.Status == models.ChangeOrderRejected {
+		notification.Message = fmt.Sprintf("Change Order %s '%s' telah ditolak.", changeOrder.CONumber, changeOrder.Title)
+		notification.Type = models.NotificationTypeApprovalRejected
+	}
+	h.DB.WithContext(c).Create(&notification)
+}
@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/budget"
+	"github.com/unipro/project-management/pkg/costledger"
+	"github.com/unipro/project-management/pkg/listquery"
+	"gorm.io/gorm"
+)
+
+type CostHandler struct {
+	DB *gorm.DB
+}
+
+// CostEntryRequest represents manual cost entry request body
+type CostEntryRequest struct {
+	Category    string  `json:"category" binding:"required"`
+	CostCodeID  *uint   `json:"cost_code_id"` // Defaults to the cost type named by the category
+	PhaseID     *uint   `json:"phase_id"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount" binding:"required"`
+	EntryDate   string  `json:"entry_date"` // YYYY-MM-DD, defaults to today
+}
+
+// NewCostHandler creates a new cost handler
+func NewCostHandler(db *gorm.DB) *CostHandler {
+	return &CostHandler{DB: db}
+}
+
+// costEntryListSpec whitelists the filters and sort keys of a project's cost
+// ledger
+var costEntryListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"source_type":  {Column: "source_type"},
+		"category":     {Column: "category"},
+		"cost_code_id": {Column: "cost_code_id", Type: listquery.Number},
+		"phase_id":     {Column: "phase_id", Type: listquery.Number},
+		"entry_date":   {Column: "entry_date", Op: listquery.Range, Type: listquery.Date},
+		"amount":       {Column: "amount", Op: listquery.Range, Type: listquery.Number},
+	},
+	Search: []string{"description"},
+	Sorts: map[string]string{
+		"entry_date": "entry_date",
+		"amount":     "amount",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-entry_date",
+}
+
+// GetProjectCosts returns a page of a project's cost ledger with totals per
+// source. from and to are kept as aliases of entry_date_from and
+// entry_date_to.
+func (h *CostHandler) GetProjectCosts(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	query := h.DB.WithContext(c).Preload("Creator").Preload("CostCode").Where("project_id = ?", projectID)
+	if from := c.Query("from"); from != "" {
+		if t, err := time.Parse("2006-01-02", from); err == nil {
+			query = query.Where("entry_date >= ?", t)
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if t, err := time.Parse("2006-01-02", to); err == nil {
+			query = query.Where("entry_date < ?", t.AddDate(0, 0, 1))
+		}
+	}
+
+	var entries []models.CostEntry
+	page, ok := findList(c, query, costEntryListSpec, &entries, "Failed to fetch cost entries")
+	if !ok {
+		return
+	}
+
+	var bySource []struct {
+		SourceType models.CostSource `json:"source_type"`
+		Total      float64           `json:"total"`
+		Count      int64             `json:"count"`
+	}
+	h.DB.WithContext(c).Model(&models.CostEntry{}).
+		Select("source_type, COALESCE(SUM(amount), 0) AS total, COUNT(*) AS count").
+		Where("project_id = ?", projectID).
+		Group("source_type").
+		Scan(&bySource)
+
+	response := listResponse(entries, page)
+	response["summary"] = gin.H{
+		"estimated_cost": project.EstimatedCost,
+		"actual_cost":    project.ActualCost,
+		"variance":       project.CalculateVariance(),
+		"status":         project.Status,
+		"by_source":      bySource,
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateCostEntry records a manual cost such as labour or equipment rental
+func (h *CostHandler) CreateCostEntry(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req CostEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	entryDate, ok := parseEntryDate(c, req.EntryDate)
+	if !ok {
+		return
+	}
+	if !validateCostAttribution(c, h.DB.WithContext(c), projectID, req) {
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	entry := models.CostEntry{
+		ProjectID:   projectID,
+		SourceType:  models.CostSourceManual,
+		Category:    req.Category,
+		CostCodeID:  req.CostCodeID,
+		PhaseID:     req.PhaseID,
+		Description: req.Description,
+		Amount:      req.Amount,
+		EntryDate:   entryDate,
+		CreatedBy:   &userID,
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := budget.Attribute(tx, &entry); err != nil {
+			return err
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+		return costledger.Refresh(tx, projectID)
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create cost entry"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Cost entry created successfully",
+		"data":    entry,
+	})
+}
+
+// UpdateCostEntry updates a manual cost entry. Entries generated from other
+// records change with their source.
+func (h *CostHandler) UpdateCostEntry(c *gin.Context) {
+	entry, ok := h.findManualEntry(c)
+	if !ok {
+		return
+	}
+
+	var req CostEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	entryDate, ok := parseEntryDate(c, req.EntryDate)
+	if !ok {
+		return
+	}
+	if !validateCostAttribution(c, h.DB.WithContext(c), entry.ProjectID, req) {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		entry.Category = req.Category
+		entry.CostCodeID = req.CostCodeID
+		entry.PhaseID = req.PhaseID
+		if err := budget.Attribute(tx, &entry); err != nil {
+			return err
+		}
+		if err := tx.Model(&entry).Updates(map[string]interface{}{
+			"category":     req.Category,
+			"cost_code_id": entry.CostCodeID,
+			"phase_id":     entry.PhaseID,
+			"description":  req.Description,
+			"amount":       req.Amount,
+			"entry_date":   entryDate,
+		}).Error; err != nil {
+			return err
+		}
+		return costledger.Refresh(tx, entry.ProjectID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cost entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Cost entry updated successfully",
+		"data":    entry,
+	})
+}
+
+// DeleteCostEntry deletes a manual cost entry
+func (h *CostHandler) DeleteCostEntry(c *gin.Context) {
+	entry, ok := h.findManualEntry(c)
+	if !ok {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&entry).Error; err != nil {
+			return err
+		}
+		return costledger.Refresh(tx, entry.ProjectID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cost entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cost entry deleted successfully"})
+}
+
+// RecalculateProjectCost rebuilds a project's ledger from material usage and
+// approved purchase requests, keeping manual entries
+func (h *CostHandler) RecalculateProjectCost(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		return costledger.Rebuild(tx, projectID)
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to recalculate project cost",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var project models.Project
+	h.DB.WithContext(c).First(&project, projectID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Project cost recalculated successfully",
+		"data": gin.H{
+			"project_id":  project.ID,
+			"actual_cost": project.ActualCost,
+			"variance":    project.CalculateVariance(),
+			"status":      project.Status,
+		},
+	})
+}
+
+// RecalculateAllCosts rebuilds the ledger of every project the user can see
+func (h *CostHandler) RecalculateAllCosts(c *gin.Context) {
+	var projectIDs []uint
+	if err := h.DB.WithContext(c).Model(&models.Project{}).
+		Scopes(scopeAccessibleProjects(h.DB.WithContext(c), c, "id")).
+		Pluck("id", &projectIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
+		return
+	}
+
+	var failed []uint
+	for _, projectID := range projectIDs {
+		id := projectID
+		if err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+			return costledger.Rebuild(tx, id)
+		}); err != nil {
+			failed = append(failed, id)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Project costs recalculated",
+		"data": gin.H{
+			"projects": len(projectIDs),
+			"failed":   failed,
+		},
+	})
+}
+
+// findManualEntry loads the cost entry in the route and checks it can be
+// edited by the user
+func (h *CostHandler) findManualEntry(c *gin.Context) (models.CostEntry, bool) {
+	var entry models.CostEntry
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cost entry ID"})
+		return entry, false
+	}
+
+	if err := h.DB.WithContext(c).First(&entry, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cost entry not found"})
+		return entry, false
+	}
+
+	if !requireProjectAccess(h.DB.WithContext(c), c, entry.ProjectID, accessWrite) {
+		return entry, false
+	}
+
+	if entry.SourceType != models.CostSourceManual {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Only manual cost entries can be changed, edit the " + string(entry.SourceType) + " record instead",
+		})
+		return entry, false
+	}
+
+	return entry, true
+}
+
+// validateCostAttribution checks the cost code and phase of a cost entry
+// request exist, the phase in the entry's project
+func validateCostAttribution(c *gin.Context, db *gorm.DB, projectID uint, req CostEntryRequest) bool {
+	if req.CostCodeID != nil {
+		if err := db.First(&models.CostCode{}, *req.CostCodeID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cost code not found"})
+			return false
+		}
+	}
+	if req.PhaseID != nil {
+		if _, err := findProjectPhase(db, projectID, *req.PhaseID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Phase not found in this project"})
+			return false
+		}
+	}
+	return true
+}
+
+// parseEntryDate parses an optional YYYY-MM-DD date, defaulting to now
+func parseEntryDate(c *gin.Context, value string) (time.Time, bool) {
+	if value == "" {
+		return time.Now(), true
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry date format. Use YYYY-MM-DD"})
+		return t, false
+	}
+	return t, true
+}
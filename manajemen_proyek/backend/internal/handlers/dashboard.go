@@ -110,6 +110,9 @@ func (h *DashboardHandler) getManagerDashboard(c *gin.Context, user *models.User
 		ActiveProjects   int64   `json:"active_projects"`
 		TotalBudget      float64 `json:"total_budget"`
 		TotalSpent       float64 `json:"total_spent"`
+		OpenIssues       int64   `json:"open_issues"`
+		OpenRisks        int64   `json:"open_risks"`
+		OverdueIssues    int64   `json:"overdue_issues"` // Open issues and risks past their due date
 	}
 
 	// Get projects managed by this user
@@ -141,12 +144,37 @@ func (h *DashboardHandler) getManagerDashboard(c *gin.Context, user *models.User
 		Limit(5).
 		Find(&recentReports)
 
+	// Get the open risk and issue register of managed projects
+	managed := h.db.Model(&models.Project{}).Select("id").Where("manager_id = ?", user.ID)
+	openRisks := func() *gorm.DB {
+		return h.db.Model(&models.Risk{}).Where("project_id IN (?) AND status IN ?", managed, models.OpenRiskStatuses)
+	}
+	openRisks().Where("type = ?", models.RiskTypeIssue).Count(&stats.OpenIssues)
+	openRisks().Where("type = ?", models.RiskTypeRisk).Count(&stats.OpenRisks)
+	openRisks().Where("due_date < ?", time.Now().Truncate(24*time.Hour)).Count(&stats.OverdueIssues)
+
+	var openIssues []models.Risk
+	openRisks().Preload("Project").Preload("Owner").
+		Where("type = ?", models.RiskTypeIssue).
+		Order("score DESC, due_date ASC NULLS LAST").
+		Limit(10).
+		Find(&openIssues)
+
+	var topRisks []models.Risk
+	openRisks().Preload("Project").Preload("Owner").
+		Where("type = ?", models.RiskTypeRisk).
+		Order("score DESC, due_date ASC NULLS LAST").
+		Limit(5).
+		Find(&topRisks)
+
 	c.JSON(http.StatusOK, gin.H{
 		"role":              user.Role.Name,
 		"stats":             stats,
 		"pending_approvals": pendingApprovals,
 		"my_projects":       myProjects,
 		"recent_reports":    recentReports,
+		"open_issues":       openIssues,
+		"top_risks":         topRisks,
 	})
 }
 
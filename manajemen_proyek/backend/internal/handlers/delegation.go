@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/listquery"
+	"gorm.io/gorm"
+)
+
+type DelegationHandler struct {
+	DB *gorm.DB
+}
+
+// DelegationRequest represents delegation create request body. Dates are
+// inclusive and use the YYYY-MM-DD format.
+type DelegationRequest struct {
+	DelegatorID uint                   `json:"delegator_id"` // Admins only, defaults to the current user
+	DelegateID  uint                   `json:"delegate_id" binding:"required"`
+	StartDate   string                 `json:"start_date" binding:"required"`
+	EndDate     string                 `json:"end_date" binding:"required"`
+	Scope       models.DelegationScope `json:"scope"`
+	MaxAmount   *float64               `json:"max_amount"`
+	Reason      string                 `json:"reason"`
+}
+
+// NewDelegationHandler creates a new delegation handler
+func NewDelegationHandler(db *gorm.DB) *DelegationHandler {
+	return &DelegationHandler{DB: db}
+}
+
+// delegationListSpec whitelists the filters and sort keys of the delegation
+// list
+var delegationListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"delegator_id": {Column: "delegator_id", Type: listquery.Number},
+		"delegate_id":  {Column: "delegate_id", Type: listquery.Number},
+		"scope":        {Column: "scope"},
+		"starts_at":    {Column: "starts_at", Op: listquery.Range, Type: listquery.Date},
+		"ends_at":      {Column: "ends_at", Op: listquery.Range, Type: listquery.Date},
+	},
+	Sorts: map[string]string{
+		"starts_at":  "starts_at",
+		"ends_at":    "ends_at",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-starts_at",
+}
+
+// GetDelegations returns a page of delegations given or received by the current user.
+// User managers can pass all=true to see every delegation.
+func (h *DelegationHandler) GetDelegations(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	query := h.DB.WithContext(c).Preload("Delegator").Preload("Delegate")
+
+	switch c.Query("direction") {
+	case "given":
+		query = query.Where("delegator_id = ?", userID)
+	case "received":
+		query = query.Where("delegate_id = ?", userID)
+	default:
+		if c.Query("all") != "true" || !middleware.HasPermission(c, "users", "manage") {
+			query = query.Where("delegator_id = ? OR delegate_id = ?", userID, userID)
+		}
+	}
+
+	if c.Query("active") == "true" {
+		now := time.Now()
+		query = query.Where("revoked_at IS NULL AND starts_at <= ? AND ends_at > ?", now, now)
+	}
+
+	var delegations []models.Delegation
+	page, ok := findList(c, query, delegationListSpec, &delegations, "Failed to fetch delegations")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(delegations, page))
+}
+
+// CreateDelegation registers a delegation from the current user, or from
+// any user when the caller manages users
+func (h *DelegationHandler) CreateDelegation(c *gin.Context) {
+	var req DelegationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	delegatorID := userID
+	if req.DelegatorID != 0 && req.DelegatorID != userID {
+		if !middleware.HasPermission(c, "users", "manage") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only delegate your own approvals"})
+			return
+		}
+		delegatorID = req.DelegatorID
+	}
+
+	if req.DelegateID == delegatorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delegate must be a different user"})
+		return
+	}
+
+	scope := req.Scope
+	if scope == "" {
+		scope = models.DelegationScopeAll
+	}
+	if !models.IsValidDelegationScope(scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delegation scope"})
+		return
+	}
+
+	if req.MaxAmount != nil && *req.MaxAmount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Max amount must be greater than zero"})
+		return
+	}
+
+	startDate, err := time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date format. Use YYYY-MM-DD"})
+		return
+	}
+	endDate, err := time.ParseInLocation("2006-01-02", req.EndDate, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end date format. Use YYYY-MM-DD"})
+		return
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "End date must not be before start date"})
+		return
+	}
+
+	var delegator models.User
+	if err := h.DB.WithContext(c).First(&delegator, delegatorID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delegator not found"})
+		return
+	}
+
+	var delegate models.User
+	if err := h.DB.WithContext(c).First(&delegate, req.DelegateID).Error; err != nil || !delegate.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delegate not found or inactive"})
+		return
+	}
+
+	delegation := models.Delegation{
+		DelegatorID: delegatorID,
+		DelegateID:  req.DelegateID,
+		StartsAt:    startDate,
+		EndsAt:      endDate.AddDate(0, 0, 1),
+		Scope:       scope,
+		MaxAmount:   req.MaxAmount,
+		Reason:      req.Reason,
+		CreatedBy:   userID,
+	}
+
+	if err := h.DB.WithContext(c).Create(&delegation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create delegation"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Delegator").Preload("Delegate").First(&delegation, delegation.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Delegation created successfully",
+		"data":    delegation,
+	})
+}
+
+// RevokeDelegation ends a delegation early
+func (h *DelegationHandler) RevokeDelegation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delegation ID"})
+		return
+	}
+
+	var delegation models.Delegation
+	if err := h.DB.WithContext(c).First(&delegation, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delegation not found"})
+		return
+	}
+
+	if delegation.DelegatorID != middleware.GetUserID(c) && !middleware.HasPermission(c, "users", "manage") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only revoke your own delegations"})
+		return
+	}
+
+	if delegation.RevokedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delegation already revoked"})
+		return
+	}
+
+	now := time.Now()
+	if err := h.DB.WithContext(c).Model(&delegation).Update("revoked_at", now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke delegation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Delegation revoked successfully",
+		"data":    delegation,
+	})
+}
+
+// activeDelegationsQuery returns delegations in force now
+func activeDelegationsQuery(db *gorm.DB) *gorm.DB {
+	now := time.Now()
+	return db.Model(&models.Delegation{}).
+		Where("revoked_at IS NULL AND starts_at <= ? AND ends_at > ?", now, now)
+}
+
+// findDelegationFrom returns the active delegation letting delegateID act for
+// delegatorID on an item of the given scope and amount
+func findDelegationFrom(db *gorm.DB, delegatorID, delegateID uint, scope models.DelegationScope, amount *float64) *models.Delegation {
+	var delegations []models.Delegation
+	if err := activeDelegationsQuery(db).
+		Where("delegator_id = ? AND delegate_id = ?", delegatorID, delegateID).
+		Order("starts_at DESC").
+		Find(&delegations).Error; err != nil {
+		return nil
+	}
+
+	for i := range delegations {
+		if delegations[i].Covers(scope, amount) {
+			return &delegations[i]
+		}
+	}
+	return nil
+}
+
+// findStageDelegation returns the active delegation of the given scope letting
+// delegateID act at an approval stage for a user holding the stage's role
+func findStageDelegation(db *gorm.DB, delegateID uint, stage models.ApprovalStage, scope models.DelegationScope, amount float64) *models.Delegation {
+	var delegations []models.Delegation
+	if err := activeDelegationsQuery(db).
+		Joins("JOIN users ON users.id = delegations.delegator_id").
+		Joins("JOIN roles ON roles.id = users.role_id").
+		Where("delegations.delegate_id = ? AND roles.name = ? AND users.is_active = ?", delegateID, stage.ApproverRole(), true).
+		Order("delegations.starts_at DESC").
+		Find(&delegations).Error; err != nil {
+		return nil
+	}
+
+	for i := range delegations {
+		if delegations[i].Covers(scope, &amount) {
+			return &delegations[i]
+		}
+	}
+	return nil
+}
+
+// delegatedStages returns the approval stages a user can act at through
+// active delegations of the given scope
+func delegatedStages(db *gorm.DB, delegateID uint, scope models.DelegationScope) []models.ApprovalStage {
+	var roleNames []string
+	activeDelegationsQuery(db).
+		Joins("JOIN users ON users.id = delegations.delegator_id").
+		Joins("JOIN roles ON roles.id = users.role_id").
+		Where("delegations.delegate_id = ? AND delegations.scope IN ?", delegateID,
+			[]models.DelegationScope{models.DelegationScopeAll, scope}).
+		Distinct().
+		Pluck("roles.name", &roleNames)
+
+	var stages []models.ApprovalStage
+	for stage, role := range models.StageRoles {
+		for _, name := range roleNames {
+			if name == role {
+				stages = append(stages, stage)
+			}
+		}
+	}
+	return stages
+}
+
+// notificationRecipients returns who should be notified in place of a user:
+// their active delegates covering the item, or the user when there are none
+func notificationRecipients(db *gorm.DB, userID uint, scope models.DelegationScope, amount *float64) []uint {
+	var delegations []models.Delegation
+	if err := activeDelegationsQuery(db).Where("delegator_id = ?", userID).Find(&delegations).Error; err != nil {
+		return []uint{userID}
+	}
+
+	var recipients []uint
+	seen := map[uint]bool{}
+	for _, delegation := range delegations {
+		if delegation.Covers(scope, amount) && !seen[delegation.DelegateID] {
+			seen[delegation.DelegateID] = true
+			recipients = append(recipients, delegation.DelegateID)
+		}
+	}
+
+	if len(recipients) == 0 {
+		return []uint{userID}
+	}
+	return recipients
+}
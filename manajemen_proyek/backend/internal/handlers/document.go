@@ -0,0 +1,883 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/config"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/listquery"
+	"github.com/unipro/project-management/pkg/recyclebin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// documentExtensions are the file types accepted in the document repository
+var documentExtensions = map[string]bool{
+	".pdf": true, ".doc": true, ".docx": true, ".xls": true, ".xlsx": true,
+	".ppt": true, ".pptx": true, ".dwg": true, ".dxf": true, ".jpg": true,
+	".jpeg": true, ".png": true, ".zip": true, ".txt": true, ".csv": true,
+}
+
+// DocumentFolderRequest represents document folder request body
+type DocumentFolderRequest struct {
+	Name     string `json:"name" binding:"required"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+// DocumentRequest represents document metadata, sent as form fields with
+// the file on upload and as JSON on update
+type DocumentRequest struct {
+	Title          string                  `form:"title" json:"title" binding:"required"`
+	Category       models.DocumentCategory `form:"category" json:"category" binding:"required"`
+	FolderID       *uint                   `form:"folder_id" json:"folder_id"` // Empty or 0 for the project root
+	DocumentNumber string                  `form:"document_number" json:"document_number"`
+	Description    string                  `form:"description" json:"description"`
+	IssuedDate     string                  `form:"issued_date" json:"issued_date"` // YYYY-MM-DD
+	ExpiryDate     string                  `form:"expiry_date" json:"expiry_date"` // YYYY-MM-DD
+}
+
+// DocumentLinkRequest attaches a document to exactly one purchase request,
+// daily report or change order
+type DocumentLinkRequest struct {
+	PurchaseRequestID *uint  `json:"purchase_request_id"`
+	DailyReportID     *uint  `json:"daily_report_id"`
+	ChangeOrderID     *uint  `json:"change_order_id"`
+	VersionID         *uint  `json:"version_id"` // Pin a revision, empty to follow the current one
+	Note              string `json:"note"`
+}
+
+type DocumentHandler struct {
+	DB          *gorm.DB
+	storagePath string
+	maxSize     int64
+}
+
+// NewDocumentHandler creates a new document handler
+func NewDocumentHandler(db *gorm.DB, cfg config.UploadConfig) *DocumentHandler {
+	if err := os.MkdirAll(cfg.DocumentPath, 0750); err != nil {
+		fmt.Printf("Warning: Could not create document directory: %v\n", err)
+	}
+
+	return &DocumentHandler{
+		DB:          db,
+		storagePath: cfg.DocumentPath,
+		maxSize:     cfg.MaxDocumentSize,
+	}
+}
+
+// ===== FOLDERS =====
+
+// GetDocumentFolders returns the document folders of a project
+func (h *DocumentHandler) GetDocumentFolders(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var folders []models.DocumentFolder
+	if err := h.DB.WithContext(c).Where("project_id = ?", projectID).
+		Order("name ASC, id ASC").Find(&folders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch document folders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": folders})
+}
+
+// CreateDocumentFolder creates a document folder in a project
+func (h *DocumentHandler) CreateDocumentFolder(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req DocumentFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	folder := models.DocumentFolder{ProjectID: projectID, CreatedBy: middleware.GetUserID(c)}
+	if !h.applyFolderRequest(c, &folder, req) {
+		return
+	}
+
+	if err := h.DB.WithContext(c).Create(&folder).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create document folder"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Document folder created successfully",
+		"data":    folder,
+	})
+}
+
+// UpdateDocumentFolder renames or moves a document folder
+func (h *DocumentHandler) UpdateDocumentFolder(c *gin.Context) {
+	folder, ok := h.findFolder(c)
+	if !ok {
+		return
+	}
+
+	var req DocumentFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if !h.applyFolderRequest(c, &folder, req) {
+		return
+	}
+
+	if err := h.DB.WithContext(c).Save(&folder).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update document folder"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Document folder updated successfully",
+		"data":    folder,
+	})
+}
+
+// DeleteDocumentFolder removes an empty document folder. Documents of the
+// folder still in the recycle bin are restored to the project root.
+func (h *DocumentHandler) DeleteDocumentFolder(c *gin.Context) {
+	folder, ok := h.findFolder(c)
+	if !ok {
+		return
+	}
+
+	var subfolders, documents int64
+	h.DB.WithContext(c).Model(&models.DocumentFolder{}).Where("parent_id = ?", folder.ID).Count(&subfolders)
+	h.DB.WithContext(c).Model(&models.Document{}).Where("folder_id = ?", folder.ID).Count(&documents)
+	if subfolders > 0 || documents > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Folder is not empty"})
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Document{}).Where("folder_id = ?", folder.ID).
+			Update("folder_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&folder).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete document folder"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document folder deleted successfully"})
+}
+
+// ===== DOCUMENTS =====
+
+// documentListSpec whitelists the filters and sort keys of the document list
+var documentListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"folder_id":   {Column: "folder_id", Type: listquery.Number},
+		"category":    {Column: "category"},
+		"created_by":  {Column: "created_by", Type: listquery.Number},
+		"issued_date": {Column: "issued_date", Op: listquery.Range, Type: listquery.Date},
+		"expiry_date": {Column: "expiry_date", Op: listquery.Range, Type: listquery.Date},
+		"updated_at":  {Column: "updated_at", Op: listquery.Range, Type: listquery.Date},
+	},
+	Search: []string{"title", "document_number", "description", "file_name"},
+	Sorts: map[string]string{
+		"title":           "title",
+		"category":        "category",
+		"document_number": "document_number",
+		"issued_date":     "issued_date",
+		"expiry_date":     "expiry_date",
+		"file_size":       "file_size",
+		"created_at":      "created_at",
+		"updated_at":      "updated_at",
+	},
+	DefaultSort: "title",
+}
+
+// GetProjectDocuments returns a page of a project's documents. root=true
+// lists only documents outside any folder.
+func (h *DocumentHandler) GetProjectDocuments(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	query := h.DB.WithContext(c).Preload("Folder").Preload("Creator").Where("project_id = ?", projectID)
+	if c.Query("root") == "true" {
+		query = query.Where("folder_id IS NULL")
+	}
+
+	var documents []models.Document
+	page, ok := findList(c, query, documentListSpec, &documents, "Failed to fetch documents")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(documents, page))
+}
+
+// GetDocumentByID returns a document with its version history and links
+func (h *DocumentHandler) GetDocumentByID(c *gin.Context) {
+	document, ok := h.findDocument(c, accessRead)
+	if !ok {
+		return
+	}
+
+	h.DB.WithContext(c).
+		Preload("Versions", func(db *gorm.DB) *gorm.DB { return db.Order("version DESC") }).
+		Preload("Versions.Uploader").
+		Preload("Links", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Preload("Links.Creator").Preload("Links.Version").
+		First(&document, document.ID)
+
+	c.JSON(http.StatusOK, gin.H{"data": document})
+}
+
+// CreateDocument uploads a new document to a project. The multipart form
+// carries the file, its metadata and optional version notes.
+func (h *DocumentHandler) CreateDocument(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req DocumentRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	file, ok := h.uploadedFile(c)
+	if !ok {
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	document := models.Document{ProjectID: projectID, CreatedBy: userID}
+	if !h.applyDocumentRequest(c, &document, req) {
+		return
+	}
+
+	var storedPath string
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		document.CurrentVersion = 1
+		document.FileName = file.Filename
+		document.FileSize = file.Size
+		document.MimeType = uploadMimeType(file)
+		if err := tx.Create(&document).Error; err != nil {
+			return err
+		}
+
+		version, err := h.storeFile(file, &document, 1)
+		if err != nil {
+			return err
+		}
+		storedPath = version.StoragePath
+		version.Notes = c.PostForm("notes")
+		version.UploadedBy = userID
+		return tx.Create(&version).Error
+	})
+	if err != nil {
+		if storedPath != "" {
+			os.Remove(storedPath)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to upload document",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Folder").Preload("Creator").Preload("Versions").First(&document, document.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Document uploaded successfully",
+		"data":    document,
+	})
+}
+
+// UpdateDocument changes the metadata of a document or moves it to another
+// folder. Files are changed by uploading a new version.
+func (h *DocumentHandler) UpdateDocument(c *gin.Context) {
+	document, ok := h.findDocument(c, accessWrite)
+	if !ok {
+		return
+	}
+
+	var req DocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if !h.applyDocumentRequest(c, &document, req) {
+		return
+	}
+
+	if err := h.DB.WithContext(c).Omit(clause.Associations).Save(&document).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update document"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Folder").Preload("Creator").First(&document, document.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Document updated successfully",
+		"data":    document,
+	})
+}
+
+// DeleteDocument moves a document and its versions to the recycle bin
+func (h *DocumentHandler) DeleteDocument(c *gin.Context) {
+	document, ok := h.findDocument(c, accessWrite)
+	if !ok {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		return recyclebin.Delete(tx, recyclebin.Documents, document.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete document"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document deleted successfully"})
+}
+
+// ===== VERSIONS =====
+
+// UploadDocumentVersion uploads a new revision of a document, keeping the
+// earlier ones in its history
+func (h *DocumentHandler) UploadDocumentVersion(c *gin.Context) {
+	document, ok := h.findDocument(c, accessWrite)
+	if !ok {
+		return
+	}
+	file, ok := h.uploadedFile(c)
+	if !ok {
+		return
+	}
+
+	var version models.DocumentVersion
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		// Lock the document so concurrent uploads get distinct numbers
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&document, document.ID).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if version, err = h.storeFile(file, &document, document.CurrentVersion+1); err != nil {
+			return err
+		}
+		version.Notes = c.PostForm("notes")
+		version.UploadedBy = middleware.GetUserID(c)
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&document).Updates(map[string]interface{}{
+			"current_version": version.Version,
+			"file_name":       version.FileName,
+			"file_size":       version.FileSize,
+			"mime_type":       version.MimeType,
+		}).Error
+	})
+	if err != nil {
+		if version.StoragePath != "" {
+			os.Remove(version.StoragePath)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to upload document version",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Uploader").First(&version, version.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": fmt.Sprintf("Version %d uploaded successfully", version.Version),
+		"data":    version,
+	})
+}
+
+// GetDocumentVersions returns the version history of a document, newest
+// first
+func (h *DocumentHandler) GetDocumentVersions(c *gin.Context) {
+	document, ok := h.findDocument(c, accessRead)
+	if !ok {
+		return
+	}
+
+	var versions []models.DocumentVersion
+	if err := h.DB.WithContext(c).Preload("Uploader").Where("document_id = ?", document.ID).
+		Order("version DESC").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch document versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": versions})
+}
+
+// DownloadDocument sends the current version of a document
+func (h *DocumentHandler) DownloadDocument(c *gin.Context) {
+	document, ok := h.findDocument(c, accessRead)
+	if !ok {
+		return
+	}
+
+	var version models.DocumentVersion
+	if err := h.DB.WithContext(c).Where("document_id = ? AND version = ?", document.ID, document.CurrentVersion).
+		First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document version not found"})
+		return
+	}
+
+	h.sendVersion(c, version)
+}
+
+// DownloadDocumentVersion sends an earlier version of a document
+func (h *DocumentHandler) DownloadDocumentVersion(c *gin.Context) {
+	document, ok := h.findDocument(c, accessRead)
+	if !ok {
+		return
+	}
+
+	versionID, err := strconv.ParseUint(c.Param("versionId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version ID"})
+		return
+	}
+
+	var version models.DocumentVersion
+	if err := h.DB.WithContext(c).Where("id = ? AND document_id = ?", versionID, document.ID).
+		First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document version not found"})
+		return
+	}
+
+	h.sendVersion(c, version)
+}
+
+// ===== LINKS =====
+
+// CreateDocumentLink attaches a document to a purchase request, daily
+// report or change order of the same project
+func (h *DocumentHandler) CreateDocumentLink(c *gin.Context) {
+	document, ok := h.findDocument(c, accessWrite)
+	if !ok {
+		return
+	}
+
+	var req DocumentLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	column, targetID, model, ok := documentLinkTarget(req)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set exactly one of purchase_request_id, daily_report_id or change_order_id"})
+		return
+	}
+
+	var projectIDs []uint
+	h.DB.WithContext(c).Model(model).Where("id = ?", targetID).Pluck("project_id", &projectIDs)
+	if len(projectIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Attachment target not found"})
+		return
+	}
+	if projectIDs[0] != document.ProjectID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Documents can only be attached within their project"})
+		return
+	}
+
+	if req.VersionID != nil {
+		var count int64
+		h.DB.WithContext(c).Model(&models.DocumentVersion{}).
+			Where("id = ? AND document_id = ?", *req.VersionID, document.ID).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Version does not belong to the document"})
+			return
+		}
+	}
+
+	var existing int64
+	h.DB.WithContext(c).Model(&models.DocumentLink{}).
+		Where("document_id = ? AND "+column+" = ?", document.ID, targetID).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Document is already attached"})
+		return
+	}
+
+	link := models.DocumentLink{
+		DocumentID:        document.ID,
+		VersionID:         req.VersionID,
+		PurchaseRequestID: req.PurchaseRequestID,
+		DailyReportID:     req.DailyReportID,
+		ChangeOrderID:     req.ChangeOrderID,
+		Note:              req.Note,
+		CreatedBy:         middleware.GetUserID(c),
+	}
+	if err := h.DB.WithContext(c).Create(&link).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to attach document"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Version").Preload("Creator").First(&link, link.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Document attached successfully",
+		"data":    link,
+	})
+}
+
+// DeleteDocumentLink detaches a document
+func (h *DocumentHandler) DeleteDocumentLink(c *gin.Context) {
+	document, ok := h.findDocument(c, accessWrite)
+	if !ok {
+		return
+	}
+
+	linkID, err := strconv.ParseUint(c.Param("linkId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID"})
+		return
+	}
+
+	result := h.DB.WithContext(c).Where("id = ? AND document_id = ?", linkID, document.ID).Delete(&models.DocumentLink{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detach document"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document link not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document detached successfully"})
+}
+
+// GetPurchaseRequestDocuments returns the documents attached to a purchase
+// request
+func (h *DocumentHandler) GetPurchaseRequestDocuments(c *gin.Context) {
+	h.respondAttachedDocuments(c, &models.PurchaseRequest{}, "purchase_request_id")
+}
+
+// GetDailyReportDocuments returns the documents attached to a daily report
+func (h *DocumentHandler) GetDailyReportDocuments(c *gin.Context) {
+	h.respondAttachedDocuments(c, &models.DailyReport{}, "daily_report_id")
+}
+
+// GetChangeOrderDocuments returns the documents attached to a change order
+func (h *DocumentHandler) GetChangeOrderDocuments(c *gin.Context) {
+	h.respondAttachedDocuments(c, &models.ChangeOrder{}, "change_order_id")
+}
+
+// respondAttachedDocuments writes the links to live documents of the record
+// in the :id param, after checking read access to its project
+func (h *DocumentHandler) respondAttachedDocuments(c *gin.Context, model interface{}, column string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var projectIDs []uint
+	h.DB.WithContext(c).Model(model).Where("id = ?", id).Pluck("project_id", &projectIDs)
+	if len(projectIDs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectIDs[0], accessRead) {
+		return
+	}
+
+	var links []models.DocumentLink
+	if err := h.DB.WithContext(c).Preload("Document.Creator").Preload("Version").Preload("Creator").
+		Joins("JOIN documents ON documents.id = document_links.document_id AND documents.deleted_at IS NULL").
+		Where("document_links."+column+" = ?", id).
+		Order("document_links.created_at ASC").
+		Find(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attached documents"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": links})
+}
+
+// ===== HELPERS =====
+
+// findDocument loads the document in the :id param and checks access to
+// its project
+func (h *DocumentHandler) findDocument(c *gin.Context, write bool) (models.Document, bool) {
+	var document models.Document
+	if err := h.DB.WithContext(c).Preload("Folder").Preload("Creator").
+		First(&document, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return document, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch document"})
+		return document, false
+	}
+
+	if !requireProjectAccess(h.DB.WithContext(c), c, document.ProjectID, write) {
+		return document, false
+	}
+	return document, true
+}
+
+// findFolder loads the folder in the :folderId param of the project in the
+// :id param, checking write access to the project
+func (h *DocumentHandler) findFolder(c *gin.Context) (models.DocumentFolder, bool) {
+	var folder models.DocumentFolder
+
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return folder, false
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return folder, false
+	}
+
+	folderID, err := strconv.ParseUint(c.Param("folderId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return folder, false
+	}
+	if err := h.DB.WithContext(c).Where("id = ? AND project_id = ?", folderID, projectID).First(&folder).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document folder not found"})
+		return folder, false
+	}
+	return folder, true
+}
+
+// applyFolderRequest validates a folder request and copies it onto the
+// folder. A folder cannot be moved below itself.
+func (h *DocumentHandler) applyFolderRequest(c *gin.Context, folder *models.DocumentFolder, req DocumentFolderRequest) bool {
+	folder.Name = strings.TrimSpace(req.Name)
+	if folder.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Folder name is required"})
+		return false
+	}
+
+	folder.ParentID = nil
+	if req.ParentID == nil || *req.ParentID == 0 {
+		return true
+	}
+
+	// Walk up from the new parent to the root
+	for id := req.ParentID; id != nil; {
+		if folder.ID != 0 && *id == folder.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A folder cannot be moved into itself"})
+			return false
+		}
+		var parent models.DocumentFolder
+		if err := h.DB.WithContext(c).Where("id = ? AND project_id = ?", *id, folder.ProjectID).First(&parent).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent folder not found in this project"})
+			return false
+		}
+		id = parent.ParentID
+	}
+
+	folder.ParentID = req.ParentID
+	return true
+}
+
+// applyDocumentRequest validates document metadata and copies it onto the
+// document
+func (h *DocumentHandler) applyDocumentRequest(c *gin.Context, document *models.Document, req DocumentRequest) bool {
+	if !models.IsValidDocumentCategory(req.Category) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category"})
+		return false
+	}
+
+	var folderID *uint
+	if req.FolderID != nil && *req.FolderID != 0 {
+		var count int64
+		h.DB.WithContext(c).Model(&models.DocumentFolder{}).
+			Where("id = ? AND project_id = ?", *req.FolderID, document.ProjectID).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Folder not found in this project"})
+			return false
+		}
+		folderID = req.FolderID
+	}
+
+	issuedDate, err := parseOptionalDate(req.IssuedDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid issued date format, use YYYY-MM-DD"})
+		return false
+	}
+	expiryDate, err := parseOptionalDate(req.ExpiryDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry date format, use YYYY-MM-DD"})
+		return false
+	}
+	if issuedDate != nil && expiryDate != nil && expiryDate.Before(*issuedDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expiry date must not be before the issued date"})
+		return false
+	}
+
+	document.Title = strings.TrimSpace(req.Title)
+	document.Category = req.Category
+	document.FolderID = folderID
+	document.Folder = nil
+	document.DocumentNumber = strings.TrimSpace(req.DocumentNumber)
+	document.Description = req.Description
+	document.IssuedDate = issuedDate
+	document.ExpiryDate = expiryDate
+	return true
+}
+
+// uploadedFile returns the file in the "file" form field after checking its
+// type and size
+func (h *DocumentHandler) uploadedFile(c *gin.Context) (*multipart.FileHeader, bool) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+		return nil, false
+	}
+
+	if !documentExtensions[strings.ToLower(filepath.Ext(file.Filename))] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File type of %s is not allowed", file.Filename)})
+		return nil, false
+	}
+	if file.Size > h.maxSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("File %s exceeds %dMB limit", file.Filename, h.maxSize>>20),
+		})
+		return nil, false
+	}
+	return file, true
+}
+
+// storeFile saves an uploaded file as the given version of a document,
+// under a directory per project and document
+func (h *DocumentHandler) storeFile(file *multipart.FileHeader, document *models.Document, version int) (models.DocumentVersion, error) {
+	dir := filepath.Join(h.storagePath, strconv.FormatUint(uint64(document.ProjectID), 10), strconv.FormatUint(uint64(document.ID), 10))
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return models.DocumentVersion{}, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("v%d_%d%s", version, time.Now().UnixNano(), strings.ToLower(filepath.Ext(file.Filename))))
+
+	src, err := file.Open()
+	if err != nil {
+		return models.DocumentVersion{}, err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return models.DocumentVersion{}, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dst, hash), src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return models.DocumentVersion{}, err
+	}
+
+	return models.DocumentVersion{
+		DocumentID:  document.ID,
+		Version:     version,
+		FileName:    file.Filename,
+		StoragePath: path,
+		FileSize:    size,
+		MimeType:    uploadMimeType(file),
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// sendVersion writes a stored version as a download
+func (h *DocumentHandler) sendVersion(c *gin.Context, version models.DocumentVersion) {
+	if _, err := os.Stat(version.StoragePath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document file not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	c.FileAttachment(version.StoragePath, version.FileName)
+}
+
+// uploadMimeType returns the MIME type of an upload, from the extension when
+// the client did not send one
+func uploadMimeType(file *multipart.FileHeader) string {
+	if contentType := file.Header.Get("Content-Type"); contentType != "" && contentType != "application/octet-stream" {
+		return contentType
+	}
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(file.Filename))); byExt != "" {
+		return byExt
+	}
+	return "application/octet-stream"
+}
+
+// documentLinkTarget returns the column, ID and model of the single target
+// of a link request
+func documentLinkTarget(req DocumentLinkRequest) (string, uint, interface{}, bool) {
+	var column string
+	var id uint
+	var model interface{}
+	targets := 0
+
+	if req.PurchaseRequestID != nil {
+		column, id, model = "purchase_request_id", *req.PurchaseRequestID, &models.PurchaseRequest{}
+		targets++
+	}
+	if req.DailyReportID != nil {
+		column, id, model = "daily_report_id", *req.DailyReportID, &models.DailyReport{}
+		targets++
+	}
+	if req.ChangeOrderID != nil {
+		column, id, model = "change_order_id", *req.ChangeOrderID, &models.ChangeOrder{}
+		targets++
+	}
+	return column, id, model, targets == 1
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/evm"
+	"gorm.io/gorm"
+)
+
+type EVMHandler struct {
+	DB *gorm.DB
+}
+
+// NewEVMHandler creates a new EVM handler
+func NewEVMHandler(db *gorm.DB) *EVMHandler {
+	return &EVMHandler{DB: db}
+}
+
+// parseAsOf reads the as_of status date, defaulting to today
+func parseAsOf(c *gin.Context) (time.Time, bool) {
+	value := c.Query("as_of")
+	if value == "" {
+		return time.Now(), true
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid as_of date format. Use YYYY-MM-DD"})
+		return t, false
+	}
+	return t, true
+}
+
+// GetProjectEVM returns a project's earned value metrics at the as_of date
+func (h *EVMHandler) GetProjectEVM(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	asOf, ok := parseAsOf(c)
+	if !ok {
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	metrics, err := evm.Compute(h.DB.WithContext(c), &project, asOf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute EVM metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": metrics})
+}
+
+// GetProjectEVMTrend returns a project's stored weekly EVM snapshots
+func (h *EVMHandler) GetProjectEVMTrend(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var snapshots []models.EVMSnapshot
+	if err := h.DB.WithContext(c).Where("project_id = ?", projectID).
+		Order("week_start ASC").
+		Find(&snapshots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch EVM snapshots"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": snapshots})
+}
+
+// GetPortfolioEVM returns the metrics of every project the user can see
+// along with portfolio totals. Completed projects are left out unless
+// include_completed=true.
+func (h *EVMHandler) GetPortfolioEVM(c *gin.Context) {
+	asOf, ok := parseAsOf(c)
+	if !ok {
+		return
+	}
+
+	query := h.DB.WithContext(c).Scopes(scopeAccessibleProjects(h.DB.WithContext(c), c, "id"))
+	if c.Query("include_completed") != "true" {
+		query = query.Where("status <> ?", models.StatusCompleted)
+	}
+
+	var projects []models.Project
+	if err := query.Order("name ASC").Find(&projects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
+		return
+	}
+
+	metrics := make([]evm.Metrics, 0, len(projects))
+	for i := range projects {
+		m, err := evm.Compute(h.DB.WithContext(c), &projects[i], asOf)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute EVM metrics"})
+			return
+		}
+		metrics = append(metrics, m)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  metrics,
+		"total": evm.Portfolio(metrics, asOf),
+	})
+}
+
+// RecordEVMSnapshots stores this week's EVM snapshot of every active project,
+// replacing one already taken this week. The server does this daily on its
+// own; this runs it on demand, and as_of records a past week.
+func (h *EVMHandler) RecordEVMSnapshots(c *gin.Context) {
+	asOf, ok := parseAsOf(c)
+	if !ok {
+		return
+	}
+
+	recorded, err := evm.RecordSnapshots(h.DB.WithContext(c), asOf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to record EVM snapshots",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "EVM snapshots recorded",
+		"week_start": evm.WeekStart(asOf).Format("2006-01-02"),
+		"projects":   recorded,
+	})
+}
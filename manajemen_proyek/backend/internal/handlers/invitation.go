@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/config"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/listquery"
+	"github.com/unipro/project-management/pkg/token"
+	"gorm.io/gorm"
+)
+
+type InvitationHandler struct {
+	DB     *gorm.DB
+	Config *config.Config
+}
+
+// CreateInvitationRequest represents invitation creation request body
+type CreateInvitationRequest struct {
+	Email          string `json:"email" binding:"required,email"`
+	RoleID         uint   `json:"role_id" binding:"required"`
+	ExpiresInHours int    `json:"expires_in_hours"` // Optional, defaults to INVITE_EXPIRY
+}
+
+// NewInvitationHandler creates a new invitation handler
+func NewInvitationHandler(db *gorm.DB, cfg *config.Config) *InvitationHandler {
+	return &InvitationHandler{
+		DB:     db,
+		Config: cfg,
+	}
+}
+
+// invitationListSpec whitelists the filters and sort keys of the invitation
+// list. Status is derived, so it is filtered by the handler.
+var invitationListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"role_id":    {Column: "role_id", Type: listquery.Number},
+		"invited_by": {Column: "invited_by", Type: listquery.Number},
+		"created_at": {Column: "created_at", Op: listquery.Range, Type: listquery.Date},
+	},
+	Search: []string{"email"},
+	Sorts: map[string]string{
+		"email":      "email",
+		"expires_at": "expires_at",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetInvitations returns a page of invitations, optionally filtered by status
+func (h *InvitationHandler) GetInvitations(c *gin.Context) {
+	status := c.Query("status") // "pending", "accepted", "revoked", "expired"
+	now := time.Now()
+
+	query := h.DB.WithContext(c).Preload("Role").Preload("Inviter")
+
+	switch status {
+	case models.InvitationStatusPending:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", now)
+	case models.InvitationStatusAccepted:
+		query = query.Where("accepted_at IS NOT NULL")
+	case models.InvitationStatusRevoked:
+		query = query.Where("revoked_at IS NOT NULL")
+	case models.InvitationStatusExpired:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", now)
+	}
+
+	var invitations []models.Invitation
+	page, ok := findList(c, query, invitationListSpec, &invitations, "Failed to fetch invitations")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(invitations, page))
+}
+
+// CreateInvitation issues a single-use registration token for an email
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	// Check if email already belongs to a user
+	var existingUser models.User
+	if err := h.DB.WithContext(c).Where("email = ?", email).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		return
+	}
+
+	// Only one pending invitation per email
+	var pending int64
+	h.DB.WithContext(c).Model(&models.Invitation{}).
+		Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", email, time.Now()).
+		Count(&pending)
+	if pending > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A pending invitation already exists for this email"})
+		return
+	}
+
+	var role models.Role
+	if err := h.DB.WithContext(c).First(&role, req.RoleID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	expiry := h.Config.Auth.InviteExpiry
+	if req.ExpiresInHours > 0 {
+		expiry = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+
+	plainToken, tokenHash, err := token.Generate()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation token"})
+		return
+	}
+
+	invitation := models.Invitation{
+		Email:     email,
+		RoleID:    role.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(expiry),
+		InvitedBy: middleware.GetUserID(c),
+	}
+
+	if err := h.DB.WithContext(c).Create(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Role").Preload("Inviter").First(&invitation, invitation.ID)
+
+	// The plain token is only returned once; it cannot be recovered later
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Invitation created successfully",
+		"data":    invitation,
+		"token":   plainToken,
+	})
+}
+
+// RevokeInvitation invalidates a pending invitation
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	var invitation models.Invitation
+	if err := h.DB.WithContext(c).First(&invitation, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	if invitation.Status != models.InvitationStatusPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending invitations can be revoked"})
+		return
+	}
+
+	now := time.Now()
+	if err := h.DB.WithContext(c).Model(&invitation).Update("revoked_at", now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+	invitation.Status = models.InvitationStatusRevoked
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Invitation revoked successfully",
+		"data":    invitation,
+	})
+}
+
+// GetInvitationByToken lets the registration page show who the invite is
+// for before the user submits the form
+func (h *InvitationHandler) GetInvitationByToken(c *gin.Context) {
+	invitation, err := findUsableInvitation(h.DB.WithContext(c), c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation is invalid or has expired"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"email":      invitation.Email,
+			"role":       invitation.Role,
+			"expires_at": invitation.ExpiresAt,
+		},
+	})
+}
+
+// findUsableInvitation looks up a pending, unexpired invitation by its plain token
+func findUsableInvitation(db *gorm.DB, plainToken string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := db.Preload("Role").
+		Where("token_hash = ?", token.Hash(plainToken)).
+		First(&invitation).Error; err != nil {
+		return nil, err
+	}
+
+	if !invitation.IsUsable() || invitation.Role == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &invitation, nil
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/pkg/listquery"
+	"gorm.io/gorm"
+)
+
+// findList loads one page of a list endpoint into dest using the request's
+// pagination, filter and sort parameters. On failure it writes the error
+// response and returns false.
+func findList(c *gin.Context, query *gorm.DB, spec listquery.Spec, dest interface{}, message string) (listquery.Page, bool) {
+	page, err := listquery.Find(query, c.Request.URL.Query(), spec, dest)
+	if err != nil {
+		var invalid *listquery.Error
+		if errors.As(err, &invalid) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid list parameters",
+				"message": err.Error(),
+			})
+			return page, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   message,
+			"message": err.Error(),
+		})
+		return page, false
+	}
+	return page, true
+}
+
+// listResponse is the envelope shared by every list endpoint
+func listResponse(data interface{}, page listquery.Page) gin.H {
+	return gin.H{
+		"data":        data,
+		"total":       page.Total,
+		"limit":       page.Limit,
+		"offset":      page.Offset,
+		"page":        page.Page,
+		"has_more":    page.HasMore,
+		"next_cursor": page.NextCursor,
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/listquery"
+)
+
+// loginAttemptListSpec whitelists the filters and sort keys of the login
+// attempt list
+var loginAttemptListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"email":      {Column: "email"},
+		"user_id":    {Column: "user_id", Type: listquery.Number},
+		"ip":         {Column: "ip_address"},
+		"success":    {Column: "success", Type: listquery.Bool},
+		"reason":     {Column: "reason"},
+		"created_at": {Column: "created_at", Op: listquery.Range, Type: listquery.Date},
+	},
+	Search: []string{"email", "ip_address"},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"email":      "email",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetLoginAttempts returns a page of recorded login attempts filtered by
+// email, user, IP, outcome and date range. from and to are kept as aliases
+// of created_at_from and created_at_to.
+func (h *UserHandler) GetLoginAttempts(c *gin.Context) {
+	query := h.DB.WithContext(c).Model(&models.LoginAttempt{})
+
+	if from := c.Query("from"); from != "" {
+		if t, err := time.Parse("2006-01-02", from); err == nil {
+			query = query.Where("created_at >= ?", t)
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if t, err := time.Parse("2006-01-02", to); err == nil {
+			query = query.Where("created_at < ?", t.AddDate(0, 0, 1))
+		}
+	}
+
+	var attempts []models.LoginAttempt
+	page, ok := findList(c, query, loginAttemptListSpec, &attempts, "Failed to fetch login attempts")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(attempts, page))
+}
+
+// GetUserLockout returns the failed-login state of a user's account
+func (h *UserHandler) GetUserLockout(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	state, err := h.Guard.Status(user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lockout status"})
+		return
+	}
+
+	var lockedUntil *time.Time
+	if state.LockedUntil.After(time.Now()) {
+		lockedUntil = &state.LockedUntil
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"locked":       lockedUntil != nil,
+			"locked_until": lockedUntil,
+			"failures":     state.Failures,
+		},
+	})
+}
+
+// UnlockUser clears a user's failed logins and lockout
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Guard.Unlock(user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
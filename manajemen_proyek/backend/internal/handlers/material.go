@@ -6,31 +6,39 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/unipro/project-management/internal/models"
 	"github.com/unipro/project-management/pkg/database"
+	"github.com/unipro/project-management/pkg/listquery"
+	"github.com/unipro/project-management/pkg/recyclebin"
 )
 
-// GetAllMaterials returns all materials with optional filters
-func GetAllMaterials(c *gin.Context) {
-	category := c.Query("category")
-	search := c.Query("search")
-
-	query := database.DB.Model(&models.Material{})
-
-	// Apply filters
-	if category != "" {
-		query = query.Where("category = ?", category)
-	}
-
-	if search != "" {
-		query = query.Where("name LIKE ? OR code LIKE ?", "%"+search+"%", "%"+search+"%")
-	}
+// materialListSpec whitelists the filters and sort keys of the material list
+var materialListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"category":   {Column: "category"},
+		"supplier":   {Column: "supplier"},
+		"unit_price": {Column: "unit_price", Op: listquery.Range, Type: listquery.Number},
+		"stock":      {Column: "stock", Op: listquery.Range, Type: listquery.Number},
+	},
+	Search: []string{"name", "code"},
+	Sorts: map[string]string{
+		"name":       "name",
+		"code":       "code",
+		"category":   "category",
+		"unit_price": "unit_price",
+		"stock":      "stock",
+		"created_at": "created_at",
+	},
+	DefaultSort: "name",
+}
 
+// GetAllMaterials returns a page of materials with optional filters
+func GetAllMaterials(c *gin.Context) {
 	var materials []models.Material
-	if err := query.Order("name ASC").Find(&materials).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch materials"})
+	page, ok := findList(c, database.DB.WithContext(c), materialListSpec, &materials, "Failed to fetch materials")
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": materials})
+	c.JSON(http.StatusOK, listResponse(materials, page))
 }
 
 // GetMaterialByID returns a single material by ID
@@ -38,7 +46,7 @@ func GetMaterialByID(c *gin.Context) {
 	id := c.Param("id")
 
 	var material models.Material
-	if err := database.DB.First(&material, id).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&material, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
 		return
 	}
@@ -67,7 +75,7 @@ func CreateMaterial(c *gin.Context) {
 
 	// Check if code already exists
 	var existing models.Material
-	if err := database.DB.Where("code = ?", input.Code).First(&existing).Error; err == nil {
+	if err := database.DB.WithContext(c).Where("code = ?", input.Code).First(&existing).Error; err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Material code already exists"})
 		return
 	}
@@ -84,7 +92,7 @@ func CreateMaterial(c *gin.Context) {
 		Description: input.Description,
 	}
 
-	if err := database.DB.Create(&material).Error; err != nil {
+	if err := database.DB.WithContext(c).Create(&material).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create material"})
 		return
 	}
@@ -97,7 +105,7 @@ func UpdateMaterial(c *gin.Context) {
 	id := c.Param("id")
 
 	var material models.Material
-	if err := database.DB.First(&material, id).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&material, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
 		return
 	}
@@ -122,7 +130,7 @@ func UpdateMaterial(c *gin.Context) {
 	// Check if new code conflicts with existing material
 	if input.Code != "" && input.Code != material.Code {
 		var existing models.Material
-		if err := database.DB.Where("code = ? AND id != ?", input.Code, id).First(&existing).Error; err == nil {
+		if err := database.DB.WithContext(c).Where("code = ? AND id != ?", input.Code, id).First(&existing).Error; err == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Material code already exists"})
 			return
 		}
@@ -153,7 +161,7 @@ func UpdateMaterial(c *gin.Context) {
 	material.Supplier = input.Supplier
 	material.Description = input.Description
 
-	if err := database.DB.Save(&material).Error; err != nil {
+	if err := database.DB.WithContext(c).Save(&material).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update material"})
 		return
 	}
@@ -166,14 +174,14 @@ func DeleteMaterial(c *gin.Context) {
 	id := c.Param("id")
 
 	var material models.Material
-	if err := database.DB.First(&material, id).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&material, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
 		return
 	}
 
 	// Check if material is used in BOM
 	var bomCount int64
-	database.DB.Model(&models.BOM{}).Where("material_id = ?", id).Count(&bomCount)
+	database.DB.WithContext(c).Model(&models.BOM{}).Where("material_id = ?", id).Count(&bomCount)
 	if bomCount > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Cannot delete material that is used in project BOMs",
@@ -182,7 +190,7 @@ func DeleteMaterial(c *gin.Context) {
 	}
 
 	// Soft delete
-	if err := database.DB.Delete(&material).Error; err != nil {
+	if err := recyclebin.Delete(database.DB.WithContext(c), recyclebin.Materials, material.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete material"})
 		return
 	}
@@ -193,7 +201,7 @@ func DeleteMaterial(c *gin.Context) {
 // GetLowStockMaterials returns materials with stock below minimum threshold
 func GetLowStockMaterials(c *gin.Context) {
 	var materials []models.Material
-	if err := database.DB.Where("stock <= min_stock").Order("stock ASC").Find(&materials).Error; err != nil {
+	if err := database.DB.WithContext(c).Where("stock <= min_stock").Order("stock ASC").Find(&materials).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch low stock materials"})
 		return
 	}
@@ -216,7 +224,7 @@ func UpdateMaterialStock(c *gin.Context) {
 	}
 
 	var material models.Material
-	if err := database.DB.First(&material, id).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&material, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
 		return
 	}
@@ -230,7 +238,7 @@ func UpdateMaterialStock(c *gin.Context) {
 
 	material.Stock = newStock
 
-	if err := database.DB.Save(&material).Error; err != nil {
+	if err := database.DB.WithContext(c).Save(&material).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
 		return
 	}
@@ -6,23 +6,53 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/costledger"
 	"github.com/unipro/project-management/pkg/database"
+	"github.com/unipro/project-management/pkg/listquery"
 )
 
-// GetMaterialUsageByProject returns all material usage records for a project
+// materialUsageListSpec whitelists the filters and sort keys of a project's
+// material usage list
+var materialUsageListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"material_id":     {Column: "material_id", Type: listquery.Number},
+		"daily_report_id": {Column: "daily_report_id", Type: listquery.Number},
+		"used_by":         {Column: "used_by", Type: listquery.Number},
+		"usage_date":      {Column: "usage_date", Op: listquery.Range, Type: listquery.Date},
+		"quantity":        {Column: "quantity", Op: listquery.Range, Type: listquery.Number},
+		"cost":            {Column: "cost", Op: listquery.Range, Type: listquery.Number},
+	},
+	Search: []string{"notes"},
+	Sorts: map[string]string{
+		"usage_date": "usage_date",
+		"quantity":   "quantity",
+		"cost":       "cost",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-usage_date",
+}
+
+// GetMaterialUsageByProject returns a page of material usage records for a project
 func GetMaterialUsageByProject(c *gin.Context) {
-	projectID := c.Param("projectId")
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+
+	if !requireProjectAccess(database.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	query := database.DB.WithContext(c).Preload("Material").Preload("Project").Preload("User").Preload("DailyReport").
+		Where("project_id = ?", projectID)
 
 	var usages []models.MaterialUsage
-	if err := database.DB.Preload("Material").Preload("Project").Preload("User").Preload("DailyReport").
-		Where("project_id = ?", projectID).
-		Order("usage_date DESC").
-		Find(&usages).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch material usage"})
+	page, ok := findList(c, query, materialUsageListSpec, &usages, "Failed to fetch material usage")
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": usages})
+	c.JSON(http.StatusOK, listResponse(usages, page))
 }
 
 // GetMaterialUsageByID returns a single material usage record by ID
@@ -30,12 +60,16 @@ func GetMaterialUsageByID(c *gin.Context) {
 	id := c.Param("id")
 
 	var usage models.MaterialUsage
-	if err := database.DB.Preload("Material").Preload("Project").Preload("User").Preload("DailyReport").
+	if err := database.DB.WithContext(c).Preload("Material").Preload("Project").Preload("User").Preload("DailyReport").
 		First(&usage, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Material usage record not found"})
 		return
 	}
 
+	if !requireProjectAccess(database.DB.WithContext(c), c, usage.ProjectID, accessRead) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": usage})
 }
 
@@ -59,14 +93,18 @@ func CreateMaterialUsage(c *gin.Context) {
 
 	// Verify project exists
 	var project models.Project
-	if err := database.DB.First(&project, input.ProjectID).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&project, input.ProjectID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
 		return
 	}
 
+	if !requireProjectAccess(database.DB.WithContext(c), c, project.ID, accessWrite) {
+		return
+	}
+
 	// Verify material exists and get its price
 	var material models.Material
-	if err := database.DB.First(&material, input.MaterialID).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&material, input.MaterialID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
 		return
 	}
@@ -91,7 +129,7 @@ func CreateMaterialUsage(c *gin.Context) {
 	cost := input.Quantity * material.UnitPrice
 
 	// Start transaction
-	tx := database.DB.Begin()
+	tx := database.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -134,6 +172,13 @@ func CreateMaterialUsage(c *gin.Context) {
 		tx.Save(&bom)
 	}
 
+	// Post the cost to the project ledger
+	if err := costledger.Sync(tx, costledger.MaterialUsageEntry(usage, &material)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project cost"})
+		return
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
@@ -146,7 +191,7 @@ func CreateMaterialUsage(c *gin.Context) {
 	}
 
 	// Load relations
-	database.DB.Preload("Material").Preload("Project").Preload("User").Preload("DailyReport").
+	database.DB.WithContext(c).Preload("Material").Preload("Project").Preload("User").Preload("DailyReport").
 		First(&usage, usage.ID)
 
 	c.JSON(http.StatusCreated, gin.H{"data": usage})
@@ -157,11 +202,15 @@ func UpdateMaterialUsage(c *gin.Context) {
 	id := c.Param("id")
 
 	var usage models.MaterialUsage
-	if err := database.DB.First(&usage, id).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&usage, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Material usage record not found"})
 		return
 	}
 
+	if !requireProjectAccess(database.DB.WithContext(c), c, usage.ProjectID, accessWrite) {
+		return
+	}
+
 	var input struct {
 		Quantity  float64    `json:"quantity"`
 		UsageDate *time.Time `json:"usage_date"`
@@ -174,7 +223,7 @@ func UpdateMaterialUsage(c *gin.Context) {
 	}
 
 	// Start transaction
-	tx := database.DB.Begin()
+	tx := database.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -239,6 +288,15 @@ func UpdateMaterialUsage(c *gin.Context) {
 		return
 	}
 
+	// Keep the project ledger in step with the new cost and date
+	var material models.Material
+	tx.Unscoped().First(&material, usage.MaterialID)
+	if err := costledger.Sync(tx, costledger.MaterialUsageEntry(usage, &material)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project cost"})
+		return
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
@@ -246,7 +304,7 @@ func UpdateMaterialUsage(c *gin.Context) {
 	}
 
 	// Load relations
-	database.DB.Preload("Material").Preload("Project").Preload("User").Preload("DailyReport").
+	database.DB.WithContext(c).Preload("Material").Preload("Project").Preload("User").Preload("DailyReport").
 		First(&usage, usage.ID)
 
 	c.JSON(http.StatusOK, gin.H{"data": usage})
@@ -257,13 +315,17 @@ func DeleteMaterialUsage(c *gin.Context) {
 	id := c.Param("id")
 
 	var usage models.MaterialUsage
-	if err := database.DB.First(&usage, id).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&usage, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Material usage record not found"})
 		return
 	}
 
+	if !requireProjectAccess(database.DB.WithContext(c), c, usage.ProjectID, accessWrite) {
+		return
+	}
+
 	// Start transaction
-	tx := database.DB.Begin()
+	tx := database.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -294,6 +356,12 @@ func DeleteMaterialUsage(c *gin.Context) {
 		return
 	}
 
+	if err := costledger.Remove(tx, models.CostSourceMaterialUsage, usage.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project cost"})
+		return
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
@@ -305,10 +373,17 @@ func DeleteMaterialUsage(c *gin.Context) {
 
 // GetMaterialUsageStats returns material usage statistics for a project
 func GetMaterialUsageStats(c *gin.Context) {
-	projectID := c.Param("projectId")
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+
+	if !requireProjectAccess(database.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
 
 	var usages []models.MaterialUsage
-	if err := database.DB.Where("project_id = ?", projectID).Find(&usages).Error; err != nil {
+	if err := database.DB.WithContext(c).Where("project_id = ?", projectID).Find(&usages).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch material usage"})
 		return
 	}
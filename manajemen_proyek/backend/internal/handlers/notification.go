@@ -6,14 +6,31 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/unipro/project-management/internal/models"
 	"github.com/unipro/project-management/pkg/database"
+	"github.com/unipro/project-management/pkg/listquery"
 )
 
-// GetNotifications returns all notifications for the logged-in user
+// notificationListSpec whitelists the filters and sort keys of the
+// notification list
+var notificationListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"type":       {Column: "type"},
+		"is_read":    {Column: "is_read", Type: listquery.Bool},
+		"created_at": {Column: "created_at", Op: listquery.Range, Type: listquery.Date},
+	},
+	Search: []string{"title", "message"},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"type":       "type",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetNotifications returns a page of notifications for the logged-in user
 func GetNotifications(c *gin.Context) {
-	userID, _ := c.Get("userID")
+	userID, _ := c.Get("user_id")
 	filter := c.Query("filter") // "unread", "read", "all"
 
-	query := database.DB.Where("user_id = ?", userID)
+	query := database.DB.WithContext(c).Where("user_id = ?", userID)
 
 	switch filter {
 	case "unread":
@@ -23,20 +40,20 @@ func GetNotifications(c *gin.Context) {
 	}
 
 	var notifications []models.Notification
-	if err := query.Order("created_at DESC").Find(&notifications).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+	page, ok := findList(c, query, notificationListSpec, &notifications, "Failed to fetch notifications")
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, notifications)
+	c.JSON(http.StatusOK, listResponse(notifications, page))
 }
 
 // GetUnreadCount returns the count of unread notifications
 func GetUnreadCount(c *gin.Context) {
-	userID, _ := c.Get("userID")
+	userID, _ := c.Get("user_id")
 
 	var count int64
-	if err := database.DB.Model(&models.Notification{}).
+	if err := database.DB.WithContext(c).Model(&models.Notification{}).
 		Where("user_id = ? AND is_read = ?", userID, false).
 		Count(&count).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
@@ -49,16 +66,16 @@ func GetUnreadCount(c *gin.Context) {
 // MarkAsRead marks a specific notification as read
 func MarkAsRead(c *gin.Context) {
 	id := c.Param("id")
-	userID, _ := c.Get("userID")
+	userID, _ := c.Get("user_id")
 
 	var notification models.Notification
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&notification).Error; err != nil {
+	if err := database.DB.WithContext(c).Where("id = ? AND user_id = ?", id, userID).First(&notification).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
 		return
 	}
 
 	notification.IsRead = true
-	if err := database.DB.Save(&notification).Error; err != nil {
+	if err := database.DB.WithContext(c).Save(&notification).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
 		return
 	}
@@ -68,9 +85,9 @@ func MarkAsRead(c *gin.Context) {
 
 // MarkAllAsRead marks all notifications as read for the logged-in user
 func MarkAllAsRead(c *gin.Context) {
-	userID, _ := c.Get("userID")
+	userID, _ := c.Get("user_id")
 
-	if err := database.DB.Model(&models.Notification{}).
+	if err := database.DB.WithContext(c).Model(&models.Notification{}).
 		Where("user_id = ? AND is_read = ?", userID, false).
 		Update("is_read", true).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
@@ -83,9 +100,9 @@ func MarkAllAsRead(c *gin.Context) {
 // DeleteNotification deletes a specific notification
 func DeleteNotification(c *gin.Context) {
 	id := c.Param("id")
-	userID, _ := c.Get("userID")
+	userID, _ := c.Get("user_id")
 
-	result := database.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Notification{})
+	result := database.DB.WithContext(c).Where("id = ? AND user_id = ?", id, userID).Delete(&models.Notification{})
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notification"})
 		return
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/mail"
+	"github.com/unipro/project-management/pkg/token"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// errResetTokenInvalid is returned when a reset token is unknown, expired or
+// already used
+var errResetTokenInvalid = errors.New("reset token is invalid or has expired")
+
+// ForgotPasswordRequest represents forgot password request body
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents password reset request body
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// ChangePasswordRequest represents change password request body
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// ForgotPassword emails a reset link to an active account. The response is
+// the same whether or not the email is registered.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	response := gin.H{
+		"message": "If the email is registered, a password reset link has been sent",
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	var user models.User
+	if err := h.DB.WithContext(c).Where("email = ? AND is_active = ?", email, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	plain, hash, err := token.Generate()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate reset token",
+		})
+		return
+	}
+
+	resetToken := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(h.Config.Auth.PasswordResetExpiry),
+		RequestIP: c.ClientIP(),
+	}
+
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		// Only the most recent link stays valid
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("expires_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&resetToken).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create reset token",
+		})
+		return
+	}
+
+	msg := mail.Message{
+		To:      []string{user.Email},
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nWe received a request to reset your password. Open the link below to choose a new one:\n\n%s?token=%s\n\nThe link expires in %s. If you did not request a reset you can ignore this email.\n",
+			user.Name, h.Config.Auth.PasswordResetURL, plain, h.Config.Auth.PasswordResetExpiry,
+		),
+	}
+
+	// Send in the background so response time does not reveal whether the
+	// account exists
+	go func() {
+		if err := h.Mailer.Send(msg); err != nil {
+			log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPassword sets a new password using a reset token and signs the user
+// out everywhere
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to hash password",
+		})
+		return
+	}
+
+	var resetToken models.PasswordResetToken
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ?", token.Hash(req.Token)).First(&resetToken).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errResetTokenInvalid
+			}
+			return err
+		}
+
+		// Conditional update so a token can only be used once
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", resetToken.ID, time.Now()).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errResetTokenInvalid
+		}
+
+		result = tx.Model(&models.User{}).
+			Where("id = ? AND is_active = ?", resetToken.UserID, true).
+			Update("password", string(hashedPassword))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errResetTokenInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errResetTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Reset token is invalid or has expired",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to reset password",
+		})
+		return
+	}
+
+	if err := revokeUserSessions(h.DB.WithContext(c), resetToken.UserID, models.RevokeReasonPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password has been reset, please sign in again",
+	})
+}
+
+// ChangePassword updates the current user's password after verifying the
+// old one. Every other session is signed out.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	user := middleware.GetUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Current password is incorrect",
+		})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to hash password",
+		})
+		return
+	}
+
+	if err := h.DB.WithContext(c).Model(&models.User{}).Where("id = ?", user.ID).
+		Update("password", string(hashedPassword)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to change password",
+		})
+		return
+	}
+
+	sessionID := middleware.GetSessionID(c)
+	if err := revokeSessions(h.DB.WithContext(c), models.RevokeReasonPassword, func(q *gorm.DB) *gorm.DB {
+		return q.Where("user_id = ? AND id != ?", user.ID, sessionID)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password changed successfully",
+	})
+}
@@ -37,7 +37,7 @@ func (h *PhotoHandler) UploadPhotos(c *gin.Context) {
 
 	// Verify daily report exists
 	var report models.DailyReport
-	if err := h.db.First(&report, reportID).Error; err != nil {
+	if err := h.db.WithContext(c).First(&report, reportID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Daily report not found"})
 			return
@@ -119,7 +119,7 @@ func (h *PhotoHandler) UploadPhotos(c *gin.Context) {
 			UploadedBy:    userID.(uint),
 		}
 
-		if err := h.db.Create(&photo).Error; err != nil {
+		if err := h.db.WithContext(c).Create(&photo).Error; err != nil {
 			// Cleanup uploaded file if DB insert fails
 			os.Remove(filePath)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save photo record"})
@@ -127,7 +127,7 @@ func (h *PhotoHandler) UploadPhotos(c *gin.Context) {
 		}
 
 		// Load uploader relation
-		h.db.Preload("Uploader").First(&photo, photo.ID)
+		h.db.WithContext(c).Preload("Uploader").First(&photo, photo.ID)
 		uploadedPhotos = append(uploadedPhotos, photo)
 	}
 
@@ -142,7 +142,7 @@ func (h *PhotoHandler) GetPhotosByReport(c *gin.Context) {
 	reportID := c.Param("id")
 
 	var photos []models.Photo
-	if err := h.db.Preload("Uploader").Where("daily_report_id = ?", reportID).Find(&photos).Error; err != nil {
+	if err := h.db.WithContext(c).Preload("Uploader").Where("daily_report_id = ?", reportID).Find(&photos).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
 		return
 	}
@@ -156,7 +156,7 @@ func (h *PhotoHandler) DeletePhoto(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	var photo models.Photo
-	if err := h.db.First(&photo, photoID).Error; err != nil {
+	if err := h.db.WithContext(c).First(&photo, photoID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
 			return
@@ -179,7 +179,7 @@ func (h *PhotoHandler) DeletePhoto(c *gin.Context) {
 	}
 
 	// Delete from database
-	if err := h.db.Delete(&photo).Error; err != nil {
+	if err := h.db.WithContext(c).Delete(&photo).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photo"})
 		return
 	}
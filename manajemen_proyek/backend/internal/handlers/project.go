@@ -1,13 +1,18 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/unipro/project-management/internal/middleware"
 	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/listquery"
+	"github.com/unipro/project-management/pkg/recyclebin"
 	"gorm.io/gorm"
 )
 
@@ -28,6 +33,13 @@ type CreateProjectRequest struct {
 	EndDate       string                    `json:"end_date" binding:"required"`
 	Deadline      string                    `json:"deadline"`
 	ManagerID     uint                      `json:"manager_id"`
+
+	// Health threshold overrides, omit to use the company default. Updates
+	// leave omitted overrides unchanged.
+	CostWarningPct      *float64 `json:"cost_warning_pct"`
+	CostCriticalPct     *float64 `json:"cost_critical_pct"`
+	ScheduleWarningPct  *float64 `json:"schedule_warning_pct"`
+	ScheduleCriticalPct *float64 `json:"schedule_critical_pct"`
 }
 
 // NewProjectHandler creates a new project handler
@@ -35,33 +47,47 @@ func NewProjectHandler(db *gorm.DB) *ProjectHandler {
 	return &ProjectHandler{DB: db}
 }
 
-// GetAllProjects returns all projects (filtered by user role)
-func (h *ProjectHandler) GetAllProjects(c *gin.Context) {
-	userRole := middleware.GetUserRole(c)
-	userID := middleware.GetUserID(c)
+// projectListSpec whitelists the filters and sort keys of the project list
+var projectListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"status":         {Column: "status"},
+		"project_type":   {Column: "project_type"},
+		"city":           {Column: "city"},
+		"manager_id":     {Column: "manager_id", Type: listquery.Number},
+		"start_date":     {Column: "start_date", Op: listquery.Range, Type: listquery.Date},
+		"end_date":       {Column: "end_date", Op: listquery.Range, Type: listquery.Date},
+		"progress":       {Column: "progress", Op: listquery.Range, Type: listquery.Number},
+		"estimated_cost": {Column: "estimated_cost", Op: listquery.Range, Type: listquery.Number},
+	},
+	Search: []string{"name", "customer", "city"},
+	Sorts: map[string]string{
+		"name":           "name",
+		"status":         "status",
+		"progress":       "progress",
+		"estimated_cost": "estimated_cost",
+		"actual_cost":    "actual_cost",
+		"start_date":     "start_date",
+		"end_date":       "end_date",
+		"created_at":     "created_at",
+	},
+	DefaultSort: "-created_at",
+}
 
+// GetAllProjects returns a page of the projects the user can access
+func (h *ProjectHandler) GetAllProjects(c *gin.Context) {
 	var projects []models.Project
-	query := h.DB.Preload("Manager").Preload("Manager.Role").Preload("ProgressBreakdown")
 
-	// Filter based on role
-	// CEO, Director, Project Director, and Manager can see all projects
-	canSeeAll := userRole == "ceo" || userRole == "director" || userRole == "project_director" || userRole == "manager"
-	if !canSeeAll {
-		// Other roles only see projects they're managing
-		query = query.Where("manager_id = ?", userID)
-	}
+	// Users without projects:read_all only see projects they manage or
+	// are a member of
+	query := h.DB.WithContext(c).Preload("Manager").Preload("Manager.Role").Preload("Phases", preloadPhases).
+		Scopes(scopeAccessibleProjects(h.DB.WithContext(c), c, "projects.id"))
 
-	if err := query.Find(&projects).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch projects",
-		})
+	page, ok := findList(c, query, projectListSpec, &projects, "Failed to fetch projects")
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":  projects,
-		"total": len(projects),
-	})
+	c.JSON(http.StatusOK, listResponse(projects, page))
 }
 
 // GetProjectByID returns a single project
@@ -75,9 +101,13 @@ func (h *ProjectHandler) GetProjectByID(c *gin.Context) {
 		return
 	}
 
+	if !requireProjectAccess(h.DB.WithContext(c), c, uint(projectID), accessRead) {
+		return
+	}
+
 	var project models.Project
-	if err := h.DB.Preload("Manager").Preload("Manager.Role").
-		Preload("ProgressBreakdown").
+	if err := h.DB.WithContext(c).Preload("Manager").Preload("Manager.Role").
+		Preload("Phases", preloadPhases).
 		First(&project, projectID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -149,23 +179,29 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 	}
 
 	project := models.Project{
-		Name:          req.Name,
-		Description:   req.Description,
-		Customer:      req.Customer,
-		City:          req.City,
-		Address:       req.Address,
-		ProjectType:   req.ProjectType,
-		EstimatedCost: req.EstimatedCost,
-		ActualCost:    0,
-		Progress:      0,
-		Status:        models.StatusOnTrack,
-		StartDate:     startDate,
-		EndDate:       endDate,
-		ManagerID:     managerID,
+		Name:           req.Name,
+		Description:    req.Description,
+		Customer:       req.Customer,
+		City:           req.City,
+		Address:        req.Address,
+		ProjectType:    req.ProjectType,
+		EstimatedCost:  req.EstimatedCost,
+		OriginalBudget: req.EstimatedCost,
+		ActualCost:     0,
+		Progress:       0,
+		Status:         models.StatusOnTrack,
+		StartDate:      startDate,
+		EndDate:        endDate,
+		ManagerID:      managerID,
+
+		CostWarningPct:      req.CostWarningPct,
+		CostCriticalPct:     req.CostCriticalPct,
+		ScheduleWarningPct:  req.ScheduleWarningPct,
+		ScheduleCriticalPct: req.ScheduleCriticalPct,
 	}
 
 	// Start transaction
-	tx := h.DB.Begin()
+	tx := h.DB.WithContext(c).Begin()
 	if err := tx.Create(&project).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -174,19 +210,20 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		return
 	}
 
-	// Create progress breakdown
-	progressBreakdown := models.ProgressBreakdown{
-		ProjectID:  project.ID,
-		Foundation: 0,
-		Utilities:  0,
-		Interior:   0,
-		Equipment:  0,
+	// Seed phases from the project type's template
+	if err := seedProjectPhases(tx, &project); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create project phases",
+		})
+		return
 	}
 
-	if err := tx.Create(&progressBreakdown).Error; err != nil {
+	// Baseline for the progress history
+	if _, err := recalculateProgress(tx, project.ID, userID); err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create progress breakdown",
+			"error": "Failed to record initial progress",
 		})
 		return
 	}
@@ -194,7 +231,7 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 	tx.Commit()
 
 	// Load relations
-	h.DB.Preload("Manager").Preload("ProgressBreakdown").First(&project, project.ID)
+	h.DB.WithContext(c).Preload("Manager").Preload("Phases", preloadPhases).First(&project, project.ID)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Project created successfully",
@@ -213,8 +250,12 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 		return
 	}
 
+	if !requireProjectAccess(h.DB.WithContext(c), c, uint(projectID), accessWrite) {
+		return
+	}
+
 	var project models.Project
-	if err := h.DB.First(&project, projectID).Error; err != nil {
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error": "Project not found",
@@ -228,7 +269,7 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 	}
 
 	var req CreateProjectRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request",
 			"message": err.Error(),
@@ -236,6 +277,29 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 		return
 	}
 
+	// Threshold overrides only change when the body names them, so clients
+	// that predate them keep the project's overrides; null clears one
+	var present map[string]json.RawMessage
+	if body, ok := c.Get(gin.BodyBytesKey); ok {
+		if raw, ok := body.([]byte); ok {
+			json.Unmarshal(raw, &present)
+		}
+	}
+
+	// Once change orders have revised the budget it only changes through
+	// further change orders, so the original budget stays traceable
+	if req.EstimatedCost != project.EstimatedCost {
+		if hasApprovedChangeOrders(h.DB.WithContext(c), project.ID) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Budget is managed by change orders",
+				"message": "Submit a change order to revise the budget of this project",
+			})
+			return
+		}
+		project.OriginalBudget = req.EstimatedCost
+		project.EstimatedCost = req.EstimatedCost
+	}
+
 	// Update fields
 	project.Name = req.Name
 	project.Description = req.Description
@@ -243,12 +307,23 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 	project.City = req.City
 	project.Address = req.Address
 	project.ProjectType = req.ProjectType
-	project.EstimatedCost = req.EstimatedCost
+	if _, ok := present["cost_warning_pct"]; ok {
+		project.CostWarningPct = req.CostWarningPct
+	}
+	if _, ok := present["cost_critical_pct"]; ok {
+		project.CostCriticalPct = req.CostCriticalPct
+	}
+	if _, ok := present["schedule_warning_pct"]; ok {
+		project.ScheduleWarningPct = req.ScheduleWarningPct
+	}
+	if _, ok := present["schedule_critical_pct"]; ok {
+		project.ScheduleCriticalPct = req.ScheduleCriticalPct
+	}
 
-	// Update status based on variance
-	project.UpdateStatus()
+	// Update status based on cost and schedule health
+	project.UpdateStatus(models.LoadHealthSettings(h.DB.WithContext(c)))
 
-	if err := h.DB.Save(&project).Error; err != nil {
+	if err := h.DB.WithContext(c).Save(&project).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to update project",
 		})
@@ -256,7 +331,7 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 	}
 
 	// Load relations
-	h.DB.Preload("Manager").Preload("ProgressBreakdown").First(&project, project.ID)
+	h.DB.WithContext(c).Preload("Manager").Preload("Phases", preloadPhases).First(&project, project.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Project updated successfully",
@@ -275,7 +350,15 @@ func (h *ProjectHandler) DeleteProject(c *gin.Context) {
 		return
 	}
 
-	if err := h.DB.Delete(&models.Project{}, projectID).Error; err != nil {
+	if !requireProjectAccess(h.DB.WithContext(c), c, uint(projectID), accessWrite) {
+		return
+	}
+
+	// Its BOM, reports and usage go to the recycle bin with it
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		return recyclebin.Delete(tx, recyclebin.Projects, uint(projectID))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to delete project",
 		})
@@ -287,7 +370,9 @@ func (h *ProjectHandler) DeleteProject(c *gin.Context) {
 	})
 }
 
-// UpdateProgress updates project progress
+// UpdateProgress updates the progress of project phases. Overall progress
+// is computed from the phase weights. The legacy foundation, utilities,
+// interior and equipment fields update phases of the same name.
 func (h *ProjectHandler) UpdateProgress(c *gin.Context) {
 	id := c.Param("id")
 	projectID, err := strconv.ParseUint(id, 10, 32)
@@ -299,11 +384,14 @@ func (h *ProjectHandler) UpdateProgress(c *gin.Context) {
 	}
 
 	var req struct {
-		Progress      float64 `json:"progress"`
-		Foundation    float64 `json:"foundation"`
-		Utilities     float64 `json:"utilities"`
-		Interior      float64 `json:"interior"`
-		Equipment     float64 `json:"equipment"`
+		Phases []struct {
+			ID       uint    `json:"id" binding:"required"`
+			Progress float64 `json:"progress"`
+		} `json:"phases"`
+		Foundation    *float64 `json:"foundation"`
+		Utilities     *float64 `json:"utilities"`
+		Interior      *float64 `json:"interior"`
+		Equipment     *float64 `json:"equipment"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -314,50 +402,238 @@ func (h *ProjectHandler) UpdateProgress(c *gin.Context) {
 		return
 	}
 
-	// Update project progress
+	if !requireProjectAccess(h.DB.WithContext(c), c, uint(projectID), accessWrite) {
+		return
+	}
+
+	var phases []models.ProjectPhase
+	if err := preloadPhases(h.DB.WithContext(c)).Where("project_id = ?", projectID).Find(&phases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch project phases",
+		})
+		return
+	}
+	if len(phases) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Project has no phases, add phases before updating progress",
+		})
+		return
+	}
+
+	// Collect the new progress of each phase
+	updates := map[uint]float64{}
+	for _, item := range req.Phases {
+		updates[item.ID] = item.Progress
+	}
+	legacy := map[string]*float64{
+		"foundation": req.Foundation,
+		"utilities":  req.Utilities,
+		"interior":   req.Interior,
+		"equipment":  req.Equipment,
+	}
+	for _, phase := range phases {
+		if value := legacy[strings.ToLower(phase.Name)]; value != nil {
+			if _, ok := updates[phase.ID]; !ok {
+				updates[phase.ID] = *value
+			}
+		}
+	}
+
+	known := map[uint]bool{}
+	for _, phase := range phases {
+		known[phase.ID] = true
+	}
+	for phaseID, progress := range updates {
+		if !known[phaseID] {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Phase " + strconv.FormatUint(uint64(phaseID), 10) + " does not belong to this project",
+			})
+			return
+		}
+		if progress < 0 || progress > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Progress must be between 0 and 100",
+			})
+			return
+		}
+	}
+
 	var project models.Project
-	if err := h.DB.First(&project, projectID).Error; err != nil {
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		for i := range phases {
+			progress, ok := updates[phases[i].ID]
+			if !ok || progress == phases[i].Progress {
+				continue
+			}
+			phases[i].Progress = progress
+			if err := tx.Model(&phases[i]).Update("progress", progress).Error; err != nil {
+				return err
+			}
+		}
+
+		var err error
+		project, err = recalculateProgress(tx, uint(projectID), middleware.GetUserID(c))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update progress",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Progress updated successfully",
+		"project": project,
+		"phases":  phases,
+	})
+}
+
+
+// ===== PROJECT MEMBERS =====
+
+// GetProjectMembers returns the members of a project
+func (h *ProjectHandler) GetProjectMembers(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var members []models.ProjectMember
+	if err := h.DB.WithContext(c).Preload("User").Preload("User.Role").
+		Where("project_id = ?", projectID).
+		Order("created_at ASC").
+		Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch project members",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": members,
+	})
+}
+
+// AddProjectMember adds a user to a project, or updates their project role
+// if they are already a member
+func (h *ProjectHandler) AddProjectMember(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserID      uint                     `json:"user_id" binding:"required"`
+		ProjectRole models.ProjectMemberRole `json:"project_role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if req.ProjectRole == "" {
+		req.ProjectRole = models.ProjectRoleMember
+	}
+	if !models.IsValidProjectRole(req.ProjectRole) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid project role",
+		})
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Project not found",
 		})
 		return
 	}
 
-	project.Progress = req.Progress
-	project.UpdateStatus()
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
 
-	if err := h.DB.Save(&project).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to update progress",
+	var user models.User
+	if err := h.DB.WithContext(c).First(&user, req.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "User not found",
 		})
 		return
 	}
 
-	// Update progress breakdown
-	var breakdown models.ProgressBreakdown
-	if err := h.DB.Where("project_id = ?", projectID).First(&breakdown).Error; err != nil {
-		// Create if not exists
-		breakdown = models.ProgressBreakdown{
-			ProjectID: uint(projectID),
+	var member models.ProjectMember
+	err := h.DB.WithContext(c).Where("project_id = ? AND user_id = ?", projectID, req.UserID).First(&member).Error
+	status := http.StatusOK
+	if err == gorm.ErrRecordNotFound {
+		member = models.ProjectMember{
+			ProjectID:   projectID,
+			UserID:      req.UserID,
+			ProjectRole: req.ProjectRole,
+			AddedBy:     middleware.GetUserID(c),
 		}
+		err = h.DB.WithContext(c).Create(&member).Error
+		status = http.StatusCreated
+	} else if err == nil {
+		err = h.DB.WithContext(c).Model(&member).Update("project_role", req.ProjectRole).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save project member",
+		})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("User").Preload("User.Role").First(&member, member.ID)
+
+	c.JSON(status, gin.H{
+		"message": "Project member saved successfully",
+		"data":    member,
+	})
+}
+
+// RemoveProjectMember removes a user from a project
+func (h *ProjectHandler) RemoveProjectMember(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
 	}
 
-	breakdown.Foundation = req.Foundation
-	breakdown.Utilities = req.Utilities
-	breakdown.Interior = req.Interior
-	breakdown.Equipment = req.Equipment
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID",
+		})
+		return
+	}
 
-	if err := h.DB.Save(&breakdown).Error; err != nil {
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	result := h.DB.WithContext(c).Where("project_id = ? AND user_id = ?", projectID, userID).Delete(&models.ProjectMember{})
+	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to update progress breakdown",
+			"error": "Failed to remove project member",
+		})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Project member not found",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Progress updated successfully",
-		"project": project,
-		"breakdown": breakdown,
+		"message": "Project member removed successfully",
 	})
 }
-
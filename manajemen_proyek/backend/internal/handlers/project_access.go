@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"gorm.io/gorm"
+)
+
+// Project access levels checked by requireProjectAccess
+const (
+	accessRead  = false
+	accessWrite = true
+)
+
+// canSeeAllProjects checks if the user may access every project regardless
+// of membership
+func canSeeAllProjects(c *gin.Context) bool {
+	return middleware.HasPermission(c, "projects", "read_all")
+}
+
+// accessibleProjectIDs returns a subquery of the project IDs the user manages
+// or is a member of
+func accessibleProjectIDs(db *gorm.DB, userID uint) *gorm.DB {
+	members := db.Model(&models.ProjectMember{}).Select("project_id").Where("user_id = ?", userID)
+	return db.Model(&models.Project{}).Select("id").Where("manager_id = ? OR id IN (?)", userID, members)
+}
+
+// scopeAccessibleProjects restricts a query to rows whose column references
+// a project the user can access
+func scopeAccessibleProjects(db *gorm.DB, c *gin.Context, column string) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		if canSeeAllProjects(c) {
+			return query
+		}
+		return query.Where(column+" IN (?)", accessibleProjectIDs(db, middleware.GetUserID(c)))
+	}
+}
+
+// hasProjectAccess checks if the user can read, or with write set change,
+// data belonging to the project
+func hasProjectAccess(db *gorm.DB, c *gin.Context, projectID uint, write bool) bool {
+	if canSeeAllProjects(c) {
+		return true
+	}
+
+	userID := middleware.GetUserID(c)
+
+	var project models.Project
+	if err := db.Select("id", "manager_id").First(&project, projectID).Error; err != nil {
+		return false
+	}
+	if project.ManagerID == userID {
+		return true
+	}
+
+	var member models.ProjectMember
+	if err := db.Where("project_id = ? AND user_id = ?", projectID, userID).First(&member).Error; err != nil {
+		return false
+	}
+
+	return !write || member.CanWrite()
+}
+
+// requireProjectAccess writes a 403 response and returns false when the user
+// cannot access the project
+func requireProjectAccess(db *gorm.DB, c *gin.Context, projectID uint, write bool) bool {
+	if hasProjectAccess(db, c, projectID, write) {
+		return true
+	}
+
+	message := "You are not a member of this project"
+	if write {
+		message = "You don't have write access to this project"
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": message})
+	return false
+}
+
+// parseProjectIDParam reads the :id path parameter of project-scoped routes
+func parseProjectIDParam(c *gin.Context) (uint, bool) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return 0, false
+	}
+	return uint(projectID), true
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+)
+
+// HealthSettingsRequest represents company health settings request body
+type HealthSettingsRequest struct {
+	CostWarningPct      float64 `json:"cost_warning_pct"`
+	CostCriticalPct     float64 `json:"cost_critical_pct"`
+	ScheduleWarningPct  float64 `json:"schedule_warning_pct"`
+	ScheduleCriticalPct float64 `json:"schedule_critical_pct"`
+}
+
+// ProjectStatusRequest represents manual status request body. Set automatic
+// to hand the status back to the health rules.
+type ProjectStatusRequest struct {
+	Status    models.ProjectStatus `json:"status"`
+	Automatic bool                 `json:"automatic"`
+}
+
+// manualStatuses lists statuses that can be set by hand
+var manualStatuses = map[models.ProjectStatus]bool{
+	models.StatusOnTrack:        true,
+	models.StatusWarning:        true,
+	models.StatusOverBudget:     true,
+	models.StatusBehindSchedule: true,
+	models.StatusCompleted:      true,
+	models.StatusOnHold:         true,
+}
+
+// GetProjectHealth returns how the project scores against its cost and
+// schedule thresholds
+func (h *ProjectHandler) GetProjectHealth(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	settings := models.LoadHealthSettings(h.DB.WithContext(c))
+	health := project.EvaluateHealth(settings.ThresholdsFor(&project), time.Now())
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"project_id":     project.ID,
+			"current_status": project.Status,
+			"health":         health,
+		},
+	})
+}
+
+// UpdateProjectStatus sets a project's status by hand, or returns it to the
+// automatic rules
+func (h *ProjectHandler) UpdateProjectStatus(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req ProjectStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	if req.Automatic {
+		project.StatusManual = false
+		// Leaving On Hold is the usual reason to resume automatic status
+		if project.Status == models.StatusOnHold {
+			project.Status = models.StatusOnTrack
+		}
+		project.UpdateStatus(models.LoadHealthSettings(h.DB.WithContext(c)))
+	} else {
+		if !manualStatuses[req.Status] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project status"})
+			return
+		}
+		project.Status = req.Status
+		project.StatusManual = true
+	}
+
+	if err := h.DB.WithContext(c).Model(&project).Updates(map[string]interface{}{
+		"status":        project.Status,
+		"status_manual": project.StatusManual,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Project status updated successfully",
+		"project": project,
+	})
+}
+
+// GetHealthSettings returns the company-wide health thresholds
+func (h *ProjectHandler) GetHealthSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": models.LoadHealthSettings(h.DB.WithContext(c))})
+}
+
+// UpdateHealthSettings changes the company-wide health thresholds and
+// re-evaluates every project that follows the automatic rules
+func (h *ProjectHandler) UpdateHealthSettings(c *gin.Context) {
+	var req HealthSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if req.CostWarningPct > req.CostCriticalPct || req.ScheduleWarningPct > req.ScheduleCriticalPct {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Warning thresholds must not exceed critical thresholds"})
+		return
+	}
+	if req.ScheduleWarningPct < 0 || req.ScheduleCriticalPct < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Schedule thresholds must not be negative"})
+		return
+	}
+
+	settings := models.LoadHealthSettings(h.DB.WithContext(c))
+	userID := middleware.GetUserID(c)
+	settings.CostWarningPct = req.CostWarningPct
+	settings.CostCriticalPct = req.CostCriticalPct
+	settings.ScheduleWarningPct = req.ScheduleWarningPct
+	settings.ScheduleCriticalPct = req.ScheduleCriticalPct
+	settings.UpdatedBy = &userID
+
+	if err := h.DB.WithContext(c).Save(&settings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save health settings"})
+		return
+	}
+
+	updated, err := h.reevaluateStatuses(c, settings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to re-evaluate project statuses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Health settings updated successfully",
+		"data":             settings,
+		"projects_changed": updated,
+	})
+}
+
+// ReevaluateStatuses applies the current health rules to every automatic
+// project. Schedule slip grows with time alone, so this is meant to be run
+// daily, e.g. from cron.
+func (h *ProjectHandler) ReevaluateStatuses(c *gin.Context) {
+	updated, err := h.reevaluateStatuses(c, models.LoadHealthSettings(h.DB.WithContext(c)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to re-evaluate project statuses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Project statuses re-evaluated",
+		"projects_changed": updated,
+	})
+}
+
+// reevaluateStatuses applies the health rules to every automatic project and
+// returns how many changed status
+func (h *ProjectHandler) reevaluateStatuses(c *gin.Context, settings models.HealthSettings) (int, error) {
+	var projects []models.Project
+	if err := h.DB.WithContext(c).
+		Where("status_manual = ? AND status <> ?", false, models.StatusOnHold).
+		Find(&projects).Error; err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for i := range projects {
+		previous := projects[i].Status
+		projects[i].UpdateStatus(settings)
+		if projects[i].Status == previous {
+			continue
+		}
+		if err := h.DB.WithContext(c).Model(&projects[i]).Update("status", projects[i].Status).Error; err != nil {
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"gorm.io/gorm"
+)
+
+// PhaseRequest represents project phase request body. Dates use the
+// YYYY-MM-DD format.
+type PhaseRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	SortOrder    *int     `json:"sort_order"`
+	Weight       float64  `json:"weight"`
+	PlannedStart string   `json:"planned_start"`
+	PlannedEnd   string   `json:"planned_end"`
+	Progress     *float64 `json:"progress"`
+}
+
+// PhaseTemplateRequest represents one phase of a project type template
+type PhaseTemplateRequest struct {
+	Name     string  `json:"name" binding:"required"`
+	Weight   float64 `json:"weight"`
+	StartPct float64 `json:"start_pct"`
+	EndPct   float64 `json:"end_pct"`
+}
+
+// preloadPhases loads a project's phases in display order
+func preloadPhases(db *gorm.DB) *gorm.DB {
+	return db.Order("sort_order ASC, id ASC")
+}
+
+// seedProjectPhases creates the phases of a new project from the template of
+// its project type
+func seedProjectPhases(tx *gorm.DB, project *models.Project) error {
+	var templates []models.PhaseTemplate
+	if err := tx.Where("project_type = ?", project.ProjectType).
+		Order("sort_order ASC, id ASC").
+		Find(&templates).Error; err != nil {
+		return err
+	}
+
+	for _, template := range templates {
+		phase := template.NewPhase(project.ID, project.StartDate, project.EndDate)
+		if err := tx.Create(&phase).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recalculateProgress sets a project's progress to the weighted progress of
+// its phases, re-evaluates its status and records a progress snapshot
+func recalculateProgress(tx *gorm.DB, projectID, userID uint) (models.Project, error) {
+	var project models.Project
+	if err := tx.First(&project, projectID).Error; err != nil {
+		return project, err
+	}
+
+	var phases []models.ProjectPhase
+	if err := preloadPhases(tx).Where("project_id = ?", projectID).Find(&phases).Error; err != nil {
+		return project, err
+	}
+
+	project.Progress = models.WeightedProgress(phases)
+	project.UpdateStatus(models.LoadHealthSettings(tx))
+
+	if err := tx.Model(&project).Updates(map[string]interface{}{
+		"progress": project.Progress,
+		"status":   project.Status,
+	}).Error; err != nil {
+		return project, err
+	}
+
+	if err := recordProgressSnapshot(tx, &project, phases, userID); err != nil {
+		return project, err
+	}
+
+	// Progress may reach billing milestones
+	err := billReachedMilestones(tx, projectID)
+	return project, err
+}
+
+// recordProgressSnapshot stores the current progress and cost of a project
+func recordProgressSnapshot(tx *gorm.DB, project *models.Project, phases []models.ProjectPhase, userID uint) error {
+	var recordedBy *uint
+	if userID != 0 {
+		recordedBy = &userID
+	}
+	snapshot := models.NewProgressSnapshot(project, phases, recordedBy, time.Now())
+	return tx.Create(&snapshot).Error
+}
+
+// findProjectPhase checks that a phase belongs to a project
+func findProjectPhase(db *gorm.DB, projectID, phaseID uint) (models.ProjectPhase, error) {
+	var phase models.ProjectPhase
+	err := db.Where("id = ? AND project_id = ?", phaseID, projectID).First(&phase).Error
+	return phase, err
+}
+
+// parseOptionalDate parses an optional YYYY-MM-DD date
+func parseOptionalDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetProjectPhases returns the phases of a project
+func (h *ProjectHandler) GetProjectPhases(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var phases []models.ProjectPhase
+	if err := preloadPhases(h.DB.WithContext(c)).Where("project_id = ?", projectID).Find(&phases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch project phases"})
+		return
+	}
+
+	totalWeight := 0.0
+	for _, phase := range phases {
+		totalWeight += phase.Weight
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         phases,
+		"total_weight": totalWeight,
+		"progress":     models.WeightedProgress(phases),
+	})
+}
+
+// CreateProjectPhase adds a phase to a project
+func (h *ProjectHandler) CreateProjectPhase(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req PhaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	phase := models.ProjectPhase{ProjectID: projectID}
+	if !applyPhaseRequest(c, &phase, req) {
+		return
+	}
+
+	if req.SortOrder == nil {
+		var maxOrder int
+		h.DB.WithContext(c).Model(&models.ProjectPhase{}).
+			Where("project_id = ?", projectID).
+			Select("COALESCE(MAX(sort_order), 0)").
+			Scan(&maxOrder)
+		phase.SortOrder = maxOrder + 1
+	}
+
+	var project models.Project
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&phase).Error; err != nil {
+			return err
+		}
+		var err error
+		project, err = recalculateProgress(tx, projectID, middleware.GetUserID(c))
+		return err
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project phase"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Project phase created successfully",
+		"data":     phase,
+		"progress": project.Progress,
+	})
+}
+
+// UpdateProjectPhase updates a phase's details or progress
+func (h *ProjectHandler) UpdateProjectPhase(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	phaseID, err := strconv.ParseUint(c.Param("phaseId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phase ID"})
+		return
+	}
+
+	phase, err := findProjectPhase(h.DB.WithContext(c), projectID, uint(phaseID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project phase not found"})
+		return
+	}
+
+	var req PhaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if !applyPhaseRequest(c, &phase, req) {
+		return
+	}
+
+	var project models.Project
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&phase).Error; err != nil {
+			return err
+		}
+		var err error
+		project, err = recalculateProgress(tx, projectID, middleware.GetUserID(c))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project phase"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Project phase updated successfully",
+		"data":     phase,
+		"progress": project.Progress,
+	})
+}
+
+// DeleteProjectPhase removes a phase. BOM lines of the phase are kept
+// without a phase.
+func (h *ProjectHandler) DeleteProjectPhase(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	phaseID, err := strconv.ParseUint(c.Param("phaseId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phase ID"})
+		return
+	}
+
+	phase, err := findProjectPhase(h.DB.WithContext(c), projectID, uint(phaseID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project phase not found"})
+		return
+	}
+
+	var project models.Project
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.BOM{}, &models.BudgetLine{}, &models.CostEntry{}} {
+			if err := tx.Model(model).Where("phase_id = ?", phase.ID).Update("phase_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&phase).Error; err != nil {
+			return err
+		}
+		var err error
+		project, err = recalculateProgress(tx, projectID, middleware.GetUserID(c))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project phase"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Project phase deleted successfully",
+		"progress": project.Progress,
+	})
+}
+
+// applyPhaseRequest validates a phase request and copies it onto the phase
+func applyPhaseRequest(c *gin.Context, phase *models.ProjectPhase, req PhaseRequest) bool {
+	if req.Weight < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Weight must not be negative"})
+		return false
+	}
+	if req.Progress != nil && (*req.Progress < 0 || *req.Progress > 100) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Progress must be between 0 and 100"})
+		return false
+	}
+
+	plannedStart, err := parseOptionalDate(req.PlannedStart)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid planned start format. Use YYYY-MM-DD"})
+		return false
+	}
+	plannedEnd, err := parseOptionalDate(req.PlannedEnd)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid planned end format. Use YYYY-MM-DD"})
+		return false
+	}
+	if plannedStart != nil && plannedEnd != nil && plannedEnd.Before(*plannedStart) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Planned end must not be before planned start"})
+		return false
+	}
+
+	phase.Name = strings.TrimSpace(req.Name)
+	phase.Weight = req.Weight
+	phase.PlannedStart = plannedStart
+	phase.PlannedEnd = plannedEnd
+	if req.SortOrder != nil {
+		phase.SortOrder = *req.SortOrder
+	}
+	if req.Progress != nil {
+		phase.Progress = *req.Progress
+	}
+	return true
+}
+
+// GetPhaseTemplates returns the phase templates, optionally for one project
+// type
+func (h *ProjectHandler) GetPhaseTemplates(c *gin.Context) {
+	query := h.DB.WithContext(c).Order("project_type ASC, sort_order ASC, id ASC")
+	if projectType := c.Query("project_type"); projectType != "" {
+		query = query.Where("project_type = ?", projectType)
+	}
+
+	var templates []models.PhaseTemplate
+	if err := query.Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch phase templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": templates})
+}
+
+// ReplacePhaseTemplates sets the phases seeded into new projects of a project
+// type. Existing projects are not changed.
+func (h *ProjectHandler) ReplacePhaseTemplates(c *gin.Context) {
+	projectType := models.ProjectType(c.Param("projectType"))
+
+	var req []PhaseTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	templates := make([]models.PhaseTemplate, 0, len(req))
+	for i, item := range req {
+		if item.Weight < 0 || item.StartPct < 0 || item.EndPct > 100 || item.EndPct < item.StartPct {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid template phase: " + item.Name,
+			})
+			return
+		}
+		templates = append(templates, models.PhaseTemplate{
+			ProjectType: projectType,
+			Name:        strings.TrimSpace(item.Name),
+			SortOrder:   i + 1,
+			Weight:      item.Weight,
+			StartPct:    item.StartPct,
+			EndPct:      item.EndPct,
+		})
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_type = ?", projectType).Delete(&models.PhaseTemplate{}).Error; err != nil {
+			return err
+		}
+		if len(templates) == 0 {
+			return nil
+		}
+		return tx.Create(&templates).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save phase templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Phase templates saved successfully",
+		"data":    templates,
+	})
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/models"
+)
+
+// maxCurvePoints caps the number of points returned for an S-curve
+const maxCurvePoints = 1000
+
+// SCurvePoint is one date of a planned versus actual progress curve.
+// Actual values are empty for dates after today.
+type SCurvePoint struct {
+	Date           string   `json:"date"`
+	PlannedPercent float64  `json:"planned_percent"`
+	ActualPercent  *float64 `json:"actual_percent"`
+	PlannedCost    float64  `json:"planned_cost"`
+	ActualCost     *float64 `json:"actual_cost"`
+}
+
+// GetProgressHistory returns the progress snapshots of a project, oldest
+// first
+func (h *ProjectHandler) GetProgressHistory(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	query := h.DB.WithContext(c).Preload("Recorder").Where("project_id = ?", projectID)
+	if from := c.Query("from"); from != "" {
+		if t, err := time.Parse("2006-01-02", from); err == nil {
+			query = query.Where("snapshot_date >= ?", t)
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if t, err := time.Parse("2006-01-02", to); err == nil {
+			query = query.Where("snapshot_date <= ?", t)
+		}
+	}
+
+	var snapshots []models.ProgressSnapshot
+	if err := query.Order("created_at ASC, id ASC").Find(&snapshots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch progress history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": snapshots})
+}
+
+// GetSCurve returns cumulative planned and actual progress and cost per day,
+// week or month. The planned curve follows the phase plan when phases have
+// planned dates, otherwise the project dates; basis=dates forces the latter.
+// Actual progress comes from progress snapshots and actual cost from the
+// cost ledger.
+func (h *ProjectHandler) GetSCurve(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	var phases []models.ProjectPhase
+	if err := preloadPhases(h.DB.WithContext(c)).Where("project_id = ?", projectID).Find(&phases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch project phases"})
+		return
+	}
+
+	var snapshots []models.ProgressSnapshot
+	if err := h.DB.WithContext(c).Where("project_id = ?", projectID).
+		Order("created_at ASC, id ASC").
+		Find(&snapshots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch progress history"})
+		return
+	}
+
+	var costs []models.CostEntry
+	if err := h.DB.WithContext(c).Select("amount", "entry_date").
+		Where("project_id = ?", projectID).
+		Order("entry_date ASC").
+		Find(&costs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cost entries"})
+		return
+	}
+
+	basis := "dates"
+	if c.Query("basis") != "dates" && models.HasPhasePlan(phases) {
+		basis = "phases"
+	}
+
+	interval := c.DefaultQuery("interval", "week")
+	step, ok := curveStep(interval)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval. Use day, week or month"})
+		return
+	}
+
+	start, end := curveRange(&project, snapshots)
+	if start.IsZero() {
+		c.JSON(http.StatusOK, gin.H{
+			"data":     []SCurvePoint{},
+			"basis":    basis,
+			"interval": interval,
+		})
+		return
+	}
+
+	today := time.Now()
+	var points []SCurvePoint
+	next, nextCost := 0, 0
+	var latest *models.ProgressSnapshot
+	spent := 0.0
+
+	for date := start; ; date = step(date) {
+		last := !date.Before(end)
+		if last {
+			date = end
+		}
+
+		planned := project.PlannedProgress(date.AddDate(0, 0, 1))
+		if basis == "phases" {
+			planned = project.PlannedProgressFromPhases(phases, date.AddDate(0, 0, 1))
+		}
+
+		point := SCurvePoint{
+			Date:           date.Format("2006-01-02"),
+			PlannedPercent: math.Round(planned*100) / 100,
+			PlannedCost:    math.Round(project.EstimatedCost*planned) / 100,
+		}
+
+		// The latest snapshot taken by the end of the day is the actual value
+		for next < len(snapshots) && snapshots[next].CreatedAt.Before(date.AddDate(0, 0, 1)) {
+			latest = &snapshots[next]
+			next++
+		}
+		// Costs are cumulated from the ledger by entry date
+		for nextCost < len(costs) && costs[nextCost].EntryDate.Before(date.AddDate(0, 0, 1)) {
+			spent += costs[nextCost].Amount
+			nextCost++
+		}
+		if !date.After(today) {
+			actual := 0.0
+			if latest != nil {
+				actual = latest.Progress
+			}
+			cost := math.Round(spent*100) / 100
+			point.ActualPercent = &actual
+			point.ActualCost = &cost
+		}
+
+		points = append(points, point)
+		if last || len(points) >= maxCurvePoints {
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     points,
+		"basis":    basis,
+		"interval": interval,
+		"summary": gin.H{
+			"start_date":       start.Format("2006-01-02"),
+			"end_date":         end.Format("2006-01-02"),
+			"estimated_cost":   project.EstimatedCost,
+			"current_progress": project.Progress,
+			"actual_cost":      project.ActualCost,
+		},
+	})
+}
+
+// curveStep returns the function advancing a curve date by one interval
+func curveStep(interval string) (func(time.Time) time.Time, bool) {
+	switch interval {
+	case "day":
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, true
+	case "week":
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }, true
+	case "month":
+		return func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }, true
+	}
+	return nil, false
+}
+
+// curveRange returns the first and last day of a project's S-curve, covering
+// the planned dates and every recorded snapshot
+func curveRange(project *models.Project, snapshots []models.ProgressSnapshot) (time.Time, time.Time) {
+	start, end := project.StartDate, project.EndDate
+	if len(snapshots) > 0 {
+		first := snapshots[0].CreatedAt
+		last := snapshots[len(snapshots)-1].CreatedAt
+		if start.IsZero() || first.Before(start) {
+			start = first
+		}
+		if end.IsZero() || last.After(end) {
+			end = last
+		}
+	}
+	if start.IsZero() {
+		return start, end
+	}
+	if end.Before(start) {
+		end = start
+	}
+
+	day := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return day(start), day(end)
+}
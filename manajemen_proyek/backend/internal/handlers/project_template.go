@@ -0,0 +1,574 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/budget"
+	"github.com/unipro/project-management/pkg/listquery"
+	"gorm.io/gorm"
+)
+
+// SaveTemplateRequest represents the request to save a project as template
+type SaveTemplateRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	IncludeTeam bool   `json:"include_team"`
+}
+
+// NewProjectFromTemplateRequest represents the request to create a project
+// from a template or an existing project. Quantities and budget amounts are
+// multiplied by ScaleFactor; materials are priced at their current price.
+type NewProjectFromTemplateRequest struct {
+	Name          string             `json:"name" binding:"required"`
+	Description   string             `json:"description"`
+	Customer      string             `json:"customer"`
+	City          string             `json:"city"`
+	Address       string             `json:"address"`
+	ProjectType   models.ProjectType `json:"project_type"`   // Defaults to the template's type
+	EstimatedCost *float64           `json:"estimated_cost"` // Defaults to the re-priced template budget
+	StartDate     string             `json:"start_date" binding:"required"`
+	EndDate       string             `json:"end_date"` // Defaults to start date plus the template duration
+	Deadline      string             `json:"deadline"`
+	ManagerID     uint               `json:"manager_id"`
+	ScaleFactor   float64            `json:"scale_factor"` // Defaults to 1
+	IncludeTeam   bool               `json:"include_team"`
+}
+
+type ProjectTemplateHandler struct {
+	DB *gorm.DB
+}
+
+// NewProjectTemplateHandler creates a new project template handler
+func NewProjectTemplateHandler(db *gorm.DB) *ProjectTemplateHandler {
+	return &ProjectTemplateHandler{DB: db}
+}
+
+// projectTemplateListSpec whitelists the filters and sort keys of the
+// project template list
+var projectTemplateListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"project_type": {Column: "project_type"},
+		"created_by":   {Column: "created_by", Type: listquery.Number},
+		"budget":       {Column: "budget", Op: listquery.Range, Type: listquery.Number},
+	},
+	Search: []string{"name", "description"},
+	Sorts: map[string]string{
+		"name":          "name",
+		"budget":        "budget",
+		"duration_days": "duration_days",
+		"created_at":    "created_at",
+	},
+	DefaultSort: "name",
+}
+
+// GetProjectTemplates returns a page of project templates
+func (h *ProjectTemplateHandler) GetProjectTemplates(c *gin.Context) {
+	var templates []models.ProjectTemplate
+	page, ok := findList(c, h.DB.WithContext(c).Preload("Creator"), projectTemplateListSpec, &templates, "Failed to fetch project templates")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(templates, page))
+}
+
+// GetProjectTemplateByID returns a project template with its contents
+func (h *ProjectTemplateHandler) GetProjectTemplateByID(c *gin.Context) {
+	template, ok := h.findTemplate(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": template})
+}
+
+// SaveProjectAsTemplate saves the phases, BOM, budget structure and
+// optionally the team of a project as a new template
+func (h *ProjectTemplateHandler) SaveProjectAsTemplate(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var req SaveTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	template, err := snapshotProject(h.DB.WithContext(c), &project, req.IncludeTeam)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read project",
+			"message": err.Error(),
+		})
+		return
+	}
+	template.Name = req.Name
+	template.Description = req.Description
+	template.CreatedBy = middleware.GetUserID(c)
+
+	if err := h.DB.WithContext(c).Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save project template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Project template saved successfully",
+		"data":    template,
+	})
+}
+
+// DeleteProjectTemplate deletes a project template. Projects created from it
+// are not affected.
+func (h *ProjectTemplateHandler) DeleteProjectTemplate(c *gin.Context) {
+	result := h.DB.WithContext(c).Delete(&models.ProjectTemplate{}, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project template"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project template deleted successfully"})
+}
+
+// CreateProjectFromTemplate creates a new project from a stored template
+func (h *ProjectTemplateHandler) CreateProjectFromTemplate(c *gin.Context) {
+	template, ok := h.findTemplate(c)
+	if !ok {
+		return
+	}
+
+	h.createProject(c, &template)
+}
+
+// CloneProject creates a new project from an existing project, as if it had
+// been saved as a template first
+func (h *ProjectTemplateHandler) CloneProject(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	// The team is always read so include_team decides at creation time
+	template, err := snapshotProject(h.DB.WithContext(c), &project, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read project",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.createProject(c, &template)
+}
+
+// createProject binds the request and creates the project from the template
+func (h *ProjectTemplateHandler) createProject(c *gin.Context, template *models.ProjectTemplate) {
+	var req NewProjectFromTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if req.ScaleFactor == 0 {
+		req.ScaleFactor = 1
+	}
+	if req.ScaleFactor < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Scale factor must be positive"})
+		return
+	}
+	if req.EstimatedCost != nil && *req.EstimatedCost < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Estimated cost cannot be negative"})
+		return
+	}
+
+	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid start date format",
+			"message": "Please use YYYY-MM-DD format",
+		})
+		return
+	}
+	endDate := startDate.AddDate(0, 0, template.DurationDays)
+	if req.EndDate != "" {
+		if endDate, err = time.Parse("2006-01-02", req.EndDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid end date format",
+				"message": "Please use YYYY-MM-DD format",
+			})
+			return
+		}
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "End date cannot be before start date"})
+		return
+	}
+	deadline, err := parseOptionalDate(req.Deadline)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid deadline format",
+			"message": "Please use YYYY-MM-DD format",
+		})
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	managerID := req.ManagerID
+	if managerID == 0 {
+		managerID = userID
+	}
+
+	projectType := req.ProjectType
+	if projectType == "" {
+		projectType = template.ProjectType
+	}
+
+	project := models.Project{
+		Name:        req.Name,
+		Description: req.Description,
+		Customer:    req.Customer,
+		City:        req.City,
+		Address:     req.Address,
+		ProjectType: projectType,
+		Status:      models.StatusOnTrack,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Deadline:    deadline,
+		ManagerID:   managerID,
+	}
+
+	var warnings []string
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		warnings, err = instantiateTemplate(tx, template, &project, req, userID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create project",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Manager").Preload("Phases", preloadPhases).First(&project, project.ID)
+
+	response := gin.H{
+		"message": "Project created successfully",
+		"project": project,
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// findTemplate loads the :id template with its contents
+func (h *ProjectTemplateHandler) findTemplate(c *gin.Context) (models.ProjectTemplate, bool) {
+	var template models.ProjectTemplate
+
+	templateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return template, false
+	}
+
+	if err := h.DB.WithContext(c).Preload("Creator").
+		Preload("Phases", preloadPhases).
+		Preload("BOMItems").Preload("BOMItems.Material").
+		Preload("BudgetLines").Preload("BudgetLines.CostCode").
+		Preload("Members").Preload("Members.User").
+		First(&template, templateID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project template not found"})
+		return template, false
+	}
+	return template, true
+}
+
+// snapshotProject builds an unsaved template from a project. Phase dates are
+// kept as a share of the project duration and BOM lines keep the cost code of
+// their budget line.
+func snapshotProject(db *gorm.DB, project *models.Project, includeTeam bool) (models.ProjectTemplate, error) {
+	template := models.ProjectTemplate{
+		ProjectType: project.ProjectType,
+		Budget:      project.EstimatedCost,
+	}
+	sourceID := project.ID
+	template.SourceProjectID = &sourceID
+
+	duration := 0.0
+	if !project.StartDate.IsZero() && project.EndDate.After(project.StartDate) {
+		duration = project.EndDate.Sub(project.StartDate).Hours() / 24
+		template.DurationDays = int(math.Round(duration))
+	}
+
+	var phases []models.ProjectPhase
+	if err := preloadPhases(db).Where("project_id = ?", project.ID).Find(&phases).Error; err != nil {
+		return template, err
+	}
+	phaseNo := map[uint]int{}
+	for i, phase := range phases {
+		phaseNo[phase.ID] = i + 1
+		templatePhase := models.TemplatePhase{
+			Name:      phase.Name,
+			SortOrder: phase.SortOrder,
+			Weight:    phase.Weight,
+			StartPct:  0,
+			EndPct:    100,
+		}
+		if duration > 0 && phase.PlannedStart != nil && phase.PlannedEnd != nil {
+			templatePhase.StartPct = durationPct(project.StartDate, *phase.PlannedStart, duration)
+			templatePhase.EndPct = durationPct(project.StartDate, *phase.PlannedEnd, duration)
+		}
+		template.Phases = append(template.Phases, templatePhase)
+	}
+	lookupPhase := func(id *uint) int {
+		if id == nil {
+			return 0
+		}
+		return phaseNo[*id]
+	}
+
+	var lines []models.BudgetLine
+	if err := db.Where("project_id = ?", project.ID).Order("id ASC").Find(&lines).Error; err != nil {
+		return template, err
+	}
+	bomCodes := map[uint]uint{}
+	itemised := 0.0
+	for _, line := range lines {
+		itemised += line.Amount
+		if line.IsFromBOM() {
+			bomCodes[*line.BOMID] = line.CostCodeID
+			continue
+		}
+		template.BudgetLines = append(template.BudgetLines, models.TemplateBudgetLine{
+			CostCodeID:  line.CostCodeID,
+			PhaseNo:     lookupPhase(line.PhaseID),
+			Description: line.Description,
+			Quantity:    line.Quantity,
+			Unit:        line.Unit,
+			UnitPrice:   line.UnitPrice,
+			Amount:      line.Amount,
+			Notes:       line.Notes,
+		})
+	}
+	template.UnallocatedBudget = math.Max(0, math.Round((project.EstimatedCost-itemised)*100)/100)
+
+	var boms []models.BOM
+	if err := db.Where("project_id = ?", project.ID).Order("id ASC").Find(&boms).Error; err != nil {
+		return template, err
+	}
+	for _, bom := range boms {
+		item := models.TemplateBOMItem{
+			MaterialID: bom.MaterialID,
+			PlannedQty: bom.PlannedQty,
+			PhaseNo:    lookupPhase(bom.PhaseID),
+			Notes:      bom.Notes,
+		}
+		if code, ok := bomCodes[bom.ID]; ok {
+			item.CostCodeID = &code
+		}
+		template.BOMItems = append(template.BOMItems, item)
+	}
+
+	if includeTeam {
+		var members []models.ProjectMember
+		if err := db.Where("project_id = ?", project.ID).Order("created_at ASC").Find(&members).Error; err != nil {
+			return template, err
+		}
+		for _, member := range members {
+			template.Members = append(template.Members, models.TemplateMember{
+				UserID:      member.UserID,
+				ProjectRole: member.ProjectRole,
+			})
+		}
+	}
+
+	return template, nil
+}
+
+// instantiateTemplate creates the project and its phases, BOM, budget lines
+// and team from a template. Materials that are no longer available and team
+// members that are no longer active are skipped with a warning.
+func instantiateTemplate(tx *gorm.DB, template *models.ProjectTemplate, project *models.Project, req NewProjectFromTemplateRequest, userID uint) ([]string, error) {
+	var warnings []string
+	scale := req.ScaleFactor
+	round := func(value float64) float64 {
+		return math.Round(value*100) / 100
+	}
+
+	// Re-price the BOM at current material prices
+	materials := map[uint]models.Material{}
+	var boms []models.BOM
+	var bomCodes []*uint
+	var bomPhases []int
+	itemised := 0.0
+	for _, item := range template.BOMItems {
+		material, ok := materials[item.MaterialID]
+		if !ok {
+			if err := tx.First(&material, item.MaterialID).Error; err != nil {
+				warnings = append(warnings, fmt.Sprintf("Material ID %d is no longer available", item.MaterialID))
+				continue
+			}
+			materials[item.MaterialID] = material
+		}
+
+		qty := round(item.PlannedQty * scale)
+		bom := models.BOM{
+			MaterialID:    item.MaterialID,
+			PlannedQty:    qty,
+			RemainingQty:  qty,
+			EstimatedCost: round(qty * material.UnitPrice),
+			Notes:         item.Notes,
+		}
+		itemised += bom.EstimatedCost
+		boms = append(boms, bom)
+		bomCodes = append(bomCodes, item.CostCodeID)
+		bomPhases = append(bomPhases, item.PhaseNo)
+	}
+
+	lines := make([]models.BudgetLine, 0, len(template.BudgetLines))
+	linePhases := make([]int, 0, len(template.BudgetLines))
+	for _, source := range template.BudgetLines {
+		line := models.BudgetLine{
+			CostCodeID:  source.CostCodeID,
+			Description: source.Description,
+			Quantity:    round(source.Quantity * scale),
+			Unit:        source.Unit,
+			UnitPrice:   source.UnitPrice,
+			Amount:      round(source.Amount * scale),
+			Notes:       source.Notes,
+		}
+		line.Calculate()
+		itemised += line.Amount
+		lines = append(lines, line)
+		linePhases = append(linePhases, source.PhaseNo)
+	}
+
+	project.EstimatedCost = round(itemised + template.UnallocatedBudget*scale)
+	if req.EstimatedCost != nil {
+		project.EstimatedCost = *req.EstimatedCost
+	}
+	project.OriginalBudget = project.EstimatedCost
+
+	if err := tx.Create(project).Error; err != nil {
+		return warnings, err
+	}
+
+	// Phases, or the project type's defaults when the template has none
+	phaseIDs := make([]*uint, len(template.Phases)+1)
+	if len(template.Phases) == 0 {
+		if err := seedProjectPhases(tx, project); err != nil {
+			return warnings, err
+		}
+	}
+	for i, templatePhase := range template.Phases {
+		phase := templatePhase.NewPhase(project.ID, project.StartDate, project.EndDate)
+		if err := tx.Create(&phase).Error; err != nil {
+			return warnings, err
+		}
+		phaseIDs[i+1] = &phase.ID
+	}
+	phaseID := func(no int) *uint {
+		if no <= 0 || no >= len(phaseIDs) {
+			return nil
+		}
+		return phaseIDs[no]
+	}
+
+	for i := range boms {
+		bom := &boms[i]
+		bom.ProjectID = project.ID
+		bom.PhaseID = phaseID(bomPhases[i])
+		if err := tx.Create(bom).Error; err != nil {
+			return warnings, err
+		}
+		if err := budget.SyncBOM(tx, bom); err != nil {
+			return warnings, err
+		}
+		if code := bomCodes[i]; code != nil {
+			if err := tx.Model(&models.BudgetLine{}).Where("bom_id = ?", bom.ID).Update("cost_code_id", *code).Error; err != nil {
+				return warnings, err
+			}
+		}
+	}
+
+	for i := range lines {
+		line := &lines[i]
+		line.ProjectID = project.ID
+		line.PhaseID = phaseID(linePhases[i])
+		if err := tx.Create(line).Error; err != nil {
+			return warnings, err
+		}
+	}
+
+	if req.IncludeTeam {
+		for _, templateMember := range template.Members {
+			if templateMember.UserID == project.ManagerID {
+				continue
+			}
+			var user models.User
+			if err := tx.First(&user, templateMember.UserID).Error; err != nil || !user.IsActive {
+				warnings = append(warnings, fmt.Sprintf("User ID %d is no longer active and was not added to the team", templateMember.UserID))
+				continue
+			}
+			member := models.ProjectMember{
+				ProjectID:   project.ID,
+				UserID:      templateMember.UserID,
+				ProjectRole: templateMember.ProjectRole,
+				AddedBy:     userID,
+			}
+			if err := tx.Create(&member).Error; err != nil {
+				return warnings, err
+			}
+		}
+	}
+
+	// Baseline for the progress history
+	if _, err := recalculateProgress(tx, project.ID, userID); err != nil {
+		return warnings, err
+	}
+	return warnings, nil
+}
+
+// durationPct returns the position of t within a project starting at start
+// as a share of its duration in days, between 0 and 100
+func durationPct(start, t time.Time, duration float64) float64 {
+	pct := t.Sub(start).Hours() / 24 / duration * 100
+	return math.Round(math.Min(100, math.Max(0, pct))*100) / 100
+}
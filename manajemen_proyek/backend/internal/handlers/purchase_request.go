@@ -7,7 +7,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/costledger"
 	"github.com/unipro/project-management/pkg/database"
+	"github.com/unipro/project-management/pkg/listquery"
 )
 
 // CreatePurchaseRequest creates a new purchase request
@@ -34,6 +36,10 @@ func CreatePurchaseRequest(c *gin.Context) {
 		return
 	}
 
+	if !requireProjectAccess(database.DB.WithContext(c), c, input.ProjectID, accessWrite) {
+		return
+	}
+
 	// Get requester ID from context
 	requesterID, _ := c.Get("user_id")
 
@@ -65,7 +71,7 @@ func CreatePurchaseRequest(c *gin.Context) {
 	}
 
 	// Start transaction
-	tx := database.DB.Begin()
+	tx := database.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -124,31 +130,66 @@ func CreatePurchaseRequest(c *gin.Context) {
 	}
 
 	// Load relations
-	database.DB.Preload("Project").Preload("Requester").Preload("Items.Material").
-		Preload("ApprovalHistory.Approver").Preload("Comments.User").First(&pr, pr.ID)
+	database.DB.WithContext(c).Preload("Project").Preload("Requester").Preload("Items.Material").
+		Preload("ApprovalHistory.Approver").Preload("ApprovalHistory.OnBehalfOf").Preload("Comments.User").First(&pr, pr.ID)
 
 	// Create notification for Purchasing department
-	go createPRNotification(pr.ID, models.StagePurchasing, pr.Title, requesterID.(uint))
+	go createPRNotification(pr.ID, models.StagePurchasing, pr.Title, requesterID.(uint), pr.TotalAmount)
 
 	c.JSON(http.StatusCreated, gin.H{"data": pr})
 }
 
-// GetPurchaseRequests returns all purchase requests with optional filters
+// purchaseRequestListSpec whitelists the filters and sort keys of the
+// purchase request list
+var purchaseRequestListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"project_id":    {Column: "project_id", Type: listquery.Number},
+		"requester_id":  {Column: "requester_id", Type: listquery.Number},
+		"status":        {Column: "status"},
+		"priority":      {Column: "priority"},
+		"current_stage": {Column: "current_stage"},
+		"total_amount":  {Column: "total_amount", Op: listquery.Range, Type: listquery.Number},
+		"required_date": {Column: "required_date", Op: listquery.Range, Type: listquery.Date},
+		"created_at":    {Column: "created_at", Op: listquery.Range, Type: listquery.Date},
+	},
+	Search: []string{"pr_number", "title"},
+	Sorts: map[string]string{
+		"pr_number":     "pr_number",
+		"title":         "title",
+		"priority":      "priority",
+		"status":        "status",
+		"total_amount":  "total_amount",
+		"required_date": "required_date",
+		"created_at":    "created_at",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetPurchaseRequests returns a page of purchase requests with optional filters
 func GetPurchaseRequests(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	userRole, _ := c.Get("role")
 	filter := c.Query("filter") // "all", "my_requests", "pending_approval", "approved", "rejected"
 
-	query := database.DB.Preload("Project").Preload("Requester").Preload("Items.Material").
-		Preload("ApprovalHistory.Approver").Preload("Comments.User")
+	query := database.DB.WithContext(c).Preload("Project").Preload("Requester").Preload("Items.Material").
+		Preload("ApprovalHistory.Approver").Preload("ApprovalHistory.OnBehalfOf").Preload("Comments.User").
+		Scopes(scopeAccessibleProjects(database.DB.WithContext(c), c, "purchase_requests.project_id"))
 
 	// Apply filters based on role and filter parameter
 	switch filter {
 	case "my_requests":
 		query = query.Where("requester_id = ?", userID)
 	case "pending_approval":
-		// Show PRs pending at the user's role stage
-		query = query.Where("current_stage = ? AND status = ?", userRole, models.PRStatusPending)
+		// Show PRs pending at the user's role stage and at stages delegated
+		// to the user
+		roleName, _ := userRole.(string)
+		stages := append(delegatedStages(database.DB.WithContext(c), userID.(uint), models.DelegationScopePurchaseRequests), models.ApprovalStage(roleName))
+		for stage, role := range models.StageRoles {
+			if role == roleName {
+				stages = append(stages, stage)
+			}
+		}
+		query = query.Where("current_stage IN ? AND status = ?", stages, models.PRStatusPending)
 	case "approved":
 		query = query.Where("status = ?", models.PRStatusApproved)
 	case "rejected":
@@ -156,12 +197,12 @@ func GetPurchaseRequests(c *gin.Context) {
 	}
 
 	var prs []models.PurchaseRequest
-	if err := query.Order("created_at DESC").Find(&prs).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch purchase requests"})
+	page, ok := findList(c, query, purchaseRequestListSpec, &prs, "Failed to fetch purchase requests")
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": prs})
+	c.JSON(http.StatusOK, listResponse(prs, page))
 }
 
 // GetPurchaseRequestByID returns a single purchase request by ID
@@ -169,13 +210,17 @@ func GetPurchaseRequestByID(c *gin.Context) {
 	id := c.Param("id")
 
 	var pr models.PurchaseRequest
-	if err := database.DB.Preload("Project").Preload("Requester").Preload("Items.Material").
-		Preload("ApprovalHistory.Approver").Preload("Comments.User").
+	if err := database.DB.WithContext(c).Preload("Project").Preload("Requester").Preload("Items.Material").
+		Preload("ApprovalHistory.Approver").Preload("ApprovalHistory.OnBehalfOf").Preload("Comments.User").
 		First(&pr, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase request not found"})
 		return
 	}
 
+	if !requireProjectAccess(database.DB.WithContext(c), c, pr.ProjectID, accessRead) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": pr})
 }
 
@@ -183,7 +228,6 @@ func GetPurchaseRequestByID(c *gin.Context) {
 func ApprovePurchaseRequest(c *gin.Context) {
 	id := c.Param("id")
 	userID, _ := c.Get("user_id")
-	userRole, _ := c.Get("role")
 
 	var input struct {
 		Stage   string `json:"stage" binding:"required"`
@@ -195,15 +239,21 @@ func ApprovePurchaseRequest(c *gin.Context) {
 		return
 	}
 
-	// Verify user role matches the approval stage
-	if input.Stage != userRole.(string) {
+	var pr models.PurchaseRequest
+	if err := database.DB.WithContext(c).First(&pr, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase request not found"})
+		return
+	}
+
+	// Verify user role matches the approval stage, or that the user holds a
+	// delegation from someone who does
+	delegation, ok := authorizeStage(c, &pr, models.ApprovalStage(input.Stage))
+	if !ok {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to approve at this stage"})
 		return
 	}
 
-	var pr models.PurchaseRequest
-	if err := database.DB.First(&pr, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase request not found"})
+	if delegation == nil && !requireProjectAccess(database.DB.WithContext(c), c, pr.ProjectID, accessRead) {
 		return
 	}
 
@@ -219,7 +269,7 @@ func ApprovePurchaseRequest(c *gin.Context) {
 	}
 
 	// Start transaction
-	tx := database.DB.Begin()
+	tx := database.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -229,14 +279,19 @@ func ApprovePurchaseRequest(c *gin.Context) {
 	// Update approval history
 	now := time.Now()
 	approverIDVal := userID.(uint)
+	updates := map[string]interface{}{
+		"status":      models.StageStatusApproved,
+		"approver_id": approverIDVal,
+		"comment":     input.Comment,
+		"approved_at": now,
+	}
+	if delegation != nil {
+		updates["on_behalf_of_id"] = delegation.DelegatorID
+		updates["delegation_id"] = delegation.ID
+	}
 	if err := tx.Model(&models.ApprovalHistory{}).
 		Where("purchase_request_id = ? AND stage = ?", pr.ID, input.Stage).
-		Updates(map[string]interface{}{
-			"status":      models.StageStatusApproved,
-			"approver_id": approverIDVal,
-			"comment":     input.Comment,
-			"approved_at": now,
-		}).Error; err != nil {
+		Updates(updates).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update approval history"})
 		return
@@ -254,7 +309,7 @@ func ApprovePurchaseRequest(c *gin.Context) {
 		}
 
 		// Notify next approver
-		go createPRNotification(pr.ID, *nextStage, pr.Title, pr.RequesterID)
+		go createPRNotification(pr.ID, *nextStage, pr.Title, pr.RequesterID, pr.TotalAmount)
 	} else {
 		// Final approval - mark as approved
 		pr.Status = models.PRStatusApproved
@@ -264,6 +319,13 @@ func ApprovePurchaseRequest(c *gin.Context) {
 			return
 		}
 
+		// Approved spending counts toward the project's actual cost
+		if err := costledger.Sync(tx, costledger.PurchaseRequestEntry(pr, now)); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project cost"})
+			return
+		}
+
 		// Notify requester
 		go notifyRequester(pr.ID, pr.RequesterID, pr.Title, "approved")
 	}
@@ -275,8 +337,8 @@ func ApprovePurchaseRequest(c *gin.Context) {
 	}
 
 	// Reload PR with relations
-	database.DB.Preload("Project").Preload("Requester").Preload("Items.Material").
-		Preload("ApprovalHistory.Approver").Preload("Comments.User").First(&pr, pr.ID)
+	database.DB.WithContext(c).Preload("Project").Preload("Requester").Preload("Items.Material").
+		Preload("ApprovalHistory.Approver").Preload("ApprovalHistory.OnBehalfOf").Preload("Comments.User").First(&pr, pr.ID)
 
 	c.JSON(http.StatusOK, gin.H{"data": pr})
 }
@@ -285,7 +347,6 @@ func ApprovePurchaseRequest(c *gin.Context) {
 func RejectPurchaseRequest(c *gin.Context) {
 	id := c.Param("id")
 	userID, _ := c.Get("user_id")
-	userRole, _ := c.Get("role")
 
 	var input struct {
 		Stage  string `json:"stage" binding:"required"`
@@ -297,15 +358,21 @@ func RejectPurchaseRequest(c *gin.Context) {
 		return
 	}
 
-	// Verify user role matches the approval stage
-	if input.Stage != userRole.(string) {
+	var pr models.PurchaseRequest
+	if err := database.DB.WithContext(c).First(&pr, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase request not found"})
+		return
+	}
+
+	// Verify user role matches the approval stage, or that the user holds a
+	// delegation from someone who does
+	delegation, ok := authorizeStage(c, &pr, models.ApprovalStage(input.Stage))
+	if !ok {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to reject at this stage"})
 		return
 	}
 
-	var pr models.PurchaseRequest
-	if err := database.DB.First(&pr, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase request not found"})
+	if delegation == nil && !requireProjectAccess(database.DB.WithContext(c), c, pr.ProjectID, accessRead) {
 		return
 	}
 
@@ -321,7 +388,7 @@ func RejectPurchaseRequest(c *gin.Context) {
 	}
 
 	// Start transaction
-	tx := database.DB.Begin()
+	tx := database.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -331,14 +398,19 @@ func RejectPurchaseRequest(c *gin.Context) {
 	// Update approval history
 	now := time.Now()
 	approverIDVal := userID.(uint)
+	updates := map[string]interface{}{
+		"status":      models.StageStatusRejected,
+		"approver_id": approverIDVal,
+		"comment":     input.Reason,
+		"approved_at": now,
+	}
+	if delegation != nil {
+		updates["on_behalf_of_id"] = delegation.DelegatorID
+		updates["delegation_id"] = delegation.ID
+	}
 	if err := tx.Model(&models.ApprovalHistory{}).
 		Where("purchase_request_id = ? AND stage = ?", pr.ID, input.Stage).
-		Updates(map[string]interface{}{
-			"status":      models.StageStatusRejected,
-			"approver_id": approverIDVal,
-			"comment":     input.Reason,
-			"approved_at": now,
-		}).Error; err != nil {
+		Updates(updates).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update approval history"})
 		return
@@ -362,8 +434,8 @@ func RejectPurchaseRequest(c *gin.Context) {
 	go notifyRequester(pr.ID, pr.RequesterID, pr.Title, "rejected")
 
 	// Reload PR with relations
-	database.DB.Preload("Project").Preload("Requester").Preload("Items.Material").
-		Preload("ApprovalHistory.Approver").Preload("Comments.User").First(&pr, pr.ID)
+	database.DB.WithContext(c).Preload("Project").Preload("Requester").Preload("Items.Material").
+		Preload("ApprovalHistory.Approver").Preload("ApprovalHistory.OnBehalfOf").Preload("Comments.User").First(&pr, pr.ID)
 
 	c.JSON(http.StatusOK, gin.H{"data": pr})
 }
@@ -384,11 +456,15 @@ func AddPRComment(c *gin.Context) {
 
 	// Verify PR exists
 	var pr models.PurchaseRequest
-	if err := database.DB.First(&pr, id).Error; err != nil {
+	if err := database.DB.WithContext(c).First(&pr, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase request not found"})
 		return
 	}
 
+	if !requireProjectAccess(database.DB.WithContext(c), c, pr.ProjectID, accessRead) {
+		return
+	}
+
 	// Create comment
 	comment := models.PRComment{
 		PurchaseRequestID: pr.ID,
@@ -396,17 +472,31 @@ func AddPRComment(c *gin.Context) {
 		Comment:           input.Comment,
 	}
 
-	if err := database.DB.Create(&comment).Error; err != nil {
+	if err := database.DB.WithContext(c).Create(&comment).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add comment"})
 		return
 	}
 
 	// Load user relation
-	database.DB.Preload("User").First(&comment, comment.ID)
+	database.DB.WithContext(c).Preload("User").First(&comment, comment.ID)
 
 	c.JSON(http.StatusCreated, gin.H{"data": comment})
 }
 
+// authorizeStage checks whether the current user may act at a purchase
+// request stage. Users holding the stage role act directly; anyone else needs
+// an active delegation covering the request, which is returned.
+func authorizeStage(c *gin.Context, pr *models.PurchaseRequest, stage models.ApprovalStage) (*models.Delegation, bool) {
+	userRole, _ := c.Get("role")
+	if role, _ := userRole.(string); role == stage.ApproverRole() || role == string(stage) {
+		return nil, true
+	}
+
+	userID, _ := c.Get("user_id")
+	delegation := findStageDelegation(database.DB.WithContext(c), userID.(uint), stage, models.DelegationScopePurchaseRequests, pr.TotalAmount)
+	return delegation, delegation != nil
+}
+
 // Helper function to generate PR number
 func generatePRNumber() (string, error) {
 	year := time.Now().Year()
@@ -421,20 +511,28 @@ func generatePRNumber() (string, error) {
 }
 
 // Helper function to create PR notification
-func createPRNotification(prID uint, stage models.ApprovalStage, title string, requesterID uint) {
+func createPRNotification(prID uint, stage models.ApprovalStage, title string, requesterID uint, amount float64) {
 	// Get users with the role matching the stage
 	var users []models.User
 	database.DB.Joins("JOIN roles ON users.role_id = roles.id").
-		Where("roles.name = ?", stage).Find(&users)
+		Where("roles.name = ? AND users.is_active = ?", stage.ApproverRole(), true).Find(&users)
 
 	// Get requester name
 	var requester models.User
 	database.DB.First(&requester, requesterID)
 
-	// Create notifications for all users in that role
+	// Route to delegates of approvers who are away
+	recipients := map[uint]bool{}
 	for _, user := range users {
+		for _, recipientID := range notificationRecipients(database.DB, user.ID, models.DelegationScopePurchaseRequests, &amount) {
+			recipients[recipientID] = true
+		}
+	}
+
+	// Create notifications for all users in that role
+	for recipientID := range recipients {
 		notification := models.Notification{
-			UserID:    user.ID,
+			UserID:    recipientID,
 			Title:     fmt.Sprintf("Purchase Request Baru: %s", title),
 			Message:   fmt.Sprintf("Dari: %s. Menunggu approval %s.", requester.Name, stage),
 			Type:      models.NotificationTypeApprovalRequest,
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/recyclebin"
+	"gorm.io/gorm"
+)
+
+// RecycleBinSettingsRequest represents recycle bin settings request body
+type RecycleBinSettingsRequest struct {
+	RetentionDays int `json:"retention_days"`
+}
+
+type RecycleBinHandler struct {
+	DB *gorm.DB
+}
+
+// NewRecycleBinHandler creates a new recycle bin handler
+func NewRecycleBinHandler(db *gorm.DB) *RecycleBinHandler {
+	return &RecycleBinHandler{DB: db}
+}
+
+// GetRecycleBin lists deleted records, optionally of one type, most recent
+// first. Project data is limited to the projects the user can access.
+func (h *RecycleBinHandler) GetRecycleBin(c *gin.Context) {
+	kinds := recyclebin.Kinds
+	if name := c.Query("type"); name != "" {
+		kind, ok := recyclebin.FindKind(name)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type. Use projects, daily_reports, documents, risks, boms, materials or subcontractors"})
+			return
+		}
+		kinds = []recyclebin.Kind{kind}
+	}
+
+	settings := models.LoadRecycleBinSettings(h.DB.WithContext(c))
+	items := []recyclebin.Item{}
+	for _, kind := range kinds {
+		var scope func(*gorm.DB) *gorm.DB
+		if kind.ProjectKey != "" {
+			table := kind.Name
+			scope = scopeAccessibleProjects(h.DB.WithContext(c).Unscoped(), c, table+"."+kind.ProjectKey)
+		}
+
+		found, err := recyclebin.List(h.DB.WithContext(c), kind, settings.RetentionDays, scope)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recycle bin"})
+			return
+		}
+		items = append(items, found...)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":           items,
+		"total":          len(items),
+		"retention_days": settings.RetentionDays,
+	})
+}
+
+// RestoreRecord restores a deleted record together with the dependents
+// deleted with it
+func (h *RecycleBinHandler) RestoreRecord(c *gin.Context) {
+	kind, id, ok := h.parseRecord(c)
+	if !ok {
+		return
+	}
+
+	var restored int64
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if restored, err = recyclebin.Restore(tx, kind, id); err != nil {
+			return err
+		}
+		return h.afterRestore(c, tx, kind, id)
+	})
+	if err != nil {
+		h.respondError(c, "Failed to restore record", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Record restored successfully",
+		"restored": restored,
+	})
+}
+
+// PurgeRecord permanently deletes a record in the recycle bin together with
+// everything that belongs to it
+func (h *RecycleBinHandler) PurgeRecord(c *gin.Context) {
+	kind, id, ok := h.parseRecord(c)
+	if !ok {
+		return
+	}
+
+	var files []string
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		files, err = recyclebin.Purge(tx, kind, id)
+		return err
+	})
+	if err != nil {
+		h.respondError(c, "Failed to purge record", err)
+		return
+	}
+	recyclebin.RemoveFiles(files)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Record purged permanently"})
+}
+
+// PurgeExpired purges every record older than the retention period. The
+// server does this daily on its own; this runs it on demand.
+func (h *RecycleBinHandler) PurgeExpired(c *gin.Context) {
+	settings := models.LoadRecycleBinSettings(h.DB.WithContext(c))
+	purged, err := recyclebin.PurgeExpired(h.DB.WithContext(c), settings.RetentionDays)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to purge expired records",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Expired records purged",
+		"purged":  purged,
+	})
+}
+
+// GetRecycleBinSettings returns the retention period of deleted records
+func (h *RecycleBinHandler) GetRecycleBinSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": models.LoadRecycleBinSettings(h.DB.WithContext(c))})
+}
+
+// UpdateRecycleBinSettings changes the retention period of deleted records.
+// Zero keeps them until purged by hand.
+func (h *RecycleBinHandler) UpdateRecycleBinSettings(c *gin.Context) {
+	var req RecycleBinSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if req.RetentionDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Retention days must not be negative"})
+		return
+	}
+
+	settings := models.LoadRecycleBinSettings(h.DB.WithContext(c))
+	userID := middleware.GetUserID(c)
+	settings.RetentionDays = req.RetentionDays
+	settings.UpdatedBy = &userID
+
+	if err := h.DB.WithContext(c).Save(&settings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recycle bin settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Recycle bin settings updated successfully",
+		"data":    settings,
+	})
+}
+
+// parseRecord reads the :type and :id params and checks write access to the
+// record's project, which may itself be deleted
+func (h *RecycleBinHandler) parseRecord(c *gin.Context) (recyclebin.Kind, uint, bool) {
+	kind, ok := recyclebin.FindKind(c.Param("type"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type. Use projects, daily_reports, documents, risks, boms, materials or subcontractors"})
+		return kind, 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record ID"})
+		return kind, 0, false
+	}
+
+	if kind.ProjectKey != "" {
+		var projectIDs []uint
+		if err := h.DB.WithContext(c).Unscoped().Table(kind.Name).Where("id = ?", id).
+			Pluck(kind.ProjectKey, &projectIDs).Error; err != nil || len(projectIDs) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+			return kind, 0, false
+		}
+		if !requireProjectAccess(h.DB.WithContext(c).Unscoped(), c, projectIDs[0], accessWrite) {
+			return kind, 0, false
+		}
+	}
+
+	return kind, uint(id), true
+}
+
+// afterRestore brings derived data back in line with a restored record
+func (h *RecycleBinHandler) afterRestore(c *gin.Context, tx *gorm.DB, kind recyclebin.Kind, id uint) error {
+	switch kind.Name {
+	case recyclebin.DailyReports.Name:
+		// Task progress may come from the restored report again
+		var report models.DailyReport
+		if err := tx.First(&report, id).Error; err != nil {
+			return err
+		}
+		var activities []models.DailyReportActivity
+		if err := tx.Where("daily_report_id = ?", id).Find(&activities).Error; err != nil {
+			return err
+		}
+		return syncFieldProgress(tx, report.ProjectID, activityTaskIDs(activities), middleware.GetUserID(c))
+	}
+	return nil
+}
+
+// respondError maps recycle bin errors to a response
+func (h *RecycleBinHandler) respondError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, recyclebin.ErrNotDeleted), errors.Is(err, recyclebin.ErrParentDeleted):
+		status = http.StatusBadRequest
+	case errors.Is(err, recyclebin.ErrConflict), errors.Is(err, recyclebin.ErrReferenced):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"error":   message,
+		"message": err.Error(),
+	})
+}
@@ -8,8 +8,11 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
 	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/listquery"
 	"github.com/unipro/project-management/pkg/pdf"
+	"github.com/unipro/project-management/pkg/recyclebin"
 	"gorm.io/gorm"
 )
 
@@ -39,6 +42,8 @@ func (h *ReportHandler) CreateDailyReport(c *gin.Context) {
 		Weather    models.WeatherCondition   `json:"weather"`
 		Workers    int                       `json:"workers"`
 		Notes      string                    `json:"notes"`
+		TaskActivities []TaskActivityRequest `json:"task_activities"`
+		Issues     []RiskRequest             `json:"issues"` // Raised into the project's issue register
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -46,6 +51,27 @@ func (h *ReportHandler) CreateDailyReport(c *gin.Context) {
 		return
 	}
 
+	if !requireProjectAccess(h.db.WithContext(c), c, input.ProjectID, accessWrite) {
+		return
+	}
+
+	activities, ok := taskActivitiesFromRequest(c, h.db.WithContext(c), input.ProjectID, input.TaskActivities)
+	if !ok {
+		return
+	}
+
+	if len(input.Issues) > 0 && !middleware.HasPermission(c, "risks", "write") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to raise issues"})
+		return
+	}
+	issues := make([]models.Risk, len(input.Issues))
+	for i, req := range input.Issues {
+		issues[i] = models.Risk{ProjectID: input.ProjectID, Type: models.RiskTypeIssue, RaisedBy: userID.(uint)}
+		if !applyRiskRequest(c, h.db.WithContext(c), &issues[i], req) {
+			return
+		}
+	}
+
 	// Parse date
 	date, err := time.Parse("2006-01-02", input.Date)
 	if err != nil {
@@ -62,47 +88,82 @@ func (h *ReportHandler) CreateDailyReport(c *gin.Context) {
 		Workers:    input.Workers,
 		Notes:      input.Notes,
 		ReportedBy: userID.(uint),
+		TaskActivities: activities,
 	}
 
-	if err := h.db.Create(&report).Error; err != nil {
+	// Task progress reported from the field updates the schedule
+	err = h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&report).Error; err != nil {
+			return err
+		}
+		for i := range issues {
+			issues[i].DailyReportID = &report.ID
+			if err := createRisk(tx, &issues[i]); err != nil {
+				return err
+			}
+		}
+		return syncFieldProgress(tx, report.ProjectID, activityTaskIDs(report.TaskActivities), userID.(uint))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create daily report"})
 		return
 	}
 
+	for i := range issues {
+		notifyRiskOwner(h.db.WithContext(c), &issues[i])
+	}
+
 	// Load relations
-	h.db.Preload("Project").Preload("Reporter").Preload("Photos").First(&report, report.ID)
+	h.db.WithContext(c).Preload("Project").Preload("Reporter").Preload("Photos").Preload("TaskActivities.Task").Preload("Issues.Owner").First(&report, report.ID)
 
 	c.JSON(http.StatusCreated, gin.H{"data": report})
 }
 
-// GetDailyReports returns all daily reports with optional filters
+// dailyReportListSpec whitelists the filters and sort keys of the daily
+// report list
+var dailyReportListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"project_id":  {Column: "project_id", Type: listquery.Number},
+		"reported_by": {Column: "reported_by", Type: listquery.Number},
+		"weather":     {Column: "weather"},
+		"date":        {Column: "date", Op: listquery.Range, Type: listquery.Date},
+		"progress":    {Column: "progress", Op: listquery.Range, Type: listquery.Number},
+		"workers":     {Column: "workers", Op: listquery.Range, Type: listquery.Number},
+	},
+	Search: []string{"activities", "notes"},
+	Sorts: map[string]string{
+		"date":       "date",
+		"progress":   "progress",
+		"workers":    "workers",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-date",
+}
+
+// GetDailyReports returns a page of daily reports with optional filters.
+// start_date and end_date are kept as aliases of date_from and date_to.
 func (h *ReportHandler) GetDailyReports(c *gin.Context) {
-	projectID := c.Query("project_id")
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
 
-	query := h.db.Preload("Project").Preload("Reporter").Preload("Photos")
-
-	// Filter by project
-	if projectID != "" {
-		query = query.Where("project_id = ?", projectID)
-	}
+	query := h.db.WithContext(c).Preload("Project").Preload("Reporter").Preload("Photos").Preload("TaskActivities.Task").
+		Scopes(scopeAccessibleProjects(h.db.WithContext(c), c, "daily_reports.project_id"))
 
 	// Filter by date range
 	if startDate != "" {
-		query = query.Where("date >= ?", startDate)
+		query = query.Where("daily_reports.date >= ?", startDate)
 	}
 	if endDate != "" {
-		query = query.Where("date <= ?", endDate)
+		query = query.Where("daily_reports.date <= ?", endDate)
 	}
 
 	var reports []models.DailyReport
-	if err := query.Order("date DESC").Find(&reports).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch daily reports"})
+	page, ok := findList(c, query, dailyReportListSpec, &reports, "Failed to fetch daily reports")
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": reports})
+	c.JSON(http.StatusOK, listResponse(reports, page))
 }
 
 // GetDailyReportByID returns a single daily report
@@ -110,7 +171,7 @@ func (h *ReportHandler) GetDailyReportByID(c *gin.Context) {
 	id := c.Param("id")
 
 	var report models.DailyReport
-	if err := h.db.Preload("Project").Preload("Reporter").Preload("Photos").First(&report, id).Error; err != nil {
+	if err := h.db.WithContext(c).Preload("Project").Preload("Reporter").Preload("Photos").Preload("TaskActivities.Task").Preload("Issues.Owner").First(&report, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Daily report not found"})
 			return
@@ -119,6 +180,10 @@ func (h *ReportHandler) GetDailyReportByID(c *gin.Context) {
 		return
 	}
 
+	if !requireProjectAccess(h.db.WithContext(c), c, report.ProjectID, accessRead) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": report})
 }
 
@@ -128,7 +193,7 @@ func (h *ReportHandler) UpdateDailyReport(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
 	var report models.DailyReport
-	if err := h.db.First(&report, id).Error; err != nil {
+	if err := h.db.WithContext(c).First(&report, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Daily report not found"})
 			return
@@ -143,12 +208,17 @@ func (h *ReportHandler) UpdateDailyReport(c *gin.Context) {
 		return
 	}
 
+	if !requireProjectAccess(h.db.WithContext(c), c, report.ProjectID, accessWrite) {
+		return
+	}
+
 	var input struct {
 		Activities string                    `json:"activities"`
 		Progress   float64                   `json:"progress"`
 		Weather    models.WeatherCondition   `json:"weather"`
 		Workers    int                       `json:"workers"`
 		Notes      string                    `json:"notes"`
+		TaskActivities []TaskActivityRequest `json:"task_activities"` // Replaces the reported task work when given
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -156,6 +226,11 @@ func (h *ReportHandler) UpdateDailyReport(c *gin.Context) {
 		return
 	}
 
+	activities, ok := taskActivitiesFromRequest(c, h.db.WithContext(c), report.ProjectID, input.TaskActivities)
+	if !ok {
+		return
+	}
+
 	// Update fields
 	report.Activities = input.Activities
 	report.Progress = input.Progress
@@ -163,13 +238,39 @@ func (h *ReportHandler) UpdateDailyReport(c *gin.Context) {
 	report.Workers = input.Workers
 	report.Notes = input.Notes
 
-	if err := h.db.Save(&report).Error; err != nil {
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&report).Error; err != nil {
+			return err
+		}
+		if input.TaskActivities == nil {
+			return nil
+		}
+
+		var previous []models.DailyReportActivity
+		if err := tx.Where("daily_report_id = ?", report.ID).Find(&previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("daily_report_id = ?", report.ID).Delete(&models.DailyReportActivity{}).Error; err != nil {
+			return err
+		}
+		for i := range activities {
+			activities[i].DailyReportID = report.ID
+		}
+		if len(activities) > 0 {
+			if err := tx.Create(&activities).Error; err != nil {
+				return err
+			}
+		}
+		taskIDs := append(activityTaskIDs(previous), activityTaskIDs(activities)...)
+		return syncFieldProgress(tx, report.ProjectID, taskIDs, userID.(uint))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update daily report"})
 		return
 	}
 
 	// Load relations
-	h.db.Preload("Project").Preload("Reporter").Preload("Photos").First(&report, report.ID)
+	h.db.WithContext(c).Preload("Project").Preload("Reporter").Preload("Photos").Preload("TaskActivities.Task").First(&report, report.ID)
 
 	c.JSON(http.StatusOK, gin.H{"data": report})
 }
@@ -180,7 +281,7 @@ func (h *ReportHandler) DeleteDailyReport(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
 	var report models.DailyReport
-	if err := h.db.First(&report, id).Error; err != nil {
+	if err := h.db.WithContext(c).First(&report, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Daily report not found"})
 			return
@@ -195,7 +296,22 @@ func (h *ReportHandler) DeleteDailyReport(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&report).Error; err != nil {
+	if !requireProjectAccess(h.db.WithContext(c), c, report.ProjectID, accessWrite) {
+		return
+	}
+
+	// Tasks fall back to the progress of their latest remaining report
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var activities []models.DailyReportActivity
+		if err := tx.Where("daily_report_id = ?", report.ID).Find(&activities).Error; err != nil {
+			return err
+		}
+		if err := recyclebin.Delete(tx, recyclebin.DailyReports, report.ID); err != nil {
+			return err
+		}
+		return syncFieldProgress(tx, report.ProjectID, activityTaskIDs(activities), userID.(uint))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete daily report"})
 		return
 	}
@@ -212,7 +328,7 @@ func (h *ReportHandler) UploadDailyReportPhotos(c *gin.Context) {
 
 	// Verify daily report exists
 	var report models.DailyReport
-	if err := h.db.First(&report, reportID).Error; err != nil {
+	if err := h.db.WithContext(c).First(&report, reportID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Daily report not found"})
 			return
@@ -221,6 +337,10 @@ func (h *ReportHandler) UploadDailyReportPhotos(c *gin.Context) {
 		return
 	}
 
+	if !requireProjectAccess(h.db.WithContext(c), c, report.ProjectID, accessWrite) {
+		return
+	}
+
 	// Parse multipart form
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -291,7 +411,7 @@ func (h *ReportHandler) UploadDailyReportPhotos(c *gin.Context) {
 			UploadedBy:    userID.(uint),
 		}
 
-		if err := h.db.Create(&photo).Error; err != nil {
+		if err := h.db.WithContext(c).Create(&photo).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save photo record"})
 			return
 		}
@@ -312,7 +432,7 @@ func (h *ReportHandler) GetDailyReportPhotos(c *gin.Context) {
 
 	// Verify daily report exists
 	var report models.DailyReport
-	if err := h.db.First(&report, reportID).Error; err != nil {
+	if err := h.db.WithContext(c).First(&report, reportID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Daily report not found"})
 			return
@@ -321,8 +441,12 @@ func (h *ReportHandler) GetDailyReportPhotos(c *gin.Context) {
 		return
 	}
 
+	if !requireProjectAccess(h.db.WithContext(c), c, report.ProjectID, accessRead) {
+		return
+	}
+
 	var photos []models.Photo
-	if err := h.db.Preload("Uploader").Where("daily_report_id = ?", reportID).Find(&photos).Error; err != nil {
+	if err := h.db.WithContext(c).Preload("Uploader").Where("daily_report_id = ?", reportID).Find(&photos).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
 		return
 	}
@@ -336,7 +460,7 @@ func (h *ReportHandler) DeletePhoto(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
 	var photo models.Photo
-	if err := h.db.First(&photo, photoID).Error; err != nil {
+	if err := h.db.WithContext(c).First(&photo, photoID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
 			return
@@ -345,6 +469,20 @@ func (h *ReportHandler) DeletePhoto(c *gin.Context) {
 		return
 	}
 
+	var report models.DailyReport
+	if err := h.db.WithContext(c).First(&report, photo.DailyReportID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Daily report not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch daily report"})
+		return
+	}
+
+	if !requireProjectAccess(h.db.WithContext(c), c, report.ProjectID, accessWrite) {
+		return
+	}
+
 	// Check if user is the uploader
 	if photo.UploadedBy != userID.(uint) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own photos"})
@@ -352,7 +490,7 @@ func (h *ReportHandler) DeletePhoto(c *gin.Context) {
 	}
 
 	// Delete photo record from database
-	if err := h.db.Delete(&photo).Error; err != nil {
+	if err := h.db.WithContext(c).Delete(&photo).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photo"})
 		return
 	}
@@ -365,28 +503,39 @@ func (h *ReportHandler) DeletePhoto(c *gin.Context) {
 
 // ===== WEEKLY REPORTS =====
 
-// GetWeeklyReports returns all weekly reports with optional filters
-func (h *ReportHandler) GetWeeklyReports(c *gin.Context) {
-	projectID := c.Query("project_id")
-	year := c.Query("year")
-
-	query := h.db.Preload("Project").Preload("Generator")
-
-	if projectID != "" {
-		query = query.Where("project_id = ?", projectID)
-	}
+// weeklyReportListSpec whitelists the filters and sort keys of the weekly
+// report list
+var weeklyReportListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"project_id":   {Column: "project_id", Type: listquery.Number},
+		"year":         {Column: "year", Type: listquery.Number},
+		"week_number":  {Column: "week_number", Type: listquery.Number},
+		"generated_by": {Column: "generated_by", Type: listquery.Number},
+		"start_date":   {Column: "start_date", Op: listquery.Range, Type: listquery.Date},
+	},
+	Search: []string{"summary", "achievements", "issues"},
+	Sorts: map[string]string{
+		"year":           "year",
+		"week_number":    "week_number",
+		"start_date":     "start_date",
+		"total_progress": "total_progress",
+		"created_at":     "created_at",
+	},
+	DefaultSort: "-year,-week_number",
+}
 
-	if year != "" {
-		query = query.Where("year = ?", year)
-	}
+// GetWeeklyReports returns a page of weekly reports with optional filters
+func (h *ReportHandler) GetWeeklyReports(c *gin.Context) {
+	query := h.db.WithContext(c).Preload("Project").Preload("Generator").
+		Scopes(scopeAccessibleProjects(h.db.WithContext(c), c, "weekly_reports.project_id"))
 
 	var reports []models.WeeklyReport
-	if err := query.Order("year DESC, week_number DESC").Find(&reports).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch weekly reports"})
+	page, ok := findList(c, query, weeklyReportListSpec, &reports, "Failed to fetch weekly reports")
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": reports})
+	c.JSON(http.StatusOK, listResponse(reports, page))
 }
 
 // GetWeeklyReportByID returns a single weekly report
@@ -394,7 +543,7 @@ func (h *ReportHandler) GetWeeklyReportByID(c *gin.Context) {
 	id := c.Param("id")
 
 	var report models.WeeklyReport
-	if err := h.db.Preload("Project").Preload("Generator").First(&report, id).Error; err != nil {
+	if err := h.db.WithContext(c).Preload("Project").Preload("Generator").First(&report, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Weekly report not found"})
 			return
@@ -403,6 +552,10 @@ func (h *ReportHandler) GetWeeklyReportByID(c *gin.Context) {
 		return
 	}
 
+	if !requireProjectAccess(h.db.WithContext(c), c, report.ProjectID, accessRead) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": report})
 }
 
@@ -425,6 +578,10 @@ func (h *ReportHandler) GenerateWeeklyReport(c *gin.Context) {
 		return
 	}
 
+	if !requireProjectAccess(h.db.WithContext(c), c, input.ProjectID, accessWrite) {
+		return
+	}
+
 	// Parse dates
 	startDate, err := time.Parse("2006-01-02", input.StartDate)
 	if err != nil {
@@ -440,7 +597,7 @@ func (h *ReportHandler) GenerateWeeklyReport(c *gin.Context) {
 
 	// Get daily reports in the date range
 	var dailyReports []models.DailyReport
-	if err := h.db.Where("project_id = ? AND date >= ? AND date <= ?", 
+	if err := h.db.WithContext(c).Where("project_id = ? AND date >= ? AND date <= ?", 
 		input.ProjectID, startDate, endDate).Find(&dailyReports).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch daily reports"})
 		return
@@ -473,13 +630,13 @@ func (h *ReportHandler) GenerateWeeklyReport(c *gin.Context) {
 		GeneratedBy:   userID.(uint),
 	}
 
-	if err := h.db.Create(&weeklyReport).Error; err != nil {
+	if err := h.db.WithContext(c).Create(&weeklyReport).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create weekly report"})
 		return
 	}
 
 	// Load relations
-	h.db.Preload("Project").Preload("Generator").First(&weeklyReport, weeklyReport.ID)
+	h.db.WithContext(c).Preload("Project").Preload("Generator").First(&weeklyReport, weeklyReport.ID)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"data": weeklyReport,
@@ -492,7 +649,7 @@ func (h *ReportHandler) DownloadWeeklyReportPDF(c *gin.Context) {
 	id := c.Param("id")
 
 	var report models.WeeklyReport
-	if err := h.db.Preload("Project").Preload("Generator").First(&report, id).Error; err != nil {
+	if err := h.db.WithContext(c).Preload("Project").Preload("Generator").First(&report, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Weekly report not found"})
 			return
@@ -501,6 +658,10 @@ func (h *ReportHandler) DownloadWeeklyReportPDF(c *gin.Context) {
 		return
 	}
 
+	if !requireProjectAccess(h.db.WithContext(c), c, report.ProjectID, accessRead) {
+		return
+	}
+
 	// Check if PDF already exists
 	if report.PDFPath != "" {
 		// Serve existing PDF
@@ -521,7 +682,7 @@ func (h *ReportHandler) DownloadWeeklyReportPDF(c *gin.Context) {
 
 	// Save PDF path to database
 	report.PDFPath = pdfPath
-	if err := h.db.Save(&report).Error; err != nil {
+	if err := h.db.WithContext(c).Save(&report).Error; err != nil {
 		// Continue even if DB update fails - PDF is already generated
 		fmt.Printf("Warning: Failed to update PDF path in database: %v\n", err)
 	}
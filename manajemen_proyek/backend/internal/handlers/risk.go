@@ -0,0 +1,517 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/listquery"
+	"github.com/unipro/project-management/pkg/recyclebin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RiskRequest represents risk and issue register request body. Issues
+// default to a probability of 5 as they have already happened.
+type RiskRequest struct {
+	Type        models.RiskType `json:"type"` // Defaults to issue when raised from a daily report, risk otherwise
+	Title       string          `json:"title" binding:"required"`
+	Description string          `json:"description"`
+	Category    string          `json:"category"`
+	Probability int             `json:"probability" binding:"omitempty,min=1,max=5"`
+	Impact      int             `json:"impact" binding:"required,min=1,max=5"`
+	Mitigation  string          `json:"mitigation"`
+	OwnerID     *uint           `json:"owner_id"`
+	DueDate     string          `json:"due_date"` // YYYY-MM-DD
+}
+
+// RiskStatusRequest represents risk status change request body. A note is
+// required to resolve or close an entry and is kept as its resolution.
+type RiskStatusRequest struct {
+	Status models.RiskStatus `json:"status" binding:"required"`
+	Note   string            `json:"note"`
+}
+
+type RiskHandler struct {
+	DB *gorm.DB
+}
+
+// NewRiskHandler creates a new risk handler
+func NewRiskHandler(db *gorm.DB) *RiskHandler {
+	return &RiskHandler{DB: db}
+}
+
+// riskListSpec whitelists the filters and sort keys of the risk register
+var riskListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"type":            {Column: "type"},
+		"status":          {Column: "status"},
+		"level":           {Column: "level"},
+		"category":        {Column: "category"},
+		"owner_id":        {Column: "owner_id", Type: listquery.Number},
+		"raised_by":       {Column: "raised_by", Type: listquery.Number},
+		"daily_report_id": {Column: "daily_report_id", Type: listquery.Number},
+		"score":           {Column: "score", Op: listquery.Range, Type: listquery.Number},
+		"due_date":        {Column: "due_date", Op: listquery.Range, Type: listquery.Date},
+		"created_at":      {Column: "created_at", Op: listquery.Range, Type: listquery.Date},
+	},
+	Search: []string{"code", "title", "description", "mitigation"},
+	Sorts: map[string]string{
+		"code":       "code",
+		"score":      "score",
+		"status":     "status",
+		"due_date":   "due_date",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "-score",
+}
+
+// GetProjectRisks returns a page of a project's risk and issue register.
+// open=true keeps entries still needing attention and overdue=true those
+// past their due date.
+func (h *RiskHandler) GetProjectRisks(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	query := h.DB.WithContext(c).Preload("Owner").Preload("Raiser").Where("project_id = ?", projectID)
+	if c.Query("open") == "true" {
+		query = query.Where("status IN ?", models.OpenRiskStatuses)
+	}
+	if c.Query("overdue") == "true" {
+		query = query.Where("status IN ? AND due_date < ?", models.OpenRiskStatuses, truncateDay(time.Now()))
+	}
+
+	var risks []models.Risk
+	page, ok := findList(c, query, riskListSpec, &risks, "Failed to fetch risks")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(risks, page))
+}
+
+// GetProjectRiskSummary returns the counts of a project's register by status
+// and level, and the probability x impact matrix of its open entries
+func (h *RiskHandler) GetProjectRiskSummary(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var risks []models.Risk
+	if err := h.DB.WithContext(c).Select("id", "type", "status", "probability", "impact", "level", "due_date").
+		Where("project_id = ?", projectID).Find(&risks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch risks"})
+		return
+	}
+
+	// matrix[probability-1][impact-1] counts the open entries of each cell
+	matrix := make([][]int, models.RiskScaleMax)
+	for i := range matrix {
+		matrix[i] = make([]int, models.RiskScaleMax)
+	}
+	byStatus := map[models.RiskStatus]int{}
+	byLevel := map[models.RiskLevel]int{}
+	openByType := map[models.RiskType]int{}
+	overdue := 0
+	now := time.Now()
+	for _, risk := range risks {
+		byStatus[risk.Status]++
+		if !risk.Status.IsOpen() {
+			continue
+		}
+		openByType[risk.Type]++
+		byLevel[risk.Level]++
+		if risk.Probability >= 1 && risk.Probability <= models.RiskScaleMax && risk.Impact >= 1 && risk.Impact <= models.RiskScaleMax {
+			matrix[risk.Probability-1][risk.Impact-1]++
+		}
+		if risk.IsOverdue(now) {
+			overdue++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"total":         len(risks),
+			"by_status":     byStatus,
+			"open_by_type":  openByType,
+			"open_by_level": byLevel,
+			"overdue":       overdue,
+			"matrix":        matrix,
+		},
+	})
+}
+
+// GetRiskByID returns a register entry with its status history
+func (h *RiskHandler) GetRiskByID(c *gin.Context) {
+	risk, ok := h.findRisk(c, accessRead)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": risk})
+}
+
+// CreateRisk adds a risk or issue to a project's register
+func (h *RiskHandler) CreateRisk(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req RiskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	risk := models.Risk{ProjectID: projectID, Type: models.RiskTypeRisk, RaisedBy: middleware.GetUserID(c)}
+	h.respondCreatedRisk(c, risk, req)
+}
+
+// RaiseDailyReportIssue raises an issue found in the field from the daily
+// report in the :id param
+func (h *RiskHandler) RaiseDailyReportIssue(c *gin.Context) {
+	var report models.DailyReport
+	if err := h.DB.WithContext(c).First(&report, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Daily report not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch daily report"})
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, report.ProjectID, accessWrite) {
+		return
+	}
+
+	var req RiskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	risk := models.Risk{
+		ProjectID:     report.ProjectID,
+		Type:          models.RiskTypeIssue,
+		DailyReportID: &report.ID,
+		RaisedBy:      middleware.GetUserID(c),
+	}
+	h.respondCreatedRisk(c, risk, req)
+}
+
+// respondCreatedRisk validates and saves a new register entry and writes
+// the response
+func (h *RiskHandler) respondCreatedRisk(c *gin.Context, risk models.Risk, req RiskRequest) {
+	if !applyRiskRequest(c, h.DB.WithContext(c), &risk, req) {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		return createRisk(tx, &risk)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create risk"})
+		return
+	}
+
+	notifyRiskOwner(h.DB.WithContext(c), &risk)
+	h.DB.WithContext(c).Preload("Owner").Preload("Raiser").First(&risk, risk.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": fmt.Sprintf("%s raised successfully", riskTypeLabel(risk.Type)),
+		"data":    risk,
+	})
+}
+
+// UpdateRisk changes the details, scoring, owner or due date of a register
+// entry. The status is changed through UpdateRiskStatus.
+func (h *RiskHandler) UpdateRisk(c *gin.Context) {
+	risk, ok := h.findRisk(c, accessWrite)
+	if !ok {
+		return
+	}
+
+	var req RiskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if req.Type != "" && req.Type != risk.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The type of a register entry cannot be changed"})
+		return
+	}
+
+	previousOwner := risk.OwnerID
+	if !applyRiskRequest(c, h.DB.WithContext(c), &risk, req) {
+		return
+	}
+
+	if err := h.DB.WithContext(c).Model(&risk).
+		Select("title", "description", "category", "probability", "impact", "score", "level", "mitigation", "owner_id", "due_date").
+		Updates(&risk).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update risk"})
+		return
+	}
+
+	if risk.OwnerID != nil && (previousOwner == nil || *previousOwner != *risk.OwnerID) {
+		notifyRiskOwner(h.DB.WithContext(c), &risk)
+	}
+	risk, _ = h.loadRisk(c, risk.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Risk updated successfully",
+		"data":    risk,
+	})
+}
+
+// UpdateRiskStatus moves a register entry through its lifecycle, recording
+// the change in its history
+func (h *RiskHandler) UpdateRiskStatus(c *gin.Context) {
+	risk, ok := h.findRisk(c, accessWrite)
+	if !ok {
+		return
+	}
+
+	var req RiskStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if !risk.CanTransition(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cannot move from %s to %s", risk.Status, req.Status)})
+		return
+	}
+
+	note := strings.TrimSpace(req.Note)
+	updates := map[string]interface{}{"status": req.Status}
+	if req.Status.IsOpen() {
+		updates["closed_at"] = nil
+	} else {
+		if note == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A note on the resolution is required"})
+			return
+		}
+		updates["closed_at"] = time.Now()
+		updates["resolution"] = note
+	}
+
+	update := models.RiskUpdate{
+		RiskID:     risk.ID,
+		FromStatus: risk.Status,
+		ToStatus:   req.Status,
+		Note:       note,
+		UserID:     middleware.GetUserID(c),
+	}
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Risk{}).Where("id = ?", risk.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Create(&update).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update risk status"})
+		return
+	}
+
+	risk, _ = h.loadRisk(c, risk.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("%s %s is now %s", riskTypeLabel(risk.Type), risk.Code, risk.Status),
+		"data":    risk,
+	})
+}
+
+// DeleteRisk moves a register entry raised in error to the recycle bin.
+// Entries dealt with should be closed instead.
+func (h *RiskHandler) DeleteRisk(c *gin.Context) {
+	risk, ok := h.findRisk(c, accessWrite)
+	if !ok {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		return recyclebin.Delete(tx, recyclebin.Risks, risk.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete risk"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Risk deleted successfully"})
+}
+
+// findRisk loads the register entry in the :id param and checks access to
+// its project
+func (h *RiskHandler) findRisk(c *gin.Context, write bool) (models.Risk, bool) {
+	risk, err := h.loadRisk(c, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Risk not found"})
+			return risk, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch risk"})
+		return risk, false
+	}
+
+	if !requireProjectAccess(h.DB.WithContext(c), c, risk.ProjectID, write) {
+		return risk, false
+	}
+	return risk, true
+}
+
+// loadRisk loads a register entry with its people and status history
+func (h *RiskHandler) loadRisk(c *gin.Context, id interface{}) (models.Risk, error) {
+	var risk models.Risk
+	err := h.DB.WithContext(c).Preload("Owner").Preload("Raiser").
+		Preload("Updates", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Updates.User").
+		First(&risk, id).Error
+	return risk, err
+}
+
+// applyRiskRequest validates a register request and copies it onto the
+// entry. The owner must manage or be a member of the project.
+func applyRiskRequest(c *gin.Context, db *gorm.DB, risk *models.Risk, req RiskRequest) bool {
+	if req.Type != "" {
+		if !models.IsValidRiskType(req.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type. Use risk or issue"})
+			return false
+		}
+		if risk.ID == 0 {
+			risk.Type = req.Type
+		}
+	}
+
+	probability := req.Probability
+	if probability == 0 {
+		if risk.Type != models.RiskTypeIssue {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Probability is required for risks"})
+			return false
+		}
+		probability = models.RiskScaleMax
+	}
+
+	dueDate, err := parseOptionalDate(req.DueDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid due date format. Use YYYY-MM-DD"})
+		return false
+	}
+
+	if req.OwnerID != nil {
+		var project models.Project
+		if err := db.Select("id", "manager_id").First(&project, risk.ProjectID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return false
+		}
+		members := db.Model(&models.ProjectMember{}).Select("user_id").Where("project_id = ?", risk.ProjectID)
+		var count int64
+		db.Model(&models.User{}).Where("id = ?", *req.OwnerID).
+			Where("id = ? OR id IN (?)", project.ManagerID, members).
+			Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Owner must be a member of the project"})
+			return false
+		}
+	}
+
+	risk.Title = strings.TrimSpace(req.Title)
+	risk.Description = req.Description
+	risk.Category = strings.ToLower(strings.TrimSpace(req.Category))
+	risk.Probability = probability
+	risk.Impact = req.Impact
+	risk.Mitigation = req.Mitigation
+	risk.OwnerID = req.OwnerID
+	risk.Owner = nil
+	risk.DueDate = dueDate
+	risk.Calculate()
+	return true
+}
+
+// createRisk numbers a new register entry within its project and saves it
+// with the first entry of its history
+func createRisk(tx *gorm.DB, risk *models.Risk) error {
+	// Lock the project so concurrent entries get distinct codes
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Project{}, risk.ProjectID).Error; err != nil {
+		return err
+	}
+
+	var count int64
+	if err := tx.Unscoped().Model(&models.Risk{}).
+		Where("project_id = ? AND type = ?", risk.ProjectID, risk.Type).Count(&count).Error; err != nil {
+		return err
+	}
+	prefix := "R"
+	if risk.Type == models.RiskTypeIssue {
+		prefix = "I"
+	}
+	risk.Code = fmt.Sprintf("%s-%03d", prefix, count+1)
+	risk.Status = models.RiskOpen
+
+	if err := tx.Create(risk).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.RiskUpdate{
+		RiskID:   risk.ID,
+		ToStatus: models.RiskOpen,
+		UserID:   risk.RaisedBy,
+	}).Error
+}
+
+// notifyRiskOwner tells the owner of a register entry it was assigned to
+// them, unless they raised it themselves
+func notifyRiskOwner(db *gorm.DB, risk *models.Risk) {
+	if risk.OwnerID == nil || *risk.OwnerID == risk.RaisedBy {
+		return
+	}
+
+	message := fmt.Sprintf("%s %s '%s' (skor %d) ditugaskan kepada Anda.", riskTypeLabel(risk.Type), risk.Code, risk.Title, risk.Score)
+	if risk.DueDate != nil {
+		message += fmt.Sprintf(" Batas waktu %s.", risk.DueDate.Format("02 Jan 2006"))
+	}
+	notification := models.Notification{
+		UserID:    *risk.OwnerID,
+		Title:     fmt.Sprintf("%s Baru: %s", riskTypeLabel(risk.Type), risk.Code),
+		Message:   message,
+		Type:      models.NotificationTypeProjectUpdate,
+		RelatedID: &risk.ID,
+	}
+	db.Create(&notification)
+}
+
+// riskTypeLabel names a register entry type in messages
+func riskTypeLabel(t models.RiskType) string {
+	if t == models.RiskTypeIssue {
+		return "Issue"
+	}
+	return "Risk"
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/models"
+	"gorm.io/gorm"
+)
+
+type RoleHandler struct {
+	DB *gorm.DB
+}
+
+// RoleRequest represents role create/update request body
+type RoleRequest struct {
+	Name        string          `json:"name"` // Only used on create
+	DisplayName string          `json:"display_name" binding:"required"`
+	Description string          `json:"description"`
+	Permissions json.RawMessage `json:"permissions" binding:"required"`
+}
+
+// RoleResponse is a role together with its resolved grants
+type RoleResponse struct {
+	models.Role
+	Grants map[string][]string `json:"grants"`
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(db *gorm.DB) *RoleHandler {
+	return &RoleHandler{DB: db}
+}
+
+// GetAllRoles returns all roles with their resolved grants
+func (h *RoleHandler) GetAllRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := h.DB.WithContext(c).Order("id ASC").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	response := make([]RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		response = append(response, newRoleResponse(role))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": response})
+}
+
+// GetRoleByID returns a single role
+func (h *RoleHandler) GetRoleByID(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": newRoleResponse(role)})
+}
+
+// CreateRole creates a new role with a permission document
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role name is required"})
+		return
+	}
+
+	permissions, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid permissions",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var existing models.Role
+	if err := h.DB.WithContext(c).Where("name = ?", req.Name).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role name already exists"})
+		return
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		DisplayName: req.DisplayName,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+
+	if err := h.DB.WithContext(c).Create(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Role created successfully",
+		"data":    newRoleResponse(role),
+	})
+}
+
+// UpdateRole updates a role's display fields and permission document.
+// Changes apply to the next request of every user holding the role.
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	permissions, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid permissions",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := h.DB.WithContext(c).Model(&role).Updates(map[string]interface{}{
+		"display_name": req.DisplayName,
+		"description":  req.Description,
+		"permissions":  permissions,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role updated successfully",
+		"data":    newRoleResponse(role),
+	})
+}
+
+// GetPermissionCatalog returns every resource and action the API checks
+func (h *RoleHandler) GetPermissionCatalog(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": models.PermissionCatalog})
+}
+
+// findRole loads the role referenced by the :id path parameter
+func (h *RoleHandler) findRole(c *gin.Context) (models.Role, bool) {
+	var role models.Role
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return role, false
+	}
+
+	if err := h.DB.WithContext(c).First(&role, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+			return role, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role"})
+		return role, false
+	}
+
+	return role, true
+}
+
+// newRoleResponse attaches the resolved grants to a role
+func newRoleResponse(role models.Role) RoleResponse {
+	return RoleResponse{
+		Role:   role,
+		Grants: role.GetPermissions().Resolve(),
+	}
+}
+
+// normalizePermissions validates a permission document and returns it in
+// compact form for storage
+func normalizePermissions(raw json.RawMessage) (string, error) {
+	perms, err := models.ParsePermissions(string(raw))
+	if err != nil {
+		return "", err
+	}
+	if err := perms.Validate(); err != nil {
+		return "", err
+	}
+
+	var compact map[string]interface{}
+	if err := json.Unmarshal(raw, &compact); err != nil {
+		return "", err
+	}
+	out, err := json.Marshal(compact)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/config"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	jwtPkg "github.com/unipro/project-management/pkg/jwt"
+	"github.com/unipro/project-management/pkg/token"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	errRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// TokenPair is returned whenever a session is started or refreshed
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"` // Access token expiry
+	SessionID    uint      `json:"session_id"`
+}
+
+// RefreshTokenRequest represents refresh request body
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken rotates a refresh token and issues a new access token.
+// Presenting an already rotated token revokes the whole session.
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	pair, err := rotateRefreshToken(h.DB.WithContext(c), h.Config, req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, errRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Refresh token reuse detected, session has been revoked",
+			})
+			return
+		}
+		if errors.Is(err, errRefreshTokenInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid or expired token",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to refresh token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         pair.AccessToken,
+		"access_token":  pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_at":    pair.ExpiresAt,
+	})
+}
+
+// Logout revokes the caller's current session
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	if sessionID := middleware.GetSessionID(c); sessionID != 0 {
+		if err := revokeSessions(h.DB.WithContext(c), models.RevokeReasonLogout, func(db *gorm.DB) *gorm.DB {
+			return db.Where("id = ? AND user_id = ?", sessionID, userID)
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to logout",
+			})
+			return
+		}
+	}
+
+	// Also deny the presented token in case it predates the latest rotation
+	if jti := middleware.GetTokenJTI(c); jti != "" {
+		denyToken(h.DB.WithContext(c), jti, userID, middleware.GetTokenExpiry(c), models.RevokeReasonLogout)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out successfully",
+	})
+}
+
+// GetSessions returns the caller's active sessions
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	currentID := middleware.GetSessionID(c)
+
+	var sessions []models.Session
+	if err := h.DB.WithContext(c).Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch sessions",
+		})
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": sessions,
+	})
+}
+
+// RevokeSession revokes one of the caller's own sessions
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid session ID",
+		})
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+
+	var session models.Session
+	if err := h.DB.WithContext(c).Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Session not found",
+		})
+		return
+	}
+
+	if err := revokeSessions(h.DB.WithContext(c), models.RevokeReasonLogout, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", session.ID)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Session revoked successfully",
+	})
+}
+
+// RevokeAllSessions logs the caller out of every device
+func (h *AuthHandler) RevokeAllSessions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	if err := revokeUserSessions(h.DB.WithContext(c), userID, models.RevokeReasonLogout); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke sessions",
+		})
+		return
+	}
+
+	if jti := middleware.GetTokenJTI(c); jti != "" {
+		denyToken(h.DB.WithContext(c), jti, userID, middleware.GetTokenExpiry(c), models.RevokeReasonLogout)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "All sessions revoked successfully",
+	})
+}
+
+// issueSession starts a new session for the user and returns its first
+// token pair. The user must have its Role loaded.
+func issueSession(db *gorm.DB, cfg *config.Config, c *gin.Context, user *models.User, deviceName string) (*TokenPair, error) {
+	now := time.Now()
+	session := models.Session{
+		UserID:     user.ID,
+		DeviceName: deviceName,
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(cfg.JWT.RefreshExpiry),
+	}
+
+	var pair *TokenPair
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = issueTokenPair(tx, cfg, user, &session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// rotateRefreshToken exchanges a refresh token for a new token pair
+func rotateRefreshToken(db *gorm.DB, cfg *config.Config, plainToken string) (*TokenPair, error) {
+	var stored models.RefreshToken
+	if err := db.Where("token_hash = ?", token.Hash(plainToken)).First(&stored).Error; err != nil {
+		return nil, errRefreshTokenInvalid
+	}
+
+	var session models.Session
+	if err := db.First(&session, stored.SessionID).Error; err != nil {
+		return nil, errRefreshTokenInvalid
+	}
+
+	// A rotated token coming back means it leaked; end the whole family
+	if stored.UsedAt != nil {
+		if err := revokeSessions(db, models.RevokeReasonReuse, func(q *gorm.DB) *gorm.DB {
+			return q.Where("id = ?", session.ID)
+		}); err != nil {
+			return nil, err
+		}
+		return nil, errRefreshTokenReused
+	}
+
+	if !session.IsActive() || time.Now().After(stored.ExpiresAt) {
+		return nil, errRefreshTokenInvalid
+	}
+
+	var user models.User
+	if err := db.Preload("Role").First(&user, session.UserID).Error; err != nil || !user.IsActive {
+		return nil, errRefreshTokenInvalid
+	}
+
+	var pair *TokenPair
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Conditional update so two concurrent refreshes cannot both win
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefreshTokenInvalid
+		}
+
+		var err error
+		pair, err = issueTokenPair(tx, cfg, &user, &session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// issueTokenPair signs an access token and creates a refresh token for an
+// existing session, recording the access jti on the session
+func issueTokenPair(tx *gorm.DB, cfg *config.Config, user *models.User, session *models.Session) (*TokenPair, error) {
+	accessToken, claims, err := jwtPkg.GenerateToken(user, session.ID, cfg.JWT.Expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	plainRefresh, refreshHash, err := token.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: refreshHash,
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := tx.Create(&refresh).Error; err != nil {
+		return nil, err
+	}
+
+	session.AccessJTI = claims.ID
+	session.AccessExpires = claims.ExpiresAt.Time
+	session.LastUsedAt = time.Now()
+	if err := tx.Model(session).Updates(map[string]interface{}{
+		"access_jti":     session.AccessJTI,
+		"access_expires": session.AccessExpires,
+		"last_used_at":   session.LastUsedAt,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: plainRefresh,
+		ExpiresAt:    session.AccessExpires,
+		SessionID:    session.ID,
+	}, nil
+}
+
+// revokeUserSessions revokes every active session of a user
+func revokeUserSessions(db *gorm.DB, userID uint, reason string) error {
+	return revokeSessions(db, reason, func(q *gorm.DB) *gorm.DB {
+		return q.Where("user_id = ?", userID)
+	})
+}
+
+// revokeSessions revokes the active sessions selected by scope and puts
+// their latest access tokens on the denylist
+func revokeSessions(db *gorm.DB, reason string, scope func(*gorm.DB) *gorm.DB) error {
+	var sessions []models.Session
+	if err := scope(db.Where("revoked_at IS NULL")).Find(&sessions).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, session := range sessions {
+			if err := tx.Model(&models.Session{}).Where("id = ?", session.ID).
+				Updates(map[string]interface{}{
+					"revoked_at":     now,
+					"revoked_reason": reason,
+				}).Error; err != nil {
+				return err
+			}
+
+			if session.AccessJTI != "" && session.AccessExpires.After(now) {
+				if err := denyToken(tx, session.AccessJTI, session.UserID, session.AccessExpires, reason); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Denylist entries are useless once the token has expired anyway
+		return tx.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error
+	})
+}
+
+// denyToken adds an access token jti to the denylist
+func denyToken(db *gorm.DB, jti string, userID uint, expiresAt time.Time, reason string) error {
+	entry := models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		Reason:    reason,
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error
+}
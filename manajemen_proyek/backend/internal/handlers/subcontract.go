@@ -0,0 +1,1377 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/budget"
+	"github.com/unipro/project-management/pkg/costledger"
+	"github.com/unipro/project-management/pkg/listquery"
+	"github.com/unipro/project-management/pkg/recyclebin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errCertificateValuation is returned when a payment certificate no longer
+// fits its subcontract when it is approved
+var errCertificateValuation = errors.New("certificate does not fit the subcontract")
+
+// SubcontractorRequest represents subcontractor request body
+type SubcontractorRequest struct {
+	Code          string `json:"code" binding:"required"`
+	Name          string `json:"name" binding:"required"`
+	Trade         string `json:"trade"`
+	ContactPerson string `json:"contact_person"`
+	Phone         string `json:"phone"`
+	Email         string `json:"email"`
+	Address       string `json:"address"`
+	TaxID         string `json:"tax_id"`
+	BankName      string `json:"bank_name"`
+	BankAccount   string `json:"bank_account"`
+	IsActive      *bool  `json:"is_active"` // Defaults to true
+	Notes         string `json:"notes"`
+}
+
+// SubcontractRequest represents subcontract request body. Cost code defaults
+// to the subcontract root code.
+type SubcontractRequest struct {
+	SubcontractorID uint    `json:"subcontractor_id" binding:"required"`
+	Title           string  `json:"title" binding:"required"`
+	Scope           string  `json:"scope"`
+	CostCodeID      *uint   `json:"cost_code_id"`
+	PhaseID         *uint   `json:"phase_id"`
+	ContractValue   float64 `json:"contract_value" binding:"required,gt=0"`
+	RetentionPct    float64 `json:"retention_pct"`
+	PaymentTermDays *int    `json:"payment_term_days"` // Defaults to 30
+	PaymentTerms    string  `json:"payment_terms"`
+	StartDate       string  `json:"start_date"`
+	EndDate         string  `json:"end_date"`
+}
+
+// SubcontractStatusRequest represents subcontract status change request body
+type SubcontractStatusRequest struct {
+	Status models.SubcontractStatus `json:"status" binding:"required"`
+}
+
+// PaymentCertificateRequest represents payment certificate request body. The
+// progress is the cumulative completion of the subcontract scope.
+type PaymentCertificateRequest struct {
+	PeriodEnd        string  `json:"period_end" binding:"required"`
+	ProgressPct      float64 `json:"progress_pct" binding:"required,gt=0,lte=100"`
+	ReleaseRetention bool    `json:"release_retention"`
+	Notes            string  `json:"notes"`
+}
+
+// PaymentCertificateDecisionRequest represents payment certificate approve
+// or reject request body
+type PaymentCertificateDecisionRequest struct {
+	Stage   string `json:"stage" binding:"required"`
+	Comment string `json:"comment"`
+}
+
+type SubcontractHandler struct {
+	DB *gorm.DB
+}
+
+// NewSubcontractHandler creates a new subcontract handler
+func NewSubcontractHandler(db *gorm.DB) *SubcontractHandler {
+	return &SubcontractHandler{DB: db}
+}
+
+// subcontractorListSpec whitelists the filters and sort keys of the
+// subcontractor list
+var subcontractorListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"trade":     {Column: "trade"},
+		"is_active": {Column: "is_active", Type: listquery.Bool},
+	},
+	Search: []string{"name", "code", "contact_person"},
+	Sorts: map[string]string{
+		"name":       "name",
+		"code":       "code",
+		"trade":      "trade",
+		"created_at": "created_at",
+	},
+	DefaultSort: "name",
+}
+
+// subcontractListSpec whitelists the filters and sort keys of the
+// subcontract lists
+var subcontractListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"project_id":       {Column: "project_id", Type: listquery.Number},
+		"subcontractor_id": {Column: "subcontractor_id", Type: listquery.Number},
+		"phase_id":         {Column: "phase_id", Type: listquery.Number},
+		"status":           {Column: "status"},
+		"contract_value":   {Column: "contract_value", Op: listquery.Range, Type: listquery.Number},
+		"start_date":       {Column: "start_date", Op: listquery.Range, Type: listquery.Date},
+	},
+	Search: []string{"contract_number", "title"},
+	Sorts: map[string]string{
+		"contract_number":  "contract_number",
+		"title":            "title",
+		"status":           "status",
+		"contract_value":   "contract_value",
+		"certified_amount": "certified_amount",
+		"paid_amount":      "paid_amount",
+		"start_date":       "start_date",
+		"created_at":       "created_at",
+	},
+	DefaultSort: "-created_at",
+}
+
+// paymentCertificateListSpec whitelists the filters and sort keys of the
+// payment certificate lists
+var paymentCertificateListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"project_id":     {Column: "project_id", Type: listquery.Number},
+		"subcontract_id": {Column: "subcontract_id", Type: listquery.Number},
+		"status":         {Column: "status"},
+		"current_stage":  {Column: "current_stage"},
+		"net_amount":     {Column: "net_amount", Op: listquery.Range, Type: listquery.Number},
+		"period_end":     {Column: "period_end", Op: listquery.Range, Type: listquery.Date},
+	},
+	Search: []string{"cert_number"},
+	Sorts: map[string]string{
+		"cert_number":  "cert_number",
+		"status":       "status",
+		"period_end":   "period_end",
+		"progress_pct": "progress_pct",
+		"net_amount":   "net_amount",
+		"submitted_at": "submitted_at",
+		"created_at":   "created_at",
+	},
+	DefaultSort: "-created_at",
+}
+
+// ===== SUBCONTRACTORS =====
+
+// GetSubcontractors returns a page of subcontractors with optional filters
+func (h *SubcontractHandler) GetSubcontractors(c *gin.Context) {
+	var subcontractors []models.Subcontractor
+	page, ok := findList(c, h.DB.WithContext(c), subcontractorListSpec, &subcontractors, "Failed to fetch subcontractors")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(subcontractors, page))
+}
+
+// GetSubcontractorByID returns a subcontractor with its subcontracts on the
+// projects the user can access
+func (h *SubcontractHandler) GetSubcontractorByID(c *gin.Context) {
+	var subcontractor models.Subcontractor
+	if err := h.DB.WithContext(c).First(&subcontractor, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subcontractor not found"})
+		return
+	}
+
+	var subcontracts []models.Subcontract
+	h.DB.WithContext(c).Preload("Project").
+		Scopes(scopeAccessibleProjects(h.DB.WithContext(c), c, "subcontracts.project_id")).
+		Where("subcontractor_id = ?", subcontractor.ID).
+		Order("created_at DESC").
+		Find(&subcontracts)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         subcontractor,
+		"subcontracts": subcontracts,
+	})
+}
+
+// CreateSubcontractor adds a subcontractor to the master data
+func (h *SubcontractHandler) CreateSubcontractor(c *gin.Context) {
+	var req SubcontractorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	subcontractor := models.Subcontractor{IsActive: true}
+	if !h.applySubcontractorRequest(c, &subcontractor, req) {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&subcontractor).Error; err != nil {
+			return err
+		}
+		// The column defaults to active, so an inactive one is set after insert
+		if !subcontractor.IsActive {
+			return tx.Model(&subcontractor).Update("is_active", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subcontractor"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Subcontractor created successfully",
+		"data":    subcontractor,
+	})
+}
+
+// UpdateSubcontractor replaces the details of a subcontractor
+func (h *SubcontractHandler) UpdateSubcontractor(c *gin.Context) {
+	var subcontractor models.Subcontractor
+	if err := h.DB.WithContext(c).First(&subcontractor, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subcontractor not found"})
+		return
+	}
+
+	var req SubcontractorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if !h.applySubcontractorRequest(c, &subcontractor, req) {
+		return
+	}
+
+	if err := h.DB.WithContext(c).Save(&subcontractor).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subcontractor"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Subcontractor updated successfully",
+		"data":    subcontractor,
+	})
+}
+
+// DeleteSubcontractor moves a subcontractor without subcontracts to the
+// recycle bin. Subcontractors no longer used should be deactivated instead.
+func (h *SubcontractHandler) DeleteSubcontractor(c *gin.Context) {
+	var subcontractor models.Subcontractor
+	if err := h.DB.WithContext(c).First(&subcontractor, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subcontractor not found"})
+		return
+	}
+
+	var count int64
+	h.DB.WithContext(c).Model(&models.Subcontract{}).Where("subcontractor_id = ?", subcontractor.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a subcontractor with subcontracts, deactivate it instead"})
+		return
+	}
+
+	if err := recyclebin.Delete(h.DB.WithContext(c), recyclebin.Subcontractors, subcontractor.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subcontractor"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subcontractor deleted successfully"})
+}
+
+// applySubcontractorRequest copies a request onto a subcontractor after
+// checking its code is not taken
+func (h *SubcontractHandler) applySubcontractorRequest(c *gin.Context, subcontractor *models.Subcontractor, req SubcontractorRequest) bool {
+	code := strings.TrimSpace(req.Code)
+	var count int64
+	h.DB.WithContext(c).Model(&models.Subcontractor{}).Where("code = ? AND id <> ?", code, subcontractor.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subcontractor code already exists"})
+		return false
+	}
+
+	subcontractor.Code = code
+	subcontractor.Name = req.Name
+	subcontractor.Trade = req.Trade
+	subcontractor.ContactPerson = req.ContactPerson
+	subcontractor.Phone = req.Phone
+	subcontractor.Email = req.Email
+	subcontractor.Address = req.Address
+	subcontractor.TaxID = req.TaxID
+	subcontractor.BankName = req.BankName
+	subcontractor.BankAccount = req.BankAccount
+	subcontractor.Notes = req.Notes
+	if req.IsActive != nil {
+		subcontractor.IsActive = *req.IsActive
+	}
+	return true
+}
+
+// ===== SUBCONTRACTS =====
+
+// GetProjectSubcontracts returns a page of the subcontracts of a project
+// with optional filters, in contract number order by default
+func (h *SubcontractHandler) GetProjectSubcontracts(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	query := h.DB.WithContext(c).Preload("Subcontractor").Preload("CostCode").
+		Where("project_id = ?", projectID)
+
+	spec := subcontractListSpec
+	spec.DefaultSort = "contract_number"
+
+	var subcontracts []models.Subcontract
+	page, ok := findList(c, query, spec, &subcontracts, "Failed to fetch subcontracts")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(subcontracts, page))
+}
+
+// GetSubcontracts returns a page of subcontracts of the projects the user
+// can access
+func (h *SubcontractHandler) GetSubcontracts(c *gin.Context) {
+	query := h.DB.WithContext(c).Preload("Project").Preload("Subcontractor").
+		Scopes(scopeAccessibleProjects(h.DB.WithContext(c), c, "subcontracts.project_id"))
+
+	var subcontracts []models.Subcontract
+	page, ok := findList(c, query, subcontractListSpec, &subcontracts, "Failed to fetch subcontracts")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(subcontracts, page))
+}
+
+// GetSubcontractByID returns a subcontract with its certificates and its
+// payable and outstanding balances
+func (h *SubcontractHandler) GetSubcontractByID(c *gin.Context) {
+	subcontract, ok := h.findSubcontract(c, accessRead)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        subcontract,
+		"uncertified": subcontract.UncertifiedValue(),
+		"payable":     subcontract.Payable(),
+		"outstanding": subcontract.Outstanding(),
+	})
+}
+
+// CreateSubcontract creates a draft subcontract for a project
+func (h *SubcontractHandler) CreateSubcontract(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req SubcontractRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	subcontract := models.Subcontract{
+		ProjectID:       projectID,
+		Status:          models.SubcontractDraft,
+		PaymentTermDays: 30,
+		CreatedBy:       middleware.GetUserID(c),
+	}
+	if !h.applySubcontractRequest(c, &subcontract, req) {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		// Lock the project so concurrent subcontracts get distinct numbers
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Project{}, projectID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Unscoped().Model(&models.Subcontract{}).Where("project_id = ?", projectID).Count(&count).Error; err != nil {
+			return err
+		}
+		subcontract.ContractNumber = fmt.Sprintf("SC-%03d", count+1)
+
+		return tx.Create(&subcontract).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subcontract"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Subcontractor").Preload("CostCode").First(&subcontract, subcontract.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Subcontract created successfully",
+		"data":    subcontract,
+	})
+}
+
+// UpdateSubcontract replaces the terms of a draft subcontract
+func (h *SubcontractHandler) UpdateSubcontract(c *gin.Context) {
+	subcontract, ok := h.findSubcontract(c, accessWrite)
+	if !ok {
+		return
+	}
+	if subcontract.Status != models.SubcontractDraft {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft subcontracts can be changed"})
+		return
+	}
+
+	var req SubcontractRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if !h.applySubcontractRequest(c, &subcontract, req) {
+		return
+	}
+
+	if err := h.DB.WithContext(c).Model(&subcontract).
+		Select("subcontractor_id", "title", "scope", "cost_code_id", "phase_id", "contract_value",
+			"retention_pct", "payment_term_days", "payment_terms", "start_date", "end_date").
+		Updates(&subcontract).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subcontract"})
+		return
+	}
+
+	subcontract, _ = h.findSubcontract(c, accessRead)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Subcontract updated successfully",
+		"data":    subcontract,
+	})
+}
+
+// DeleteSubcontract deletes a draft subcontract
+func (h *SubcontractHandler) DeleteSubcontract(c *gin.Context) {
+	subcontract, ok := h.findSubcontract(c, accessWrite)
+	if !ok {
+		return
+	}
+	if subcontract.Status != models.SubcontractDraft {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft subcontracts can be deleted"})
+		return
+	}
+
+	if err := h.DB.WithContext(c).Delete(&subcontract).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subcontract"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subcontract deleted successfully"})
+}
+
+// UpdateSubcontractStatus moves a subcontract through its lifecycle. A
+// subcontract is activated once signed, and completed or terminated once
+// no certificate is open.
+func (h *SubcontractHandler) UpdateSubcontractStatus(c *gin.Context) {
+	subcontract, ok := h.findSubcontract(c, accessWrite)
+	if !ok {
+		return
+	}
+
+	var req SubcontractStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if !models.IsValidSubcontractStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Use draft, active, completed or terminated"})
+		return
+	}
+	if !subcontract.CanTransition(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cannot move from %s to %s", subcontract.Status, req.Status)})
+		return
+	}
+
+	if req.Status == models.SubcontractActive {
+		var subcontractor models.Subcontractor
+		if err := h.DB.WithContext(c).First(&subcontractor, subcontract.SubcontractorID).Error; err != nil || !subcontractor.IsActive {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Subcontractor is not active"})
+			return
+		}
+	} else if h.hasOpenCertificate(h.DB.WithContext(c), subcontract.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Approve, reject or delete the open payment certificate first"})
+		return
+	}
+
+	if err := h.DB.WithContext(c).Model(&models.Subcontract{}).Where("id = ?", subcontract.ID).
+		Update("status", req.Status).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subcontract status"})
+		return
+	}
+
+	subcontract, _ = h.findSubcontract(c, accessRead)
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Subcontract %s is now %s", subcontract.ContractNumber, subcontract.Status),
+		"data":    subcontract,
+	})
+}
+
+// GetProjectSubcontractSummary returns a project's subcontract position:
+// contract value, certified, retention held, paid, payable and outstanding
+// amounts per subcontract and in total
+func (h *SubcontractHandler) GetProjectSubcontractSummary(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var subcontracts []models.Subcontract
+	if err := h.DB.WithContext(c).Preload("Subcontractor").
+		Where("project_id = ? AND status <> ?", projectID, models.SubcontractDraft).
+		Order("contract_number ASC").
+		Find(&subcontracts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subcontracts"})
+		return
+	}
+
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	rows := make([]gin.H, 0, len(subcontracts))
+	var contractValue, certified, retention, paid, payable, outstanding, committed float64
+	for _, sc := range subcontracts {
+		uncertified := 0.0
+		if sc.Status == models.SubcontractActive {
+			uncertified = sc.UncertifiedValue()
+		}
+		rows = append(rows, gin.H{
+			"subcontract_id":   sc.ID,
+			"contract_number":  sc.ContractNumber,
+			"title":            sc.Title,
+			"subcontractor":    sc.Subcontractor,
+			"status":           sc.Status,
+			"contract_value":   sc.ContractValue,
+			"certified_amount": sc.CertifiedAmount,
+			"retention_held":   sc.RetentionHeld,
+			"paid_amount":      sc.PaidAmount,
+			"payable":          sc.Payable(),
+			"outstanding":      sc.Outstanding(),
+			"committed":        uncertified,
+		})
+		contractValue += sc.ContractValue
+		certified += sc.CertifiedAmount
+		retention += sc.RetentionHeld
+		paid += sc.PaidAmount
+		payable += sc.Payable()
+		outstanding += sc.Outstanding()
+		committed += uncertified
+	}
+
+	var pending struct {
+		Count  int64
+		Amount float64
+	}
+	h.DB.WithContext(c).Model(&models.PaymentCertificate{}).
+		Where("project_id = ? AND status = ?", projectID, models.CertificatePending).
+		Select("COUNT(*) AS count, COALESCE(SUM(net_amount), 0) AS amount").
+		Scan(&pending)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"project_id":           projectID,
+			"subcontracts":         rows,
+			"contract_value":       round(contractValue),
+			"certified_amount":     round(certified),
+			"retention_held":       round(retention),
+			"paid_amount":          round(paid),
+			"payable":              round(payable),
+			"outstanding":          round(outstanding),
+			"committed":            round(committed),
+			"pending_certificates": pending.Count,
+			"pending_amount":       round(pending.Amount),
+		},
+	})
+}
+
+// findSubcontract loads the :id subcontract with its certificates and checks
+// project access
+func (h *SubcontractHandler) findSubcontract(c *gin.Context, write bool) (models.Subcontract, bool) {
+	var subcontract models.Subcontract
+	if err := h.DB.WithContext(c).Preload("Project").Preload("Subcontractor").Preload("CostCode").Preload("Creator").
+		Preload("Certificates", func(db *gorm.DB) *gorm.DB { return db.Order("cert_number ASC") }).
+		First(&subcontract, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subcontract not found"})
+			return subcontract, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subcontract"})
+		return subcontract, false
+	}
+
+	if !requireProjectAccess(h.DB.WithContext(c), c, subcontract.ProjectID, write) {
+		return subcontract, false
+	}
+	return subcontract, true
+}
+
+// applySubcontractRequest validates a request and copies it onto a
+// subcontract. Without a cost code the subcontract goes to the subcontract
+// root code.
+func (h *SubcontractHandler) applySubcontractRequest(c *gin.Context, subcontract *models.Subcontract, req SubcontractRequest) bool {
+	db := h.DB.WithContext(c)
+
+	var subcontractor models.Subcontractor
+	if err := db.First(&subcontractor, req.SubcontractorID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subcontractor not found"})
+		return false
+	}
+	if !subcontractor.IsActive && subcontractor.ID != subcontract.SubcontractorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subcontractor is not active"})
+		return false
+	}
+	if req.RetentionPct < 0 || req.RetentionPct > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Retention must be between 0 and 100 percent"})
+		return false
+	}
+
+	costCodeID := req.CostCodeID
+	if costCodeID != nil {
+		if err := db.First(&models.CostCode{}, *costCodeID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cost code not found"})
+			return false
+		}
+	} else if root, err := budget.RootCode(db, models.CostTypeSubcontract); err == nil {
+		costCodeID = &root.ID
+	}
+	if req.PhaseID != nil {
+		if _, err := findProjectPhase(db, subcontract.ProjectID, *req.PhaseID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Phase not found in this project"})
+			return false
+		}
+	}
+
+	startDate, err := parseOptionalDate(req.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date format. Use YYYY-MM-DD"})
+		return false
+	}
+	endDate, err := parseOptionalDate(req.EndDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end date format. Use YYYY-MM-DD"})
+		return false
+	}
+	if startDate != nil && endDate != nil && endDate.Before(*startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "End date must not be before start date"})
+		return false
+	}
+
+	subcontract.SubcontractorID = subcontractor.ID
+	subcontract.Title = req.Title
+	subcontract.Scope = req.Scope
+	subcontract.CostCodeID = costCodeID
+	subcontract.PhaseID = req.PhaseID
+	subcontract.ContractValue = math.Round(req.ContractValue*100) / 100
+	subcontract.RetentionPct = req.RetentionPct
+	if req.PaymentTermDays != nil && *req.PaymentTermDays >= 0 {
+		subcontract.PaymentTermDays = *req.PaymentTermDays
+	}
+	subcontract.PaymentTerms = req.PaymentTerms
+	subcontract.StartDate = startDate
+	subcontract.EndDate = endDate
+	return true
+}
+
+// ===== PAYMENT CERTIFICATES =====
+
+// GetSubcontractCertificates returns the payment certificates of a
+// subcontract in certificate number order
+func (h *SubcontractHandler) GetSubcontractCertificates(c *gin.Context) {
+	subcontract, ok := h.findSubcontract(c, accessRead)
+	if !ok {
+		return
+	}
+
+	query := h.DB.WithContext(c).Preload("Creator").Where("subcontract_id = ?", subcontract.ID)
+
+	spec := paymentCertificateListSpec
+	spec.DefaultSort = "cert_number"
+
+	var certificates []models.PaymentCertificate
+	page, ok := findList(c, query, spec, &certificates, "Failed to fetch payment certificates")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(certificates, page))
+}
+
+// GetPaymentCertificates returns a page of payment certificates of the
+// projects the user can access. The pending_approval filter lists those
+// waiting at the user's stages.
+func (h *SubcontractHandler) GetPaymentCertificates(c *gin.Context) {
+	query := h.DB.WithContext(c).Preload("Subcontract.Subcontractor").Preload("Creator").
+		Scopes(scopeAccessibleProjects(h.DB.WithContext(c), c, "payment_certificates.project_id"))
+
+	switch filter := c.Query("filter"); filter {
+	case "pending_approval":
+		query = query.Where("status = ? AND current_stage IN ?", models.CertificatePending, h.actionableStages(c))
+	case "unpaid":
+		query = query.Where("status = ? AND paid_amount < net_amount", models.CertificateApproved)
+	case "":
+	default:
+		query = query.Where("status = ?", filter)
+	}
+
+	var certificates []models.PaymentCertificate
+	page, ok := findList(c, query, paymentCertificateListSpec, &certificates, "Failed to fetch payment certificates")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(certificates, page))
+}
+
+// GetPaymentCertificateByID returns a payment certificate with its approvals
+// and payments
+func (h *SubcontractHandler) GetPaymentCertificateByID(c *gin.Context) {
+	certificate, ok := h.findCertificate(c, accessRead)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        certificate,
+		"outstanding": certificate.Outstanding(),
+	})
+}
+
+// CreatePaymentCertificate creates a draft payment certificate for an active
+// subcontract. Only one certificate per subcontract can be open at a time.
+func (h *SubcontractHandler) CreatePaymentCertificate(c *gin.Context) {
+	subcontract, ok := h.findSubcontract(c, accessWrite)
+	if !ok {
+		return
+	}
+	if subcontract.Status != models.SubcontractActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment certificates can only be raised on active subcontracts"})
+		return
+	}
+
+	var req PaymentCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	certificate := models.PaymentCertificate{
+		SubcontractID: subcontract.ID,
+		ProjectID:     subcontract.ProjectID,
+		Status:        models.CertificateDraft,
+		CreatedBy:     middleware.GetUserID(c),
+	}
+	if !h.applyCertificateRequest(c, &certificate, &subcontract, req) {
+		return
+	}
+
+	errOpen := errors.New("open certificate")
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		// Lock the subcontract so concurrent certificates get distinct
+		// numbers and only one stays open
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Subcontract{}, subcontract.ID).Error; err != nil {
+			return err
+		}
+		if h.hasOpenCertificate(tx, subcontract.ID) {
+			return errOpen
+		}
+
+		var count int64
+		if err := tx.Unscoped().Model(&models.PaymentCertificate{}).Where("subcontract_id = ?", subcontract.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		certificate.CertNumber = fmt.Sprintf("PC-%02d", count+1)
+
+		return tx.Create(&certificate).Error
+	})
+	if err != nil {
+		if errors.Is(err, errOpen) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Subcontract already has an open payment certificate"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment certificate"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Payment certificate created successfully",
+		"data":    certificate,
+	})
+}
+
+// UpdatePaymentCertificate revalues a draft payment certificate
+func (h *SubcontractHandler) UpdatePaymentCertificate(c *gin.Context) {
+	certificate, ok := h.findCertificate(c, accessWrite)
+	if !ok {
+		return
+	}
+	if certificate.Status != models.CertificateDraft {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft payment certificates can be changed"})
+		return
+	}
+
+	var req PaymentCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if !h.applyCertificateRequest(c, &certificate, certificate.Subcontract, req) {
+		return
+	}
+
+	if err := h.DB.WithContext(c).Model(&certificate).
+		Select("period_end", "progress_pct", "cumulative_value", "previous_value", "work_value", "retention_amount",
+			"release_retention", "retention_released", "net_amount", "notes").
+		Updates(&certificate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment certificate"})
+		return
+	}
+
+	certificate, _ = h.findCertificate(c, accessRead)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment certificate updated successfully",
+		"data":    certificate,
+	})
+}
+
+// DeletePaymentCertificate deletes a draft payment certificate
+func (h *SubcontractHandler) DeletePaymentCertificate(c *gin.Context) {
+	certificate, ok := h.findCertificate(c, accessWrite)
+	if !ok {
+		return
+	}
+	if certificate.Status != models.CertificateDraft {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft payment certificates can be deleted"})
+		return
+	}
+
+	if err := h.DB.WithContext(c).Delete(&certificate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete payment certificate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment certificate deleted successfully"})
+}
+
+// SubmitPaymentCertificate sends a draft payment certificate into approval,
+// starting at the first stage
+func (h *SubcontractHandler) SubmitPaymentCertificate(c *gin.Context) {
+	certificate, ok := h.findCertificate(c, accessWrite)
+	if !ok {
+		return
+	}
+	if certificate.Status != models.CertificateDraft {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment certificate already submitted"})
+		return
+	}
+
+	now := time.Now()
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		for _, stage := range models.PaymentCertificateStages {
+			approval := models.PaymentCertificateApproval{
+				CertificateID: certificate.ID,
+				Stage:         stage,
+				Status:        models.StageStatusPending,
+			}
+			if err := tx.Create(&approval).Error; err != nil {
+				return err
+			}
+		}
+
+		certificate.Status = models.CertificatePending
+		certificate.CurrentStage = models.PaymentCertificateStages[0]
+		certificate.SubmittedAt = &now
+		return tx.Model(&certificate).
+			Select("status", "current_stage", "submitted_at").
+			Updates(&certificate).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit payment certificate"})
+		return
+	}
+
+	h.notifyStageApprovers(c, &certificate)
+
+	certificate, _ = h.findCertificate(c, accessRead)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment certificate submitted for approval",
+		"data":    certificate,
+	})
+}
+
+// ApprovePaymentCertificate approves a payment certificate at its current
+// stage. The final approval certifies the work and adds it to the project's
+// actual cost.
+func (h *SubcontractHandler) ApprovePaymentCertificate(c *gin.Context) {
+	h.decideCertificate(c, models.StageStatusApproved)
+}
+
+// RejectPaymentCertificate rejects a payment certificate at its current
+// stage
+func (h *SubcontractHandler) RejectPaymentCertificate(c *gin.Context) {
+	h.decideCertificate(c, models.StageStatusRejected)
+}
+
+// decideCertificate records an approver's decision at the current stage
+func (h *SubcontractHandler) decideCertificate(c *gin.Context, decision models.ApprovalHistoryStatus) {
+	var req PaymentCertificateDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if decision == models.StageStatusRejected && strings.TrimSpace(req.Comment) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to reject a payment certificate"})
+		return
+	}
+
+	var certificate models.PaymentCertificate
+	if err := h.DB.WithContext(c).Preload("Subcontract").First(&certificate, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment certificate not found"})
+		return
+	}
+
+	// Approvers act through their role at the stage, or through a delegation
+	// from someone who holds it
+	stage := models.ApprovalStage(req.Stage)
+	delegation, ok := h.authorizeStage(c, &certificate, stage)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to decide at this stage"})
+		return
+	}
+	if delegation == nil && !requireProjectAccess(h.DB.WithContext(c), c, certificate.ProjectID, accessRead) {
+		return
+	}
+
+	if certificate.Status != models.CertificatePending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment certificate is not pending approval"})
+		return
+	}
+	if certificate.CurrentStage != stage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment certificate not at this approval stage"})
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	now := time.Now()
+	nextStage := certificate.NextStage()
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"status":      decision,
+			"approver_id": userID,
+			"comment":     req.Comment,
+			"approved_at": now,
+		}
+		if delegation != nil {
+			updates["on_behalf_of_id"] = delegation.DelegatorID
+			updates["delegation_id"] = delegation.ID
+		}
+		if err := tx.Model(&models.PaymentCertificateApproval{}).
+			Where("certificate_id = ? AND stage = ?", certificate.ID, stage).
+			Updates(updates).Error; err != nil {
+			return err
+		}
+
+		switch {
+		case decision == models.StageStatusRejected:
+			certificate.Status = models.CertificateRejected
+		case nextStage != nil:
+			certificate.CurrentStage = *nextStage
+		default:
+			return certifyWork(tx, &certificate, now)
+		}
+
+		return tx.Model(&certificate).Select("status", "current_stage").Updates(&certificate).Error
+	})
+	if err != nil {
+		if errors.Is(err, errCertificateValuation) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Payment certificate cannot be approved",
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment certificate"})
+		return
+	}
+
+	switch certificate.Status {
+	case models.CertificatePending:
+		h.notifyStageApprovers(c, &certificate)
+	default:
+		h.notifyCreator(c, &certificate)
+	}
+
+	certificate, _ = h.findCertificate(c, accessRead)
+	c.JSON(http.StatusOK, gin.H{"data": certificate})
+}
+
+// certifyWork approves a payment certificate: it is revalued against the
+// locked subcontract, its work value is recorded in the cost ledger and the
+// subcontract totals are refreshed
+func certifyWork(tx *gorm.DB, certificate *models.PaymentCertificate, approvedAt time.Time) error {
+	var subcontract models.Subcontract
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&subcontract, certificate.SubcontractID).Error; err != nil {
+		return err
+	}
+
+	certificate.Calculate(&subcontract)
+	if certificate.WorkValue < 0 || certificate.CumulativeValue > subcontract.ContractValue+0.005 {
+		return fmt.Errorf("%w: %.2f%% is below the progress already certified", errCertificateValuation, certificate.ProgressPct)
+	}
+
+	certificate.Status = models.CertificateApproved
+	certificate.ApprovedAt = &approvedAt
+	if err := tx.Model(certificate).
+		Select("status", "approved_at", "cumulative_value", "previous_value", "work_value",
+			"retention_amount", "retention_released", "net_amount").
+		Updates(certificate).Error; err != nil {
+		return err
+	}
+
+	if err := costledger.Sync(tx, costledger.PaymentCertificateEntry(*certificate, &subcontract, approvedAt)); err != nil {
+		return err
+	}
+	return refreshSubcontractTotals(tx, subcontract.ID)
+}
+
+// findCertificate loads the :id payment certificate with its subcontract,
+// approvals and payments and checks project access
+func (h *SubcontractHandler) findCertificate(c *gin.Context, write bool) (models.PaymentCertificate, bool) {
+	var certificate models.PaymentCertificate
+	if err := h.DB.WithContext(c).Preload("Subcontract.Subcontractor").Preload("Creator").
+		Preload("Approvals", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Approvals.Approver").Preload("Approvals.OnBehalfOf").
+		Preload("Payments", func(db *gorm.DB) *gorm.DB { return db.Order("payment_date ASC, id ASC") }).
+		Preload("Payments.Payer").
+		First(&certificate, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment certificate not found"})
+			return certificate, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment certificate"})
+		return certificate, false
+	}
+
+	if !requireProjectAccess(h.DB.WithContext(c), c, certificate.ProjectID, write) {
+		return certificate, false
+	}
+	return certificate, true
+}
+
+// applyCertificateRequest validates a request and values the certificate
+// against its subcontract. Progress must move past the last approved
+// certificate, except on a retention release at completion.
+func (h *SubcontractHandler) applyCertificateRequest(c *gin.Context, certificate *models.PaymentCertificate, subcontract *models.Subcontract, req PaymentCertificateRequest) bool {
+	periodEnd, err := time.Parse("2006-01-02", req.PeriodEnd)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period end format. Use YYYY-MM-DD"})
+		return false
+	}
+
+	var previous float64
+	h.DB.WithContext(c).Model(&models.PaymentCertificate{}).
+		Where("subcontract_id = ? AND status = ?", subcontract.ID, models.CertificateApproved).
+		Select("COALESCE(MAX(progress_pct), 0)").
+		Scan(&previous)
+
+	if req.ReleaseRetention && req.ProgressPct < 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Retention can only be released at 100% progress"})
+		return false
+	}
+	if req.ProgressPct < previous || (req.ProgressPct == previous && !req.ReleaseRetention) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "Progress must be above the progress already certified",
+			"previous_progress": previous,
+		})
+		return false
+	}
+
+	certificate.PeriodEnd = periodEnd
+	certificate.ProgressPct = req.ProgressPct
+	certificate.ReleaseRetention = req.ReleaseRetention
+	certificate.Notes = req.Notes
+	certificate.Calculate(subcontract)
+
+	if certificate.NetAmount <= 0 && certificate.WorkValue <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment certificate has nothing to pay"})
+		return false
+	}
+	return true
+}
+
+// hasOpenCertificate checks if a subcontract has a draft or pending payment
+// certificate
+func (h *SubcontractHandler) hasOpenCertificate(db *gorm.DB, subcontractID uint) bool {
+	var count int64
+	db.Model(&models.PaymentCertificate{}).
+		Where("subcontract_id = ? AND status IN ?", subcontractID,
+			[]models.PaymentCertificateStatus{models.CertificateDraft, models.CertificatePending}).
+		Count(&count)
+	return count > 0
+}
+
+// authorizeStage checks whether the current user may act at a payment
+// certificate stage, directly through the stage role or through a
+// subcontract delegation from someone who holds it
+func (h *SubcontractHandler) authorizeStage(c *gin.Context, certificate *models.PaymentCertificate, stage models.ApprovalStage) (*models.Delegation, bool) {
+	userRole, _ := c.Get("role")
+	if role, _ := userRole.(string); role == stage.ApproverRole() || role == string(stage) {
+		return nil, true
+	}
+
+	delegation := findStageDelegation(h.DB.WithContext(c), middleware.GetUserID(c), stage,
+		models.DelegationScopeSubcontracts, certificate.NetAmount)
+	return delegation, delegation != nil
+}
+
+// actionableStages returns the payment certificate stages the current user
+// can act at, through their role or through delegations
+func (h *SubcontractHandler) actionableStages(c *gin.Context) []models.ApprovalStage {
+	userRole, _ := c.Get("role")
+	roleName, _ := userRole.(string)
+
+	stages := delegatedStages(h.DB.WithContext(c), middleware.GetUserID(c), models.DelegationScopeSubcontracts)
+	stages = append(stages, models.ApprovalStage(roleName))
+	for stage, role := range models.StageRoles {
+		if role == roleName {
+			stages = append(stages, stage)
+		}
+	}
+	return stages
+}
+
+// notifyStageApprovers notifies the approvers of a payment certificate's
+// current stage, or their delegates when they are away
+func (h *SubcontractHandler) notifyStageApprovers(c *gin.Context, certificate *models.PaymentCertificate) {
+	var users []models.User
+	h.DB.WithContext(c).Joins("JOIN roles ON users.role_id = roles.id").
+		Where("roles.name = ? AND users.is_active = ?", certificate.CurrentStage.ApproverRole(), true).
+		Find(&users)
+
+	title := certificate.CertNumber
+	if certificate.Subcontract != nil {
+		title = fmt.Sprintf("%s %s %s", certificate.Subcontract.ContractNumber, certificate.CertNumber, certificate.Subcontract.Title)
+	}
+
+	amount := certificate.NetAmount
+	recipients := map[uint]bool{}
+	for _, user := range users {
+		for _, recipientID := range notificationRecipients(h.DB.WithContext(c), user.ID, models.DelegationScopeSubcontracts, &amount) {
+			recipients[recipientID] = true
+		}
+	}
+
+	for recipientID := range recipients {
+		notification := models.Notification{
+			UserID:    recipientID,
+			Title:     fmt.Sprintf("Sertifikat Pembayaran Baru: %s", title),
+			Message:   fmt.Sprintf("Progres %.2f%%, nilai bersih %.2f. Menunggu approval %s.", certificate.ProgressPct, certificate.NetAmount, certificate.CurrentStage),
+			Type:      models.NotificationTypeApprovalRequest,
+			RelatedID: &certificate.ID,
+		}
+		h.DB.WithContext(c).Create(&notification)
+	}
+}
+
+// notifyCreator tells the creator a payment certificate was approved or
+// rejected
+func (h *SubcontractHandler) notifyCreator(c *gin.Context, certificate *models.PaymentCertificate) {
+	notification := models.Notification{
+		UserID:    certificate.CreatedBy,
+		Title:     "Status Sertifikat Pembayaran",
+		Message:   fmt.Sprintf("Sertifikat Pembayaran %s telah disetujui.", certificate.CertNumber),
+		Type:      models.NotificationTypeApprovalApproved,
+		RelatedID: &certificate.ID,
+	}
+	if certificate.Status == models.CertificateRejected {
+		notification.Message = fmt.Sprintf("Sertifikat Pembayaran %s telah ditolak.", certificate.CertNumber)
+		notification.Type = models.NotificationTypeApprovalRejected
+	}
+	h.DB.WithContext(c).Create(&notification)
+}
+
+// ===== PAYMENTS =====
+
+// CreateSubcontractPayment records a payment made against an approved
+// payment certificate
+func (h *SubcontractHandler) CreateSubcontractPayment(c *gin.Context) {
+	certificate, ok := h.findCertificate(c, accessWrite)
+	if !ok {
+		return
+	}
+	if certificate.Status != models.CertificateApproved {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payments can only be made against approved payment certificates"})
+		return
+	}
+
+	var req PaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	paymentDate, err := time.Parse("2006-01-02", req.PaymentDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment date format. Use YYYY-MM-DD"})
+		return
+	}
+	if req.Amount > certificate.Outstanding()+0.005 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":       "Payment exceeds the outstanding balance",
+			"outstanding": certificate.Outstanding(),
+		})
+		return
+	}
+
+	payment := models.SubcontractPayment{
+		CertificateID: certificate.ID,
+		Amount:        math.Round(req.Amount*100) / 100,
+		PaymentDate:   paymentDate,
+		Method:        req.Method,
+		Reference:     req.Reference,
+		Notes:         req.Notes,
+		PaidBy:        middleware.GetUserID(c),
+	}
+
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		number, err := generateDocumentNumber(tx, &models.SubcontractPayment{}, "SCP")
+		if err != nil {
+			return err
+		}
+		payment.VoucherNumber = number
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		return refreshCertificatePaid(tx, certificate.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+		return
+	}
+
+	h.DB.WithContext(c).First(&certificate, certificate.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Payment recorded successfully",
+		"data":        payment,
+		"certificate": certificate,
+		"outstanding": certificate.Outstanding(),
+	})
+}
+
+// DeleteSubcontractPayment removes a payment recorded by mistake
+func (h *SubcontractHandler) DeleteSubcontractPayment(c *gin.Context) {
+	var payment models.SubcontractPayment
+	if err := h.DB.WithContext(c).First(&payment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+
+	var certificate models.PaymentCertificate
+	if err := h.DB.WithContext(c).First(&certificate, payment.CertificateID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment certificate not found"})
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, certificate.ProjectID, accessWrite) {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&payment).Error; err != nil {
+			return err
+		}
+		return refreshCertificatePaid(tx, certificate.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete payment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment deleted successfully"})
+}
+
+// refreshCertificatePaid sums a payment certificate's payments into its
+// paid amount, then refreshes its subcontract. The certificate row is locked
+// so concurrent payments add up.
+func refreshCertificatePaid(tx *gorm.DB, certificateID uint) error {
+	var certificate models.PaymentCertificate
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&certificate, certificateID).Error; err != nil {
+		return err
+	}
+
+	var paid float64
+	if err := tx.Model(&models.SubcontractPayment{}).
+		Where("certificate_id = ?", certificateID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&paid).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&certificate).Update("paid_amount", math.Round(paid*100)/100).Error; err != nil {
+		return err
+	}
+	return refreshSubcontractTotals(tx, certificate.SubcontractID)
+}
+
+// refreshSubcontractTotals sums the approved certificates of a subcontract
+// into its certified, retention held and paid amounts
+func refreshSubcontractTotals(tx *gorm.DB, subcontractID uint) error {
+	var subcontract models.Subcontract
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&subcontract, subcontractID).Error; err != nil {
+		return err
+	}
+
+	var totals struct {
+		Certified float64
+		Retention float64
+		Paid      float64
+	}
+	if err := tx.Model(&models.PaymentCertificate{}).
+		Where("subcontract_id = ? AND status = ?", subcontractID, models.CertificateApproved).
+		Select("COALESCE(SUM(work_value), 0) AS certified, " +
+			"COALESCE(SUM(retention_amount - retention_released), 0) AS retention, " +
+			"COALESCE(SUM(paid_amount), 0) AS paid").
+		Scan(&totals).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&subcontract).Updates(map[string]interface{}{
+		"certified_amount": math.Round(totals.Certified*100) / 100,
+		"retention_held":   math.Round(totals.Retention*100) / 100,
+		"paid_amount":      math.Round(totals.Paid*100) / 100,
+	}).Error
+}
@@ -0,0 +1,1262 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/schedule"
+	"gorm.io/gorm"
+)
+
+// TaskRequest represents WBS task request body. Dates use the YYYY-MM-DD
+// format and duration is in calendar days.
+type TaskRequest struct {
+	Name            string `json:"name" binding:"required"`
+	Description     string `json:"description"`
+	ParentID        *uint  `json:"parent_id"`
+	PhaseID         *uint  `json:"phase_id"`
+	SortOrder       *int   `json:"sort_order"`
+	Duration        int    `json:"duration"`
+	ConstraintStart string `json:"constraint_start"`
+	AssigneeIDs     []uint `json:"assignee_ids"`
+}
+
+// TaskProgressRequest represents a manual task progress update
+type TaskProgressRequest struct {
+	Progress    *float64 `json:"progress" binding:"required"`
+	ActualStart string   `json:"actual_start"`
+	ActualEnd   string   `json:"actual_end"`
+}
+
+// DependencyRequest represents task dependency request body
+type DependencyRequest struct {
+	PredecessorID uint   `json:"predecessor_id" binding:"required"`
+	SuccessorID   uint   `json:"successor_id" binding:"required"`
+	Type          string `json:"type"` // FS (default), SS, FF or SF
+	Lag           int    `json:"lag"`
+}
+
+// TaskActivityRequest is work on a task reported in a daily report
+type TaskActivityRequest struct {
+	TaskID      uint    `json:"task_id" binding:"required"`
+	Description string  `json:"description"`
+	Progress    float64 `json:"progress"`
+}
+
+// GanttTask is one bar of a Gantt chart
+type GanttTask struct {
+	ID          uint          `json:"id"`
+	ParentID    *uint         `json:"parent_id"`
+	WBSCode     string        `json:"wbs_code"`
+	Name        string        `json:"name"`
+	Start       string        `json:"start"`
+	End         string        `json:"end"`
+	Duration    int           `json:"duration"`
+	Progress    float64       `json:"progress"`
+	IsSummary   bool          `json:"is_summary"`
+	IsMilestone bool          `json:"is_milestone"`
+	IsCritical  bool          `json:"is_critical"`
+	TotalFloat  int           `json:"total_float"`
+	FreeFloat   int           `json:"free_float"`
+	LateStart   string        `json:"late_start"`
+	LateEnd     string        `json:"late_end"`
+	ActualStart string        `json:"actual_start,omitempty"`
+	ActualEnd   string        `json:"actual_end,omitempty"`
+	PhaseID     *uint         `json:"phase_id"`
+	Assignees   []GanttPerson `json:"assignees"`
+}
+
+// GanttPerson is a user assigned to a Gantt task
+type GanttPerson struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// GanttLink is a dependency arrow of a Gantt chart
+type GanttLink struct {
+	ID     uint   `json:"id"`
+	Source uint   `json:"source"`
+	Target uint   `json:"target"`
+	Type   string `json:"type"`
+	Lag    int    `json:"lag"`
+}
+
+type TaskHandler struct {
+	DB *gorm.DB
+}
+
+// NewTaskHandler creates a new task handler
+func NewTaskHandler(db *gorm.DB) *TaskHandler {
+	return &TaskHandler{DB: db}
+}
+
+// GetProjectTasks returns the tasks of a project in WBS order along with
+// their dependencies
+func (h *TaskHandler) GetProjectTasks(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	query := h.DB.WithContext(c).Preload("Assignees").Where("project_id = ?", projectID)
+	if assigneeID := c.Query("assignee_id"); assigneeID != "" {
+		assigned := h.DB.WithContext(c).Table("task_assignees").Select("task_id").Where("user_id = ?", assigneeID)
+		query = query.Where("id IN (?)", assigned)
+	}
+	if c.Query("critical") == "true" {
+		query = query.Where("is_critical = ?", true)
+	}
+
+	var tasks []models.Task
+	if err := query.Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+		return
+	}
+	sortByWBSCode(tasks)
+
+	var dependencies []models.TaskDependency
+	if err := h.DB.WithContext(c).Where("project_id = ?", projectID).Find(&dependencies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch task dependencies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         tasks,
+		"dependencies": dependencies,
+	})
+}
+
+// GetTask returns a task with its dependencies
+func (h *TaskHandler) GetTask(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	taskID, ok := parseTaskIDParam(c)
+	if !ok {
+		return
+	}
+
+	var task models.Task
+	if err := h.DB.WithContext(c).Preload("Assignees").Preload("Phase").
+		Where("id = ? AND project_id = ?", taskID, projectID).
+		First(&task).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	var predecessors, successors []models.TaskDependency
+	h.DB.WithContext(c).Preload("Predecessor").Where("successor_id = ?", task.ID).Find(&predecessors)
+	h.DB.WithContext(c).Preload("Successor").Where("predecessor_id = ?", task.ID).Find(&successors)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         task,
+		"predecessors": predecessors,
+		"successors":   successors,
+	})
+}
+
+// CreateTask adds a task to a project's WBS and reschedules the project
+func (h *TaskHandler) CreateTask(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req TaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	task := models.Task{ProjectID: projectID}
+	assignees, ok := applyTaskRequest(c, h.DB.WithContext(c), &task, req)
+	if !ok {
+		return
+	}
+
+	if req.SortOrder == nil {
+		var maxOrder int
+		siblings := h.DB.WithContext(c).Model(&models.Task{}).Where("project_id = ?", projectID)
+		if task.ParentID != nil {
+			siblings = siblings.Where("parent_id = ?", *task.ParentID)
+		} else {
+			siblings = siblings.Where("parent_id IS NULL")
+		}
+		siblings.Select("COALESCE(MAX(sort_order), 0)").Scan(&maxOrder)
+		task.SortOrder = maxOrder + 1
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Assignees").Create(&task).Error; err != nil {
+			return err
+		}
+		if len(assignees) > 0 {
+			if err := tx.Model(&task).Association("Assignees").Replace(assignees); err != nil {
+				return err
+			}
+		}
+		return refreshTasks(tx, projectID, middleware.GetUserID(c))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Assignees").First(&task, task.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Task created successfully",
+		"data":    task,
+	})
+}
+
+// UpdateTask updates a task's details and reschedules the project.
+// Assignees are replaced when assignee_ids is given.
+func (h *TaskHandler) UpdateTask(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	taskID, ok := parseTaskIDParam(c)
+	if !ok {
+		return
+	}
+
+	task, err := findProjectTask(h.DB.WithContext(c), projectID, taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	var req TaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	assignees, ok := applyTaskRequest(c, h.DB.WithContext(c), &task, req)
+	if !ok {
+		return
+	}
+
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Assignees").Save(&task).Error; err != nil {
+			return err
+		}
+		if req.AssigneeIDs != nil {
+			if err := tx.Model(&task).Association("Assignees").Replace(assignees); err != nil {
+				return err
+			}
+		}
+		return refreshTasks(tx, projectID, middleware.GetUserID(c))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Assignees").First(&task, task.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task updated successfully",
+		"data":    task,
+	})
+}
+
+// DeleteTask removes a task together with its subtasks and their
+// dependencies, then reschedules the project
+func (h *TaskHandler) DeleteTask(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	taskID, ok := parseTaskIDParam(c)
+	if !ok {
+		return
+	}
+
+	task, err := findProjectTask(h.DB.WithContext(c), projectID, taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		ids, err := taskSubtree(tx, projectID, task.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("predecessor_id IN ? OR successor_id IN ?", ids, ids).Delete(&models.TaskDependency{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", ids).Delete(&models.Task{}).Error; err != nil {
+			return err
+		}
+		return refreshTasks(tx, projectID, middleware.GetUserID(c))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully"})
+}
+
+// UpdateTaskProgress sets a task's progress and actual dates by hand. The
+// actual start defaults to today once work begins and the actual end to
+// today when the task is complete.
+func (h *TaskHandler) UpdateTaskProgress(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	taskID, ok := parseTaskIDParam(c)
+	if !ok {
+		return
+	}
+
+	task, err := findProjectTask(h.DB.WithContext(c), projectID, taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	if task.IsSummary {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The progress of a summary task rolls up from its subtasks"})
+		return
+	}
+
+	var req TaskProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if *req.Progress < 0 || *req.Progress > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Progress must be between 0 and 100"})
+		return
+	}
+
+	actualStart, err := parseOptionalDate(req.ActualStart)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actual start format. Use YYYY-MM-DD"})
+		return
+	}
+	actualEnd, err := parseOptionalDate(req.ActualEnd)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actual end format. Use YYYY-MM-DD"})
+		return
+	}
+
+	today := truncateDay(time.Now())
+	task.Progress = *req.Progress
+	if actualStart != nil {
+		task.ActualStart = actualStart
+	} else if task.ActualStart == nil && task.Progress > 0 {
+		task.ActualStart = &today
+	}
+	switch {
+	case task.Progress < 100:
+		task.ActualEnd = nil
+	case actualEnd != nil:
+		task.ActualEnd = actualEnd
+	case task.ActualEnd == nil:
+		task.ActualEnd = &today
+	}
+
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&task).Select("progress", "actual_start", "actual_end").Updates(&task).Error; err != nil {
+			return err
+		}
+		return refreshTasks(tx, projectID, middleware.GetUserID(c))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task progress updated successfully",
+		"data":    task,
+	})
+}
+
+// CreateTaskDependency links two tasks of a project and reschedules it.
+// Dependencies that would form a loop are rejected.
+func (h *TaskHandler) CreateTaskDependency(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	var req DependencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	depType := schedule.DependencyType(strings.ToUpper(req.Type))
+	if depType == "" {
+		depType = schedule.FinishToStart
+	}
+	if !depType.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dependency type. Use FS, SS, FF or SF"})
+		return
+	}
+	if req.PredecessorID == req.SuccessorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A task cannot depend on itself"})
+		return
+	}
+
+	for _, id := range []uint{req.PredecessorID, req.SuccessorID} {
+		task, err := findProjectTask(h.DB.WithContext(c), projectID, id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Task not found in this project"})
+			return
+		}
+		if task.IsSummary {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Summary tasks cannot have dependencies, link their subtasks instead"})
+			return
+		}
+	}
+
+	var existing int64
+	h.DB.WithContext(c).Model(&models.TaskDependency{}).
+		Where("predecessor_id = ? AND successor_id = ?", req.PredecessorID, req.SuccessorID).
+		Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "These tasks are already linked"})
+		return
+	}
+
+	dependency := models.TaskDependency{
+		ProjectID:     projectID,
+		PredecessorID: req.PredecessorID,
+		SuccessorID:   req.SuccessorID,
+		Type:          string(depType),
+		Lag:           req.Lag,
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&dependency).Error; err != nil {
+			return err
+		}
+		return refreshTasks(tx, projectID, middleware.GetUserID(c))
+	})
+	if err != nil {
+		if errors.Is(err, schedule.ErrCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This dependency would create a loop"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task dependency"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Task dependency created successfully",
+		"data":    dependency,
+	})
+}
+
+// DeleteTaskDependency removes a dependency and reschedules the project
+func (h *TaskHandler) DeleteTaskDependency(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	dependencyID, err := strconv.ParseUint(c.Param("dependencyId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dependency ID"})
+		return
+	}
+
+	var dependency models.TaskDependency
+	if err := h.DB.WithContext(c).Where("id = ? AND project_id = ?", dependencyID, projectID).First(&dependency).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task dependency not found"})
+		return
+	}
+
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&dependency).Error; err != nil {
+			return err
+		}
+		return refreshTasks(tx, projectID, middleware.GetUserID(c))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task dependency"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task dependency deleted successfully"})
+}
+
+// RescheduleProject recomputes a project's schedule, e.g. after its start
+// date has moved
+func (h *TaskHandler) RescheduleProject(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessWrite) {
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		return refreshTasks(tx, projectID, middleware.GetUserID(c))
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reschedule project"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project rescheduled successfully"})
+}
+
+// GetGantt returns a project's schedule shaped for Gantt chart libraries:
+// tasks in WBS order, dependency links and the critical path
+func (h *TaskHandler) GetGantt(c *gin.Context) {
+	projectID, ok := parseProjectIDParam(c)
+	if !ok {
+		return
+	}
+	if !requireProjectAccess(h.DB.WithContext(c), c, projectID, accessRead) {
+		return
+	}
+
+	var project models.Project
+	if err := h.DB.WithContext(c).First(&project, projectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	var tasks []models.Task
+	if err := h.DB.WithContext(c).Preload("Assignees").Where("project_id = ?", projectID).Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+		return
+	}
+	sortByWBSCode(tasks)
+
+	var dependencies []models.TaskDependency
+	if err := h.DB.WithContext(c).Where("project_id = ?", projectID).Order("id ASC").Find(&dependencies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch task dependencies"})
+		return
+	}
+
+	ganttTasks := make([]GanttTask, 0, len(tasks))
+	var critical []models.Task
+	var finish *time.Time
+	for _, task := range tasks {
+		item := GanttTask{
+			ID:          task.ID,
+			ParentID:    task.ParentID,
+			WBSCode:     task.WBSCode,
+			Name:        task.Name,
+			Start:       formatDate(task.PlannedStart),
+			End:         formatDate(task.PlannedEnd),
+			Duration:    task.Duration,
+			Progress:    task.Progress,
+			IsSummary:   task.IsSummary,
+			IsMilestone: !task.IsSummary && task.Duration == 0,
+			IsCritical:  task.IsCritical,
+			TotalFloat:  task.TotalFloat,
+			FreeFloat:   task.FreeFloat,
+			LateStart:   formatDate(task.LateStart),
+			LateEnd:     formatDate(task.LateEnd),
+			ActualStart: formatDate(task.ActualStart),
+			ActualEnd:   formatDate(task.ActualEnd),
+			PhaseID:     task.PhaseID,
+			Assignees:   make([]GanttPerson, 0, len(task.Assignees)),
+		}
+		for _, user := range task.Assignees {
+			item.Assignees = append(item.Assignees, GanttPerson{ID: user.ID, Name: user.Name})
+		}
+		ganttTasks = append(ganttTasks, item)
+
+		if task.IsCritical && !task.IsSummary {
+			critical = append(critical, task)
+		}
+		if task.PlannedEnd != nil && (finish == nil || task.PlannedEnd.After(*finish)) {
+			finish = task.PlannedEnd
+		}
+	}
+
+	sort.SliceStable(critical, func(i, j int) bool {
+		return critical[i].PlannedStart != nil && critical[j].PlannedStart != nil &&
+			critical[i].PlannedStart.Before(*critical[j].PlannedStart)
+	})
+	criticalPath := make([]uint, 0, len(critical))
+	for _, task := range critical {
+		criticalPath = append(criticalPath, task.ID)
+	}
+
+	links := make([]GanttLink, 0, len(dependencies))
+	for _, dep := range dependencies {
+		links = append(links, GanttLink{
+			ID:     dep.ID,
+			Source: dep.PredecessorID,
+			Target: dep.SuccessorID,
+			Type:   dep.Type,
+			Lag:    dep.Lag,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"project": gin.H{
+				"id":               project.ID,
+				"name":             project.Name,
+				"start_date":       project.StartDate.Format("2006-01-02"),
+				"end_date":         project.EndDate.Format("2006-01-02"),
+				"scheduled_finish": formatDate(finish),
+			},
+			"tasks":         ganttTasks,
+			"links":         links,
+			"critical_path": criticalPath,
+		},
+	})
+}
+
+// taskActivitiesFromRequest validates the task work of a daily report. Tasks
+// must belong to the report's project and must not be summary tasks.
+func taskActivitiesFromRequest(c *gin.Context, db *gorm.DB, projectID uint, reqs []TaskActivityRequest) ([]models.DailyReportActivity, bool) {
+	activities := make([]models.DailyReportActivity, 0, len(reqs))
+	seen := make(map[uint]bool, len(reqs))
+	for _, req := range reqs {
+		if req.Progress < 0 || req.Progress > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Task progress must be between 0 and 100"})
+			return nil, false
+		}
+		if seen[req.TaskID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Each task can only be reported once per daily report"})
+			return nil, false
+		}
+		seen[req.TaskID] = true
+
+		task, err := findProjectTask(db, projectID, req.TaskID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Task not found in this project"})
+			return nil, false
+		}
+		if task.IsSummary {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Report work on subtasks, summary task progress rolls up from them"})
+			return nil, false
+		}
+
+		activities = append(activities, models.DailyReportActivity{
+			TaskID:      req.TaskID,
+			Description: req.Description,
+			Progress:    req.Progress,
+		})
+	}
+	return activities, true
+}
+
+// activityTaskIDs returns the tasks the activities report on
+func activityTaskIDs(activities []models.DailyReportActivity) []uint {
+	ids := make([]uint, 0, len(activities))
+	for _, activity := range activities {
+		ids = append(ids, activity.TaskID)
+	}
+	return ids
+}
+
+// syncFieldProgress sets each task's progress to the one reported in its
+// latest daily report, with the actual start on the first report showing
+// progress and the actual end on the report completing it. Tasks no longer
+// in any report keep their progress. The project is then refreshed.
+func syncFieldProgress(tx *gorm.DB, projectID uint, taskIDs []uint, userID uint) error {
+	if len(taskIDs) == 0 {
+		return nil
+	}
+
+	type reported struct {
+		Progress float64
+		Date     time.Time
+	}
+
+	for _, taskID := range uniqueIDs(taskIDs) {
+		reports := tx.Table("daily_report_activities").
+			Joins("JOIN daily_reports ON daily_reports.id = daily_report_activities.daily_report_id AND daily_reports.deleted_at IS NULL").
+			Where("daily_report_activities.task_id = ?", taskID)
+
+		var latest []reported
+		if err := reports.Session(&gorm.Session{}).
+			Select("daily_report_activities.progress", "daily_reports.date").
+			Order("daily_reports.date DESC, daily_report_activities.id DESC").
+			Limit(1).
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+		if len(latest) == 0 {
+			continue
+		}
+
+		var started []reported
+		if err := reports.Session(&gorm.Session{}).
+			Select("daily_report_activities.progress", "daily_reports.date").
+			Where("daily_report_activities.progress > 0").
+			Order("daily_reports.date ASC").
+			Limit(1).
+			Scan(&started).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"progress":     latest[0].Progress,
+			"actual_start": nil,
+			"actual_end":   nil,
+		}
+		if len(started) > 0 {
+			updates["actual_start"] = started[0].Date
+		}
+		if latest[0].Progress >= 100 {
+			updates["actual_end"] = latest[0].Date
+		}
+		if err := tx.Model(&models.Task{}).Where("id = ?", taskID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	return refreshTasks(tx, projectID, userID)
+}
+
+// parseTaskIDParam reads the :taskId path parameter
+func parseTaskIDParam(c *gin.Context) (uint, bool) {
+	taskID, err := strconv.ParseUint(c.Param("taskId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return 0, false
+	}
+	return uint(taskID), true
+}
+
+// findProjectTask checks that a task belongs to a project
+func findProjectTask(db *gorm.DB, projectID, taskID uint) (models.Task, error) {
+	var task models.Task
+	err := db.Where("id = ? AND project_id = ?", taskID, projectID).First(&task).Error
+	return task, err
+}
+
+// applyTaskRequest validates a task request and copies it onto the task. It
+// returns the requested assignees, who must be on the project.
+func applyTaskRequest(c *gin.Context, db *gorm.DB, task *models.Task, req TaskRequest) ([]models.User, bool) {
+	if req.Duration < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Duration must not be negative"})
+		return nil, false
+	}
+
+	constraintStart, err := parseOptionalDate(req.ConstraintStart)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid constraint start format. Use YYYY-MM-DD"})
+		return nil, false
+	}
+
+	if req.PhaseID != nil {
+		if _, err := findProjectPhase(db, task.ProjectID, *req.PhaseID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Phase not found in this project"})
+			return nil, false
+		}
+	}
+
+	if req.ParentID != nil {
+		if _, err := findProjectTask(db, task.ProjectID, *req.ParentID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent task not found in this project"})
+			return nil, false
+		}
+		if task.ID != 0 {
+			subtree, err := taskSubtree(db, task.ProjectID, task.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task hierarchy"})
+				return nil, false
+			}
+			for _, id := range subtree {
+				if id == *req.ParentID {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "A task cannot be moved under itself or its subtasks"})
+					return nil, false
+				}
+			}
+		}
+
+		// The parent becomes a summary task, which cannot have dependencies
+		var linked int64
+		db.Model(&models.TaskDependency{}).
+			Where("predecessor_id = ? OR successor_id = ?", *req.ParentID, *req.ParentID).
+			Count(&linked)
+		if linked > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Remove the parent task's dependencies before adding subtasks"})
+			return nil, false
+		}
+	}
+
+	var assignees []models.User
+	if len(req.AssigneeIDs) > 0 {
+		var project models.Project
+		if err := db.Select("id", "manager_id").First(&project, task.ProjectID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return nil, false
+		}
+		members := db.Model(&models.ProjectMember{}).Select("user_id").Where("project_id = ?", task.ProjectID)
+		if err := db.Where("id IN ?", req.AssigneeIDs).
+			Where("id = ? OR id IN (?)", project.ManagerID, members).
+			Find(&assignees).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignees"})
+			return nil, false
+		}
+		if len(assignees) != len(uniqueIDs(req.AssigneeIDs)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Assignees must be members of the project"})
+			return nil, false
+		}
+	}
+
+	task.Name = strings.TrimSpace(req.Name)
+	task.Description = req.Description
+	task.ParentID = req.ParentID
+	task.PhaseID = req.PhaseID
+	task.Duration = req.Duration
+	task.ConstraintStart = constraintStart
+	if req.SortOrder != nil {
+		task.SortOrder = *req.SortOrder
+	}
+	return assignees, true
+}
+
+// uniqueIDs drops repeated IDs
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// taskSubtree returns the ID of a task and of all its subtasks
+func taskSubtree(db *gorm.DB, projectID, taskID uint) ([]uint, error) {
+	var tasks []models.Task
+	if err := db.Select("id", "parent_id").Where("project_id = ?", projectID).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	children := make(map[uint][]uint)
+	for _, task := range tasks {
+		if task.ParentID != nil {
+			children[*task.ParentID] = append(children[*task.ParentID], task.ID)
+		}
+	}
+
+	ids := []uint{taskID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids, nil
+}
+
+// sortByWBSCode orders tasks by their WBS code, comparing each level
+// numerically so 1.2 comes before 1.10
+func sortByWBSCode(tasks []models.Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a := strings.Split(tasks[i].WBSCode, ".")
+		b := strings.Split(tasks[j].WBSCode, ".")
+		for k := 0; k < len(a) && k < len(b); k++ {
+			x, _ := strconv.Atoi(a[k])
+			y, _ := strconv.Atoi(b[k])
+			if x != y {
+				return x < y
+			}
+		}
+		return len(a) < len(b)
+	})
+}
+
+// refreshTasks renumbers a project's WBS, schedules its tasks with the
+// critical path method from the project start date, rolls summary tasks up
+// from their subtasks and carries task progress over to the project phases
+func refreshTasks(tx *gorm.DB, projectID, userID uint) error {
+	var project models.Project
+	if err := tx.First(&project, projectID).Error; err != nil {
+		return err
+	}
+
+	var stored []models.Task
+	if err := tx.Where("project_id = ?", projectID).Find(&stored).Error; err != nil {
+		return err
+	}
+	var dependencies []models.TaskDependency
+	if err := tx.Where("project_id = ?", projectID).Find(&dependencies).Error; err != nil {
+		return err
+	}
+
+	tasks, children := orderTasks(stored)
+
+	base := truncateDay(project.StartDate)
+	if project.StartDate.IsZero() {
+		base = truncateDay(time.Now())
+	}
+
+	activities := make([]schedule.Activity, 0, len(tasks))
+	for _, task := range tasks {
+		if task.IsSummary {
+			continue
+		}
+		activity := schedule.Activity{ID: task.ID, Duration: task.Duration}
+		if task.ConstraintStart != nil {
+			activity.MinStart = dayOffset(base, *task.ConstraintStart)
+		}
+		activities = append(activities, activity)
+	}
+	links := make([]schedule.Link, 0, len(dependencies))
+	for _, dep := range dependencies {
+		links = append(links, schedule.Link{
+			Predecessor: dep.PredecessorID,
+			Successor:   dep.SuccessorID,
+			Type:        schedule.DependencyType(dep.Type),
+			Lag:         dep.Lag,
+		})
+	}
+
+	plan, err := schedule.Compute(activities, links)
+	if err != nil {
+		return err
+	}
+
+	// Summary tasks come before their subtasks, so walking backwards rolls
+	// the deepest levels up first
+	results := plan.Results
+	for i := len(tasks) - 1; i >= 0; i-- {
+		task := &tasks[i]
+		if task.IsSummary {
+			results[task.ID] = rollUpSummary(task, children[task.ID], results)
+			continue
+		}
+		r := results[task.ID]
+		task.TotalFloat = r.TotalFloat
+		task.FreeFloat = r.FreeFloat
+		task.IsCritical = r.Critical
+	}
+	for i := range tasks {
+		r := results[tasks[i].ID]
+		tasks[i].PlannedStart, tasks[i].PlannedEnd = scheduleDates(base, r.EarlyStart, r.EarlyFinish)
+		tasks[i].LateStart, tasks[i].LateEnd = scheduleDates(base, r.LateStart, r.LateFinish)
+	}
+
+	original := make(map[uint]models.Task, len(stored))
+	for _, task := range stored {
+		original[task.ID] = task
+	}
+	for i := range tasks {
+		if !taskScheduleChanged(original[tasks[i].ID], tasks[i]) {
+			continue
+		}
+		if err := tx.Model(&tasks[i]).Select(
+			"wbs_code", "is_summary", "duration", "progress", "actual_start", "actual_end",
+			"planned_start", "planned_end", "late_start", "late_end",
+			"total_float", "free_float", "is_critical",
+		).Updates(&tasks[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := syncPhaseProgress(tx, projectID, tasks, children, userID); err != nil {
+		return err
+	}
+
+	// Completed tasks may reach billing milestones
+	return billReachedMilestones(tx, projectID)
+}
+
+// orderTasks returns tasks depth-first in WBS order with their WBS codes and
+// summary flags set, and the subtasks of each task. Tasks whose parent is
+// gone are treated as top level.
+func orderTasks(tasks []models.Task) ([]models.Task, map[uint][]*models.Task) {
+	exists := make(map[uint]bool, len(tasks))
+	for _, task := range tasks {
+		exists[task.ID] = true
+	}
+
+	byParent := make(map[uint][]int)
+	for i, task := range tasks {
+		var parent uint
+		if task.ParentID != nil && exists[*task.ParentID] {
+			parent = *task.ParentID
+		}
+		byParent[parent] = append(byParent[parent], i)
+	}
+	for _, siblings := range byParent {
+		sort.SliceStable(siblings, func(i, j int) bool {
+			a, b := tasks[siblings[i]], tasks[siblings[j]]
+			if a.SortOrder != b.SortOrder {
+				return a.SortOrder < b.SortOrder
+			}
+			return a.ID < b.ID
+		})
+	}
+
+	ordered := make([]models.Task, 0, len(tasks))
+	var walk func(parent uint, prefix string)
+	walk = func(parent uint, prefix string) {
+		for n, i := range byParent[parent] {
+			task := tasks[i]
+			task.WBSCode = prefix + strconv.Itoa(n+1)
+			task.IsSummary = len(byParent[task.ID]) > 0
+			ordered = append(ordered, task)
+			walk(task.ID, task.WBSCode+".")
+		}
+	}
+	walk(0, "")
+
+	children := make(map[uint][]*models.Task)
+	for i := range ordered {
+		if parent := ordered[i].ParentID; parent != nil && exists[*parent] {
+			children[*parent] = append(children[*parent], &ordered[i])
+		}
+	}
+	return ordered, children
+}
+
+// rollUpSummary sets a summary task's duration, float, progress and actual
+// dates from its subtasks and returns its span. Progress is weighted by
+// duration.
+func rollUpSummary(task *models.Task, subtasks []*models.Task, results map[uint]schedule.Result) schedule.Result {
+	var span schedule.Result
+	totalDuration := 0
+	weighted := 0.0
+	sum := 0.0
+	complete := true
+	task.ActualStart = nil
+	task.ActualEnd = nil
+	task.IsCritical = false
+
+	for i, sub := range subtasks {
+		r := results[sub.ID]
+		if i == 0 {
+			span = r
+			task.TotalFloat = sub.TotalFloat
+			task.FreeFloat = sub.FreeFloat
+		}
+		span.EarlyStart = min(span.EarlyStart, r.EarlyStart)
+		span.EarlyFinish = max(span.EarlyFinish, r.EarlyFinish)
+		span.LateStart = min(span.LateStart, r.LateStart)
+		span.LateFinish = max(span.LateFinish, r.LateFinish)
+		task.TotalFloat = min(task.TotalFloat, sub.TotalFloat)
+		task.FreeFloat = min(task.FreeFloat, sub.FreeFloat)
+		task.IsCritical = task.IsCritical || sub.IsCritical
+
+		totalDuration += sub.Duration
+		weighted += float64(sub.Duration) * sub.Progress
+		sum += sub.Progress
+
+		if sub.ActualStart != nil && (task.ActualStart == nil || sub.ActualStart.Before(*task.ActualStart)) {
+			task.ActualStart = sub.ActualStart
+		}
+		if sub.ActualEnd == nil {
+			complete = false
+		} else if task.ActualEnd == nil || sub.ActualEnd.After(*task.ActualEnd) {
+			task.ActualEnd = sub.ActualEnd
+		}
+	}
+
+	if !complete {
+		task.ActualEnd = nil
+	}
+	task.Duration = span.EarlyFinish - span.EarlyStart
+	switch {
+	case totalDuration > 0:
+		task.Progress = math.Round(weighted/float64(totalDuration)*100) / 100
+	case len(subtasks) > 0:
+		task.Progress = math.Round(sum/float64(len(subtasks))*100) / 100
+	}
+	return span
+}
+
+// syncPhaseProgress sets the progress of every phase that has tasks to the
+// duration-weighted progress of its tasks and, when a phase changed,
+// recalculates the project progress. Subtasks belong to their parent's phase
+// unless they name their own.
+func syncPhaseProgress(tx *gorm.DB, projectID uint, tasks []models.Task, children map[uint][]*models.Task, userID uint) error {
+	type phaseTotal struct {
+		duration, weighted, sum float64
+		count                   int
+	}
+	totals := make(map[uint]*phaseTotal)
+
+	var collect func(task *models.Task, phaseID *uint)
+	collect = func(task *models.Task, phaseID *uint) {
+		if task.PhaseID != nil {
+			phaseID = task.PhaseID
+		}
+		if task.IsSummary {
+			for _, sub := range children[task.ID] {
+				collect(sub, phaseID)
+			}
+			return
+		}
+		if phaseID == nil {
+			return
+		}
+		total, ok := totals[*phaseID]
+		if !ok {
+			total = &phaseTotal{}
+			totals[*phaseID] = total
+		}
+		total.duration += float64(task.Duration)
+		total.weighted += float64(task.Duration) * task.Progress
+		total.sum += task.Progress
+		total.count++
+	}
+	isSubtask := make(map[uint]bool)
+	for _, subtasks := range children {
+		for _, sub := range subtasks {
+			isSubtask[sub.ID] = true
+		}
+	}
+	for i := range tasks {
+		if !isSubtask[tasks[i].ID] {
+			collect(&tasks[i], nil)
+		}
+	}
+	if len(totals) == 0 {
+		return nil
+	}
+
+	var phases []models.ProjectPhase
+	if err := tx.Where("project_id = ?", projectID).Find(&phases).Error; err != nil {
+		return err
+	}
+
+	changed := false
+	for _, phase := range phases {
+		total, ok := totals[phase.ID]
+		if !ok {
+			continue
+		}
+		progress := total.sum / float64(total.count)
+		if total.duration > 0 {
+			progress = total.weighted / total.duration
+		}
+		progress = math.Round(progress*100) / 100
+		if progress == phase.Progress {
+			continue
+		}
+		if err := tx.Model(&phase).Update("progress", progress).Error; err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	_, err := recalculateProgress(tx, projectID, userID)
+	return err
+}
+
+// taskScheduleChanged checks if refreshTasks changed any computed field of
+// a task
+func taskScheduleChanged(before, after models.Task) bool {
+	return before.WBSCode != after.WBSCode ||
+		before.IsSummary != after.IsSummary ||
+		before.Duration != after.Duration ||
+		before.Progress != after.Progress ||
+		!sameDate(before.ActualStart, after.ActualStart) ||
+		!sameDate(before.ActualEnd, after.ActualEnd) ||
+		!sameDate(before.PlannedStart, after.PlannedStart) ||
+		!sameDate(before.PlannedEnd, after.PlannedEnd) ||
+		!sameDate(before.LateStart, after.LateStart) ||
+		!sameDate(before.LateEnd, after.LateEnd) ||
+		before.TotalFloat != after.TotalFloat ||
+		before.FreeFloat != after.FreeFloat ||
+		before.IsCritical != after.IsCritical
+}
+
+// sameDate checks if two optional dates fall on the same day
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return formatDate(a) == formatDate(b)
+}
+
+// formatDate formats an optional date as YYYY-MM-DD
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// truncateDay returns midnight of t's day
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// dayOffset returns the number of days from base to t's day
+func dayOffset(base, t time.Time) int {
+	return int(math.Round(truncateDay(t).Sub(base).Hours() / 24))
+}
+
+// scheduleDates converts a start and finish day offset into the first and
+// last day worked. Milestones start and end on the same day.
+func scheduleDates(base time.Time, start, finish int) (*time.Time, *time.Time) {
+	last := finish - 1
+	if last < start {
+		last = start
+	}
+	first := base.AddDate(0, 0, start)
+	end := base.AddDate(0, 0, last)
+	return &first, &end
+}
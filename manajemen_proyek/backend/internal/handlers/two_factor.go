@@ -0,0 +1,647 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/config"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/token"
+	"github.com/unipro/project-management/pkg/totp"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is the number of recovery codes issued at a time
+const recoveryCodeCount = 10
+
+// errChallengeInvalid is returned when a login challenge is unknown, expired,
+// already used or has run out of attempts
+var errChallengeInvalid = errors.New("login challenge is invalid or has expired")
+
+// errChallengeAttempts is returned when a user has entered too many wrong
+// codes across their login challenges
+var errChallengeAttempts = errors.New("too many invalid authentication codes")
+
+// ChallengeRequest carries the token returned by Login when a second factor
+// is needed
+type ChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+// ChallengeCodeRequest answers a login challenge with a TOTP or recovery code
+type ChallengeCodeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// TwoFactorCodeRequest represents a request confirmed with a TOTP or
+// recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest represents disable 2FA request body
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// VerifyTwoFactor completes a login by answering a verify challenge
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req ChallengeCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	challenge, user, err := findLoginChallenge(h.DB.WithContext(c), req.ChallengeToken, models.ChallengeVerify)
+	if err != nil {
+		challengeError(c, err)
+		return
+	}
+	if !h.allowChallengeAttempt(c, user) {
+		return
+	}
+
+	ok, err := verifySecondFactor(h.DB.WithContext(c), user, req.Code, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		failLoginChallenge(h.DB.WithContext(c), challenge)
+		h.failAttempt(c, user.Email, &user.ID, models.LoginReasonInvalidTwoFactor)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	h.completeLoginChallenge(c, challenge, user, nil)
+}
+
+// StartTwoFactorEnrollment returns a new TOTP secret to a user whose role
+// requires 2FA but who has not set it up, identified by an enroll challenge
+func (h *AuthHandler) StartTwoFactorEnrollment(c *gin.Context) {
+	var req ChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	_, user, err := findLoginChallenge(h.DB.WithContext(c), req.ChallengeToken, models.ChallengeEnroll)
+	if err != nil {
+		challengeError(c, err)
+		return
+	}
+
+	h.startSetup(c, user)
+}
+
+// ConfirmTwoFactorEnrollment enables 2FA with the first code from the
+// authenticator app and completes the login
+func (h *AuthHandler) ConfirmTwoFactorEnrollment(c *gin.Context) {
+	var req ChallengeCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	challenge, user, err := findLoginChallenge(h.DB.WithContext(c), req.ChallengeToken, models.ChallengeEnroll)
+	if err != nil {
+		challengeError(c, err)
+		return
+	}
+	if !h.allowChallengeAttempt(c, user) {
+		return
+	}
+
+	codes, ok, err := enableTwoFactor(h.DB.WithContext(c), user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	if !ok {
+		failLoginChallenge(h.DB.WithContext(c), challenge)
+		h.failAttempt(c, user.Email, &user.ID, models.LoginReasonInvalidTwoFactor)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	h.completeLoginChallenge(c, challenge, user, codes)
+}
+
+// GetTwoFactorStatus returns the current user's 2FA state
+func (h *AuthHandler) GetTwoFactorStatus(c *gin.Context) {
+	user := middleware.GetUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	var remaining int64
+	if err := h.DB.WithContext(c).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Count(&remaining).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"enabled":                  user.TwoFactorEnabled,
+			"required":                 h.Config.Auth.RequiresTwoFactor(user.Role.Name),
+			"recovery_codes_remaining": remaining,
+		},
+	})
+}
+
+// SetupTwoFactor starts optional enrolment for the signed-in user
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	user := middleware.GetUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	h.startSetup(c, user)
+}
+
+// EnableTwoFactor confirms the pending secret for the signed-in user
+func (h *AuthHandler) EnableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	user := middleware.GetUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	codes, ok, err := enableTwoFactor(h.DB.WithContext(c), user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication enabled",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// DisableTwoFactor turns 2FA off for the signed-in user unless their role
+// requires it
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	user := middleware.GetUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	if h.Config.Auth.RequiresTwoFactor(user.Role.Name) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	ok, err := verifySecondFactor(h.DB.WithContext(c), user, req.Code, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	if err := clearTwoFactor(h.DB.WithContext(c), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the signed-in user's recovery codes
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	user := middleware.GetUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	// Only a TOTP code is accepted here, a recovery code would be replaced
+	// by the very request it authorises
+	ok, err := verifySecondFactor(h.DB.WithContext(c), user, req.Code, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	var codes []string
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Recovery codes regenerated",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// allowChallengeAttempt refuses a code while the account or IP is locked or
+// throttled, the same as a password attempt
+func (h *AuthHandler) allowChallengeAttempt(c *gin.Context, user *models.User) bool {
+	decision, err := h.Guard.Check(user.Email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	if !decision.Allowed {
+		h.refuseLogin(c, user.Email, &user.ID, decision)
+		return false
+	}
+	return true
+}
+
+// startSetup stores a fresh pending secret for the user and returns it with
+// the provisioning URI to render as a QR code
+func (h *AuthHandler) startSetup(c *gin.Context, user *models.User) {
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	if err := h.DB.WithContext(c).Model(&models.User{}).Where("id = ?", user.ID).
+		Update("two_factor_secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"secret":           secret,
+			"provisioning_uri": totp.ProvisioningURI(h.Config.Auth.TwoFactorIssuer, user.Email, secret),
+		},
+	})
+}
+
+// completeLoginChallenge burns the challenge and starts a session. Recovery
+// codes created during enrolment are returned alongside the tokens.
+func (h *AuthHandler) completeLoginChallenge(c *gin.Context, challenge *models.LoginChallenge, user *models.User, recoveryCodes []string) {
+	result := h.DB.WithContext(c).Model(&models.LoginChallenge{}).
+		Where("id = ? AND used_at IS NULL", challenge.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete login"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge is invalid or has expired"})
+		return
+	}
+
+	if err := h.Guard.Succeed(user.Email); err != nil {
+		log.Printf("Failed to reset login failures for user %d: %v", user.ID, err)
+	}
+
+	pair, err := issueSession(h.DB.WithContext(c), h.Config, c, user, challenge.DeviceName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	recordLoginAttempt(h.DB.WithContext(c), c, user.Email, &user.ID, true, models.LoginReasonSuccess)
+
+	user.Password = ""
+
+	data := gin.H{
+		"access_token":  pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_at":    pair.ExpiresAt,
+		"user":          user,
+	}
+	if recoveryCodes != nil {
+		data["recovery_codes"] = recoveryCodes
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Login successful",
+		"data":    data,
+	})
+}
+
+// needsSecondFactor reports the challenge purpose a user must go through
+// before a session is issued, or "" when the password is enough
+func needsSecondFactor(cfg *config.Config, user *models.User) string {
+	if user.TwoFactorEnabled {
+		return models.ChallengeVerify
+	}
+	if cfg.Auth.RequiresTwoFactor(user.Role.Name) {
+		return models.ChallengeEnroll
+	}
+	return ""
+}
+
+// createLoginChallenge stores a challenge and returns its plain token
+func createLoginChallenge(db *gorm.DB, cfg *config.Config, user *models.User, purpose, deviceName string) (string, *models.LoginChallenge, error) {
+	plain, hash, err := token.Generate()
+	if err != nil {
+		return "", nil, err
+	}
+
+	challenge := models.LoginChallenge{
+		UserID:     user.ID,
+		TokenHash:  hash,
+		Purpose:    purpose,
+		DeviceName: deviceName,
+		ExpiresAt:  time.Now().Add(cfg.Auth.ChallengeExpiry),
+	}
+	if err := db.Create(&challenge).Error; err != nil {
+		return "", nil, err
+	}
+
+	// Expired challenges are of no use to anyone
+	db.Where("expires_at < ?", time.Now().Add(-24*time.Hour)).Delete(&models.LoginChallenge{})
+
+	return plain, &challenge, nil
+}
+
+// challengeResponse is the body returned instead of tokens when a second
+// factor is still needed
+func challengeResponse(plain string, challenge *models.LoginChallenge) gin.H {
+	message := "Two-factor authentication required"
+	if challenge.Purpose == models.ChallengeEnroll {
+		message = "Two-factor authentication must be set up for your role"
+	}
+
+	return gin.H{
+		"success": true,
+		"message": message,
+		"data": gin.H{
+			"two_factor_required": true,
+			"enrollment_required": challenge.Purpose == models.ChallengeEnroll,
+			"challenge_token":     plain,
+			"expires_at":          challenge.ExpiresAt,
+		},
+	}
+}
+
+// findLoginChallenge loads a usable challenge and its active user
+func findLoginChallenge(db *gorm.DB, plain, purpose string) (*models.LoginChallenge, *models.User, error) {
+	var challenge models.LoginChallenge
+	if err := db.Where("token_hash = ? AND purpose = ?", token.Hash(plain), purpose).First(&challenge).Error; err != nil {
+		return nil, nil, errChallengeInvalid
+	}
+	if !challenge.IsUsable() {
+		return nil, nil, errChallengeInvalid
+	}
+
+	var user models.User
+	if err := db.Preload("Role").First(&user, challenge.UserID).Error; err != nil {
+		return nil, nil, errChallengeInvalid
+	}
+	if !user.IsActive {
+		return nil, nil, errChallengeInvalid
+	}
+
+	exhausted, err := challengeAttemptsExhausted(db, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if exhausted {
+		return nil, nil, errChallengeAttempts
+	}
+
+	return &challenge, &user, nil
+}
+
+// challengeAttemptsExhausted checks if a user has entered
+// MaxChallengeAttempts wrong codes across their recent challenges
+func challengeAttemptsExhausted(db *gorm.DB, userID uint) (bool, error) {
+	var attempts int64
+	if err := db.Model(&models.LoginChallenge{}).
+		Where("user_id = ? AND created_at > ?", userID, time.Now().Add(-models.ChallengeAttemptWindow)).
+		Select("COALESCE(SUM(attempts), 0)").
+		Scan(&attempts).Error; err != nil {
+		return false, err
+	}
+	return attempts >= models.MaxChallengeAttempts, nil
+}
+
+// challengeError writes the response for a challenge that cannot be
+// answered
+func challengeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errChallengeAttempts):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many invalid authentication codes, please try again later"})
+	case errors.Is(err, errChallengeInvalid):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge is invalid or has expired"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	}
+}
+
+// failLoginChallenge counts a wrong code against the challenge and so
+// against the user's recent attempts
+func failLoginChallenge(db *gorm.DB, challenge *models.LoginChallenge) {
+	db.Model(&models.LoginChallenge{}).Where("id = ?", challenge.ID).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+}
+
+// verifySecondFactor checks a TOTP code, or when allowRecovery is set a
+// recovery code, for a user with 2FA enabled. Each code is accepted once.
+func verifySecondFactor(db *gorm.DB, user *models.User, code string, allowRecovery bool) (bool, error) {
+	if !user.TwoFactorEnabled || user.TwoFactorSecret == "" {
+		return false, nil
+	}
+
+	if step, ok := totp.ValidateAfter(user.TwoFactorSecret, code, time.Now(), user.TwoFactorLastStep); ok {
+		return acceptTOTPStep(db, user.ID, step)
+	}
+
+	if !allowRecovery {
+		return false, nil
+	}
+
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// acceptTOTPStep records a matched step, refusing steps that were already
+// used so an observed code cannot be replayed
+func acceptTOTPStep(db *gorm.DB, userID uint, step int64) (bool, error) {
+	result := db.Model(&models.User{}).
+		Where("id = ? AND two_factor_last_step < ?", userID, step).
+		Update("two_factor_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// enableTwoFactor checks a code against the pending secret and, when it
+// matches, turns 2FA on and returns a fresh set of recovery codes
+func enableTwoFactor(db *gorm.DB, user *models.User, code string) ([]string, bool, error) {
+	if user.TwoFactorEnabled || user.TwoFactorSecret == "" {
+		return nil, false, nil
+	}
+
+	step, ok := totp.Validate(user.TwoFactorSecret, code, time.Now())
+	if !ok {
+		return nil, false, nil
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"two_factor_enabled":   true,
+			"two_factor_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	user.TwoFactorEnabled = true
+	user.TwoFactorLastStep = step
+	return codes, true, nil
+}
+
+// clearTwoFactor removes the secret and recovery codes of a user
+func clearTwoFactor(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"two_factor_enabled":   false,
+			"two_factor_secret":    "",
+			"two_factor_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and creates a new
+// set, returning the plain codes to show once
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := token.NewRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode normalises a recovery code as typed by the user and
+// hashes it for lookup
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	return token.Hash(code)
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/middleware"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/listquery"
+	"github.com/unipro/project-management/pkg/loginguard"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type UserHandler struct {
+	DB    *gorm.DB
+	Guard *loginguard.Guard
+}
+
+// CreateUserRequest represents user creation request body
+type CreateUserRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
+	Phone    string `json:"phone"`
+	Position string `json:"position"`
+	RoleID   uint   `json:"role_id" binding:"required"`
+}
+
+// UpdateUserRequest represents user profile update request body
+type UpdateUserRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Phone    string `json:"phone"`
+	Position string `json:"position"`
+}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler(db *gorm.DB, guard *loginguard.Guard) *UserHandler {
+	return &UserHandler{DB: db, Guard: guard}
+}
+
+// userListSpec whitelists the filters and sort keys of the user list
+var userListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"is_active": {Column: "is_active", Type: listquery.Bool},
+		"role_id":   {Column: "role_id", Type: listquery.Number},
+	},
+	Search: []string{"name", "email", "position"},
+	Sorts: map[string]string{
+		"name":       "name",
+		"email":      "email",
+		"created_at": "created_at",
+	},
+	DefaultSort:  "name",
+	DefaultLimit: 20,
+	MaxLimit:     100,
+}
+
+// GetAllUsers returns a page of users with optional search, role and status filters
+func (h *UserHandler) GetAllUsers(c *gin.Context) {
+	query := h.DB.WithContext(c).Preload("Role")
+
+	if roleName := c.Query("role"); roleName != "" {
+		query = query.Joins("JOIN roles ON users.role_id = roles.id").Where("roles.name = ?", roleName)
+	}
+
+	var users []models.User
+	page, ok := findList(c, query, userListSpec, &users, "Failed to fetch users")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse(users, page))
+}
+
+// GetUserByID returns a single user
+func (h *UserHandler) GetUserByID(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+// CreateUser creates a new user account
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	// Check if email already exists
+	var existing models.User
+	if err := h.DB.WithContext(c).Where("email = ?", email).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		return
+	}
+
+	// Check if role exists
+	var role models.Role
+	if err := h.DB.WithContext(c).First(&role, req.RoleID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	user := models.User{
+		Name:     req.Name,
+		Email:    email,
+		Password: string(hashedPassword),
+		RoleID:   req.RoleID,
+		Phone:    req.Phone,
+		Position: req.Position,
+		IsActive: true,
+	}
+
+	if err := h.DB.WithContext(c).Create(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Role").First(&user, user.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "User created successfully",
+		"data":    user,
+	})
+}
+
+// UpdateUser updates a user's profile fields
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	// Check if new email conflicts with another user
+	if email != user.Email {
+		var existing models.User
+		if err := h.DB.WithContext(c).Where("email = ? AND id != ?", email, user.ID).First(&existing).Error; err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+			return
+		}
+	}
+
+	if err := h.DB.WithContext(c).Model(&user).Updates(map[string]interface{}{
+		"name":     req.Name,
+		"email":    email,
+		"phone":    req.Phone,
+		"position": req.Position,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Role").First(&user, user.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User updated successfully",
+		"data":    user,
+	})
+}
+
+// ChangeUserRole assigns a different role to a user
+func (h *UserHandler) ChangeUserRole(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		RoleID uint `json:"role_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Prevent admins from locking themselves out
+	if user.ID == middleware.GetUserID(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+
+	var role models.Role
+	if err := h.DB.WithContext(c).First(&role, req.RoleID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	if err := h.DB.WithContext(c).Model(&user).Update("role_id", role.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change role"})
+		return
+	}
+
+	h.DB.WithContext(c).Preload("Role").First(&user, user.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User role changed successfully",
+		"data":    user,
+	})
+}
+
+// DeactivateUser disables a user account
+func (h *UserHandler) DeactivateUser(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+// ReactivateUser re-enables a deactivated user account
+func (h *UserHandler) ReactivateUser(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+// ResetUserPassword sets a new password for a user and signs them out
+func (h *UserHandler) ResetUserPassword(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		NewPassword string `json:"new_password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	if err := h.DB.WithContext(c).Model(&user).Update("password", string(hashedPassword)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	// Sessions opened with the old password must not outlive it
+	if err := revokeUserSessions(h.DB.WithContext(c), user.ID, models.RevokeReasonPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke user sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// ForceLogout revokes every session of a user so they must sign in again
+func (h *UserHandler) ForceLogout(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	if err := revokeUserSessions(h.DB.WithContext(c), user.ID, models.RevokeReasonForced); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke user sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User has been logged out of all sessions"})
+}
+
+// ResetTwoFactor removes a user's 2FA secret and recovery codes after a lost
+// device. Users whose role requires 2FA enrol again on their next login.
+func (h *UserHandler) ResetTwoFactor(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	if err := clearTwoFactor(h.DB.WithContext(c), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+
+	if err := revokeUserSessions(h.DB.WithContext(c), user.ID, models.RevokeReasonForced); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke user sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
+
+// setActive toggles the is_active flag of the user in the path
+func (h *UserHandler) setActive(c *gin.Context, active bool) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	if !active && user.ID == middleware.GetUserID(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot deactivate your own account"})
+		return
+	}
+
+	if err := h.DB.WithContext(c).Model(&user).Update("is_active", active).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user status"})
+		return
+	}
+
+	if !active {
+		if err := revokeUserSessions(h.DB.WithContext(c), user.ID, models.RevokeReasonDeactivated); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke user sessions"})
+			return
+		}
+	}
+
+	message := "User reactivated successfully"
+	if !active {
+		message = "User deactivated successfully"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data":    user,
+	})
+}
+
+// findUser loads the user referenced by the :id path parameter, writing
+// the error response itself when the lookup fails
+func (h *UserHandler) findUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return user, false
+	}
+
+	if err := h.DB.WithContext(c).Preload("Role").First(&user, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return user, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return user, false
+	}
+
+	return user, true
+}
@@ -3,8 +3,11 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/database"
 	jwtPkg "github.com/unipro/project-management/pkg/jwt"
 )
 
@@ -43,47 +46,74 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Set user info in context for use in handlers
-		c.Set("user_id", claims.UserID)
-		c.Set("email", claims.Email)
-		c.Set("role_id", claims.RoleID)
-		c.Set("role", claims.Role)
+		// Reject tokens that were revoked by logout or a forced logout
+		if claims.ID != "" {
+			var revoked int64
+			database.DB.Model(&models.RevokedToken{}).Where("jti = ?", claims.ID).Count(&revoked)
+			if revoked > 0 {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Token has been revoked",
+				})
+				c.Abort()
+				return
+			}
+		}
 
-		c.Next()
-	}
-}
+		// Reject every token of a session that was revoked or removed, not
+		// just the latest jti that was denylisted with it
+		var session models.Session
+		err = database.DB.Select("id").
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", claims.SessionID, claims.UserID).
+			First(&session).Error
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Session has been revoked",
+			})
+			c.Abort()
+			return
+		}
 
-// RequireRole middleware checks if user has required role
-func RequireRole(allowedRoles ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "Role not found in context",
+		// Load the user so deactivation and role changes apply to tokens
+		// that were issued before the change
+		var user models.User
+		if err := database.DB.Preload("Role").First(&user, claims.UserID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "User not found",
 			})
 			c.Abort()
 			return
 		}
 
-		userRole := role.(string)
-
-		// Check if user role is in allowed roles
-		allowed := false
-		
-		// CEO, Project Director, and Director have full access
-		if userRole == "ceo" || userRole == "project_director" || userRole == "director" {
-			allowed = true
-		} else {
-			// Check specific roles
-			for _, allowedRole := range allowedRoles {
-				if userRole == allowedRole {
-					allowed = true
-					break
-				}
-			}
+		if !user.IsActive {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Account is deactivated",
+			})
+			c.Abort()
+			return
 		}
 
-		if !allowed {
+		// Set user info in context for use in handlers
+		c.Set("user", &user)
+		c.Set("user_id", user.ID)
+		c.Set("email", user.Email)
+		c.Set("role_id", user.RoleID)
+		c.Set("role", user.Role.Name)
+		c.Set("session_id", claims.SessionID)
+		c.Set("jti", claims.ID)
+		if claims.ExpiresAt != nil {
+			c.Set("token_expires_at", claims.ExpiresAt.Time)
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission middleware checks if the user's role grants action on
+// resource. Permissions are read from the role on every request, so edits
+// made through the roles API apply immediately.
+func RequirePermission(resource, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, resource, action) {
 			c.JSON(http.StatusForbidden, gin.H{
 				"error":   "Insufficient permissions",
 				"message": "You don't have permission to access this resource",
@@ -96,6 +126,22 @@ func RequireRole(allowedRoles ...string) gin.HandlerFunc {
 	}
 }
 
+// HasPermission checks if the authenticated user may perform action on resource
+func HasPermission(c *gin.Context, resource, action string) bool {
+	user := GetUser(c)
+	if user == nil {
+		return false
+	}
+	return user.Role.GetPermissions().Allows(resource, action)
+}
+
+// GetUser gets the authenticated user (with role) from context
+func GetUser(c *gin.Context) *models.User {
+	value, _ := c.Get("user")
+	user, _ := value.(*models.User)
+	return user
+}
+
 // GetUserID gets user ID from context
 func GetUserID(c *gin.Context) uint {
 	userID, _ := c.Get("user_id")
@@ -108,3 +154,24 @@ func GetUserRole(c *gin.Context) string {
 	return role.(string)
 }
 
+
+// GetSessionID gets the session ID of the current access token from context
+func GetSessionID(c *gin.Context) uint {
+	sessionID, _ := c.Get("session_id")
+	id, _ := sessionID.(uint)
+	return id
+}
+
+// GetTokenJTI gets the jti of the current access token from context
+func GetTokenJTI(c *gin.Context) string {
+	jti, _ := c.Get("jti")
+	value, _ := jti.(string)
+	return value
+}
+
+// GetTokenExpiry gets the expiry of the current access token from context
+func GetTokenExpiry(c *gin.Context) time.Time {
+	expiresAt, _ := c.Get("token_expires_at")
+	value, _ := expiresAt.(time.Time)
+	return value
+}
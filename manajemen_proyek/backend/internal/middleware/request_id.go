@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/pkg/token"
+)
+
+// RequestIDHeader carries the request ID in and out of the API
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID tags each request with an ID, reusing the caller's when it sends
+// one, so log lines and audit entries can be correlated
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 64 {
+			id, _ = token.NewID()
+		}
+
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// GetRequestID gets the request ID from context
+func GetRequestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
@@ -40,6 +40,9 @@ type Approval struct {
 	ApproverID    uint           `gorm:"not null" json:"approver_id"`
 	Approver      *User          `gorm:"foreignKey:ApproverID" json:"approver,omitempty"`
 	ApprovedAt    *time.Time     `json:"approved_at,omitempty"`
+	DecidedByID   *uint          `json:"decided_by_id,omitempty"` // Delegate who decided on behalf of the approver
+	DecidedBy     *User          `gorm:"foreignKey:DecidedByID" json:"decided_by,omitempty"`
+	DelegationID  *uint          `json:"delegation_id,omitempty"`
 	ApprovalNotes string         `gorm:"type:text" json:"approval_notes,omitempty"` // Notes from approver
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
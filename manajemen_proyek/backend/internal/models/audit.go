@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// Audit actions
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// JSONText is a JSON document stored in a text column and emitted as raw
+// JSON rather than as a quoted string
+type JSONText string
+
+// MarshalJSON emits the stored document as is
+func (j JSONText) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
+
+// UnmarshalJSON stores the raw document
+func (j *JSONText) UnmarshalJSON(data []byte) error {
+	*j = JSONText(data)
+	return nil
+}
+
+// AuditLog records a single create, update or delete of a row. It is written
+// by the GORM callbacks in pkg/audit, never by handlers.
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ActorID    *uint     `gorm:"index" json:"actor_id,omitempty"` // Empty for system changes
+	Actor      *User     `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	EntityType string    `gorm:"type:varchar(100);not null;index:idx_audit_entity" json:"entity_type"` // Table name
+	EntityID   string    `gorm:"type:varchar(100);index:idx_audit_entity" json:"entity_id"`
+	ProjectID  *uint     `gorm:"index" json:"project_id,omitempty"` // Project the row belongs to, for project history
+	Action     string    `gorm:"type:varchar(20);not null;index" json:"action"`
+	Before     JSONText  `gorm:"type:text" json:"before"`
+	After      JSONText  `gorm:"type:text" json:"after"`
+	Changes    JSONText  `gorm:"type:text" json:"changes"` // {"column": {"old": ..., "new": ...}}
+	IPAddress  string    `gorm:"type:varchar(45)" json:"ip_address"`
+	RequestID  string    `gorm:"type:varchar(64);index" json:"request_id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for AuditLog model
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
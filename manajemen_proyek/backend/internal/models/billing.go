@@ -0,0 +1,252 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProjectContract holds the customer contract terms a project is billed by
+type ProjectContract struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	ProjectID       uint           `gorm:"unique;not null" json:"project_id"`
+	ContractNumber  string         `json:"contract_number"`
+	ContractValue   float64        `gorm:"type:decimal(15,2);not null" json:"contract_value"` // Before tax
+	CustomerName    string         `json:"customer_name"`
+	CustomerAddress string         `gorm:"type:text" json:"customer_address"`
+	CustomerTaxID   string         `json:"customer_tax_id"`                                     // NPWP
+	DownPaymentPct  float64        `gorm:"type:decimal(5,2);default:0" json:"down_payment_pct"` // Uang muka, recovered from each progress invoice
+	RetentionPct    float64        `gorm:"type:decimal(5,2);default:0" json:"retention_pct"`    // Withheld from each progress invoice until release
+	TaxPct          float64        `gorm:"type:decimal(5,2);default:11" json:"tax_pct"`         // PPN
+	PaymentTermDays int            `gorm:"default:30" json:"payment_term_days"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for ProjectContract model
+func (ProjectContract) TableName() string {
+	return "project_contracts"
+}
+
+// DownPaymentAmount returns the down payment billed under the contract
+func (c *ProjectContract) DownPaymentAmount() float64 {
+	return roundCurrency(c.ContractValue * c.DownPaymentPct / 100)
+}
+
+// MilestoneType represents what a billing milestone bills
+type MilestoneType string
+
+const (
+	MilestoneDownPayment      MilestoneType = "down_payment"      // Uang muka
+	MilestoneProgress         MilestoneType = "progress"          // Termin tied to progress
+	MilestoneRetentionRelease MilestoneType = "retention_release" // Release of retention held
+)
+
+// MilestoneStatus represents the billing state of a milestone
+type MilestoneStatus string
+
+const (
+	MilestonePending  MilestoneStatus = "pending"
+	MilestoneReached  MilestoneStatus = "reached"
+	MilestoneInvoiced MilestoneStatus = "invoiced"
+)
+
+// BillingMilestone is one billing stage (termin) of a project. It is reached
+// when project progress meets its threshold or its task is complete; stages
+// with neither are invoiced by hand. Progress stages normally add up to 100%
+// of the contract value.
+type BillingMilestone struct {
+	ID                uint            `gorm:"primaryKey" json:"id"`
+	ProjectID         uint            `gorm:"not null;index" json:"project_id"`
+	Name              string          `gorm:"not null" json:"name"`
+	SortOrder         int             `gorm:"default:0" json:"sort_order"`
+	Type              MilestoneType   `gorm:"type:varchar(30);not null;default:'progress'" json:"type"`
+	Percentage        *float64        `gorm:"type:decimal(5,2)" json:"percentage,omitempty"` // Of the contract value
+	Amount            *float64        `gorm:"type:decimal(15,2)" json:"amount,omitempty"`    // Fixed amount, instead of a percentage
+	ProgressThreshold *float64        `gorm:"type:decimal(5,2)" json:"progress_threshold,omitempty"`
+	TaskID            *uint           `gorm:"index" json:"task_id,omitempty"`
+	Task              *Task           `gorm:"foreignKey:TaskID" json:"task,omitempty"`
+	Status            MilestoneStatus `gorm:"type:varchar(20);default:'pending'" json:"status"`
+	ReachedAt         *time.Time      `json:"reached_at,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt  `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for BillingMilestone model
+func (BillingMilestone) TableName() string {
+	return "billing_milestones"
+}
+
+// Value returns the amount the milestone bills before deductions and tax
+func (m *BillingMilestone) Value(contract *ProjectContract) float64 {
+	if m.Amount != nil {
+		return roundCurrency(*m.Amount)
+	}
+	if m.Percentage != nil {
+		return roundCurrency(contract.ContractValue * *m.Percentage / 100)
+	}
+	return 0
+}
+
+// IsReached checks if the milestone's trigger is met by the project progress
+// or by its task being complete
+func (m *BillingMilestone) IsReached(projectProgress float64, task *Task) bool {
+	if m.ProgressThreshold != nil && projectProgress >= *m.ProgressThreshold {
+		return true
+	}
+	return task != nil && task.Progress >= 100
+}
+
+// InvoiceStatus represents the lifecycle of a customer invoice
+type InvoiceStatus string
+
+const (
+	InvoiceDraft         InvoiceStatus = "draft"
+	InvoiceIssued        InvoiceStatus = "issued"
+	InvoicePartiallyPaid InvoiceStatus = "partially_paid"
+	InvoicePaid          InvoiceStatus = "paid"
+	InvoiceCancelled     InvoiceStatus = "cancelled"
+)
+
+// Invoice bills the customer for a milestone. PPN is charged on the gross
+// amount less the down payment recovered, and retention is withheld from
+// the amount due.
+type Invoice struct {
+	ID                   uint              `gorm:"primaryKey" json:"id"`
+	InvoiceNumber        string            `gorm:"unique;not null" json:"invoice_number"` // Auto-generated: INV-YYYY-XXXX
+	ProjectID            uint              `gorm:"not null;index" json:"project_id"`
+	Project              *Project          `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	MilestoneID          *uint             `gorm:"index" json:"milestone_id,omitempty"`
+	Milestone            *BillingMilestone `gorm:"foreignKey:MilestoneID" json:"milestone,omitempty"`
+	Type                 MilestoneType     `gorm:"type:varchar(30)" json:"type"`
+	Description          string            `json:"description"`
+	Status               InvoiceStatus     `gorm:"type:varchar(20);default:'draft';index" json:"status"`
+	IssueDate            *time.Time        `gorm:"type:date" json:"issue_date,omitempty"`
+	DueDate              *time.Time        `gorm:"type:date" json:"due_date,omitempty"`
+	GrossAmount          float64           `gorm:"type:decimal(15,2)" json:"gross_amount"`
+	DownPaymentDeduction float64           `gorm:"type:decimal(15,2);default:0" json:"down_payment_deduction"`
+	TaxBase              float64           `gorm:"type:decimal(15,2)" json:"tax_base"` // DPP
+	TaxPct               float64           `gorm:"type:decimal(5,2)" json:"tax_pct"`
+	TaxAmount            float64           `gorm:"type:decimal(15,2)" json:"tax_amount"`
+	RetentionAmount      float64           `gorm:"type:decimal(15,2);default:0" json:"retention_amount"`
+	TotalAmount          float64           `gorm:"type:decimal(15,2)" json:"total_amount"` // Due from the customer
+	PaidAmount           float64           `gorm:"type:decimal(15,2);default:0" json:"paid_amount"`
+	Notes                string            `gorm:"type:text" json:"notes"`
+	PDFPath              string            `json:"pdf_path"`
+	CreatedBy            *uint             `json:"created_by,omitempty"`
+	Creator              *User             `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+	Payments             []InvoicePayment  `gorm:"foreignKey:InvoiceID" json:"payments,omitempty"`
+	CreatedAt            time.Time         `json:"created_at"`
+	UpdatedAt            time.Time         `json:"updated_at"`
+	DeletedAt            gorm.DeletedAt    `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for Invoice model
+func (Invoice) TableName() string {
+	return "invoices"
+}
+
+// NewMilestoneInvoice builds the draft invoice of a milestone under the
+// contract. Down payments bill their share of the contract value, progress
+// stages recover the down payment and withhold retention pro rata, and a
+// retention release bills the retention still held without tax, as PPN was
+// charged when it was first billed.
+func NewMilestoneInvoice(contract *ProjectContract, milestone *BillingMilestone, retentionHeld float64) Invoice {
+	invoice := Invoice{
+		ProjectID:   milestone.ProjectID,
+		MilestoneID: &milestone.ID,
+		Type:        milestone.Type,
+		Description: milestone.Name,
+		Status:      InvoiceDraft,
+		TaxPct:      contract.TaxPct,
+	}
+
+	switch milestone.Type {
+	case MilestoneDownPayment:
+		invoice.GrossAmount = milestone.Value(contract)
+		if milestone.Amount == nil && milestone.Percentage == nil {
+			invoice.GrossAmount = contract.DownPaymentAmount()
+		}
+	case MilestoneRetentionRelease:
+		invoice.GrossAmount = retentionHeld
+		if milestone.Amount != nil || milestone.Percentage != nil {
+			invoice.GrossAmount = math.Min(milestone.Value(contract), retentionHeld)
+		}
+		invoice.TaxPct = 0
+	default:
+		invoice.GrossAmount = milestone.Value(contract)
+		invoice.DownPaymentDeduction = roundCurrency(invoice.GrossAmount * contract.DownPaymentPct / 100)
+		invoice.RetentionAmount = roundCurrency(invoice.GrossAmount * contract.RetentionPct / 100)
+	}
+
+	invoice.Calculate()
+	return invoice
+}
+
+// Calculate derives the tax base, tax and total from the gross amount,
+// deductions and tax rate
+func (i *Invoice) Calculate() {
+	i.TaxBase = roundCurrency(i.GrossAmount - i.DownPaymentDeduction)
+	i.TaxAmount = roundCurrency(i.TaxBase * i.TaxPct / 100)
+	i.TotalAmount = roundCurrency(i.TaxBase + i.TaxAmount - i.RetentionAmount)
+}
+
+// Outstanding returns the amount still to be paid
+func (i *Invoice) Outstanding() float64 {
+	if i.Status == InvoiceDraft || i.Status == InvoiceCancelled {
+		return 0
+	}
+	return roundCurrency(i.TotalAmount - i.PaidAmount)
+}
+
+// IsOverdue checks if an issued invoice is past its due date and unpaid
+func (i *Invoice) IsOverdue(now time.Time) bool {
+	return i.DueDate != nil && i.Outstanding() > 0 && now.After(i.DueDate.AddDate(0, 0, 1))
+}
+
+// ApplyPaid sets the paid amount and moves an issued invoice between
+// issued, partially paid and paid
+func (i *Invoice) ApplyPaid(paid float64) {
+	i.PaidAmount = roundCurrency(paid)
+	if i.Status == InvoiceDraft || i.Status == InvoiceCancelled {
+		return
+	}
+	switch {
+	case i.PaidAmount <= 0:
+		i.Status = InvoiceIssued
+	case i.PaidAmount < i.TotalAmount:
+		i.Status = InvoicePartiallyPaid
+	default:
+		i.Status = InvoicePaid
+	}
+}
+
+// InvoicePayment is a payment received from the customer against an invoice
+type InvoicePayment struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	ReceiptNumber string         `gorm:"unique;not null" json:"receipt_number"` // Auto-generated: RCP-YYYY-XXXX
+	InvoiceID     uint           `gorm:"not null;index" json:"invoice_id"`
+	Amount        float64        `gorm:"type:decimal(15,2);not null" json:"amount"`
+	PaymentDate   time.Time      `gorm:"type:date;not null" json:"payment_date"`
+	Method        string         `json:"method"` // e.g. transfer, cheque
+	Reference     string         `json:"reference"`
+	Notes         string         `gorm:"type:text" json:"notes"`
+	ReceivedBy    uint           `gorm:"not null" json:"received_by"`
+	Receiver      *User          `gorm:"foreignKey:ReceivedBy" json:"receiver,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for InvoicePayment model
+func (InvoicePayment) TableName() string {
+	return "invoice_payments"
+}
+
+// roundCurrency rounds an amount to cents
+func roundCurrency(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CostCodeType is the cost category a cost code belongs to
+type CostCodeType string
+
+const (
+	CostTypeMaterials   CostCodeType = "materials"
+	CostTypeLabour      CostCodeType = "labour"
+	CostTypeEquipment   CostCodeType = "equipment"
+	CostTypeSubcontract CostCodeType = "subcontract"
+	CostTypeOverhead    CostCodeType = "overhead"
+)
+
+// IsValidCostCodeType checks if the cost code type is known
+func IsValidCostCodeType(t CostCodeType) bool {
+	switch t {
+	case CostTypeMaterials, CostTypeLabour, CostTypeEquipment, CostTypeSubcontract, CostTypeOverhead:
+		return true
+	}
+	return false
+}
+
+// CostCode is one node of the company-wide cost code hierarchy budgets and
+// costs are broken down by, e.g. 1 Materials > 1.01 Structural steel.
+// Children share the type of their root.
+type CostCode struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Code        string         `gorm:"type:varchar(30);uniqueIndex;not null" json:"code"`
+	Name        string         `gorm:"not null" json:"name"`
+	Type        CostCodeType   `gorm:"type:varchar(20);not null;index" json:"type"`
+	ParentID    *uint          `gorm:"index" json:"parent_id,omitempty"`
+	SortOrder   int            `gorm:"default:0" json:"sort_order"`
+	Description string         `gorm:"type:text" json:"description"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for CostCode model
+func (CostCode) TableName() string {
+	return "cost_codes"
+}
+
+// DefaultCostCodes are the root cost codes seeded for every company
+var DefaultCostCodes = []CostCode{
+	{Code: "1", Name: "Material", Type: CostTypeMaterials, SortOrder: 1},
+	{Code: "2", Name: "Upah Tenaga Kerja", Type: CostTypeLabour, SortOrder: 2},
+	{Code: "3", Name: "Peralatan", Type: CostTypeEquipment, SortOrder: 3},
+	{Code: "4", Name: "Subkontraktor", Type: CostTypeSubcontract, SortOrder: 4},
+	{Code: "5", Name: "Overhead", Type: CostTypeOverhead, SortOrder: 5},
+}
+
+// BudgetLine is one line of a project's itemised budget (RAB). Lines linked
+// to a BOM line follow its quantity, price and phase; only their cost code
+// and description can be changed by hand.
+type BudgetLine struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	ProjectID   uint           `gorm:"not null;index" json:"project_id"`
+	CostCodeID  uint           `gorm:"not null;index" json:"cost_code_id"`
+	CostCode    *CostCode      `gorm:"foreignKey:CostCodeID" json:"cost_code,omitempty"`
+	PhaseID     *uint          `gorm:"index" json:"phase_id,omitempty"`
+	Phase       *ProjectPhase  `gorm:"foreignKey:PhaseID" json:"phase,omitempty"`
+	BOMID       *uint          `gorm:"column:bom_id;index" json:"bom_id,omitempty"`
+	Description string         `gorm:"not null" json:"description"`
+	Quantity    float64        `gorm:"type:decimal(15,2);default:0" json:"quantity"`
+	Unit        string         `json:"unit"`
+	UnitPrice   float64        `gorm:"type:decimal(15,2);default:0" json:"unit_price"`
+	Amount      float64        `gorm:"type:decimal(15,2);not null" json:"amount"` // Quantity * UnitPrice, or a lump sum
+	Notes       string         `gorm:"type:text" json:"notes"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for BudgetLine model
+func (BudgetLine) TableName() string {
+	return "budget_lines"
+}
+
+// IsFromBOM checks if the line is kept in sync with a BOM line
+func (l *BudgetLine) IsFromBOM() bool {
+	return l.BOMID != nil
+}
+
+// Calculate sets the amount from quantity and unit price. Lines without a
+// quantity are lump sums and keep their amount.
+func (l *BudgetLine) Calculate() {
+	if l.Quantity != 0 {
+		l.Amount = roundCurrency(l.Quantity * l.UnitPrice)
+	}
+}
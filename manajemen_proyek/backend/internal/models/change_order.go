@@ -0,0 +1,182 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ChangeOrderStatus represents the lifecycle of a change order
+type ChangeOrderStatus string
+
+const (
+	ChangeOrderDraft    ChangeOrderStatus = "draft"
+	ChangeOrderPending  ChangeOrderStatus = "pending"
+	ChangeOrderApproved ChangeOrderStatus = "approved"
+	ChangeOrderRejected ChangeOrderStatus = "rejected"
+)
+
+// ChangeOrderStages are the approval stages a change order goes through, in
+// order
+var ChangeOrderStages = []ApprovalStage{StageCostControl, StageGM}
+
+// ChangeOrder revises a project's scope. Once approved its cost impact is
+// added to the project budget, its schedule impact moves the end date and
+// deadline, and its items change the BOM.
+type ChangeOrder struct {
+	ID                 uint                  `gorm:"primaryKey" json:"id"`
+	CONumber           string                `gorm:"not null;uniqueIndex:idx_change_order_number" json:"co_number"` // Per project: CO-001, CO-002, ...
+	ProjectID          uint                  `gorm:"not null;uniqueIndex:idx_change_order_number" json:"project_id"`
+	Project            *Project              `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	Title              string                `gorm:"not null" json:"title"`
+	Description        string                `gorm:"type:text" json:"description"`
+	Reason             string                `gorm:"type:text" json:"reason"`                         // Why the scope changes, e.g. customer request
+	CostImpact         float64               `gorm:"type:decimal(15,2);default:0" json:"cost_impact"` // Negative for reductions
+	ScheduleImpactDays int                   `gorm:"default:0" json:"schedule_impact_days"`           // Negative to shorten
+	Status             ChangeOrderStatus     `gorm:"type:varchar(20);default:'draft';index" json:"status"`
+	CurrentStage       ApprovalStage         `gorm:"type:varchar(50)" json:"current_stage"`
+	RequesterID        uint                  `gorm:"not null;index" json:"requester_id"`
+	Requester          *User                 `gorm:"foreignKey:RequesterID" json:"requester,omitempty"`
+	SubmittedAt        *time.Time            `json:"submitted_at,omitempty"`
+	ApprovedAt         *time.Time            `json:"approved_at,omitempty"`
+	BudgetBefore       *float64              `gorm:"type:decimal(15,2)" json:"budget_before,omitempty"` // Project budget when approved
+	BudgetAfter        *float64              `gorm:"type:decimal(15,2)" json:"budget_after,omitempty"`
+	Items              []ChangeOrderItem     `gorm:"foreignKey:ChangeOrderID" json:"items,omitempty"`
+	Approvals          []ChangeOrderApproval `gorm:"foreignKey:ChangeOrderID" json:"approvals,omitempty"`
+	CreatedAt          time.Time             `json:"created_at"`
+	UpdatedAt          time.Time             `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt        `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for ChangeOrder model
+func (ChangeOrder) TableName() string {
+	return "change_orders"
+}
+
+// NextStage returns the approval stage after the current one, or nil when
+// the current stage is the last
+func (co *ChangeOrder) NextStage() *ApprovalStage {
+	for i, stage := range ChangeOrderStages {
+		if stage == co.CurrentStage && i+1 < len(ChangeOrderStages) {
+			next := ChangeOrderStages[i+1]
+			return &next
+		}
+	}
+	return nil
+}
+
+// ItemsCost returns the cost of the BOM changes of the change order
+func (co *ChangeOrder) ItemsCost() float64 {
+	var total float64
+	for _, item := range co.Items {
+		total += item.CostImpact
+	}
+	return roundCurrency(total)
+}
+
+// ChangeOrderItem changes the planned quantity of a material in the project
+// BOM. Materials not yet in the BOM get a new line when the change order is
+// approved.
+type ChangeOrderItem struct {
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	ChangeOrderID    uint           `gorm:"not null;index" json:"change_order_id"`
+	MaterialID       uint           `gorm:"not null;index" json:"material_id"`
+	Material         *Material      `gorm:"foreignKey:MaterialID" json:"material,omitempty"`
+	BOMID            *uint          `gorm:"column:bom_id;index" json:"bom_id,omitempty"`        // Set when applied
+	QuantityChange   float64        `gorm:"type:decimal(15,2);not null" json:"quantity_change"` // Negative to reduce
+	UnitPrice        float64        `gorm:"type:decimal(15,2);not null" json:"unit_price"`
+	CostImpact       float64        `gorm:"type:decimal(15,2)" json:"cost_impact"`                  // QuantityChange * UnitPrice
+	PlannedQtyBefore *float64       `gorm:"type:decimal(15,2)" json:"planned_qty_before,omitempty"` // BOM quantity when applied
+	Notes            string         `gorm:"type:text" json:"notes"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for ChangeOrderItem model
+func (ChangeOrderItem) TableName() string {
+	return "change_order_items"
+}
+
+// Calculate sets the cost impact of the item from its quantity and price
+func (item *ChangeOrderItem) Calculate() {
+	item.CostImpact = roundCurrency(item.QuantityChange * item.UnitPrice)
+}
+
+// BeforeSave hook to calculate the cost impact of the item
+func (item *ChangeOrderItem) BeforeSave(tx *gorm.DB) error {
+	item.Calculate()
+	return nil
+}
+
+// ChangeOrderApproval tracks a change order through each approval stage
+type ChangeOrderApproval struct {
+	ID            uint                  `gorm:"primaryKey" json:"id"`
+	ChangeOrderID uint                  `gorm:"not null;index" json:"change_order_id"`
+	Stage         ApprovalStage         `gorm:"type:varchar(50);not null" json:"stage"`
+	Status        ApprovalHistoryStatus `gorm:"type:varchar(20);default:'pending'" json:"status"`
+	ApproverID    *uint                 `json:"approver_id,omitempty"`
+	Approver      *User                 `gorm:"foreignKey:ApproverID" json:"approver,omitempty"`
+	OnBehalfOfID  *uint                 `json:"on_behalf_of_id,omitempty"` // Absent approver when a delegate acted
+	OnBehalfOf    *User                 `gorm:"foreignKey:OnBehalfOfID" json:"on_behalf_of,omitempty"`
+	DelegationID  *uint                 `json:"delegation_id,omitempty"`
+	Comment       string                `gorm:"type:text" json:"comment"`
+	ApprovedAt    *time.Time            `json:"approved_at,omitempty"`
+	CreatedAt     time.Time             `json:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at"`
+}
+
+// TableName specifies the table name for ChangeOrderApproval model
+func (ChangeOrderApproval) TableName() string {
+	return "change_order_approvals"
+}
+
+// ChangeOrderLog lists the submitted change orders of a project with the
+// budget and end date before and after the approved ones
+type ChangeOrderLog struct {
+	ProjectID       uint          `json:"project_id"`
+	ProjectName     string        `json:"project_name"`
+	OriginalBudget  float64       `json:"original_budget"`
+	CurrentBudget   float64       `json:"current_budget"`
+	OriginalEndDate time.Time     `json:"original_end_date"`
+	CurrentEndDate  time.Time     `json:"current_end_date"`
+	ApprovedCount   int           `json:"approved_count"`
+	ApprovedCost    float64       `json:"approved_cost"`
+	ApprovedDays    int           `json:"approved_days"`
+	PendingCount    int           `json:"pending_count"`
+	PendingCost     float64       `json:"pending_cost"`
+	RejectedCount   int           `json:"rejected_count"`
+	ChangeOrders    []ChangeOrder `json:"change_orders"`
+}
+
+// NewChangeOrderLog builds the change order log of a project from its
+// submitted change orders
+func NewChangeOrderLog(project *Project, changeOrders []ChangeOrder) *ChangeOrderLog {
+	log := &ChangeOrderLog{
+		ProjectID:      project.ID,
+		ProjectName:    project.Name,
+		OriginalBudget: project.OriginalBudget,
+		CurrentBudget:  project.EstimatedCost,
+		CurrentEndDate: project.EndDate,
+		ChangeOrders:   changeOrders,
+	}
+
+	for _, co := range changeOrders {
+		switch co.Status {
+		case ChangeOrderApproved:
+			log.ApprovedCount++
+			log.ApprovedCost += co.CostImpact
+			log.ApprovedDays += co.ScheduleImpactDays
+		case ChangeOrderPending:
+			log.PendingCount++
+			log.PendingCost += co.CostImpact
+		case ChangeOrderRejected:
+			log.RejectedCount++
+		}
+	}
+
+	log.ApprovedCost = roundCurrency(log.ApprovedCost)
+	log.PendingCost = roundCurrency(log.PendingCost)
+	log.OriginalEndDate = project.EndDate.AddDate(0, 0, -log.ApprovedDays)
+	return log
+}
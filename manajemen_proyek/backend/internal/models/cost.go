@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CostSource identifies where a cost entry comes from
+type CostSource string
+
+const (
+	CostSourceMaterialUsage   CostSource = "material_usage"   // Material consumed on site
+	CostSourcePurchaseRequest CostSource = "purchase_request" // Fully approved purchase request
+	CostSourceSubcontract     CostSource = "subcontract"      // Fully approved subcontract payment certificate
+	CostSourceManual          CostSource = "manual"           // Entered by cost control, e.g. labour or equipment rental
+)
+
+// CostEntry is one line of a project's cost ledger. Project.ActualCost is
+// the sum of its entries. Entries generated from another record carry its
+// source type and ID so they can be updated or removed along with it. The
+// cost code and phase attribute the entry to the project budget.
+type CostEntry struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	ProjectID   uint           `gorm:"not null;index" json:"project_id"`
+	Project     *Project       `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	SourceType  CostSource     `gorm:"type:varchar(30);not null;index:idx_cost_source" json:"source_type"`
+	SourceID    *uint          `gorm:"index:idx_cost_source" json:"source_id,omitempty"`
+	Category    string         `gorm:"type:varchar(50)" json:"category"`
+	CostCodeID  *uint          `gorm:"index" json:"cost_code_id,omitempty"` // Empty when it could not be attributed
+	CostCode    *CostCode      `gorm:"foreignKey:CostCodeID" json:"cost_code,omitempty"`
+	PhaseID     *uint          `gorm:"index" json:"phase_id,omitempty"`
+	Description string         `gorm:"type:text" json:"description"`
+	Amount      float64        `gorm:"type:decimal(15,2);not null" json:"amount"`
+	EntryDate   time.Time      `gorm:"not null;index" json:"entry_date"`
+	CreatedBy   *uint          `json:"created_by,omitempty"`
+	Creator     *User          `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for CostEntry model
+func (CostEntry) TableName() string {
+	return "cost_entries"
+}
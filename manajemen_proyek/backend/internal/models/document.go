@@ -0,0 +1,125 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DocumentCategory is the kind of project document
+type DocumentCategory string
+
+const (
+	DocumentContract       DocumentCategory = "contract"
+	DocumentDrawing        DocumentCategory = "drawing"
+	DocumentPermit         DocumentCategory = "permit"
+	DocumentQuotation      DocumentCategory = "quotation"
+	DocumentSpecification  DocumentCategory = "specification"
+	DocumentCorrespondence DocumentCategory = "correspondence"
+	DocumentOther          DocumentCategory = "other"
+)
+
+// IsValidDocumentCategory checks if the document category is known
+func IsValidDocumentCategory(c DocumentCategory) bool {
+	switch c {
+	case DocumentContract, DocumentDrawing, DocumentPermit, DocumentQuotation,
+		DocumentSpecification, DocumentCorrespondence, DocumentOther:
+		return true
+	}
+	return false
+}
+
+// DocumentFolder groups the documents of a project. Folders nest through
+// ParentID; a folder can only be removed once it is empty.
+type DocumentFolder struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ProjectID uint      `gorm:"not null;index" json:"project_id"`
+	ParentID  *uint     `gorm:"index" json:"parent_id,omitempty"`
+	Name      string    `gorm:"not null" json:"name"`
+	CreatedBy uint      `gorm:"not null" json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for DocumentFolder model
+func (DocumentFolder) TableName() string {
+	return "document_folders"
+}
+
+// Document is a file kept for a project, such as a contract, drawing or
+// permit. Every upload is kept as a DocumentVersion; the current version's
+// file details are copied here for listings.
+type Document struct {
+	ID             uint              `gorm:"primaryKey" json:"id"`
+	ProjectID      uint              `gorm:"not null;index" json:"project_id"`
+	Project        *Project          `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	FolderID       *uint             `gorm:"index" json:"folder_id,omitempty"` // Empty for the project root
+	Folder         *DocumentFolder   `gorm:"foreignKey:FolderID" json:"folder,omitempty"`
+	Title          string            `gorm:"not null" json:"title"`
+	Category       DocumentCategory  `gorm:"type:varchar(30);not null;index" json:"category"`
+	DocumentNumber string            `gorm:"type:varchar(100)" json:"document_number"` // e.g. drawing or permit number
+	Description    string            `gorm:"type:text" json:"description"`
+	IssuedDate     *time.Time        `gorm:"type:date" json:"issued_date,omitempty"`
+	ExpiryDate     *time.Time        `gorm:"type:date" json:"expiry_date,omitempty"` // Permits and guarantees
+	CurrentVersion int               `gorm:"not null;default:1" json:"current_version"`
+	FileName       string            `json:"file_name"` // Of the current version
+	FileSize       int64             `json:"file_size"`
+	MimeType       string            `json:"mime_type"`
+	CreatedBy      uint              `gorm:"not null" json:"created_by"`
+	Creator        *User             `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+	Versions       []DocumentVersion `gorm:"foreignKey:DocumentID" json:"versions,omitempty"`
+	Links          []DocumentLink    `gorm:"foreignKey:DocumentID" json:"links,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt    `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for Document model
+func (Document) TableName() string {
+	return "documents"
+}
+
+// DocumentVersion is one uploaded revision of a document. Files are stored
+// outside the public uploads directory and only served through the API.
+type DocumentVersion struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	DocumentID  uint           `gorm:"not null;uniqueIndex:idx_document_version" json:"document_id"`
+	Version     int            `gorm:"not null;uniqueIndex:idx_document_version" json:"version"`
+	FileName    string         `gorm:"not null" json:"file_name"` // As uploaded
+	StoragePath string         `gorm:"not null" json:"-"`
+	FileSize    int64          `json:"file_size"`
+	MimeType    string         `json:"mime_type"`
+	Checksum    string         `gorm:"type:varchar(64)" json:"checksum"` // SHA-256 of the file
+	Notes       string         `gorm:"type:text" json:"notes"`           // What changed in this revision
+	UploadedBy  uint           `gorm:"not null" json:"uploaded_by"`
+	Uploader    *User          `gorm:"foreignKey:UploadedBy" json:"uploader,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for DocumentVersion model
+func (DocumentVersion) TableName() string {
+	return "document_versions"
+}
+
+// DocumentLink attaches a document to a purchase request, daily report or
+// change order of the same project. Exactly one of the targets is set.
+type DocumentLink struct {
+	ID                uint             `gorm:"primaryKey" json:"id"`
+	DocumentID        uint             `gorm:"not null;index" json:"document_id"`
+	Document          *Document        `gorm:"foreignKey:DocumentID" json:"document,omitempty"`
+	VersionID         *uint            `json:"version_id,omitempty"` // Pins a revision, empty follows the current one
+	Version           *DocumentVersion `gorm:"foreignKey:VersionID" json:"version,omitempty"`
+	PurchaseRequestID *uint            `gorm:"index" json:"purchase_request_id,omitempty"`
+	DailyReportID     *uint            `gorm:"index" json:"daily_report_id,omitempty"`
+	ChangeOrderID     *uint            `gorm:"index" json:"change_order_id,omitempty"`
+	Note              string           `gorm:"type:text" json:"note"`
+	CreatedBy         uint             `gorm:"not null" json:"created_by"`
+	Creator           *User            `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+	CreatedAt         time.Time        `json:"created_at"`
+}
+
+// TableName specifies the table name for DocumentLink model
+func (DocumentLink) TableName() string {
+	return "document_links"
+}
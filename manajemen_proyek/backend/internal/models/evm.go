@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// EVMSnapshot stores a project's earned value metrics once a week so trends
+// can be plotted
+type EVMSnapshot struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ProjectID      uint      `gorm:"not null;uniqueIndex:idx_evm_project_week" json:"project_id"`
+	WeekStart      time.Time `gorm:"type:date;not null;uniqueIndex:idx_evm_project_week" json:"week_start"` // Monday of the week
+	AsOf           time.Time `gorm:"not null" json:"as_of"`
+	PlannedPercent float64   `gorm:"type:decimal(5,2)" json:"planned_percent"`
+	ActualPercent  float64   `gorm:"type:decimal(5,2)" json:"actual_percent"`
+	BAC            float64   `gorm:"column:bac;type:decimal(15,2)" json:"bac"`
+	PV             float64   `gorm:"column:pv;type:decimal(15,2)" json:"pv"`
+	EV             float64   `gorm:"column:ev;type:decimal(15,2)" json:"ev"`
+	AC             float64   `gorm:"column:ac;type:decimal(15,2)" json:"ac"`
+	CPI            *float64  `gorm:"column:cpi;type:decimal(10,4)" json:"cpi"`
+	SPI            *float64  `gorm:"column:spi;type:decimal(10,4)" json:"spi"`
+	EAC            float64   `gorm:"column:eac;type:decimal(15,2)" json:"eac"`
+	ETC            float64   `gorm:"column:etc;type:decimal(15,2)" json:"etc"`
+	VAC            float64   `gorm:"column:vac;type:decimal(15,2)" json:"vac"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for EVMSnapshot model
+func (EVMSnapshot) TableName() string {
+	return "evm_snapshots"
+}
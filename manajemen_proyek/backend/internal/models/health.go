@@ -0,0 +1,158 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HealthLevel grades one dimension of project health
+type HealthLevel string
+
+const (
+	HealthOK       HealthLevel = "ok"
+	HealthWarning  HealthLevel = "warning"
+	HealthCritical HealthLevel = "critical"
+)
+
+// HealthSettings holds the company-wide thresholds used to evaluate project
+// status. There is a single row; projects may override each threshold.
+type HealthSettings struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	CostWarningPct      float64   `gorm:"type:decimal(7,2);default:0" json:"cost_warning_pct"`       // Cost variance above this is a warning
+	CostCriticalPct     float64   `gorm:"type:decimal(7,2);default:5" json:"cost_critical_pct"`      // Cost variance above this is over budget
+	ScheduleWarningPct  float64   `gorm:"type:decimal(7,2);default:10" json:"schedule_warning_pct"`  // Progress points behind plan for a warning
+	ScheduleCriticalPct float64   `gorm:"type:decimal(7,2);default:20" json:"schedule_critical_pct"` // Progress points behind plan for behind schedule
+	UpdatedBy           *uint     `json:"updated_by,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for HealthSettings model
+func (HealthSettings) TableName() string {
+	return "health_settings"
+}
+
+// DefaultHealthSettings returns the thresholds used before any are saved
+func DefaultHealthSettings() HealthSettings {
+	return HealthSettings{
+		CostWarningPct:      0,
+		CostCriticalPct:     5,
+		ScheduleWarningPct:  10,
+		ScheduleCriticalPct: 20,
+	}
+}
+
+// LoadHealthSettings returns the saved company thresholds, or the defaults
+// when none are saved
+func LoadHealthSettings(db *gorm.DB) HealthSettings {
+	var settings HealthSettings
+	if err := db.Order("id").First(&settings).Error; err != nil {
+		return DefaultHealthSettings()
+	}
+	return settings
+}
+
+// HealthThresholds are the thresholds in effect for one project
+type HealthThresholds struct {
+	CostWarningPct      float64 `json:"cost_warning_pct"`
+	CostCriticalPct     float64 `json:"cost_critical_pct"`
+	ScheduleWarningPct  float64 `json:"schedule_warning_pct"`
+	ScheduleCriticalPct float64 `json:"schedule_critical_pct"`
+}
+
+// ThresholdsFor applies a project's overrides to the company thresholds
+func (s HealthSettings) ThresholdsFor(p *Project) HealthThresholds {
+	t := HealthThresholds{
+		CostWarningPct:      s.CostWarningPct,
+		CostCriticalPct:     s.CostCriticalPct,
+		ScheduleWarningPct:  s.ScheduleWarningPct,
+		ScheduleCriticalPct: s.ScheduleCriticalPct,
+	}
+	if p.CostWarningPct != nil {
+		t.CostWarningPct = *p.CostWarningPct
+	}
+	if p.CostCriticalPct != nil {
+		t.CostCriticalPct = *p.CostCriticalPct
+	}
+	if p.ScheduleWarningPct != nil {
+		t.ScheduleWarningPct = *p.ScheduleWarningPct
+	}
+	if p.ScheduleCriticalPct != nil {
+		t.ScheduleCriticalPct = *p.ScheduleCriticalPct
+	}
+	return t
+}
+
+// ProjectHealth explains how a project's automatic status was reached
+type ProjectHealth struct {
+	CostVariance    float64          `json:"cost_variance"`    // % over (positive) or under the estimate
+	PlannedProgress float64          `json:"planned_progress"` // % of work expected by now
+	ActualProgress  float64          `json:"actual_progress"`
+	ScheduleSlip    float64          `json:"schedule_slip"` // Progress points behind plan
+	CostLevel       HealthLevel      `json:"cost_level"`
+	ScheduleLevel   HealthLevel      `json:"schedule_level"`
+	Thresholds      HealthThresholds `json:"thresholds"`
+	Status          ProjectStatus    `json:"status"` // Status the rules give
+	StatusManual    bool             `json:"status_manual"`
+}
+
+// PlannedProgress returns the progress expected at t assuming linear work
+// between StartDate and EndDate
+func (p *Project) PlannedProgress(t time.Time) float64 {
+	if p.StartDate.IsZero() || p.EndDate.IsZero() || !p.EndDate.After(p.StartDate) {
+		return 0
+	}
+	if !t.After(p.StartDate) {
+		return 0
+	}
+	if !t.Before(p.EndDate) {
+		return 100
+	}
+	elapsed := t.Sub(p.StartDate).Hours()
+	total := p.EndDate.Sub(p.StartDate).Hours()
+	return math.Round(elapsed/total*10000) / 100
+}
+
+// EvaluateHealth grades cost and schedule against the thresholds and derives
+// the automatic status
+func (p *Project) EvaluateHealth(t HealthThresholds, now time.Time) ProjectHealth {
+	h := ProjectHealth{
+		CostVariance:    p.CalculateVariance(),
+		PlannedProgress: p.PlannedProgress(now),
+		ActualProgress:  p.Progress,
+		Thresholds:      t,
+		StatusManual:    p.StatusManual,
+	}
+	h.ScheduleSlip = math.Max(0, h.PlannedProgress-p.Progress)
+
+	h.CostLevel = gradeHealth(h.CostVariance, t.CostWarningPct, t.CostCriticalPct)
+	h.ScheduleLevel = gradeHealth(h.ScheduleSlip, t.ScheduleWarningPct, t.ScheduleCriticalPct)
+
+	switch {
+	case p.Progress >= 100:
+		h.Status = StatusCompleted
+	case h.CostLevel == HealthCritical:
+		h.Status = StatusOverBudget
+	case h.ScheduleLevel == HealthCritical:
+		h.Status = StatusBehindSchedule
+	case h.CostLevel == HealthWarning || h.ScheduleLevel == HealthWarning:
+		h.Status = StatusWarning
+	default:
+		h.Status = StatusOnTrack
+	}
+	return h
+}
+
+// gradeHealth compares a value against warning and critical thresholds
+func gradeHealth(value, warning, critical float64) HealthLevel {
+	switch {
+	case value > critical:
+		return HealthCritical
+	case value > warning:
+		return HealthWarning
+	default:
+		return HealthOK
+	}
+}
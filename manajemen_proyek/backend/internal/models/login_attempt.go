@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginAttempt records every sign-in attempt for auditing
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Email     string    `gorm:"index;not null" json:"email"`
+	UserID    *uint     `gorm:"index" json:"user_id,omitempty"` // Set when the email matched an account
+	IPAddress string    `gorm:"type:varchar(45);index" json:"ip_address"`
+	UserAgent string    `gorm:"type:text" json:"user_agent"`
+	Success   bool      `gorm:"index" json:"success"`
+	Reason    string    `gorm:"type:varchar(50)" json:"reason"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// Login attempt outcomes
+const (
+	LoginReasonSuccess            = "success"
+	LoginReasonInvalidCredentials = "invalid_credentials"
+	LoginReasonInactive           = "inactive"
+	LoginReasonLocked             = "locked"
+	LoginReasonThrottled          = "throttled"
+	LoginReasonTwoFactorPending   = "two_factor_pending"
+	LoginReasonInvalidTwoFactor   = "invalid_two_factor"
+)
+
+// LoginThrottle is the shared failure counter for an account or IP, used
+// when the login guard runs with the database store
+type LoginThrottle struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Key         string    `gorm:"type:varchar(255);unique;not null" json:"key"`
+	Failures    int       `gorm:"default:0" json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for LoginAttempt model
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+// TableName specifies the table name for LoginThrottle model
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
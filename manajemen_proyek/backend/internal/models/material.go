@@ -46,7 +46,8 @@ type BOM struct {
 	RemainingQty   float64        `gorm:"type:decimal(15,2)" json:"remaining_qty"` // Calculated: PlannedQty - UsedQty
 	EstimatedCost  float64        `gorm:"type:decimal(15,2)" json:"estimated_cost"` // PlannedQty * UnitPrice
 	ActualCost     float64        `gorm:"type:decimal(15,2);default:0" json:"actual_cost"` // UsedQty * UnitPrice
-	Phase          string         `json:"phase"` // Construction phase (foundation, utilities, interior, equipment)
+	PhaseID        *uint          `gorm:"index" json:"phase_id,omitempty"` // Construction phase of the project
+	Phase          *ProjectPhase  `gorm:"foreignKey:PhaseID" json:"phase,omitempty"`
 	Notes          string         `gorm:"type:text" json:"notes"`
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// PermissionWildcard grants every action on a resource, or every resource
+// when used under the "all" key
+const PermissionWildcard = "*"
+
+// PermissionAll is the resource key that grants access to everything
+const PermissionAll = "all"
+
+// PermissionCatalog lists the resources and actions checked by the API.
+// Role permission documents are validated against it.
+var PermissionCatalog = map[string][]string{
+	"projects":       {"read", "read_all", "write", "update_progress"},
+	"reports":        {"read", "write"},
+	"daily_reports":  {"read", "write"},
+	"materials":      {"read", "write", "delete", "adjust_stock"},
+	"bom":            {"read", "write", "delete"},
+	"material_usage": {"read", "write", "delete"},
+	"purchasing":     {"read", "write", "verify"},
+	"costs":          {"read", "write", "verify"},
+	"billing":        {"read", "write"},
+	"users":          {"manage"},
+	"roles":          {"manage"},
+	"audit":          {"read"},
+	"settings":       {"manage"},
+	"documents":      {"read", "write", "delete"},
+	"risks":          {"read", "write", "delete"},
+	"subcontracts":   {"read", "write", "pay"},
+	"recycle_bin":    {"read", "restore", "purge"},
+	"approval":       {PermissionWildcard},
+	"final_approval": {PermissionWildcard},
+}
+
+// Permissions maps a resource to the actions granted on it
+type Permissions map[string][]string
+
+// ParsePermissions parses a role permission document. Each key is a resource
+// and its value is either a list of actions or a boolean, where true grants
+// every action on the resource, e.g.
+//
+//	{"all": true}
+//	{"projects": ["read", "write"], "approval": true}
+func ParsePermissions(raw string) (Permissions, error) {
+	perms := Permissions{}
+	if raw == "" {
+		return perms, nil
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		return nil, fmt.Errorf("invalid permissions document: %w", err)
+	}
+
+	for resource, value := range doc {
+		var granted bool
+		if err := json.Unmarshal(value, &granted); err == nil {
+			if granted {
+				perms[resource] = []string{PermissionWildcard}
+			}
+			continue
+		}
+
+		var actions []string
+		if err := json.Unmarshal(value, &actions); err != nil {
+			return nil, fmt.Errorf("permissions for %q must be a boolean or a list of actions", resource)
+		}
+		if len(actions) > 0 {
+			perms[resource] = actions
+		}
+	}
+
+	return perms, nil
+}
+
+// Allows checks if the permissions grant action on resource
+func (p Permissions) Allows(resource, action string) bool {
+	for _, granted := range p[PermissionAll] {
+		if granted == PermissionWildcard {
+			return true
+		}
+	}
+
+	for _, granted := range p[resource] {
+		if granted == PermissionWildcard || granted == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolve expands the permissions against the catalog, returning every
+// resource with the concrete actions the holder may perform
+func (p Permissions) Resolve() map[string][]string {
+	resolved := map[string][]string{}
+	for resource, actions := range PermissionCatalog {
+		var allowed []string
+		for _, action := range actions {
+			if p.Allows(resource, action) {
+				allowed = append(allowed, action)
+			}
+		}
+		if len(allowed) > 0 {
+			sort.Strings(allowed)
+			resolved[resource] = allowed
+		}
+	}
+	return resolved
+}
+
+// Validate checks every resource and action against the catalog
+func (p Permissions) Validate() error {
+	for resource, actions := range p {
+		if resource == PermissionAll {
+			continue
+		}
+
+		known, ok := PermissionCatalog[resource]
+		if !ok {
+			return fmt.Errorf("unknown permission resource %q", resource)
+		}
+
+		for _, action := range actions {
+			if action == PermissionWildcard {
+				continue
+			}
+			found := false
+			for _, k := range known {
+				if k == action || k == PermissionWildcard {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("unknown action %q for resource %q", action, resource)
+			}
+		}
+	}
+	return nil
+}
+
+// GetPermissions parses the role's permission document. A malformed
+// document grants nothing.
+func (r *Role) GetPermissions() Permissions {
+	perms, err := ParsePermissions(r.Permissions)
+	if err != nil {
+		return Permissions{}
+	}
+	return perms
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProjectPhase is one construction phase of a project. Project progress is
+// the weighted average of its phases.
+type ProjectPhase struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	ProjectID    uint           `gorm:"not null;index" json:"project_id"`
+	Name         string         `gorm:"not null" json:"name"`
+	SortOrder    int            `gorm:"default:0" json:"sort_order"`
+	Weight       float64        `gorm:"type:decimal(7,2);not null;default:0" json:"weight"` // Relative share of the project's work
+	PlannedStart *time.Time     `json:"planned_start,omitempty"`
+	PlannedEnd   *time.Time     `json:"planned_end,omitempty"`
+	Progress     float64        `gorm:"type:decimal(5,2);default:0" json:"progress"` // %
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for ProjectPhase model
+func (ProjectPhase) TableName() string {
+	return "project_phases"
+}
+
+// PhaseTemplate is one phase seeded into new projects of a project type.
+// Planned dates are placed within the project's start and end dates.
+type PhaseTemplate struct {
+	ID          uint        `gorm:"primaryKey" json:"id"`
+	ProjectType ProjectType `gorm:"type:varchar(50);not null;index" json:"project_type"`
+	Name        string      `gorm:"not null" json:"name"`
+	SortOrder   int         `gorm:"default:0" json:"sort_order"`
+	Weight      float64     `gorm:"type:decimal(7,2);not null" json:"weight"`
+	StartPct    float64     `gorm:"type:decimal(5,2);default:0" json:"start_pct"` // Planned start as % of project duration
+	EndPct      float64     `gorm:"type:decimal(5,2);default:100" json:"end_pct"` // Planned end as % of project duration
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// TableName specifies the table name for PhaseTemplate model
+func (PhaseTemplate) TableName() string {
+	return "phase_templates"
+}
+
+// NewPhase builds a project phase from the template, placing its planned
+// dates between start and end when both are known
+func (t PhaseTemplate) NewPhase(projectID uint, start, end time.Time) ProjectPhase {
+	phase := ProjectPhase{
+		ProjectID: projectID,
+		Name:      t.Name,
+		SortOrder: t.SortOrder,
+		Weight:    t.Weight,
+	}
+
+	if !start.IsZero() && end.After(start) {
+		span := end.Sub(start)
+		plannedStart := start.Add(time.Duration(float64(span) * t.StartPct / 100)).Truncate(24 * time.Hour)
+		plannedEnd := start.Add(time.Duration(float64(span) * t.EndPct / 100)).Truncate(24 * time.Hour)
+		phase.PlannedStart = &plannedStart
+		phase.PlannedEnd = &plannedEnd
+	}
+	return phase
+}
+
+// WeightedProgress returns the weighted average progress of the phases. Phases
+// without weight count equally when no phase has a weight.
+func WeightedProgress(phases []ProjectPhase) float64 {
+	if len(phases) == 0 {
+		return 0
+	}
+
+	totalWeight := 0.0
+	weighted := 0.0
+	for _, phase := range phases {
+		totalWeight += phase.Weight
+		weighted += phase.Weight * phase.Progress
+	}
+
+	if totalWeight == 0 {
+		sum := 0.0
+		for _, phase := range phases {
+			sum += phase.Progress
+		}
+		return math.Round(sum/float64(len(phases))*100) / 100
+	}
+	return math.Round(weighted/totalWeight*100) / 100
+}
+
+// DefaultPhaseTemplates returns the phases seeded for each project type when
+// no templates are stored yet
+func DefaultPhaseTemplates() []PhaseTemplate {
+	return []PhaseTemplate{
+		{ProjectType: TypeNewBuild, Name: "Foundation", SortOrder: 1, Weight: 25, StartPct: 0, EndPct: 30},
+		{ProjectType: TypeNewBuild, Name: "Utilities", SortOrder: 2, Weight: 25, StartPct: 25, EndPct: 60},
+		{ProjectType: TypeNewBuild, Name: "Interior", SortOrder: 3, Weight: 25, StartPct: 55, EndPct: 90},
+		{ProjectType: TypeNewBuild, Name: "Equipment", SortOrder: 4, Weight: 25, StartPct: 80, EndPct: 100},
+
+		{ProjectType: TypeRenovation, Name: "Demolition", SortOrder: 1, Weight: 15, StartPct: 0, EndPct: 15},
+		{ProjectType: TypeRenovation, Name: "Structural Repair", SortOrder: 2, Weight: 25, StartPct: 10, EndPct: 45},
+		{ProjectType: TypeRenovation, Name: "Utilities", SortOrder: 3, Weight: 20, StartPct: 35, EndPct: 65},
+		{ProjectType: TypeRenovation, Name: "Finishing", SortOrder: 4, Weight: 30, StartPct: 60, EndPct: 95},
+		{ProjectType: TypeRenovation, Name: "Handover", SortOrder: 5, Weight: 10, StartPct: 90, EndPct: 100},
+
+		{ProjectType: TypeExpansion, Name: "Site Preparation", SortOrder: 1, Weight: 10, StartPct: 0, EndPct: 10},
+		{ProjectType: TypeExpansion, Name: "Foundation", SortOrder: 2, Weight: 25, StartPct: 10, EndPct: 35},
+		{ProjectType: TypeExpansion, Name: "Structure", SortOrder: 3, Weight: 30, StartPct: 30, EndPct: 65},
+		{ProjectType: TypeExpansion, Name: "Utilities", SortOrder: 4, Weight: 15, StartPct: 60, EndPct: 85},
+		{ProjectType: TypeExpansion, Name: "Finishing", SortOrder: 5, Weight: 20, StartPct: 80, EndPct: 100},
+	}
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// ProgressSnapshot records a project's progress and cost at the moment a
+// progress update was made, so its trajectory can be charted
+type ProgressSnapshot struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ProjectID     uint      `gorm:"not null;index:idx_snapshot_project_date" json:"project_id"`
+	SnapshotDate  time.Time `gorm:"type:date;not null;index:idx_snapshot_project_date" json:"snapshot_date"`
+	Progress      float64   `gorm:"type:decimal(5,2);not null" json:"progress"`
+	ActualCost    float64   `gorm:"type:decimal(15,2);default:0" json:"actual_cost"`
+	PhaseProgress JSONText  `gorm:"type:text" json:"phase_progress"` // []PhaseProgressPoint
+	RecordedBy    *uint     `json:"recorded_by,omitempty"`
+	Recorder      *User     `gorm:"foreignKey:RecordedBy" json:"recorder,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for ProgressSnapshot model
+func (ProgressSnapshot) TableName() string {
+	return "progress_snapshots"
+}
+
+// PhaseProgressPoint is the progress of one phase within a snapshot
+type PhaseProgressPoint struct {
+	PhaseID  uint    `json:"phase_id"`
+	Name     string  `json:"name"`
+	Weight   float64 `json:"weight"`
+	Progress float64 `json:"progress"`
+}
+
+// NewProgressSnapshot captures the current state of a project and its phases
+func NewProgressSnapshot(project *Project, phases []ProjectPhase, recordedBy *uint, at time.Time) ProgressSnapshot {
+	points := make([]PhaseProgressPoint, 0, len(phases))
+	for _, phase := range phases {
+		points = append(points, PhaseProgressPoint{
+			PhaseID:  phase.ID,
+			Name:     phase.Name,
+			Weight:   phase.Weight,
+			Progress: phase.Progress,
+		})
+	}
+	encoded, _ := json.Marshal(points)
+
+	return ProgressSnapshot{
+		ProjectID:     project.ID,
+		SnapshotDate:  time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location()),
+		Progress:      project.Progress,
+		ActualCost:    project.ActualCost,
+		PhaseProgress: JSONText(encoded),
+		RecordedBy:    recordedBy,
+	}
+}
+
+// PlannedProgressFromPhases returns the progress expected at t when each phase
+// is worked linearly between its planned dates. Phases without planned dates
+// follow the project dates.
+func (p *Project) PlannedProgressFromPhases(phases []ProjectPhase, t time.Time) float64 {
+	if len(phases) == 0 {
+		return p.PlannedProgress(t)
+	}
+
+	totalWeight := 0.0
+	weighted := 0.0
+	for _, phase := range phases {
+		weight := phase.Weight
+		if weight == 0 {
+			continue
+		}
+		start, end := p.StartDate, p.EndDate
+		if phase.PlannedStart != nil && phase.PlannedEnd != nil {
+			start, end = *phase.PlannedStart, *phase.PlannedEnd
+		}
+		totalWeight += weight
+		weighted += weight * linearProgress(start, end, t)
+	}
+
+	if totalWeight == 0 {
+		return p.PlannedProgress(t)
+	}
+	return math.Round(weighted/totalWeight*100) / 100
+}
+
+// HasPhasePlan checks if any weighted phase has planned dates
+func HasPhasePlan(phases []ProjectPhase) bool {
+	for _, phase := range phases {
+		if phase.Weight > 0 && phase.PlannedStart != nil && phase.PlannedEnd != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// linearProgress returns the share of the span from start to end that has
+// passed at t, as a percentage
+func linearProgress(start, end, t time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	if !t.After(start) {
+		return 0
+	}
+	if !t.Before(end) {
+		return 100
+	}
+	return t.Sub(start).Hours() / end.Sub(start).Hours() * 100
+}
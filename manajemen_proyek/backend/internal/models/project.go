@@ -13,6 +13,7 @@ const (
 	StatusOnTrack    ProjectStatus = "On Track"
 	StatusWarning    ProjectStatus = "Warning"
 	StatusOverBudget ProjectStatus = "Over Budget"
+	StatusBehindSchedule ProjectStatus = "Behind Schedule"
 	StatusCompleted  ProjectStatus = "Completed"
 	StatusOnHold     ProjectStatus = "On Hold"
 )
@@ -35,27 +36,38 @@ type Project struct {
 	City           string            `json:"city"`
 	Address        string            `gorm:"type:text" json:"address"`
 	ProjectType    ProjectType       `gorm:"type:varchar(50);default:'New Build'" json:"project_type"`
-	EstimatedCost  float64           `gorm:"type:decimal(15,2);not null" json:"estimated_cost"`
+	EstimatedCost  float64           `gorm:"type:decimal(15,2);not null" json:"estimated_cost"` // Current budget: original plus approved change orders
+	OriginalBudget float64           `gorm:"type:decimal(15,2);default:0" json:"original_budget"`
 	ActualCost     float64           `gorm:"type:decimal(15,2);default:0" json:"actual_cost"`
 	Progress       float64           `gorm:"type:decimal(5,2);default:0" json:"progress"` // Overall progress percentage
 	Status         ProjectStatus     `gorm:"type:varchar(50);default:'On Track'" json:"status"`
+	StatusManual   bool              `gorm:"default:false" json:"status_manual"` // Set by hand, automatic rules leave it alone
 	StartDate      time.Time         `json:"start_date"`
 	EndDate        time.Time         `json:"end_date"`
 	Deadline       *time.Time        `json:"deadline,omitempty"`
 	
+	// Health threshold overrides, empty means the company default applies
+	CostWarningPct      *float64     `gorm:"type:decimal(7,2)" json:"cost_warning_pct,omitempty"`
+	CostCriticalPct     *float64     `gorm:"type:decimal(7,2)" json:"cost_critical_pct,omitempty"`
+	ScheduleWarningPct  *float64     `gorm:"type:decimal(7,2)" json:"schedule_warning_pct,omitempty"`
+	ScheduleCriticalPct *float64     `gorm:"type:decimal(7,2)" json:"schedule_critical_pct,omitempty"`
+	
 	// Relations
 	ManagerID      uint              `json:"manager_id"`
 	Manager        *User             `gorm:"foreignKey:ManagerID" json:"manager,omitempty"`
 	DailyReports   []DailyReport     `gorm:"foreignKey:ProjectID" json:"daily_reports,omitempty"`
 	WeeklyReports  []WeeklyReport    `gorm:"foreignKey:ProjectID" json:"weekly_reports,omitempty"`
-	ProgressBreakdown *ProgressBreakdown `gorm:"foreignKey:ProjectID" json:"progress_breakdown,omitempty"`
+	Phases         []ProjectPhase    `gorm:"foreignKey:ProjectID" json:"phases,omitempty"`
 	
 	CreatedAt      time.Time         `json:"created_at"`
 	UpdatedAt      time.Time         `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt    `gorm:"index" json:"-"`
 }
 
-// ProgressBreakdown tracks detailed progress per construction phase
+// ProgressBreakdown tracks detailed progress per construction phase.
+//
+// Deprecated: replaced by ProjectPhase. Kept only so existing rows can be
+// migrated.
 type ProgressBreakdown struct {
 	ID         uint           `gorm:"primaryKey" json:"id"`
 	ProjectID  uint           `gorm:"unique;not null" json:"project_id"`
@@ -86,18 +98,53 @@ func (p *Project) CalculateVariance() float64 {
 	return ((p.ActualCost - p.EstimatedCost) / p.EstimatedCost) * 100
 }
 
-// UpdateStatus updates project status based on cost variance
-func (p *Project) UpdateStatus() {
-	variance := p.CalculateVariance()
-	
-	if p.Progress >= 100 {
-		p.Status = StatusCompleted
-	} else if variance > 5 {
-		p.Status = StatusOverBudget
-	} else if variance > 0 {
-		p.Status = StatusWarning
-	} else {
-		p.Status = StatusOnTrack
+// UpdateStatus re-evaluates the status from cost and schedule health using
+// the given company settings. A manually set status is kept, as is On Hold
+// which the rules never produce.
+func (p *Project) UpdateStatus(settings HealthSettings) {
+	if p.StatusManual || p.Status == StatusOnHold {
+		return
 	}
+	p.Status = p.EvaluateHealth(settings.ThresholdsFor(p), time.Now()).Status
+}
+
+// ProjectMemberRole represents a user's role within a single project
+type ProjectMemberRole string
+
+const (
+	ProjectRoleLead   ProjectMemberRole = "lead"   // Runs the project day to day
+	ProjectRoleMember ProjectMemberRole = "member" // Can read and record project data
+	ProjectRoleViewer ProjectMemberRole = "viewer" // Read-only access
+)
+
+// ProjectMember grants a user access to a project
+type ProjectMember struct {
+	ID          uint              `gorm:"primaryKey" json:"id"`
+	ProjectID   uint              `gorm:"not null;uniqueIndex:idx_project_member" json:"project_id"`
+	Project     *Project          `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	UserID      uint              `gorm:"not null;uniqueIndex:idx_project_member;index" json:"user_id"`
+	User        *User             `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ProjectRole ProjectMemberRole `gorm:"type:varchar(20);default:'member'" json:"project_role"`
+	AddedBy     uint              `json:"added_by"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// TableName specifies the table name for ProjectMember model
+func (ProjectMember) TableName() string {
+	return "project_members"
 }
 
+// CanWrite checks if the member may change project data
+func (m *ProjectMember) CanWrite() bool {
+	return m.ProjectRole != ProjectRoleViewer
+}
+
+// IsValidProjectRole checks if role is a known project member role
+func IsValidProjectRole(role ProjectMemberRole) bool {
+	switch role {
+	case ProjectRoleLead, ProjectRoleMember, ProjectRoleViewer:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProjectTemplate is a reusable project blueprint saved from an existing
+// project: its phases, BOM, budget structure and optionally its team.
+// Phases are referenced by their 1-based position (PhaseNo) so a template
+// taken from a project can be used before it is stored.
+type ProjectTemplate struct {
+	ID                uint                 `gorm:"primaryKey" json:"id"`
+	Name              string               `gorm:"not null" json:"name"`
+	Description       string               `gorm:"type:text" json:"description"`
+	ProjectType       ProjectType          `gorm:"type:varchar(50)" json:"project_type"`
+	SourceProjectID   *uint                `gorm:"index" json:"source_project_id,omitempty"`
+	Budget            float64              `gorm:"type:decimal(15,2);default:0" json:"budget"`             // Budget of the source project
+	UnallocatedBudget float64              `gorm:"type:decimal(15,2);default:0" json:"unallocated_budget"` // Part of Budget not itemised in budget lines
+	DurationDays      int                  `gorm:"default:0" json:"duration_days"`
+	CreatedBy         uint                 `gorm:"not null" json:"created_by"`
+	Creator           *User                `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+	Phases            []TemplatePhase      `gorm:"foreignKey:TemplateID" json:"phases,omitempty"`
+	BOMItems          []TemplateBOMItem    `gorm:"foreignKey:TemplateID" json:"bom_items,omitempty"`
+	BudgetLines       []TemplateBudgetLine `gorm:"foreignKey:TemplateID" json:"budget_lines,omitempty"`
+	Members           []TemplateMember     `gorm:"foreignKey:TemplateID" json:"members,omitempty"`
+	CreatedAt         time.Time            `json:"created_at"`
+	UpdatedAt         time.Time            `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt       `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for ProjectTemplate model
+func (ProjectTemplate) TableName() string {
+	return "project_templates"
+}
+
+// TemplatePhase is one phase of a project template. Planned dates are kept
+// as a share of the project duration, as in PhaseTemplate.
+type TemplatePhase struct {
+	ID         uint    `gorm:"primaryKey" json:"id"`
+	TemplateID uint    `gorm:"not null;index" json:"template_id"`
+	Name       string  `gorm:"not null" json:"name"`
+	SortOrder  int     `gorm:"default:0" json:"sort_order"`
+	Weight     float64 `gorm:"type:decimal(7,2);not null;default:0" json:"weight"`
+	StartPct   float64 `gorm:"type:decimal(5,2);default:0" json:"start_pct"`
+	EndPct     float64 `gorm:"type:decimal(5,2);default:100" json:"end_pct"`
+}
+
+// TableName specifies the table name for TemplatePhase model
+func (TemplatePhase) TableName() string {
+	return "template_phases"
+}
+
+// NewPhase builds a project phase from the template phase
+func (t TemplatePhase) NewPhase(projectID uint, start, end time.Time) ProjectPhase {
+	return PhaseTemplate{
+		Name:      t.Name,
+		SortOrder: t.SortOrder,
+		Weight:    t.Weight,
+		StartPct:  t.StartPct,
+		EndPct:    t.EndPct,
+	}.NewPhase(projectID, start, end)
+}
+
+// TemplateBOMItem is one BOM line of a project template. Prices are not
+// kept; the material's current price applies when a project is created.
+type TemplateBOMItem struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TemplateID uint      `gorm:"not null;index" json:"template_id"`
+	MaterialID uint      `gorm:"not null" json:"material_id"`
+	Material   *Material `gorm:"foreignKey:MaterialID" json:"material,omitempty"`
+	PlannedQty float64   `gorm:"type:decimal(15,2);not null" json:"planned_qty"`
+	PhaseNo    int       `gorm:"default:0" json:"phase_no"` // 1-based position in Phases, 0 for none
+	CostCodeID *uint     `json:"cost_code_id,omitempty"`    // Cost code of the BOM line's budget line
+	Notes      string    `gorm:"type:text" json:"notes"`
+}
+
+// TableName specifies the table name for TemplateBOMItem model
+func (TemplateBOMItem) TableName() string {
+	return "template_bom_items"
+}
+
+// TemplateBudgetLine is one budget line of a project template that does not
+// come from the BOM
+type TemplateBudgetLine struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	TemplateID  uint      `gorm:"not null;index" json:"template_id"`
+	CostCodeID  uint      `gorm:"not null" json:"cost_code_id"`
+	CostCode    *CostCode `gorm:"foreignKey:CostCodeID" json:"cost_code,omitempty"`
+	PhaseNo     int       `gorm:"default:0" json:"phase_no"` // 1-based position in Phases, 0 for none
+	Description string    `gorm:"not null" json:"description"`
+	Quantity    float64   `gorm:"type:decimal(15,2);default:0" json:"quantity"`
+	Unit        string    `json:"unit"`
+	UnitPrice   float64   `gorm:"type:decimal(15,2);default:0" json:"unit_price"`
+	Amount      float64   `gorm:"type:decimal(15,2);not null" json:"amount"`
+	Notes       string    `gorm:"type:text" json:"notes"`
+}
+
+// TableName specifies the table name for TemplateBudgetLine model
+func (TemplateBudgetLine) TableName() string {
+	return "template_budget_lines"
+}
+
+// TemplateMember is one team member of a project template
+type TemplateMember struct {
+	ID          uint              `gorm:"primaryKey" json:"id"`
+	TemplateID  uint              `gorm:"not null;index" json:"template_id"`
+	UserID      uint              `gorm:"not null" json:"user_id"`
+	User        *User             `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ProjectRole ProjectMemberRole `gorm:"type:varchar(20);default:'member'" json:"project_role"`
+}
+
+// TableName specifies the table name for TemplateMember model
+func (TemplateMember) TableName() string {
+	return "template_members"
+}
@@ -34,6 +34,21 @@ const (
 	StageGM           ApprovalStage = "GM"
 )
 
+// StageRoles maps each approval stage to the role that approves it
+var StageRoles = map[ApprovalStage]string{
+	StagePurchasing:  "purchasing",
+	StageCostControl: "cost_control",
+	StageGM:          "manager",
+}
+
+// ApproverRole returns the role that approves the stage
+func (s ApprovalStage) ApproverRole() string {
+	if role, ok := StageRoles[s]; ok {
+		return role
+	}
+	return string(s)
+}
+
 // ApprovalHistoryStatus represents status for each approval stage
 type ApprovalHistoryStatus string
 
@@ -91,6 +106,9 @@ type ApprovalHistory struct {
 	Status            ApprovalHistoryStatus `gorm:"type:varchar(20);default:'pending'" json:"status"`
 	ApproverID        *uint                 `json:"approver_id,omitempty"`
 	Approver          *User                 `gorm:"foreignKey:ApproverID" json:"approver,omitempty"`
+	OnBehalfOfID      *uint                 `json:"on_behalf_of_id,omitempty"` // Absent approver when a delegate acted
+	OnBehalfOf        *User                 `gorm:"foreignKey:OnBehalfOfID" json:"on_behalf_of,omitempty"`
+	DelegationID      *uint                 `json:"delegation_id,omitempty"`
 	Comment           string                `gorm:"type:text" json:"comment"`
 	ApprovedAt        *time.Time            `json:"approved_at,omitempty"`
 	CreatedAt         time.Time             `json:"created_at"`
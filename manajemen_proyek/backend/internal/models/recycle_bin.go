@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultRetentionDays is how long deleted records are kept before any
+// settings are saved. Nothing is purged until an admin sets a retention
+// period explicitly.
+const DefaultRetentionDays = 0
+
+// RecycleBinSettings holds the company-wide retention period of deleted
+// records. There is at most one row.
+type RecycleBinSettings struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	RetentionDays int       `gorm:"not null" json:"retention_days"` // Deleted records older than this are purged, 0 keeps them indefinitely
+	UpdatedBy     *uint     `json:"updated_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for RecycleBinSettings model
+func (RecycleBinSettings) TableName() string {
+	return "recycle_bin_settings"
+}
+
+// LoadRecycleBinSettings returns the stored settings, or the defaults when
+// none are saved
+func LoadRecycleBinSettings(db *gorm.DB) RecycleBinSettings {
+	var settings RecycleBinSettings
+	if err := db.Order("id").First(&settings).Error; err != nil {
+		return RecycleBinSettings{RetentionDays: DefaultRetentionDays}
+	}
+	return settings
+}
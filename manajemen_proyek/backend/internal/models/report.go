@@ -28,6 +28,8 @@ type DailyReport struct {
 	Workers     int              `json:"workers"`                              // Number of workers present
 	Notes       string           `gorm:"type:text" json:"notes"`               // Additional notes or issues
 	Photos      []Photo          `gorm:"foreignKey:DailyReportID" json:"photos,omitempty"`
+	TaskActivities []DailyReportActivity `gorm:"foreignKey:DailyReportID" json:"task_activities,omitempty"`
+	Issues      []Risk           `gorm:"foreignKey:DailyReportID" json:"issues,omitempty"` // Raised from this report
 	ReportedBy  uint             `gorm:"not null" json:"reported_by"`
 	Reporter    *User            `gorm:"foreignKey:ReportedBy" json:"reporter,omitempty"`
 	CreatedAt   time.Time        `json:"created_at"`
@@ -35,6 +37,19 @@ type DailyReport struct {
 	DeletedAt   gorm.DeletedAt   `gorm:"index" json:"-"`
 }
 
+// DailyReportActivity is work done on a scheduled task reported in a daily
+// report. Progress is the task's completion at the end of the report date.
+type DailyReportActivity struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	DailyReportID uint      `gorm:"not null;index" json:"daily_report_id"`
+	TaskID        uint      `gorm:"not null;index" json:"task_id"`
+	Task          *Task     `gorm:"foreignKey:TaskID" json:"task,omitempty"`
+	Description   string    `gorm:"type:text" json:"description"`
+	Progress      float64   `gorm:"type:decimal(5,2);not null" json:"progress"` // Task completion %
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
 // Photo represents uploaded photos for daily reports
 type Photo struct {
 	ID            uint           `gorm:"primaryKey" json:"id"`
@@ -78,6 +93,11 @@ func (DailyReport) TableName() string {
 	return "daily_reports"
 }
 
+// TableName specifies the table name for DailyReportActivity model
+func (DailyReportActivity) TableName() string {
+	return "daily_report_activities"
+}
+
 // TableName specifies the table name for Photo model
 func (Photo) TableName() string {
 	return "photos"
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RiskType separates risks that may still happen from issues that already
+// have
+type RiskType string
+
+const (
+	RiskTypeRisk  RiskType = "risk"
+	RiskTypeIssue RiskType = "issue"
+)
+
+// RiskStatus represents the lifecycle of a register entry
+type RiskStatus string
+
+const (
+	RiskOpen       RiskStatus = "open"
+	RiskMitigating RiskStatus = "mitigating"
+	RiskResolved   RiskStatus = "resolved"
+	RiskClosed     RiskStatus = "closed"
+)
+
+// RiskLevel groups risk scores for reporting
+type RiskLevel string
+
+const (
+	RiskLevelLow      RiskLevel = "low"
+	RiskLevelMedium   RiskLevel = "medium"
+	RiskLevelHigh     RiskLevel = "high"
+	RiskLevelCritical RiskLevel = "critical"
+)
+
+// RiskScaleMax is the top of the probability and impact scales
+const RiskScaleMax = 5
+
+// riskTransitions lists the statuses each status can move to. Resolved and
+// closed entries can be reopened.
+var riskTransitions = map[RiskStatus][]RiskStatus{
+	RiskOpen:       {RiskMitigating, RiskResolved, RiskClosed},
+	RiskMitigating: {RiskOpen, RiskResolved, RiskClosed},
+	RiskResolved:   {RiskOpen, RiskClosed},
+	RiskClosed:     {RiskOpen},
+}
+
+// OpenRiskStatuses are the statuses of entries still needing attention
+var OpenRiskStatuses = []RiskStatus{RiskOpen, RiskMitigating}
+
+// IsValidRiskType checks if the risk type is known
+func IsValidRiskType(t RiskType) bool {
+	return t == RiskTypeRisk || t == RiskTypeIssue
+}
+
+// IsOpen checks if the status still needs attention
+func (s RiskStatus) IsOpen() bool {
+	return s == RiskOpen || s == RiskMitigating
+}
+
+// RiskLevelForScore returns the level of a probability x impact score on
+// the 5x5 matrix
+func RiskLevelForScore(score int) RiskLevel {
+	switch {
+	case score >= 20:
+		return RiskLevelCritical
+	case score >= 10:
+		return RiskLevelHigh
+	case score >= 5:
+		return RiskLevelMedium
+	default:
+		return RiskLevelLow
+	}
+}
+
+// Risk is an entry of a project's risk and issue register. Probability and
+// impact are scored 1-5; issues raised from the field link back to the
+// daily report they were found in.
+type Risk struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	Code          string         `gorm:"not null;uniqueIndex:idx_risk_code" json:"code"` // Per project: R-001 for risks, I-001 for issues
+	ProjectID     uint           `gorm:"not null;uniqueIndex:idx_risk_code;index" json:"project_id"`
+	Project       *Project       `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	Type          RiskType       `gorm:"type:varchar(20);not null;index" json:"type"`
+	Title         string         `gorm:"not null" json:"title"`
+	Description   string         `gorm:"type:text" json:"description"`
+	Category      string         `gorm:"type:varchar(50);index" json:"category"` // e.g. safety, schedule, cost, quality
+	Probability   int            `gorm:"not null" json:"probability"`            // 1 (rare) to 5 (almost certain)
+	Impact        int            `gorm:"not null" json:"impact"`                 // 1 (negligible) to 5 (severe)
+	Score         int            `gorm:"not null;index" json:"score"`            // Probability x impact
+	Level         RiskLevel      `gorm:"type:varchar(20);not null" json:"level"`
+	Mitigation    string         `gorm:"type:text" json:"mitigation"` // Planned or taken response
+	Resolution    string         `gorm:"type:text" json:"resolution"` // How it was resolved or why it was closed
+	Status        RiskStatus     `gorm:"type:varchar(20);default:'open';index" json:"status"`
+	OwnerID       *uint          `gorm:"index" json:"owner_id,omitempty"`
+	Owner         *User          `gorm:"foreignKey:OwnerID" json:"owner,omitempty"`
+	DueDate       *time.Time     `gorm:"type:date;index" json:"due_date,omitempty"`
+	DailyReportID *uint          `gorm:"index" json:"daily_report_id,omitempty"` // Report the issue was raised from
+	RaisedBy      uint           `gorm:"not null" json:"raised_by"`
+	Raiser        *User          `gorm:"foreignKey:RaisedBy" json:"raiser,omitempty"`
+	ClosedAt      *time.Time     `json:"closed_at,omitempty"` // When it was last resolved or closed
+	Updates       []RiskUpdate   `gorm:"foreignKey:RiskID" json:"updates,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for Risk model
+func (Risk) TableName() string {
+	return "risks"
+}
+
+// Calculate sets the score and level from probability and impact
+func (r *Risk) Calculate() {
+	r.Score = r.Probability * r.Impact
+	r.Level = RiskLevelForScore(r.Score)
+}
+
+// BeforeSave hook to calculate the score of the entry
+func (r *Risk) BeforeSave(tx *gorm.DB) error {
+	r.Calculate()
+	return nil
+}
+
+// CanTransition checks if the entry can move to the given status
+func (r *Risk) CanTransition(to RiskStatus) bool {
+	for _, status := range riskTransitions[r.Status] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOverdue checks if an open entry is past its due date
+func (r *Risk) IsOverdue(now time.Time) bool {
+	return r.Status.IsOpen() && r.DueDate != nil && r.DueDate.Before(now.Truncate(24*time.Hour))
+}
+
+// RiskUpdate records a status change of a register entry
+type RiskUpdate struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	RiskID     uint       `gorm:"not null;index" json:"risk_id"`
+	FromStatus RiskStatus `gorm:"type:varchar(20)" json:"from_status"` // Empty when raised
+	ToStatus   RiskStatus `gorm:"type:varchar(20);not null" json:"to_status"`
+	Note       string     `gorm:"type:text" json:"note"`
+	UserID     uint       `gorm:"not null" json:"user_id"`
+	User       *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for RiskUpdate model
+func (RiskUpdate) TableName() string {
+	return "risk_updates"
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+)
+
+// Session represents a logged-in device. All refresh tokens rotated from the
+// same login belong to one session, so revoking the session ends the family.
+type Session struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"not null;index" json:"user_id"`
+	User          *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	DeviceName    string     `json:"device_name"`
+	UserAgent     string     `gorm:"type:text" json:"user_agent"`
+	IPAddress     string     `gorm:"type:varchar(45)" json:"ip_address"`
+	AccessJTI     string     `gorm:"type:varchar(64);index" json:"-"` // jti of the latest access token
+	AccessExpires time.Time  `json:"-"`                               // Expiry of the latest access token
+	LastUsedAt    time.Time  `json:"last_used_at"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt     *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason,omitempty"`
+	Current       bool       `gorm:"-" json:"current"` // Set when listing the caller's own sessions
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// RefreshToken is an opaque, single-use token bound to a session. Only the
+// hash of the token is stored.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	SessionID uint       `gorm:"not null;index" json:"session_id"`
+	TokenHash string     `gorm:"unique;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"` // Set once the token has been rotated
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RevokedToken is a denylist entry for an access token jti. Entries can be
+// dropped once the token they refer to has expired.
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	JTI       string    `gorm:"type:varchar(64);unique;not null" json:"jti"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Session revocation reasons
+const (
+	RevokeReasonLogout      = "logout"
+	RevokeReasonReuse       = "refresh_token_reuse"
+	RevokeReasonForced      = "forced_logout"
+	RevokeReasonDeactivated = "user_deactivated"
+	RevokeReasonPassword    = "password_changed"
+)
+
+// PasswordResetToken is a single-use token emailed to a user who forgot
+// their password. Only the hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"unique;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RequestIP string     `gorm:"type:varchar(45)" json:"request_ip"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for Session model
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// TableName specifies the table name for RefreshToken model
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// TableName specifies the table name for RevokedToken model
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// TableName specifies the table name for PasswordResetToken model
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
+
+// IsActive checks if the session can still be refreshed
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
@@ -0,0 +1,265 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Subcontractor is a company work is subcontracted to
+type Subcontractor struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	Code          string         `gorm:"unique;not null" json:"code"`
+	Name          string         `gorm:"not null" json:"name"`
+	Trade         string         `json:"trade"` // e.g. MEP, finishing, steel works
+	ContactPerson string         `json:"contact_person"`
+	Phone         string         `json:"phone"`
+	Email         string         `json:"email"`
+	Address       string         `gorm:"type:text" json:"address"`
+	TaxID         string         `json:"tax_id"` // NPWP
+	BankName      string         `json:"bank_name"`
+	BankAccount   string         `json:"bank_account"`
+	IsActive      bool           `gorm:"default:true" json:"is_active"`
+	Notes         string         `gorm:"type:text" json:"notes"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for Subcontractor model
+func (Subcontractor) TableName() string {
+	return "subcontractors"
+}
+
+// SubcontractStatus represents the lifecycle of a subcontract
+type SubcontractStatus string
+
+const (
+	SubcontractDraft      SubcontractStatus = "draft"      // Being negotiated, can be changed
+	SubcontractActive     SubcontractStatus = "active"     // Signed, work can be certified
+	SubcontractCompleted  SubcontractStatus = "completed"  // Work finished, only payments remain
+	SubcontractTerminated SubcontractStatus = "terminated" // Ended early, the uncertified value is released
+)
+
+// subcontractTransitions lists the statuses a subcontract can move to
+var subcontractTransitions = map[SubcontractStatus][]SubcontractStatus{
+	SubcontractDraft:  {SubcontractActive},
+	SubcontractActive: {SubcontractCompleted, SubcontractTerminated},
+}
+
+// IsValidSubcontractStatus checks if the status is known
+func IsValidSubcontractStatus(status SubcontractStatus) bool {
+	switch status {
+	case SubcontractDraft, SubcontractActive, SubcontractCompleted, SubcontractTerminated:
+		return true
+	}
+	return false
+}
+
+// Subcontract is an agreement with a subcontractor for part of a project's
+// work. Its value is committed cost until certified; approved payment
+// certificates add the certified work to the project's actual cost.
+type Subcontract struct {
+	ID              uint                 `gorm:"primaryKey" json:"id"`
+	ContractNumber  string               `gorm:"not null;uniqueIndex:idx_subcontract_number" json:"contract_number"` // Per project: SC-001, SC-002, ...
+	ProjectID       uint                 `gorm:"not null;uniqueIndex:idx_subcontract_number" json:"project_id"`
+	Project         *Project             `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	SubcontractorID uint                 `gorm:"not null;index" json:"subcontractor_id"`
+	Subcontractor   *Subcontractor       `gorm:"foreignKey:SubcontractorID" json:"subcontractor,omitempty"`
+	Title           string               `gorm:"not null" json:"title"`
+	Scope           string               `gorm:"type:text" json:"scope"`
+	CostCodeID      *uint                `gorm:"index" json:"cost_code_id,omitempty"` // Defaults to the subcontract root code
+	CostCode        *CostCode            `gorm:"foreignKey:CostCodeID" json:"cost_code,omitempty"`
+	PhaseID         *uint                `gorm:"index" json:"phase_id,omitempty"`
+	ContractValue   float64              `gorm:"type:decimal(15,2);not null" json:"contract_value"`
+	RetentionPct    float64              `gorm:"type:decimal(5,2);default:0" json:"retention_pct"` // Withheld from each certificate until release
+	PaymentTermDays int                  `gorm:"default:30" json:"payment_term_days"`
+	PaymentTerms    string               `gorm:"type:text" json:"payment_terms"`
+	StartDate       *time.Time           `gorm:"type:date" json:"start_date,omitempty"`
+	EndDate         *time.Time           `gorm:"type:date" json:"end_date,omitempty"`
+	Status          SubcontractStatus    `gorm:"type:varchar(20);default:'draft';index" json:"status"`
+	CertifiedAmount float64              `gorm:"type:decimal(15,2);default:0" json:"certified_amount"` // Work value of approved certificates
+	RetentionHeld   float64              `gorm:"type:decimal(15,2);default:0" json:"retention_held"`   // Withheld and not yet released
+	PaidAmount      float64              `gorm:"type:decimal(15,2);default:0" json:"paid_amount"`
+	CreatedBy       uint                 `gorm:"not null" json:"created_by"`
+	Creator         *User                `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+	Certificates    []PaymentCertificate `gorm:"foreignKey:SubcontractID" json:"certificates,omitempty"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt       `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for Subcontract model
+func (Subcontract) TableName() string {
+	return "subcontracts"
+}
+
+// CanTransition checks if the subcontract can move to the given status
+func (s *Subcontract) CanTransition(to SubcontractStatus) bool {
+	for _, status := range subcontractTransitions[s.Status] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// UncertifiedValue returns the contract value not certified yet. It is
+// committed cost while the subcontract is active.
+func (s *Subcontract) UncertifiedValue() float64 {
+	if s.CertifiedAmount >= s.ContractValue {
+		return 0
+	}
+	return roundCurrency(s.ContractValue - s.CertifiedAmount)
+}
+
+// Payable returns the amount certified for payment and not paid yet
+func (s *Subcontract) Payable() float64 {
+	return roundCurrency(s.CertifiedAmount - s.RetentionHeld - s.PaidAmount)
+}
+
+// Outstanding returns what is still to be paid under the subcontract:
+// the contract value less payments, or only the certified amount once the
+// subcontract is terminated
+func (s *Subcontract) Outstanding() float64 {
+	switch s.Status {
+	case SubcontractDraft:
+		return 0
+	case SubcontractTerminated:
+		return roundCurrency(s.CertifiedAmount - s.PaidAmount)
+	}
+	return roundCurrency(s.ContractValue - s.PaidAmount)
+}
+
+// PaymentCertificateStatus represents the lifecycle of a payment certificate
+type PaymentCertificateStatus string
+
+const (
+	CertificateDraft    PaymentCertificateStatus = "draft"
+	CertificatePending  PaymentCertificateStatus = "pending"
+	CertificateApproved PaymentCertificateStatus = "approved"
+	CertificateRejected PaymentCertificateStatus = "rejected"
+)
+
+// PaymentCertificateStages are the approval stages a payment certificate
+// goes through, in order
+var PaymentCertificateStages = []ApprovalStage{StageCostControl, StageGM}
+
+// PaymentCertificate certifies a subcontractor's progress for payment. The
+// progress is cumulative; the certificate is worth the work done since the
+// previous approved certificate, less retention.
+type PaymentCertificate struct {
+	ID                uint                         `gorm:"primaryKey" json:"id"`
+	CertNumber        string                       `gorm:"not null;uniqueIndex:idx_payment_certificate_number" json:"cert_number"` // Per subcontract: PC-01, PC-02, ...
+	SubcontractID     uint                         `gorm:"not null;uniqueIndex:idx_payment_certificate_number" json:"subcontract_id"`
+	Subcontract       *Subcontract                 `gorm:"foreignKey:SubcontractID" json:"subcontract,omitempty"`
+	ProjectID         uint                         `gorm:"not null;index" json:"project_id"`
+	PeriodEnd         time.Time                    `gorm:"type:date;not null" json:"period_end"`
+	ProgressPct       float64                      `gorm:"type:decimal(5,2);not null" json:"progress_pct"`         // Cumulative
+	CumulativeValue   float64                      `gorm:"type:decimal(15,2);not null" json:"cumulative_value"`    // ContractValue * ProgressPct
+	PreviousValue     float64                      `gorm:"type:decimal(15,2);default:0" json:"previous_value"`     // Certified before this certificate
+	WorkValue         float64                      `gorm:"type:decimal(15,2);not null" json:"work_value"`          // CumulativeValue - PreviousValue
+	RetentionAmount   float64                      `gorm:"type:decimal(15,2);default:0" json:"retention_amount"`   // Withheld from the work value
+	ReleaseRetention  bool                         `gorm:"default:false" json:"release_retention"`                 // Pays out the retention held, final certificate only
+	RetentionReleased float64                      `gorm:"type:decimal(15,2);default:0" json:"retention_released"` // Released by this certificate
+	NetAmount         float64                      `gorm:"type:decimal(15,2);not null" json:"net_amount"`          // To pay: work value - retention + released
+	PaidAmount        float64                      `gorm:"type:decimal(15,2);default:0" json:"paid_amount"`
+	Notes             string                       `gorm:"type:text" json:"notes"`
+	Status            PaymentCertificateStatus     `gorm:"type:varchar(20);default:'draft';index" json:"status"`
+	CurrentStage      ApprovalStage                `gorm:"type:varchar(50)" json:"current_stage"`
+	CreatedBy         uint                         `gorm:"not null" json:"created_by"`
+	Creator           *User                        `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+	SubmittedAt       *time.Time                   `json:"submitted_at,omitempty"`
+	ApprovedAt        *time.Time                   `json:"approved_at,omitempty"`
+	Approvals         []PaymentCertificateApproval `gorm:"foreignKey:CertificateID" json:"approvals,omitempty"`
+	Payments          []SubcontractPayment         `gorm:"foreignKey:CertificateID" json:"payments,omitempty"`
+	CreatedAt         time.Time                    `json:"created_at"`
+	UpdatedAt         time.Time                    `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt               `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for PaymentCertificate model
+func (PaymentCertificate) TableName() string {
+	return "payment_certificates"
+}
+
+// Calculate values the certificate against its subcontract: the cumulative
+// value of the progress, the work since the approved certificates, the
+// retention withheld and released, and the net amount to pay
+func (pc *PaymentCertificate) Calculate(subcontract *Subcontract) {
+	pc.CumulativeValue = roundCurrency(subcontract.ContractValue * pc.ProgressPct / 100)
+	pc.PreviousValue = subcontract.CertifiedAmount
+	pc.WorkValue = roundCurrency(pc.CumulativeValue - pc.PreviousValue)
+	pc.RetentionAmount = roundCurrency(pc.WorkValue * subcontract.RetentionPct / 100)
+	pc.RetentionReleased = 0
+	if pc.ReleaseRetention {
+		pc.RetentionReleased = roundCurrency(subcontract.RetentionHeld + pc.RetentionAmount)
+	}
+	pc.NetAmount = roundCurrency(pc.WorkValue - pc.RetentionAmount + pc.RetentionReleased)
+}
+
+// Outstanding returns the amount of an approved certificate still to be paid
+func (pc *PaymentCertificate) Outstanding() float64 {
+	if pc.Status != CertificateApproved {
+		return 0
+	}
+	return roundCurrency(pc.NetAmount - pc.PaidAmount)
+}
+
+// NextStage returns the approval stage after the current one, or nil when
+// the current stage is the last
+func (pc *PaymentCertificate) NextStage() *ApprovalStage {
+	for i, stage := range PaymentCertificateStages {
+		if stage == pc.CurrentStage && i+1 < len(PaymentCertificateStages) {
+			next := PaymentCertificateStages[i+1]
+			return &next
+		}
+	}
+	return nil
+}
+
+// PaymentCertificateApproval tracks a payment certificate through each
+// approval stage
+type PaymentCertificateApproval struct {
+	ID            uint                  `gorm:"primaryKey" json:"id"`
+	CertificateID uint                  `gorm:"not null;index" json:"certificate_id"`
+	Stage         ApprovalStage         `gorm:"type:varchar(50);not null" json:"stage"`
+	Status        ApprovalHistoryStatus `gorm:"type:varchar(20);default:'pending'" json:"status"`
+	ApproverID    *uint                 `json:"approver_id,omitempty"`
+	Approver      *User                 `gorm:"foreignKey:ApproverID" json:"approver,omitempty"`
+	OnBehalfOfID  *uint                 `json:"on_behalf_of_id,omitempty"` // Absent approver when a delegate acted
+	OnBehalfOf    *User                 `gorm:"foreignKey:OnBehalfOfID" json:"on_behalf_of,omitempty"`
+	DelegationID  *uint                 `json:"delegation_id,omitempty"`
+	Comment       string                `gorm:"type:text" json:"comment"`
+	ApprovedAt    *time.Time            `json:"approved_at,omitempty"`
+	CreatedAt     time.Time             `json:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at"`
+}
+
+// TableName specifies the table name for PaymentCertificateApproval model
+func (PaymentCertificateApproval) TableName() string {
+	return "payment_certificate_approvals"
+}
+
+// SubcontractPayment is a payment made to a subcontractor against an
+// approved payment certificate
+type SubcontractPayment struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	VoucherNumber string         `gorm:"unique;not null" json:"voucher_number"` // Auto-generated: SCP-YYYY-XXXX
+	CertificateID uint           `gorm:"not null;index" json:"certificate_id"`
+	Amount        float64        `gorm:"type:decimal(15,2);not null" json:"amount"`
+	PaymentDate   time.Time      `gorm:"type:date;not null" json:"payment_date"`
+	Method        string         `json:"method"` // e.g. transfer, cheque
+	Reference     string         `json:"reference"`
+	Notes         string         `gorm:"type:text" json:"notes"`
+	PaidBy        uint           `gorm:"not null" json:"paid_by"`
+	Payer         *User          `gorm:"foreignKey:PaidBy" json:"payer,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for SubcontractPayment model
+func (SubcontractPayment) TableName() string {
+	return "subcontract_payments"
+}
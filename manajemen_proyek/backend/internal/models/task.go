@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Task is one item of a project's work breakdown structure. Tasks with
+// subtasks are summary tasks whose dates and progress roll up from their
+// subtasks; the other tasks are scheduled with the critical path method.
+type Task struct {
+	ID              uint          `gorm:"primaryKey" json:"id"`
+	ProjectID       uint          `gorm:"not null;index" json:"project_id"`
+	ParentID        *uint         `gorm:"index" json:"parent_id,omitempty"`
+	PhaseID         *uint         `gorm:"index" json:"phase_id,omitempty"`
+	Phase           *ProjectPhase `gorm:"foreignKey:PhaseID" json:"phase,omitempty"`
+	WBSCode         string        `gorm:"column:wbs_code;type:varchar(50)" json:"wbs_code"` // e.g. 1.2.3, kept up to date on every change
+	Name            string        `gorm:"not null" json:"name"`
+	Description     string        `gorm:"type:text" json:"description"`
+	SortOrder       int           `gorm:"default:0" json:"sort_order"`
+	Duration        int           `gorm:"default:0" json:"duration"`                   // Calendar days, zero for milestones
+	ConstraintStart *time.Time    `gorm:"type:date" json:"constraint_start,omitempty"` // Start no earlier than
+	IsSummary       bool          `gorm:"default:false" json:"is_summary"`
+
+	// Scheduled dates, set by the forward and backward passes
+	PlannedStart *time.Time `gorm:"type:date" json:"planned_start,omitempty"`
+	PlannedEnd   *time.Time `gorm:"type:date" json:"planned_end,omitempty"`
+	LateStart    *time.Time `gorm:"type:date" json:"late_start,omitempty"`
+	LateEnd      *time.Time `gorm:"type:date" json:"late_end,omitempty"`
+	TotalFloat   int        `gorm:"default:0" json:"total_float"` // Days
+	FreeFloat    int        `gorm:"default:0" json:"free_float"`  // Days
+	IsCritical   bool       `gorm:"default:false" json:"is_critical"`
+
+	// Field progress, reported through daily reports or set by hand
+	Progress    float64    `gorm:"type:decimal(5,2);default:0" json:"progress"` // %
+	ActualStart *time.Time `gorm:"type:date" json:"actual_start,omitempty"`
+	ActualEnd   *time.Time `gorm:"type:date" json:"actual_end,omitempty"`
+
+	Assignees []User         `gorm:"many2many:task_assignees" json:"assignees,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for Task model
+func (Task) TableName() string {
+	return "tasks"
+}
+
+// TaskDependency links two tasks of a project. Type is one of FS, SS, FF or
+// SF and Lag is in days, negative for lead time.
+type TaskDependency struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ProjectID     uint      `gorm:"not null;index" json:"project_id"`
+	PredecessorID uint      `gorm:"not null;uniqueIndex:idx_task_dependency" json:"predecessor_id"`
+	Predecessor   *Task     `gorm:"foreignKey:PredecessorID" json:"predecessor,omitempty"`
+	SuccessorID   uint      `gorm:"not null;uniqueIndex:idx_task_dependency;index" json:"successor_id"`
+	Successor     *Task     `gorm:"foreignKey:SuccessorID" json:"successor,omitempty"`
+	Type          string    `gorm:"type:varchar(2);not null;default:'FS'" json:"type"`
+	Lag           int       `gorm:"default:0" json:"lag"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for TaskDependency model
+func (TaskDependency) TableName() string {
+	return "task_dependencies"
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryCode is a single-use code that stands in for a TOTP code when the
+// authenticator device is lost. Only the hash is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null;index" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LoginChallenge is issued after a correct password when a second factor is
+// still needed. Its token is exchanged for a session once the code checks out.
+type LoginChallenge struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash  string     `gorm:"unique;not null" json:"-"`
+	Purpose    string     `gorm:"type:varchar(20);not null" json:"purpose"`
+	DeviceName string     `json:"device_name"`
+	Attempts   int        `gorm:"default:0" json:"attempts"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Login challenge purposes
+const (
+	ChallengeVerify = "verify" // User has 2FA and must enter a code
+	ChallengeEnroll = "enroll" // Role requires 2FA that the user has not set up yet
+)
+
+// MaxChallengeAttempts is the number of wrong codes a user may enter across
+// all of their challenges within ChallengeAttemptWindow. New challenges do
+// not reset it, so logging in again gives no fresh guesses.
+const MaxChallengeAttempts = 5
+
+// ChallengeAttemptWindow is how far back wrong codes count towards
+// MaxChallengeAttempts
+const ChallengeAttemptWindow = time.Hour
+
+// TableName specifies the table name for RecoveryCode model
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+// TableName specifies the table name for LoginChallenge model
+func (LoginChallenge) TableName() string {
+	return "login_challenges"
+}
+
+// IsUsable checks if the challenge can still be answered
+func (l *LoginChallenge) IsUsable() bool {
+	return l.UsedAt == nil && l.Attempts < MaxChallengeAttempts && time.Now().Before(l.ExpiresAt)
+}
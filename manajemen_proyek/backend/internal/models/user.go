@@ -20,18 +20,21 @@ type Role struct {
 
 // User represents system users
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Name      string         `gorm:"not null" json:"name"`
-	Email     string         `gorm:"unique;not null" json:"email"`
-	Password  string         `gorm:"not null" json:"-"` // Password hash, never exposed in JSON
-	RoleID    uint           `gorm:"not null" json:"role_id"`
-	Role      Role           `gorm:"foreignKey:RoleID" json:"role"`
-	Phone     string         `json:"phone"`
-	Position  string         `json:"position"`
-	IsActive  bool           `gorm:"default:true" json:"is_active"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	Name              string         `gorm:"not null" json:"name"`
+	Email             string         `gorm:"unique;not null" json:"email"`
+	Password          string         `gorm:"not null" json:"-"` // Password hash, never exposed in JSON
+	RoleID            uint           `gorm:"not null" json:"role_id"`
+	Role              Role           `gorm:"foreignKey:RoleID" json:"role"`
+	Phone             string         `json:"phone"`
+	Position          string         `json:"position"`
+	IsActive          bool           `gorm:"default:true" json:"is_active"`
+	TwoFactorEnabled  bool           `gorm:"default:false" json:"two_factor_enabled"`
+	TwoFactorSecret   string         `json:"-"` // Base32 TOTP secret, pending until 2FA is enabled
+	TwoFactorLastStep int64          `json:"-"` // Last accepted TOTP step, to reject replayed codes
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // TableName specifies the table name for User model
@@ -44,3 +47,60 @@ func (Role) TableName() string {
 	return "roles"
 }
 
+// Invitation grants a single email address the right to register with a
+// role chosen by an admin. Only the hash of the invite token is stored.
+type Invitation struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	Email      string         `gorm:"not null;index" json:"email"`
+	RoleID     uint           `gorm:"not null" json:"role_id"`
+	Role       *Role          `gorm:"foreignKey:RoleID" json:"role,omitempty"`
+	TokenHash  string         `gorm:"unique;not null" json:"-"`
+	ExpiresAt  time.Time      `gorm:"not null" json:"expires_at"`
+	AcceptedAt *time.Time     `json:"accepted_at,omitempty"`
+	UserID     *uint          `json:"user_id,omitempty"` // User created from this invitation
+	RevokedAt  *time.Time     `json:"revoked_at,omitempty"`
+	InvitedBy  uint           `gorm:"not null" json:"invited_by"`
+	Inviter    *User          `gorm:"foreignKey:InvitedBy" json:"inviter,omitempty"`
+	Status     string         `gorm:"-" json:"status"` // Derived, see CurrentStatus
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// InvitationStatus values derived from the invitation timestamps
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusRevoked  = "revoked"
+	InvitationStatusExpired  = "expired"
+)
+
+// TableName specifies the table name for Invitation model
+func (Invitation) TableName() string {
+	return "invitations"
+}
+
+// AfterFind hook to fill in the derived status
+func (i *Invitation) AfterFind(tx *gorm.DB) error {
+	i.Status = i.CurrentStatus()
+	return nil
+}
+
+// CurrentStatus returns the current state of the invitation
+func (i *Invitation) CurrentStatus() string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationStatusAccepted
+	case i.RevokedAt != nil:
+		return InvitationStatusRevoked
+	case time.Now().After(i.ExpiresAt):
+		return InvitationStatusExpired
+	default:
+		return InvitationStatusPending
+	}
+}
+
+// IsUsable checks if the invitation can still be used to register
+func (i *Invitation) IsUsable() bool {
+	return i.CurrentStatus() == InvitationStatusPending
+}
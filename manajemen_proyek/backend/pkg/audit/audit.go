@@ -0,0 +1,462 @@
+// Package audit records every create, update and delete made through GORM
+// in the audit_logs table. The acting user, client IP and request ID are
+// read from the statement context, so handlers pass the gin context with
+// db.WithContext(c). Changes made without it are logged without an actor.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unipro/project-management/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// beforeKey is the statement instance key holding the rows captured before
+// an update or delete
+const beforeKey = "audit:before"
+
+// maxRows caps the rows captured for a single bulk statement
+const maxRows = 500
+
+// redacted replaces the value of columns hidden from JSON, such as password
+// hashes, so the log shows that they changed but not what to
+const redacted = "[redacted]"
+
+// skipTables are not audited: the audit log itself and high-volume
+// authentication bookkeeping that has its own records
+var skipTables = map[string]bool{
+	"audit_logs":            true,
+	"sessions":              true,
+	"refresh_tokens":        true,
+	"revoked_tokens":        true,
+	"password_reset_tokens": true,
+	"recovery_codes":        true,
+	"login_challenges":      true,
+	"login_attempts":        true,
+	"login_throttles":       true,
+	"notifications":         true,
+}
+
+// ignoredColumns change on every write and would only add noise to diffs
+var ignoredColumns = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+// Actor identifies who made a change
+type Actor struct {
+	UserID    *uint
+	IPAddress string
+	RequestID string
+}
+
+// Register installs the audit callbacks on the database
+func Register(db *gorm.DB) error {
+	cb := db.Callback()
+
+	if err := cb.Create().After("gorm:create").Register("audit:after_create", afterCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("audit:before_update", captureBefore); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("audit:after_update", afterUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("audit:before_delete", captureBefore); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("audit:after_delete", afterDelete)
+}
+
+// actorFrom reads the actor from a gin context passed to WithContext
+func actorFrom(ctx context.Context) Actor {
+	c, ok := ctx.(*gin.Context)
+	if !ok {
+		return Actor{}
+	}
+
+	actor := Actor{
+		IPAddress: c.ClientIP(),
+		RequestID: c.GetString("request_id"),
+	}
+	if value, exists := c.Get("user_id"); exists {
+		if userID, ok := value.(uint); ok {
+			actor.UserID = &userID
+		}
+	}
+	return actor
+}
+
+// auditable checks if the statement touches an audited table
+func auditable(db *gorm.DB) bool {
+	return db.Error == nil && db.Statement.Schema != nil && !skipTables[db.Statement.Table]
+}
+
+// session returns a handle on the same connection or transaction as the
+// statement, without its clauses and without re-entering the callbacks
+func session(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true, SkipHooks: true})
+}
+
+// captureBefore loads the rows an update or delete is about to change
+func captureBefore(db *gorm.DB) {
+	if !auditable(db) {
+		return
+	}
+
+	stmt := db.Statement
+	query := session(db).Table(stmt.Table)
+	hasConditions := false
+
+	if where, ok := stmt.Clauses["WHERE"]; ok {
+		if w, ok := where.Expression.(clause.Where); ok && len(w.Exprs) > 0 {
+			query = query.Clauses(clause.Where{Exprs: w.Exprs})
+			hasConditions = true
+		}
+	}
+
+	// Model(&row).Updates(...) and Delete(&row) target the row's primary key
+	if ids := primaryKeys(stmt); len(ids) > 0 {
+		query = query.Where(clause.IN{
+			Column: clause.Column{Table: stmt.Table, Name: primaryColumn(stmt.Schema)},
+			Values: ids,
+		})
+		hasConditions = true
+	}
+
+	if !hasConditions {
+		return
+	}
+
+	if !stmt.Unscoped {
+		if field := stmt.Schema.LookUpField("DeletedAt"); field != nil {
+			query = query.Where(clause.Eq{Column: clause.Column{Table: stmt.Table, Name: field.DBName}, Value: nil})
+		}
+	}
+
+	var rows []map[string]interface{}
+	if err := query.Limit(maxRows).Find(&rows).Error; err != nil {
+		db.AddError(fmt.Errorf("audit: failed to capture rows: %w", err))
+		return
+	}
+	db.InstanceSet(beforeKey, rows)
+}
+
+// afterCreate logs the inserted rows
+func afterCreate(db *gorm.DB) {
+	if !auditable(db) || db.RowsAffected == 0 {
+		return
+	}
+
+	stmt := db.Statement
+	var rows []map[string]interface{}
+
+	value := reflect.Indirect(stmt.ReflectValue)
+	switch value.Kind() {
+	case reflect.Struct:
+		rows = append(rows, rowFromStruct(stmt, value))
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			rows = append(rows, rowFromStruct(stmt, reflect.Indirect(value.Index(i))))
+		}
+	default:
+		// Creates from a map are rare and carry no primary key to log
+		return
+	}
+
+	logs := make([]models.AuditLog, 0, len(rows))
+	for _, row := range rows {
+		entry, err := newEntry(stmt, models.AuditActionCreate, nil, row)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		logs = append(logs, entry)
+	}
+	write(db, logs)
+}
+
+// afterUpdate logs the diff of every captured row that actually changed
+func afterUpdate(db *gorm.DB) {
+	if !auditable(db) || db.RowsAffected == 0 {
+		return
+	}
+
+	before := capturedRows(db)
+	if len(before) == 0 {
+		return
+	}
+
+	stmt := db.Statement
+	pk := primaryColumn(stmt.Schema)
+
+	ids := make([]interface{}, 0, len(before))
+	for _, row := range before {
+		ids = append(ids, row[pk])
+	}
+
+	var afterRows []map[string]interface{}
+	if err := session(db).Table(stmt.Table).
+		Where(clause.IN{Column: clause.Column{Table: stmt.Table, Name: pk}, Values: ids}).
+		Find(&afterRows).Error; err != nil {
+		db.AddError(fmt.Errorf("audit: failed to load updated rows: %w", err))
+		return
+	}
+
+	afterByID := make(map[string]map[string]interface{}, len(afterRows))
+	for _, row := range afterRows {
+		afterByID[fmt.Sprint(row[pk])] = row
+	}
+
+	var logs []models.AuditLog
+	for _, old := range before {
+		updated, ok := afterByID[fmt.Sprint(old[pk])]
+		if !ok {
+			continue
+		}
+		entry, err := newEntry(stmt, models.AuditActionUpdate, old, updated)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		if entry.Changes == "" {
+			continue
+		}
+		logs = append(logs, entry)
+	}
+	write(db, logs)
+}
+
+// afterDelete logs the captured rows as deleted
+func afterDelete(db *gorm.DB) {
+	if !auditable(db) || db.RowsAffected == 0 {
+		return
+	}
+
+	before := capturedRows(db)
+	logs := make([]models.AuditLog, 0, len(before))
+	for _, row := range before {
+		entry, err := newEntry(db.Statement, models.AuditActionDelete, row, nil)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		logs = append(logs, entry)
+	}
+	write(db, logs)
+}
+
+// write inserts audit entries in the statement's transaction, so they are
+// rolled back together with the change they describe
+func write(db *gorm.DB, logs []models.AuditLog) {
+	if len(logs) == 0 {
+		return
+	}
+	if err := session(db).Create(&logs).Error; err != nil {
+		db.AddError(fmt.Errorf("audit: failed to write log: %w", err))
+	}
+}
+
+// capturedRows returns the rows stored by captureBefore
+func capturedRows(db *gorm.DB) []map[string]interface{} {
+	value, ok := db.InstanceGet(beforeKey)
+	if !ok {
+		return nil
+	}
+	rows, _ := value.([]map[string]interface{})
+	return rows
+}
+
+// newEntry builds an audit log for one row. Either side may be nil.
+func newEntry(stmt *gorm.Statement, action string, before, after map[string]interface{}) (models.AuditLog, error) {
+	actor := actorFrom(stmt.Context)
+	entry := models.AuditLog{
+		ActorID:    actor.UserID,
+		EntityType: stmt.Table,
+		Action:     action,
+		IPAddress:  actor.IPAddress,
+		RequestID:  actor.RequestID,
+	}
+
+	row := after
+	if row == nil {
+		row = before
+	}
+	pk := primaryColumn(stmt.Schema)
+	entry.EntityID = fmt.Sprint(row[pk])
+	if stmt.Table == "projects" {
+		entry.ProjectID = toUint(row[pk])
+	} else if projectID, ok := row["project_id"]; ok {
+		entry.ProjectID = toUint(projectID)
+	}
+
+	hidden := hiddenColumns(stmt.Schema)
+
+	var err error
+	if entry.Before, err = encode(redact(before, hidden)); err != nil {
+		return entry, err
+	}
+	if entry.After, err = encode(redact(after, hidden)); err != nil {
+		return entry, err
+	}
+
+	if before != nil && after != nil {
+		changes := diff(before, after, hidden)
+		if len(changes) > 0 {
+			if entry.Changes, err = encode(changes); err != nil {
+				return entry, err
+			}
+		}
+	}
+
+	return entry, nil
+}
+
+// diff returns the columns whose values differ, compared by their JSON
+// form. Hidden columns are reported as changed without their values.
+func diff(before, after map[string]interface{}, hidden map[string]bool) map[string]interface{} {
+	changes := map[string]interface{}{}
+	for column, newValue := range after {
+		if ignoredColumns[column] {
+			continue
+		}
+		oldValue := before[column]
+
+		oldJSON, _ := json.Marshal(oldValue)
+		newJSON, _ := json.Marshal(newValue)
+		if string(oldJSON) == string(newJSON) {
+			continue
+		}
+
+		if hidden[column] {
+			oldValue, newValue = redacted, redacted
+		}
+		changes[column] = map[string]interface{}{"old": oldValue, "new": newValue}
+	}
+	return changes
+}
+
+// rowFromStruct converts a model value to a column map
+func rowFromStruct(stmt *gorm.Statement, value reflect.Value) map[string]interface{} {
+	row := map[string]interface{}{}
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		fieldValue, _ := field.ValueOf(stmt.Context, value)
+		row[field.DBName] = fieldValue
+	}
+	return row
+}
+
+// redact masks the values of hidden columns in a row
+func redact(row map[string]interface{}, hidden map[string]bool) map[string]interface{} {
+	if row == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(row))
+	for column, value := range row {
+		if hidden[column] {
+			value = redacted
+		}
+		out[column] = value
+	}
+	return out
+}
+
+// hiddenColumns lists the columns of fields excluded from JSON output
+func hiddenColumns(s *schema.Schema) map[string]bool {
+	hidden := map[string]bool{}
+	for _, field := range s.Fields {
+		if field.DBName != "" && field.Tag.Get("json") == "-" {
+			hidden[field.DBName] = true
+		}
+	}
+	return hidden
+}
+
+// primaryColumn returns the primary key column of the schema
+func primaryColumn(s *schema.Schema) string {
+	if s.PrioritizedPrimaryField != nil {
+		return s.PrioritizedPrimaryField.DBName
+	}
+	return "id"
+}
+
+// primaryKeys returns the non-zero primary keys of the statement's model
+func primaryKeys(stmt *gorm.Statement) []interface{} {
+	if stmt.Model == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil
+	}
+
+	field := stmt.Schema.PrioritizedPrimaryField
+	value := reflect.Indirect(reflect.ValueOf(stmt.Model))
+
+	var ids []interface{}
+	switch value.Kind() {
+	case reflect.Struct:
+		if id, zero := field.ValueOf(stmt.Context, value); !zero {
+			ids = append(ids, id)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			elem := reflect.Indirect(value.Index(i))
+			if elem.Kind() != reflect.Struct {
+				continue
+			}
+			if id, zero := field.ValueOf(stmt.Context, elem); !zero {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// encode marshals a value to JSON text, leaving nil empty
+func encode(value interface{}) (models.JSONText, error) {
+	if value == nil || reflect.ValueOf(value).IsNil() {
+		return "", nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("audit: failed to encode row: %w", err)
+	}
+	return models.JSONText(data), nil
+}
+
+// toUint converts a scanned ID to *uint
+func toUint(value interface{}) *uint {
+	var id uint
+	switch v := value.(type) {
+	case uint:
+		id = v
+	case uint32:
+		id = uint(v)
+	case uint64:
+		id = uint(v)
+	case int:
+		id = uint(v)
+	case int32:
+		id = uint(v)
+	case int64:
+		id = uint(v)
+	case *uint:
+		if v == nil {
+			return nil
+		}
+		id = *v
+	default:
+		return nil
+	}
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
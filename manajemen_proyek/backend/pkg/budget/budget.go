@@ -0,0 +1,353 @@
+// Package budget keeps the itemised project budget (RAB) in line with the
+// BOM, attributes ledger entries to cost codes and reports budget against
+// actual and committed cost per cost code. Callers pass the transaction that
+// changes the source so budget lines commit or roll back with it.
+package budget
+
+import (
+	"math"
+	"strings"
+
+	"github.com/unipro/project-management/internal/models"
+	"gorm.io/gorm"
+)
+
+// RootCode returns the first root cost code of a type
+func RootCode(tx *gorm.DB, t models.CostCodeType) (models.CostCode, error) {
+	var code models.CostCode
+	err := tx.Where("parent_id IS NULL AND type = ?", t).Order("sort_order ASC, id ASC").First(&code).Error
+	return code, err
+}
+
+// SyncBOM creates or updates the materials budget line of a BOM line. New
+// lines go to the materials root cost code; lines moved to another cost code
+// keep it.
+func SyncBOM(tx *gorm.DB, bom *models.BOM) error {
+	var material models.Material
+	if err := tx.Unscoped().First(&material, bom.MaterialID).Error; err != nil {
+		return err
+	}
+
+	var line models.BudgetLine
+	err := tx.Where("bom_id = ?", bom.ID).First(&line).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		root, err := RootCode(tx, models.CostTypeMaterials)
+		if err != nil {
+			return err
+		}
+		bomID := bom.ID
+		line = models.BudgetLine{
+			ProjectID:   bom.ProjectID,
+			CostCodeID:  root.ID,
+			BOMID:       &bomID,
+			Description: material.Name,
+		}
+	case err != nil:
+		return err
+	}
+
+	line.PhaseID = bom.PhaseID
+	line.Quantity = bom.PlannedQty
+	line.Unit = material.Unit
+	line.Amount = roundCurrency(bom.EstimatedCost)
+	line.UnitPrice = material.UnitPrice
+	if bom.PlannedQty != 0 {
+		line.UnitPrice = roundCurrency(bom.EstimatedCost / bom.PlannedQty)
+	}
+
+	return tx.Save(&line).Error
+}
+
+// SyncProjectBOM brings every BOM budget line of a project in line with its
+// BOM, removing lines of BOM lines that no longer exist
+func SyncProjectBOM(tx *gorm.DB, projectID uint) error {
+	var boms []models.BOM
+	if err := tx.Where("project_id = ?", projectID).Find(&boms).Error; err != nil {
+		return err
+	}
+
+	ids := make([]uint, 0, len(boms))
+	for i := range boms {
+		if err := SyncBOM(tx, &boms[i]); err != nil {
+			return err
+		}
+		ids = append(ids, boms[i].ID)
+	}
+
+	query := tx.Where("project_id = ? AND bom_id IS NOT NULL", projectID)
+	if len(ids) > 0 {
+		query = query.Where("bom_id NOT IN ?", ids)
+	}
+	return query.Delete(&models.BudgetLine{}).Error
+}
+
+// Attribute sets the cost code and phase of a ledger entry that has no cost
+// code yet. Material usage follows the budget line of the material's BOM
+// line, purchase requests go to materials, subcontract certificates to
+// subcontracting and manual entries to the cost type named by their category. Entries matching none stay unattributed.
+func Attribute(tx *gorm.DB, entry *models.CostEntry) error {
+	if entry.CostCodeID != nil {
+		return nil
+	}
+
+	costType := models.CostCodeType(strings.ToLower(strings.TrimSpace(entry.Category)))
+	switch entry.SourceType {
+	case models.CostSourceMaterialUsage:
+		if entry.SourceID != nil {
+			var usage models.MaterialUsage
+			if err := tx.Unscoped().First(&usage, *entry.SourceID).Error; err == nil {
+				if line, ok := materialLine(tx, usage.ProjectID, usage.MaterialID); ok {
+					entry.CostCodeID = &line.CostCodeID
+					entry.PhaseID = line.PhaseID
+					return nil
+				}
+			}
+		}
+		costType = models.CostTypeMaterials
+	case models.CostSourcePurchaseRequest:
+		costType = models.CostTypeMaterials
+	case models.CostSourceSubcontract:
+		costType = models.CostTypeSubcontract
+	}
+
+	if !models.IsValidCostCodeType(costType) {
+		return nil
+	}
+	root, err := RootCode(tx, costType)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entry.CostCodeID = &root.ID
+	return nil
+}
+
+// materialLine returns the budget line of a material's BOM line in a project
+func materialLine(tx *gorm.DB, projectID, materialID uint) (models.BudgetLine, bool) {
+	var line models.BudgetLine
+	err := tx.Joins("JOIN boms ON boms.id = budget_lines.bom_id AND boms.deleted_at IS NULL").
+		Where("budget_lines.project_id = ? AND boms.material_id = ?", projectID, materialID).
+		First(&line).Error
+	return line, err == nil
+}
+
+// Row is the budget, actual and committed cost of a cost code, including
+// its descendants
+type Row struct {
+	CostCodeID uint                `json:"cost_code_id"`
+	Code       string              `json:"code"`
+	Name       string              `json:"name"`
+	Type       models.CostCodeType `json:"type"`
+	ParentID   *uint               `json:"parent_id,omitempty"`
+	Level      int                 `json:"level"`
+	Budget     float64             `json:"budget"`
+	Actual     float64             `json:"actual"`
+	Committed  float64             `json:"committed"` // Purchase requests in approval and uncertified subcontract value
+	Forecast   float64             `json:"forecast"`  // Actual plus committed
+	Remaining  float64             `json:"remaining"` // Budget less forecast
+	UsedPct    float64             `json:"used_pct"`  // Forecast as a share of budget
+	OverBudget bool                `json:"over_budget"`
+}
+
+// Report is a project's budget versus actual versus committed cost per cost
+// code
+type Report struct {
+	ProjectID          uint    `json:"project_id"`
+	PhaseID            *uint   `json:"phase_id,omitempty"`
+	ProjectBudget      float64 `json:"project_budget"`      // Project.EstimatedCost
+	UnallocatedBudget  float64 `json:"unallocated_budget"`  // Project budget not yet itemised
+	UnattributedActual float64 `json:"unattributed_actual"` // Costs without a cost code
+	Totals             Row     `json:"totals"`
+	Rows               []Row   `json:"rows"` // Depth first, parents before children
+}
+
+// BuildReport builds the budget report of a project, optionally limited to
+// one phase. Committed cost without a phase is left out of phase reports.
+func BuildReport(db *gorm.DB, project *models.Project, phaseID *uint) (Report, error) {
+	report := Report{ProjectID: project.ID, PhaseID: phaseID, ProjectBudget: project.EstimatedCost}
+
+	var codes []models.CostCode
+	if err := db.Unscoped().Order("sort_order ASC, code ASC").Find(&codes).Error; err != nil {
+		return report, err
+	}
+
+	budgets := map[uint]float64{}
+	var lines []models.BudgetLine
+	lineQuery := db.Where("project_id = ?", project.ID)
+	if phaseID != nil {
+		lineQuery = lineQuery.Where("phase_id = ?", *phaseID)
+	}
+	if err := lineQuery.Find(&lines).Error; err != nil {
+		return report, err
+	}
+	for _, line := range lines {
+		budgets[line.CostCodeID] += line.Amount
+	}
+
+	actuals := map[uint]float64{}
+	var spent []struct {
+		CostCodeID *uint
+		Total      float64
+	}
+	entryQuery := db.Model(&models.CostEntry{}).
+		Select("cost_code_id, COALESCE(SUM(amount), 0) AS total").
+		Where("project_id = ?", project.ID)
+	if phaseID != nil {
+		entryQuery = entryQuery.Where("phase_id = ?", *phaseID)
+	}
+	if err := entryQuery.Group("cost_code_id").Scan(&spent).Error; err != nil {
+		return report, err
+	}
+	for _, row := range spent {
+		if row.CostCodeID == nil {
+			report.UnattributedActual += row.Total
+			continue
+		}
+		actuals[*row.CostCodeID] += row.Total
+	}
+
+	committed, err := committedCost(db, project.ID, phaseID)
+	if err != nil {
+		return report, err
+	}
+
+	// Roll values up the hierarchy, parents before children
+	children := map[uint][]models.CostCode{}
+	var roots []models.CostCode
+	for _, code := range codes {
+		if code.ParentID == nil {
+			roots = append(roots, code)
+		} else {
+			children[*code.ParentID] = append(children[*code.ParentID], code)
+		}
+	}
+
+	var walk func(code models.CostCode, level int) Row
+	walk = func(code models.CostCode, level int) Row {
+		row := Row{
+			CostCodeID: code.ID,
+			Code:       code.Code,
+			Name:       code.Name,
+			Type:       code.Type,
+			ParentID:   code.ParentID,
+			Level:      level,
+			Budget:     budgets[code.ID],
+			Actual:     actuals[code.ID],
+			Committed:  committed[code.ID],
+		}
+		index := len(report.Rows)
+		report.Rows = append(report.Rows, row)
+
+		for _, child := range children[code.ID] {
+			sub := walk(child, level+1)
+			row.Budget += sub.Budget
+			row.Actual += sub.Actual
+			row.Committed += sub.Committed
+		}
+
+		row.finish()
+		// Codes removed from the hierarchy only show while they carry values
+		if code.DeletedAt.Valid && row.Budget == 0 && row.Actual == 0 && row.Committed == 0 {
+			report.Rows = report.Rows[:index]
+			return row
+		}
+		report.Rows[index] = row
+		return row
+	}
+
+	for _, root := range roots {
+		sub := walk(root, 0)
+		report.Totals.Budget += sub.Budget
+		report.Totals.Actual += sub.Actual
+		report.Totals.Committed += sub.Committed
+	}
+	report.Totals.Name = "Total"
+	report.Totals.Actual += report.UnattributedActual
+	report.Totals.finish()
+
+	report.UnattributedActual = roundCurrency(report.UnattributedActual)
+	if phaseID == nil {
+		report.UnallocatedBudget = roundCurrency(project.EstimatedCost - report.Totals.Budget)
+	}
+	return report, nil
+}
+
+// committedCost returns the cost committed but not incurred yet per cost
+// code: purchase requests still in approval and the uncertified value of
+// active subcontracts. Purchase request items follow the budget line of their
+// material's BOM line, or go to the materials root; subcontracts go to their
+// own cost code, or the subcontract root.
+func committedCost(db *gorm.DB, projectID uint, phaseID *uint) (map[uint]float64, error) {
+	var items []struct {
+		MaterialID uint
+		TotalPrice float64
+	}
+	if err := db.Model(&models.PRItem{}).
+		Select("pr_items.material_id, pr_items.total_price").
+		Joins("JOIN purchase_requests ON purchase_requests.id = pr_items.purchase_request_id AND purchase_requests.deleted_at IS NULL").
+		Where("purchase_requests.project_id = ? AND purchase_requests.status = ?", projectID, models.PRStatusPending).
+		Scan(&items).Error; err != nil {
+		return nil, err
+	}
+
+	committed := map[uint]float64{}
+	if len(items) > 0 {
+		root, rootErr := RootCode(db, models.CostTypeMaterials)
+		for _, item := range items {
+			line, ok := materialLine(db, projectID, item.MaterialID)
+			switch {
+			case phaseID != nil && (!ok || line.PhaseID == nil || *line.PhaseID != *phaseID):
+				continue
+			case ok:
+				committed[line.CostCodeID] += item.TotalPrice
+			case rootErr == nil:
+				committed[root.ID] += item.TotalPrice
+			}
+		}
+	}
+
+	query := db.Where("project_id = ? AND status = ?", projectID, models.SubcontractActive)
+	if phaseID != nil {
+		query = query.Where("phase_id = ?", *phaseID)
+	}
+	var subcontracts []models.Subcontract
+	if err := query.Find(&subcontracts).Error; err != nil {
+		return nil, err
+	}
+	if len(subcontracts) == 0 {
+		return committed, nil
+	}
+
+	root, rootErr := RootCode(db, models.CostTypeSubcontract)
+	for _, subcontract := range subcontracts {
+		switch {
+		case subcontract.CostCodeID != nil:
+			committed[*subcontract.CostCodeID] += subcontract.UncertifiedValue()
+		case rootErr == nil:
+			committed[root.ID] += subcontract.UncertifiedValue()
+		}
+	}
+	return committed, nil
+}
+
+// finish rounds the row and derives forecast, remaining and usage
+func (r *Row) finish() {
+	r.Budget = roundCurrency(r.Budget)
+	r.Actual = roundCurrency(r.Actual)
+	r.Committed = roundCurrency(r.Committed)
+	r.Forecast = roundCurrency(r.Actual + r.Committed)
+	r.Remaining = roundCurrency(r.Budget - r.Forecast)
+	r.OverBudget = r.Forecast > r.Budget
+	r.UsedPct = 0
+	if r.Budget > 0 {
+		r.UsedPct = roundCurrency(r.Forecast / r.Budget * 100)
+	}
+}
+
+// roundCurrency rounds an amount to cents
+func roundCurrency(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,340 @@
+// Package costledger keeps Project.ActualCost in sync with the cost_entries
+// ledger. Callers pass the transaction that changes the cost source so the
+// ledger and the project total commit or roll back together. Entries are
+// attributed to the project budget as they are recorded.
+//
+// Material bought through a purchase request and later recorded as used is
+// counted once: usage is always recognised in full, and each approved
+// purchase request only adds the part of its lines that usage of the same
+// material has not consumed yet.
+package costledger
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/budget"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sync records the cost of a source row, replacing any earlier entry for the
+// same source, then refreshes the project total. A zero amount removes the
+// entry.
+func Sync(tx *gorm.DB, entry models.CostEntry) error {
+	if entry.SourceID == nil {
+		return fmt.Errorf("cost entry from %s has no source ID", entry.SourceType)
+	}
+	if err := budget.Attribute(tx, &entry); err != nil {
+		return err
+	}
+
+	var existing models.CostEntry
+	err := tx.Where("source_type = ? AND source_id = ?", entry.SourceType, *entry.SourceID).First(&existing).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		if entry.Amount != 0 {
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+		}
+	case err != nil:
+		return err
+	case entry.Amount == 0:
+		if err := tx.Delete(&existing).Error; err != nil {
+			return err
+		}
+	default:
+		if err := tx.Model(&existing).Updates(map[string]interface{}{
+			"project_id":   entry.ProjectID,
+			"category":     entry.Category,
+			"cost_code_id": entry.CostCodeID,
+			"phase_id":     entry.PhaseID,
+			"description":  entry.Description,
+			"amount":       entry.Amount,
+			"entry_date":   entry.EntryDate,
+		}).Error; err != nil {
+			return err
+		}
+		// A source moved to another project leaves the old total stale
+		if existing.ProjectID != entry.ProjectID {
+			if err := Refresh(tx, existing.ProjectID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return Refresh(tx, entry.ProjectID)
+}
+
+// Remove deletes the entry of a source row and refreshes the project total
+func Remove(tx *gorm.DB, source models.CostSource, sourceID uint) error {
+	var entries []models.CostEntry
+	if err := tx.Where("source_type = ? AND source_id = ?", source, sourceID).Find(&entries).Error; err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := tx.Delete(&entry).Error; err != nil {
+			return err
+		}
+		if err := Refresh(tx, entry.ProjectID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Refresh offsets purchase requests against material usage, sets a
+// project's actual cost to the sum of its ledger and re-evaluates its status
+func Refresh(tx *gorm.DB, projectID uint) error {
+	var project models.Project
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&project, projectID).Error; err != nil {
+		return err
+	}
+
+	if err := offsetPurchases(tx, projectID); err != nil {
+		return err
+	}
+
+	var total float64
+	if err := tx.Model(&models.CostEntry{}).
+		Where("project_id = ?", projectID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error; err != nil {
+		return err
+	}
+
+	project.ActualCost = total
+	project.UpdateStatus(models.LoadHealthSettings(tx))
+
+	return tx.Model(&project).Updates(map[string]interface{}{
+		"actual_cost": project.ActualCost,
+		"status":      project.Status,
+	}).Error
+}
+
+// Rebuild regenerates every derived entry of a project from its source rows.
+// Manual entries are kept.
+func Rebuild(tx *gorm.DB, projectID uint) error {
+	if err := tx.Unscoped().Where("project_id = ? AND source_type <> ?", projectID, models.CostSourceManual).
+		Delete(&models.CostEntry{}).Error; err != nil {
+		return err
+	}
+
+	var usages []models.MaterialUsage
+	if err := tx.Preload("Material").Where("project_id = ?", projectID).Find(&usages).Error; err != nil {
+		return err
+	}
+	for _, usage := range usages {
+		if usage.Cost == 0 {
+			continue
+		}
+		entry := MaterialUsageEntry(usage, usage.Material)
+		if err := budget.Attribute(tx, &entry); err != nil {
+			return err
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+
+	var prs []models.PurchaseRequest
+	if err := tx.Where("project_id = ? AND status = ?", projectID, models.PRStatusApproved).Find(&prs).Error; err != nil {
+		return err
+	}
+	for _, pr := range prs {
+		if pr.TotalAmount == 0 {
+			continue
+		}
+		entry := PurchaseRequestEntry(pr, pr.UpdatedAt)
+		if err := budget.Attribute(tx, &entry); err != nil {
+			return err
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+
+	var certificates []models.PaymentCertificate
+	if err := tx.Preload("Subcontract").
+		Where("project_id = ? AND status = ?", projectID, models.CertificateApproved).
+		Find(&certificates).Error; err != nil {
+		return err
+	}
+	for _, cert := range certificates {
+		if cert.WorkValue == 0 || cert.Subcontract == nil {
+			continue
+		}
+		approvedAt := cert.UpdatedAt
+		if cert.ApprovedAt != nil {
+			approvedAt = *cert.ApprovedAt
+		}
+		entry := PaymentCertificateEntry(cert, cert.Subcontract, approvedAt)
+		if err := budget.Attribute(tx, &entry); err != nil {
+			return err
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+
+	return Refresh(tx, projectID)
+}
+
+// offsetPurchases sets the entry of every approved purchase request of a
+// project to the part of it not yet covered by material usage
+func offsetPurchases(tx *gorm.DB, projectID uint) error {
+	var prs []models.PurchaseRequest
+	if err := tx.Preload("Items").
+		Where("project_id = ? AND status = ?", projectID, models.PRStatusApproved).
+		Order("updated_at, id").
+		Find(&prs).Error; err != nil {
+		return err
+	}
+	if len(prs) == 0 {
+		return nil
+	}
+
+	var usage []struct {
+		MaterialID uint
+		Cost       float64
+	}
+	if err := tx.Model(&models.MaterialUsage{}).
+		Where("project_id = ?", projectID).
+		Select("material_id, COALESCE(SUM(cost), 0) AS cost").
+		Group("material_id").
+		Scan(&usage).Error; err != nil {
+		return err
+	}
+	used := make(map[uint]float64, len(usage))
+	for _, u := range usage {
+		used[u.MaterialID] = u.Cost
+	}
+
+	amounts := purchaseAmounts(prs, used)
+	for _, pr := range prs {
+		amount := amounts[pr.ID]
+
+		var existing models.CostEntry
+		err := tx.Where("source_type = ? AND source_id = ?", models.CostSourcePurchaseRequest, pr.ID).First(&existing).Error
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			if amount == 0 {
+				continue
+			}
+			entry := PurchaseRequestEntry(pr, pr.UpdatedAt)
+			entry.Amount = amount
+			if err := budget.Attribute(tx, &entry); err != nil {
+				return err
+			}
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case amount == 0:
+			if err := tx.Delete(&existing).Error; err != nil {
+				return err
+			}
+		case existing.Amount != amount:
+			if err := tx.Model(&existing).Update("amount", amount).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// purchaseAmounts returns the uncovered amount of each purchase request by
+// ID. Usage cost per material is consumed by the lines of that material in
+// the order the requests are given; the part of a request's total not
+// itemised in lines is never offset.
+func purchaseAmounts(prs []models.PurchaseRequest, used map[uint]float64) map[uint]float64 {
+	remaining := make(map[uint]float64, len(used))
+	for materialID, cost := range used {
+		remaining[materialID] = cost
+	}
+
+	amounts := make(map[uint]float64, len(prs))
+	for _, pr := range prs {
+		lines := 0.0
+		amount := 0.0
+		for _, item := range pr.Items {
+			lines += item.TotalPrice
+			covered := math.Min(item.TotalPrice, remaining[item.MaterialID])
+			if covered > 0 {
+				remaining[item.MaterialID] -= covered
+			} else {
+				covered = 0
+			}
+			amount += item.TotalPrice - covered
+		}
+		if rest := pr.TotalAmount - lines; rest > 0 {
+			amount += rest
+		}
+		amounts[pr.ID] = math.Round(amount*100) / 100
+	}
+	return amounts
+}
+
+// MaterialUsageEntry builds the ledger entry of a material usage record.
+// The material is optional and only used to describe the entry.
+func MaterialUsageEntry(usage models.MaterialUsage, material *models.Material) models.CostEntry {
+	description := "Material usage"
+	category := "material"
+	if material != nil {
+		description = fmt.Sprintf("%s: %.2f %s", material.Name, usage.Quantity, material.Unit)
+		category = string(material.Category)
+	}
+
+	sourceID := usage.ID
+	createdBy := usage.UsedBy
+	return models.CostEntry{
+		ProjectID:   usage.ProjectID,
+		SourceType:  models.CostSourceMaterialUsage,
+		SourceID:    &sourceID,
+		Category:    category,
+		Description: description,
+		Amount:      usage.Cost,
+		EntryDate:   usage.UsageDate,
+		CreatedBy:   &createdBy,
+	}
+}
+
+// PurchaseRequestEntry builds the ledger entry of a fully approved purchase
+// request at its full amount. Refresh reduces it by the usage it covers.
+func PurchaseRequestEntry(pr models.PurchaseRequest, approvedAt time.Time) models.CostEntry {
+	sourceID := pr.ID
+	return models.CostEntry{
+		ProjectID:   pr.ProjectID,
+		SourceType:  models.CostSourcePurchaseRequest,
+		SourceID:    &sourceID,
+		Category:    "purchasing",
+		Description: fmt.Sprintf("%s: %s", pr.PRNumber, pr.Title),
+		Amount:      pr.TotalAmount,
+		EntryDate:   approvedAt,
+	}
+}
+
+// PaymentCertificateEntry builds the ledger entry of a fully approved
+// subcontract payment certificate. The cost is the certified work value;
+// retention is a payment timing matter and does not reduce it.
+func PaymentCertificateEntry(cert models.PaymentCertificate, subcontract *models.Subcontract, approvedAt time.Time) models.CostEntry {
+	sourceID := cert.ID
+	createdBy := cert.CreatedBy
+	return models.CostEntry{
+		ProjectID:   cert.ProjectID,
+		SourceType:  models.CostSourceSubcontract,
+		SourceID:    &sourceID,
+		Category:    string(models.CostTypeSubcontract),
+		CostCodeID:  subcontract.CostCodeID,
+		PhaseID:     subcontract.PhaseID,
+		Description: fmt.Sprintf("%s %s: %s", subcontract.ContractNumber, cert.CertNumber, subcontract.Title),
+		Amount:      cert.WorkValue,
+		EntryDate:   approvedAt,
+		CreatedBy:   &createdBy,
+	}
+}
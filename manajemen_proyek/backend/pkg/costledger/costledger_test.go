@@ -0,0 +1,84 @@
+package costledger
+
+import (
+	"testing"
+
+	"github.com/unipro/project-management/internal/models"
+)
+
+// cement is the material of the purchase request lines in the tests
+const cement = 7
+
+func purchase(id uint, total float64, lines ...float64) models.PurchaseRequest {
+	pr := models.PurchaseRequest{ID: id, TotalAmount: total}
+	for _, line := range lines {
+		pr.Items = append(pr.Items, models.PRItem{MaterialID: cement, TotalPrice: line})
+	}
+	return pr
+}
+
+func TestPurchaseAndItsUsageCountOnce(t *testing.T) {
+	prs := []models.PurchaseRequest{purchase(1, 1000, 1000)}
+	usage := 1000.0
+
+	amounts := purchaseAmounts(prs, map[uint]float64{cement: usage})
+	if total := amounts[1] + usage; total != 1000 {
+		t.Errorf("purchase %v plus usage %v = %v, want 1000", amounts[1], usage, total)
+	}
+}
+
+func TestPurchaseAmounts(t *testing.T) {
+	tests := []struct {
+		name string
+		prs  []models.PurchaseRequest
+		used map[uint]float64
+		want map[uint]float64
+	}{
+		{
+			name: "no usage",
+			prs:  []models.PurchaseRequest{purchase(1, 1000, 1000)},
+			want: map[uint]float64{1: 1000},
+		},
+		{
+			name: "partly used",
+			prs:  []models.PurchaseRequest{purchase(1, 1000, 1000)},
+			used: map[uint]float64{cement: 400},
+			want: map[uint]float64{1: 600},
+		},
+		{
+			name: "used beyond the purchase",
+			prs:  []models.PurchaseRequest{purchase(1, 1000, 1000)},
+			used: map[uint]float64{cement: 1500},
+			want: map[uint]float64{1: 0},
+		},
+		{
+			name: "earlier purchases are consumed first",
+			prs:  []models.PurchaseRequest{purchase(1, 500, 500), purchase(2, 800, 800)},
+			used: map[uint]float64{cement: 700},
+			want: map[uint]float64{1: 0, 2: 600},
+		},
+		{
+			name: "other materials are not offset",
+			prs:  []models.PurchaseRequest{purchase(1, 1000, 1000)},
+			used: map[uint]float64{cement + 1: 1000},
+			want: map[uint]float64{1: 1000},
+		},
+		{
+			name: "amount outside the lines is kept",
+			prs:  []models.PurchaseRequest{purchase(1, 1100, 1000)},
+			used: map[uint]float64{cement: 1000},
+			want: map[uint]float64{1: 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := purchaseAmounts(tt.prs, tt.used)
+			for id, want := range tt.want {
+				if got[id] != want {
+					t.Errorf("purchase %d = %v, want %v", id, got[id], want)
+				}
+			}
+		})
+	}
+}
@@ -1,11 +1,17 @@
 package database
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/unipro/project-management/config"
 	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/audit"
+	"github.com/unipro/project-management/pkg/budget"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -36,6 +42,11 @@ func Connect(cfg *config.DatabaseConfig) error {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// Record every create, update and delete in audit_logs
+	if err := audit.Register(DB); err != nil {
+		return fmt.Errorf("failed to register audit callbacks: %w", err)
+	}
+
 	log.Println("✓ Database connected successfully")
 	return nil
 }
@@ -48,13 +59,30 @@ func AutoMigrate() error {
 		// User & Auth
 		&models.Role{},
 		&models.User{},
+		&models.Invitation{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.PasswordResetToken{},
+		&models.RecoveryCode{},
+		&models.LoginChallenge{},
+		&models.LoginAttempt{},
+		&models.LoginThrottle{},
+		&models.AuditLog{},
 		
 		// Project Management
 		&models.Project{},
-		&models.ProgressBreakdown{},
+		&models.ProjectPhase{},
+		&models.PhaseTemplate{},
+		&models.ProgressSnapshot{},
+		&models.ProjectMember{},
+		&models.HealthSettings{},
+		&models.Task{},
+		&models.TaskDependency{},
 		
 		// Reports
 		&models.DailyReport{},
+		&models.DailyReportActivity{},
 		&models.Photo{},
 		&models.WeeklyReport{},
 		
@@ -62,6 +90,50 @@ func AutoMigrate() error {
 		&models.Material{},
 		&models.BOM{},
 		&models.MaterialUsage{},
+		&models.CostEntry{},
+		&models.EVMSnapshot{},
+		
+		// Budget (RAB)
+		&models.CostCode{},
+		&models.BudgetLine{},
+		
+		// Project Templates
+		&models.ProjectTemplate{},
+		&models.TemplatePhase{},
+		&models.TemplateBOMItem{},
+		&models.TemplateBudgetLine{},
+		&models.TemplateMember{},
+		
+		// Documents
+		&models.DocumentFolder{},
+		&models.Document{},
+		&models.DocumentVersion{},
+		&models.DocumentLink{},
+		
+		// Risk & Issue Register
+		&models.Risk{},
+		&models.RiskUpdate{},
+		
+		// Subcontracts
+		&models.Subcontractor{},
+		&models.Subcontract{},
+		&models.PaymentCertificate{},
+		&models.PaymentCertificateApproval{},
+		&models.SubcontractPayment{},
+		
+		// Recycle Bin
+		&models.RecycleBinSettings{},
+		
+		// Change Orders
+		&models.ChangeOrder{},
+		&models.ChangeOrderItem{},
+		&models.ChangeOrderApproval{},
+		
+		// Customer Billing
+		&models.ProjectContract{},
+		&models.BillingMilestone{},
+		&models.Invoice{},
+		&models.InvoicePayment{},
 		
 		// Purchase Requests
 		&models.PurchaseRequest{},
@@ -71,6 +143,7 @@ func AutoMigrate() error {
 		
 		// Approvals & Notifications
 		&models.Approval{},
+		&models.Delegation{},
 		&models.Notification{},
 	)
 
@@ -82,6 +155,100 @@ func AutoMigrate() error {
 	return nil
 }
 
+// stageApproverGrants returns the actions a role needs to run the approval
+// stages it is assigned in models.StageRoles. Approvers decide requests of
+// every project, not only the ones they are members of.
+func stageApproverGrants(roleName string) map[string][]string {
+	for _, approver := range models.StageRoles {
+		if approver == roleName {
+			return map[string][]string{"projects": {"read_all"}}
+		}
+	}
+	return nil
+}
+
+// mergeDefaultPermissions adds the default grants of every resource missing
+// from a role's permission document and returns the merged document with the
+// added resources. Resources the document already has, including ones an
+// admin set to false or an empty list, are kept as they are, except that
+// the required actions are added to a resource granted as a list.
+func mergeDefaultPermissions(current, defaults string, required map[string][]string) (string, []string, error) {
+	if _, err := models.ParsePermissions(current); err != nil {
+		return "", nil, err
+	}
+	defaultPerms, err := models.ParsePermissions(defaults)
+	if err != nil {
+		return "", nil, err
+	}
+
+	doc := map[string]json.RawMessage{}
+	if current != "" {
+		if err := json.Unmarshal([]byte(current), &doc); err != nil {
+			return "", nil, err
+		}
+	}
+	var defaultDoc map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(defaults), &defaultDoc); err != nil {
+		return "", nil, err
+	}
+
+	var added []string
+	for resource := range defaultPerms {
+		if _, ok := doc[resource]; ok {
+			continue
+		}
+		doc[resource] = defaultDoc[resource]
+		added = append(added, resource)
+	}
+
+	for resource, actions := range required {
+		raw, ok := doc[resource]
+		if !ok {
+			continue
+		}
+		var granted []string
+		if err := json.Unmarshal(raw, &granted); err != nil || len(granted) == 0 {
+			// true already grants everything, false is a deliberate denial
+			continue
+		}
+		changed := false
+		for _, action := range actions {
+			if !containsString(granted, action) {
+				granted = append(granted, action)
+				added = append(added, resource+"."+action)
+				changed = true
+			}
+		}
+		if changed {
+			value, err := json.Marshal(granted)
+			if err != nil {
+				return "", nil, err
+			}
+			doc[resource] = value
+		}
+	}
+	if len(added) == 0 {
+		return current, nil, nil
+	}
+	sort.Strings(added)
+
+	merged, err := json.Marshal(doc)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(merged), added, nil
+}
+
+// containsString checks if values holds value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // SeedDefaultRoles creates default roles if they don't exist
 func SeedDefaultRoles() error {
 	log.Println("Seeding default roles...")
@@ -109,25 +276,25 @@ func SeedDefaultRoles() error {
 			Name:        "manager",
 			DisplayName: "Manager/GM",
 			Description: "Can manage projects and approve requests",
-			Permissions: `{"projects": ["read", "write"], "reports": ["read", "write"], "approval": true}`,
+			Permissions: `{"projects": ["read", "read_all", "write", "update_progress"], "reports": ["read", "write"], "daily_reports": ["read", "write"], "materials": ["read", "write", "delete", "adjust_stock"], "bom": ["read", "write", "delete"], "material_usage": ["read", "write", "delete"], "documents": ["read", "write", "delete"], "risks": ["read", "write", "delete"], "subcontracts": ["read", "write"], "recycle_bin": ["read", "restore"], "approval": true}`,
 		},
 		{
 			Name:        "cost_control",
 			DisplayName: "Cost Control",
 			Description: "Can verify and control project costs",
-			Permissions: `{"projects": ["read"], "costs": ["read", "write", "verify"], "purchasing": ["read", "verify"]}`,
+			Permissions: `{"projects": ["read", "read_all"], "costs": ["read", "write", "verify"], "billing": ["read", "write"], "purchasing": ["read", "verify"], "bom": ["read", "write"], "documents": ["read", "write"], "risks": ["read"], "subcontracts": ["read", "write", "pay"]}`,
 		},
 		{
 			Name:        "purchasing",
 			DisplayName: "Purchasing",
 			Description: "Can create and manage purchase requests",
-			Permissions: `{"purchasing": ["read", "write"], "projects": ["read"]}`,
+			Permissions: `{"purchasing": ["read", "write"], "projects": ["read", "read_all"], "materials": ["read", "write", "adjust_stock"], "documents": ["read", "write"], "subcontracts": ["read", "write"]}`,
 		},
 		{
 			Name:        "tim_lapangan",
 			DisplayName: "Tim Lapangan",
 			Description: "Can submit daily reports and update project progress",
-			Permissions: `{"daily_reports": ["read", "write"], "projects": ["read", "update_progress"]}`,
+			Permissions: `{"daily_reports": ["read", "write"], "projects": ["read", "update_progress"], "material_usage": ["read", "write"], "documents": ["read", "write"], "risks": ["read", "write"]}`,
 		},
 	}
 
@@ -140,6 +307,16 @@ func SeedDefaultRoles() error {
 				return fmt.Errorf("failed to create role %s: %w", role.Name, err)
 			}
 			log.Printf("✓ Created role: %s", role.DisplayName)
+		} else if merged, added, err := mergeDefaultPermissions(existing.Permissions, role.Permissions, stageApproverGrants(role.Name)); err != nil {
+			// A malformed document grants nothing; leave it for an admin to fix
+			log.Printf("⚠ Skipped permission upgrade for role %s: %v", role.DisplayName, err)
+		} else if len(added) > 0 {
+			// Grant resources added since the role was created, keeping
+			// everything an admin has set
+			if err := DB.Model(&existing).Update("permissions", merged).Error; err != nil {
+				return fmt.Errorf("failed to upgrade permissions for role %s: %w", role.Name, err)
+			}
+			log.Printf("✓ Added %s permissions to role: %s", strings.Join(added, ", "), role.DisplayName)
 		} else {
 			log.Printf("→ Role already exists: %s", role.DisplayName)
 		}
@@ -263,6 +440,252 @@ func SeedDummyUsers() error {
 	return nil
 }
 
+// SeedPhaseTemplates creates the default phase templates of each project
+// type that has none
+func SeedPhaseTemplates() error {
+	log.Println("Seeding phase templates...")
+
+	byType := map[models.ProjectType][]models.PhaseTemplate{}
+	var types []models.ProjectType
+	for _, template := range models.DefaultPhaseTemplates() {
+		if _, ok := byType[template.ProjectType]; !ok {
+			types = append(types, template.ProjectType)
+		}
+		byType[template.ProjectType] = append(byType[template.ProjectType], template)
+	}
+
+	for _, projectType := range types {
+		var count int64
+		if err := DB.Model(&models.PhaseTemplate{}).Where("project_type = ?", projectType).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check phase templates: %w", err)
+		}
+		if count > 0 {
+			log.Printf("→ Phase templates already exist: %s", projectType)
+			continue
+		}
+
+		templates := byType[projectType]
+		if err := DB.Create(&templates).Error; err != nil {
+			return fmt.Errorf("failed to create phase templates for %s: %w", projectType, err)
+		}
+		log.Printf("✓ Created %d phase templates: %s", len(templates), projectType)
+	}
+
+	log.Println("✓ Phase templates seeded successfully")
+	return nil
+}
+
+// SeedCostCodes creates the default root cost codes when none exist
+func SeedCostCodes() error {
+	var count int64
+	if err := DB.Unscoped().Model(&models.CostCode{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check cost codes: %w", err)
+	}
+	if count > 0 {
+		log.Println("→ Cost codes already exist")
+		return nil
+	}
+
+	codes := append([]models.CostCode(nil), models.DefaultCostCodes...)
+	if err := DB.Create(&codes).Error; err != nil {
+		return fmt.Errorf("failed to create cost codes: %w", err)
+	}
+	log.Printf("✓ Created %d cost codes", len(codes))
+	return nil
+}
+
+// MigrateBudgetLines gives every BOM line its materials budget line and
+// attributes ledger entries recorded before cost codes
+func MigrateBudgetLines() error {
+	var projectIDs []uint
+	if err := DB.Model(&models.Project{}).
+		Where("id IN (?)", DB.Model(&models.BOM{}).Where("id NOT IN (?)", DB.Model(&models.BudgetLine{}).Where("bom_id IS NOT NULL").Select("bom_id")).Select("project_id")).
+		Pluck("id", &projectIDs).Error; err != nil {
+		return fmt.Errorf("failed to find projects without budget lines: %w", err)
+	}
+	for _, projectID := range projectIDs {
+		if err := DB.Transaction(func(tx *gorm.DB) error {
+			return budget.SyncProjectBOM(tx, projectID)
+		}); err != nil {
+			return fmt.Errorf("failed to sync budget of project %d: %w", projectID, err)
+		}
+	}
+	if len(projectIDs) > 0 {
+		log.Printf("✓ Synced budget lines of %d projects from their BOM", len(projectIDs))
+	}
+
+	var entries []models.CostEntry
+	if err := DB.Where("cost_code_id IS NULL").Find(&entries).Error; err != nil {
+		return fmt.Errorf("failed to find unattributed cost entries: %w", err)
+	}
+	attributed := 0
+	for i := range entries {
+		entry := &entries[i]
+		if err := budget.Attribute(DB, entry); err != nil {
+			return fmt.Errorf("failed to attribute cost entry %d: %w", entry.ID, err)
+		}
+		if entry.CostCodeID == nil {
+			continue
+		}
+		if err := DB.Model(entry).Updates(map[string]interface{}{
+			"cost_code_id": entry.CostCodeID,
+			"phase_id":     entry.PhaseID,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to attribute cost entry %d: %w", entry.ID, err)
+		}
+		attributed++
+	}
+	if attributed > 0 {
+		log.Printf("✓ Attributed %d cost entries to cost codes", attributed)
+	}
+	return nil
+}
+
+// MigrateProjectBudgets records the budget of projects created before change
+// orders as their original budget
+func MigrateProjectBudgets() error {
+	result := DB.Model(&models.Project{}).
+		Where("original_budget = 0 AND estimated_cost <> 0").
+		Where("id NOT IN (?)", DB.Model(&models.ChangeOrder{}).Where("status = ?", models.ChangeOrderApproved).Select("project_id")).
+		Update("original_budget", gorm.Expr("estimated_cost"))
+	if result.Error != nil {
+		return fmt.Errorf("failed to migrate project budgets: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("✓ Recorded original budget of %d projects", result.RowsAffected)
+	}
+	return nil
+}
+
+// legacyPhases are the columns of the old progress_breakdowns table
+var legacyPhases = []string{"Foundation", "Utilities", "Interior", "Equipment"}
+
+// MigrateProjectPhases gives every project without phases its own phases.
+// Projects with a progress_breakdowns row get the four legacy phases with
+// their recorded progress, others are seeded from their type's template.
+// Free-text BOM phases are then linked to the matching phase by name.
+func MigrateProjectPhases() error {
+	log.Println("Migrating project phases...")
+
+	hasBreakdowns := DB.Migrator().HasTable("progress_breakdowns")
+
+	var projects []models.Project
+	if err := DB.Where("id NOT IN (?)", DB.Unscoped().Model(&models.ProjectPhase{}).Select("project_id")).
+		Find(&projects).Error; err != nil {
+		return fmt.Errorf("failed to find projects without phases: %w", err)
+	}
+
+	for _, project := range projects {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var phases []models.ProjectPhase
+
+			var breakdown models.ProgressBreakdown
+			if hasBreakdowns && tx.Where("project_id = ?", project.ID).First(&breakdown).Error == nil {
+				values := []float64{breakdown.Foundation, breakdown.Utilities, breakdown.Interior, breakdown.Equipment}
+				recorded := false
+				for _, value := range values {
+					recorded = recorded || value > 0
+				}
+				for i, name := range legacyPhases {
+					progress := values[i]
+					// Only the overall figure was ever recorded, so spread it
+					// evenly to keep the project's progress unchanged
+					if !recorded {
+						progress = project.Progress
+					}
+					phases = append(phases, models.ProjectPhase{
+						ProjectID: project.ID,
+						Name:      name,
+						SortOrder: i + 1,
+						Weight:    25,
+						Progress:  progress,
+					})
+				}
+			} else {
+				var templates []models.PhaseTemplate
+				if err := tx.Where("project_type = ?", project.ProjectType).Order("sort_order ASC, id ASC").Find(&templates).Error; err != nil {
+					return err
+				}
+				for _, template := range templates {
+					phase := template.NewPhase(project.ID, project.StartDate, project.EndDate)
+					phase.Progress = project.Progress
+					phases = append(phases, phase)
+				}
+			}
+
+			if len(phases) == 0 {
+				return nil
+			}
+			if err := tx.Create(&phases).Error; err != nil {
+				return err
+			}
+
+			project.Progress = models.WeightedProgress(phases)
+			if err := tx.Model(&models.Project{}).Where("id = ?", project.ID).Update("progress", project.Progress).Error; err != nil {
+				return err
+			}
+
+			// Starting point of the progress history
+			snapshot := models.NewProgressSnapshot(&project, phases, nil, time.Now())
+			return tx.Create(&snapshot).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate phases of project %d: %w", project.ID, err)
+		}
+		log.Printf("✓ Created phases for project: %s", project.Name)
+	}
+
+	if err := migrateBOMPhases(); err != nil {
+		return err
+	}
+
+	log.Println("✓ Project phases migrated successfully")
+	return nil
+}
+
+// migrateBOMPhases links BOM lines still carrying the old free-text phase
+// column to a project phase of the same name. Unknown names get a phase with
+// no weight so overall progress is unaffected.
+func migrateBOMPhases() error {
+	if !DB.Migrator().HasColumn(&models.BOM{}, "phase") {
+		return nil
+	}
+
+	var rows []struct {
+		ID        uint
+		ProjectID uint
+		Phase     string
+	}
+	if err := DB.Table("boms").
+		Select("id, project_id, phase").
+		Where("phase_id IS NULL AND phase IS NOT NULL AND phase <> '' AND deleted_at IS NULL").
+		Scan(&rows).Error; err != nil {
+		return fmt.Errorf("failed to read BOM phases: %w", err)
+	}
+
+	for _, row := range rows {
+		name := strings.TrimSpace(row.Phase)
+
+		var phase models.ProjectPhase
+		err := DB.Where("project_id = ? AND LOWER(name) = ?", row.ProjectID, strings.ToLower(name)).First(&phase).Error
+		if err == gorm.ErrRecordNotFound {
+			var maxOrder int
+			DB.Model(&models.ProjectPhase{}).Where("project_id = ?", row.ProjectID).
+				Select("COALESCE(MAX(sort_order), 0)").Scan(&maxOrder)
+			phase = models.ProjectPhase{ProjectID: row.ProjectID, Name: name, SortOrder: maxOrder + 1}
+			err = DB.Create(&phase).Error
+		}
+		if err != nil {
+			return fmt.Errorf("failed to resolve phase %q of BOM %d: %w", name, row.ID, err)
+		}
+
+		if err := DB.Model(&models.BOM{}).Where("id = ?", row.ID).Update("phase_id", phase.ID).Error; err != nil {
+			return fmt.Errorf("failed to link BOM %d to phase: %w", row.ID, err)
+		}
+	}
+	return nil
+}
+
 // GetDB returns the database instance
 func GetDB() *gorm.DB {
 	return DB
@@ -0,0 +1,246 @@
+// Package evm computes earned value management metrics for projects from
+// their budget, planned schedule, recorded progress and cost ledger.
+package evm
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/unipro/project-management/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Inputs are the figures EVM metrics are derived from
+type Inputs struct {
+	BAC            float64 // Budget at completion
+	PlannedPercent float64 // Work scheduled by the status date
+	ActualPercent  float64 // Work done by the status date
+	ActualCost     float64 // Cost booked by the status date
+}
+
+// Metrics are the EVM figures of a project at a status date. Ratios are
+// empty when their denominator is zero.
+type Metrics struct {
+	ProjectID      uint      `json:"project_id"`
+	ProjectName    string    `json:"project_name,omitempty"`
+	AsOf           time.Time `json:"as_of"`
+	PlannedPercent float64   `json:"planned_percent"`
+	ActualPercent  float64   `json:"actual_percent"`
+	BAC            float64   `json:"bac"`
+	PV             float64   `json:"pv"`
+	EV             float64   `json:"ev"`
+	AC             float64   `json:"ac"`
+	CV             float64   `json:"cv"`
+	SV             float64   `json:"sv"`
+	CPI            *float64  `json:"cpi"`
+	SPI            *float64  `json:"spi"`
+	EAC            float64   `json:"eac"`
+	ETC            float64   `json:"etc"`
+	VAC            float64   `json:"vac"`
+	TCPI           *float64  `json:"tcpi"`
+}
+
+// Calculate derives the EVM metrics from their inputs. Without a CPI the
+// estimate at completion assumes remaining work is done on budget.
+func Calculate(in Inputs) Metrics {
+	m := Metrics{
+		PlannedPercent: round(in.PlannedPercent),
+		ActualPercent:  round(in.ActualPercent),
+		BAC:            round(in.BAC),
+		PV:             round(in.BAC * in.PlannedPercent / 100),
+		EV:             round(in.BAC * in.ActualPercent / 100),
+		AC:             round(in.ActualCost),
+	}
+	m.CV = round(m.EV - m.AC)
+	m.SV = round(m.EV - m.PV)
+	m.CPI = ratio(m.EV, m.AC)
+	m.SPI = ratio(m.EV, m.PV)
+
+	if m.CPI != nil && *m.CPI > 0 {
+		m.EAC = round(m.BAC / *m.CPI)
+	} else {
+		m.EAC = round(m.AC + m.BAC - m.EV)
+	}
+	m.ETC = round(m.EAC - m.AC)
+	m.VAC = round(m.BAC - m.EAC)
+	m.TCPI = ratio(m.BAC-m.EV, m.BAC-m.AC)
+	return m
+}
+
+// Compute gathers a project's inputs at the end of the asOf day and
+// calculates its metrics
+func Compute(db *gorm.DB, project *models.Project, asOf time.Time) (Metrics, error) {
+	cutoff := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, asOf.Location()).AddDate(0, 0, 1)
+
+	var phases []models.ProjectPhase
+	if err := db.Where("project_id = ?", project.ID).Find(&phases).Error; err != nil {
+		return Metrics{}, err
+	}
+
+	planned := project.PlannedProgress(cutoff)
+	if models.HasPhasePlan(phases) {
+		planned = project.PlannedProgressFromPhases(phases, cutoff)
+	}
+
+	// Progress as recorded by the status date; today's figure is current
+	actual := project.Progress
+	if cutoff.Before(time.Now()) {
+		var snapshot models.ProgressSnapshot
+		err := db.Where("project_id = ? AND created_at < ?", project.ID, cutoff).
+			Order("created_at DESC, id DESC").
+			First(&snapshot).Error
+		switch {
+		case err == nil:
+			actual = snapshot.Progress
+		case err == gorm.ErrRecordNotFound:
+			actual = 0
+		default:
+			return Metrics{}, err
+		}
+	}
+
+	var actualCost float64
+	if err := db.Model(&models.CostEntry{}).
+		Where("project_id = ? AND entry_date < ?", project.ID, cutoff).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&actualCost).Error; err != nil {
+		return Metrics{}, err
+	}
+
+	m := Calculate(Inputs{
+		BAC:            BudgetAtCompletion(project),
+		PlannedPercent: planned,
+		ActualPercent:  actual,
+		ActualCost:     actualCost,
+	})
+	m.ProjectID = project.ID
+	m.ProjectName = project.Name
+	m.AsOf = asOf
+	return m, nil
+}
+
+// BudgetAtCompletion returns the budget EVM measures against
+func BudgetAtCompletion(project *models.Project) float64 {
+	return project.EstimatedCost
+}
+
+// Portfolio sums the metrics of several projects. Ratios and percentages are
+// recomputed from the totals so larger projects weigh more, while the
+// estimate at completion sums each project's own estimate.
+func Portfolio(metrics []Metrics, asOf time.Time) Metrics {
+	var in Inputs
+	var pv, ev, eac float64
+	for _, m := range metrics {
+		in.BAC += m.BAC
+		in.ActualCost += m.AC
+		pv += m.PV
+		ev += m.EV
+		eac += m.EAC
+	}
+	if in.BAC > 0 {
+		in.PlannedPercent = pv / in.BAC * 100
+		in.ActualPercent = ev / in.BAC * 100
+	}
+
+	total := Calculate(in)
+	total.EAC = round(eac)
+	total.ETC = round(total.EAC - total.AC)
+	total.VAC = round(total.BAC - total.EAC)
+	total.AsOf = asOf
+	return total
+}
+
+// NewSnapshot turns metrics into a stored weekly snapshot
+func NewSnapshot(m Metrics) models.EVMSnapshot {
+	return models.EVMSnapshot{
+		ProjectID:      m.ProjectID,
+		WeekStart:      WeekStart(m.AsOf),
+		AsOf:           m.AsOf,
+		PlannedPercent: m.PlannedPercent,
+		ActualPercent:  m.ActualPercent,
+		BAC:            m.BAC,
+		PV:             m.PV,
+		EV:             m.EV,
+		AC:             m.AC,
+		CPI:            m.CPI,
+		SPI:            m.SPI,
+		EAC:            m.EAC,
+		ETC:            m.ETC,
+		VAC:            m.VAC,
+	}
+}
+
+// RecordSnapshots stores the snapshot of every active project for the week
+// of asOf, replacing one already taken that week, and returns how many were
+// stored
+func RecordSnapshots(db *gorm.DB, asOf time.Time) (int, error) {
+	var projects []models.Project
+	if err := db.Where("status <> ?", models.StatusCompleted).Find(&projects).Error; err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	for i := range projects {
+		m, err := Compute(db, &projects[i], asOf)
+		if err != nil {
+			return recorded, fmt.Errorf("failed to compute EVM metrics of project %d: %w", projects[i].ID, err)
+		}
+
+		snapshot := NewSnapshot(m)
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "project_id"}, {Name: "week_start"}},
+			DoUpdates: clause.AssignmentColumns([]string{"as_of", "planned_percent", "actual_percent", "bac", "pv", "ev", "ac", "cpi", "spi", "eac", "etc", "vac", "updated_at"}),
+		}).Create(&snapshot).Error; err != nil {
+			return recorded, fmt.Errorf("failed to store EVM snapshot of project %d: %w", projects[i].ID, err)
+		}
+		recorded++
+	}
+	return recorded, nil
+}
+
+// StartSnapshotter records this week's snapshots now and then every
+// interval. A run replaces the week's earlier snapshot, so the last run of a
+// week is the one kept.
+func StartSnapshotter(db *gorm.DB, interval time.Duration) {
+	run := func() {
+		recorded, err := RecordSnapshots(db, time.Now())
+		if err != nil {
+			log.Printf("⚠ EVM snapshot failed: %v", err)
+			return
+		}
+		log.Printf("✓ Recorded EVM snapshots of %d projects", recorded)
+	}
+
+	go func() {
+		run()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			run()
+		}
+	}()
+}
+
+// WeekStart returns the Monday of t's week
+func WeekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// ratio divides two figures, returning nil when the denominator is zero
+func ratio(numerator, denominator float64) *float64 {
+	if denominator == 0 {
+		return nil
+	}
+	value := math.Round(numerator/denominator*10000) / 10000
+	return &value
+}
+
+// round rounds a currency figure to cents
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
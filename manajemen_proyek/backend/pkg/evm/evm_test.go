@@ -0,0 +1,149 @@
+package evm
+
+import (
+	"testing"
+	"time"
+)
+
+// ptr returns a pointer to a ratio expected by a test
+func ptr(v float64) *float64 { return &v }
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Inputs
+		want Metrics
+	}{
+		{
+			name: "over budget and behind schedule",
+			in:   Inputs{BAC: 1000, PlannedPercent: 50, ActualPercent: 40, ActualCost: 500},
+			want: Metrics{
+				PV: 500, EV: 400, AC: 500, CV: -100, SV: -100,
+				CPI: ptr(0.8), SPI: ptr(0.8), EAC: 1250, ETC: 750, VAC: -250, TCPI: ptr(1.2),
+			},
+		},
+		{
+			name: "under budget and ahead of schedule",
+			in:   Inputs{BAC: 2000, PlannedPercent: 25, ActualPercent: 30, ActualCost: 400},
+			want: Metrics{
+				PV: 500, EV: 600, AC: 400, CV: 200, SV: 100,
+				CPI: ptr(1.5), SPI: ptr(1.2), EAC: 1333.33, ETC: 933.33, VAC: 666.67, TCPI: ptr(0.875),
+			},
+		},
+		{
+			name: "no cost booked leaves CPI empty and assumes the budget rate",
+			in:   Inputs{BAC: 1000, PlannedPercent: 20, ActualPercent: 10},
+			want: Metrics{
+				PV: 200, EV: 100, SV: -100, CV: 100,
+				SPI: ptr(0.5), EAC: 900, ETC: 900, VAC: 100, TCPI: ptr(0.9),
+			},
+		},
+		{
+			name: "nothing planned leaves SPI empty",
+			in:   Inputs{BAC: 1000, ActualPercent: 10, ActualCost: 50},
+			want: Metrics{
+				EV: 100, AC: 50, CV: 50, SV: 100,
+				CPI: ptr(2), EAC: 500, ETC: 450, VAC: 500, TCPI: ptr(0.9474),
+			},
+		},
+		{
+			name: "no work earned gives zero ratios and the budget rate",
+			in:   Inputs{BAC: 1000, PlannedPercent: 10, ActualCost: 50},
+			want: Metrics{
+				PV: 100, AC: 50, CV: -50, SV: -100,
+				CPI: ptr(0), SPI: ptr(0), EAC: 1050, ETC: 1000, VAC: -50, TCPI: ptr(1.0526),
+			},
+		},
+		{
+			name: "budget spent leaves TCPI empty",
+			in:   Inputs{BAC: 1000, PlannedPercent: 50, ActualPercent: 50, ActualCost: 1000},
+			want: Metrics{
+				PV: 500, EV: 500, AC: 1000, CV: -500,
+				CPI: ptr(0.5), SPI: ptr(1), EAC: 2000, ETC: 1000, VAC: -1000,
+			},
+		},
+		{
+			name: "empty project",
+			in:   Inputs{},
+			want: Metrics{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Calculate(tt.in)
+			tt.want.BAC = tt.in.BAC
+			tt.want.PlannedPercent = tt.in.PlannedPercent
+			tt.want.ActualPercent = tt.in.ActualPercent
+
+			figures := []struct {
+				name      string
+				got, want float64
+			}{
+				{"BAC", got.BAC, tt.want.BAC},
+				{"PV", got.PV, tt.want.PV},
+				{"EV", got.EV, tt.want.EV},
+				{"AC", got.AC, tt.want.AC},
+				{"CV", got.CV, tt.want.CV},
+				{"SV", got.SV, tt.want.SV},
+				{"EAC", got.EAC, tt.want.EAC},
+				{"ETC", got.ETC, tt.want.ETC},
+				{"VAC", got.VAC, tt.want.VAC},
+			}
+			for _, f := range figures {
+				if f.got != f.want {
+					t.Errorf("%s = %v, want %v", f.name, f.got, f.want)
+				}
+			}
+
+			ratios := []struct {
+				name      string
+				got, want *float64
+			}{
+				{"CPI", got.CPI, tt.want.CPI},
+				{"SPI", got.SPI, tt.want.SPI},
+				{"TCPI", got.TCPI, tt.want.TCPI},
+			}
+			for _, r := range ratios {
+				switch {
+				case r.want == nil && r.got != nil:
+					t.Errorf("%s = %v, want empty", r.name, *r.got)
+				case r.want != nil && r.got == nil:
+					t.Errorf("%s is empty, want %v", r.name, *r.want)
+				case r.want != nil && *r.got != *r.want:
+					t.Errorf("%s = %v, want %v", r.name, *r.got, *r.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPortfolioWeighsByBudget(t *testing.T) {
+	asOf := time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)
+	small := Calculate(Inputs{BAC: 100, PlannedPercent: 100, ActualPercent: 100, ActualCost: 100})
+	large := Calculate(Inputs{BAC: 900, PlannedPercent: 50, ActualPercent: 40, ActualCost: 450})
+
+	total := Portfolio([]Metrics{small, large}, asOf)
+	if total.BAC != 1000 || total.PV != 550 || total.EV != 460 || total.AC != 550 {
+		t.Errorf("totals = BAC %v PV %v EV %v AC %v, want 1000 550 460 550", total.BAC, total.PV, total.EV, total.AC)
+	}
+	if total.SPI == nil || *total.SPI != 0.8364 {
+		t.Errorf("SPI = %v, want 0.8364", total.SPI)
+	}
+	if want := small.EAC + large.EAC; total.EAC != want {
+		t.Errorf("EAC = %v, want the sum %v", total.EAC, want)
+	}
+	if !total.AsOf.Equal(asOf) {
+		t.Errorf("AsOf = %v, want %v", total.AsOf, asOf)
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	for day := 0; day < 7; day++ {
+		at := monday.AddDate(0, 0, day).Add(15 * time.Hour)
+		if got := WeekStart(at); !got.Equal(monday) {
+			t.Errorf("WeekStart(%s) = %s, want %s", at.Weekday(), got, monday)
+		}
+	}
+}
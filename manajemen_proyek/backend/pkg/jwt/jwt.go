@@ -6,16 +6,18 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/unipro/project-management/internal/models"
+	"github.com/unipro/project-management/pkg/token"
 )
 
 var jwtSecret []byte
 
 // Claims represents JWT claims structure
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	RoleID uint   `json:"role_id"`
-	Role   string `json:"role"`
+	UserID    uint   `json:"user_id"`
+	Email     string `json:"email"`
+	RoleID    uint   `json:"role_id"`
+	Role      string `json:"role"`
+	SessionID uint   `json:"sid"`
 	jwt.RegisteredClaims
 }
 
@@ -24,15 +26,23 @@ func Initialize(secret string) {
 	jwtSecret = []byte(secret)
 }
 
-// GenerateToken creates a new JWT token for a user
-func GenerateToken(user *models.User, expiry time.Duration) (string, error) {
+// GenerateToken creates a new JWT access token for a user session. The
+// returned claims carry the generated jti (claims.ID) for revocation.
+func GenerateToken(user *models.User, sessionID uint, expiry time.Duration) (string, *Claims, error) {
+	jti, err := token.NewID()
+	if err != nil {
+		return "", nil, err
+	}
+
 	now := time.Now()
 	claims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
-		RoleID: user.RoleID,
-		Role:   user.Role.Name,
+		UserID:    user.ID,
+		Email:     user.Email,
+		RoleID:    user.RoleID,
+		Role:      user.Role.Name,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
@@ -40,8 +50,11 @@ func GenerateToken(user *models.User, expiry time.Duration) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
 // ValidateToken validates and parses a JWT token
@@ -64,20 +77,3 @@ func ValidateToken(tokenString string) (*Claims, error) {
 
 	return nil, errors.New("invalid token")
 }
-
-// RefreshToken generates a new token with extended expiry
-func RefreshToken(tokenString string, expiry time.Duration) (string, error) {
-	claims, err := ValidateToken(tokenString)
-	if err != nil {
-		return "", err
-	}
-
-	// Create new token with fresh expiry
-	now := time.Now()
-	claims.ExpiresAt = jwt.NewNumericDate(now.Add(expiry))
-	claims.IssuedAt = jwt.NewNumericDate(now)
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
-}
-
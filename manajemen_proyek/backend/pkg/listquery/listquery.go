@@ -0,0 +1,533 @@
+// Package listquery applies pagination, filtering and sorting taken from
+// query parameters to list queries. Each endpoint whitelists what may be
+// filtered and sorted with a Spec, so every list accepts the same parameters
+// and answers with the same envelope:
+//
+//	?status=pending,approved          IN filter, also status=pending&status=approved
+//	?date_from=2025-01-01&date_to=... range filter, dates inclusive
+//	?search=semen                     case-insensitive match on the search columns
+//	?sort=-date,name                  sort keys, "-" for descending
+//	?limit=50&page=2 or &offset=50    offset pagination
+//	?limit=50&cursor=...              keyset pagination from next_cursor
+package listquery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// DefaultLimit is the page size when neither the request nor the spec sets one
+	DefaultLimit = 50
+	// MaxLimit caps the page size when the spec does not
+	MaxLimit = 200
+)
+
+const dateLayout = "2006-01-02"
+
+// Op is how a filter matches its values
+type Op int
+
+const (
+	// In matches any of the given values
+	In Op = iota
+	// Range matches between <name>_from and <name>_to, both inclusive
+	Range
+)
+
+// Type is how filter values are parsed
+type Type int
+
+const (
+	String Type = iota
+	Number
+	Date
+	Bool
+)
+
+// Filter is a whitelisted filter parameter
+type Filter struct {
+	Column string
+	Op     Op
+	Type   Type
+}
+
+// Spec whitelists the filters, search columns and sort keys of a list.
+// Unqualified columns belong to the listed model's table.
+type Spec struct {
+	Filters      map[string]Filter
+	Search       []string
+	Sorts        map[string]string // Sort key to column
+	DefaultSort  string            // e.g. "-created_at"
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Page describes the returned page of a list
+type Page struct {
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	Page       int    `json:"page"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Error is a problem with the query parameters, reported to the client as a
+// bad request
+type Error struct {
+	msg string
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+func errorf(format string, args ...interface{}) error {
+	return &Error{msg: fmt.Sprintf(format, args...)}
+}
+
+type sortKey struct {
+	column string
+	field  *schema.Field // Nil when the column is not on the model, which rules out cursors
+	desc   bool
+}
+
+// cursorValue is a typed sort value, kept as a string so numbers and times
+// survive the JSON round trip exactly
+type cursorValue struct {
+	T string `json:"t"` // n(ull), t(ime), i(nt), u(int), f(loat), s(tring) or b(ool)
+	V string `json:"v,omitempty"`
+}
+
+type cursor struct {
+	Sort   string        `json:"s"`
+	Values []cursorValue `json:"v"`
+}
+
+// Find filters, sorts and pages query by params and loads the page into
+// dest, a pointer to a slice of models. Preloads on query only apply to the
+// page, not to the count.
+func Find(query *gorm.DB, params url.Values, spec Spec, dest interface{}) (Page, error) {
+	var page Page
+
+	stmt := &gorm.Statement{DB: query}
+	if err := stmt.Parse(dest); err != nil {
+		return page, err
+	}
+	table := stmt.Schema.Table
+	qualify := func(column string) string {
+		if strings.ContainsAny(column, ".(") {
+			return column
+		}
+		return table + "." + column
+	}
+
+	query = query.Model(dest)
+
+	var err error
+	if query, err = applyFilters(query, params, spec, qualify); err != nil {
+		return page, err
+	}
+
+	keys, sortSpec, err := parseSort(params, spec, stmt.Schema, qualify)
+	if err != nil {
+		return page, err
+	}
+
+	if page.Limit, err = parseLimit(params, spec); err != nil {
+		return page, err
+	}
+
+	// Count on a copy without preloads, which do not apply to a count
+	ctx := query.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	count := query.Session(&gorm.Session{Context: ctx})
+	count.Statement.Preloads = map[string][]interface{}{}
+	if err := count.Count(&page.Total).Error; err != nil {
+		return page, err
+	}
+
+	if raw := params.Get("cursor"); raw != "" {
+		values, err := decodeCursor(raw, sortSpec, keys)
+		if err != nil {
+			return page, err
+		}
+		condition, args := keysetCondition(keys, values)
+		query = query.Where(condition, args...)
+	} else {
+		if page.Offset, err = parseOffset(params, page.Limit); err != nil {
+			return page, err
+		}
+		page.Page = page.Offset/page.Limit + 1
+	}
+
+	for _, key := range keys {
+		if key.desc {
+			query = query.Order(key.column + " DESC")
+		} else {
+			query = query.Order(key.column + " ASC")
+		}
+	}
+
+	// One extra row tells whether there is a next page
+	if err := query.Offset(page.Offset).Limit(page.Limit + 1).Find(dest).Error; err != nil {
+		return page, err
+	}
+
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() > page.Limit {
+		page.HasMore = true
+		rows.Set(rows.Slice(0, page.Limit))
+		if next, ok := encodeCursor(query.Statement.Context, sortSpec, keys, rows.Index(page.Limit-1)); ok {
+			page.NextCursor = next
+		}
+	}
+
+	return page, nil
+}
+
+func applyFilters(query *gorm.DB, params url.Values, spec Spec, qualify func(string) string) (*gorm.DB, error) {
+	names := make([]string, 0, len(spec.Filters))
+	for name := range spec.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		filter := spec.Filters[name]
+		column := qualify(filter.Column)
+
+		switch filter.Op {
+		case In:
+			var values []interface{}
+			for _, param := range params[name] {
+				for _, raw := range strings.Split(param, ",") {
+					if raw = strings.TrimSpace(raw); raw == "" {
+						continue
+					}
+					value, err := parseValue(raw, filter.Type, name)
+					if err != nil {
+						return nil, err
+					}
+					values = append(values, value)
+				}
+			}
+			if len(values) > 0 {
+				query = query.Where(column+" IN ?", values)
+			}
+
+		case Range:
+			if raw := strings.TrimSpace(params.Get(name + "_from")); raw != "" {
+				value, err := parseValue(raw, filter.Type, name+"_from")
+				if err != nil {
+					return nil, err
+				}
+				query = query.Where(column+" >= ?", value)
+			}
+			if raw := strings.TrimSpace(params.Get(name + "_to")); raw != "" {
+				value, err := parseValue(raw, filter.Type, name+"_to")
+				if err != nil {
+					return nil, err
+				}
+				if filter.Type == Date {
+					// Include the whole last day
+					query = query.Where(column+" < ?", value.(time.Time).AddDate(0, 0, 1))
+				} else {
+					query = query.Where(column+" <= ?", value)
+				}
+			}
+		}
+	}
+
+	if search := strings.TrimSpace(params.Get("search")); search != "" && len(spec.Search) > 0 {
+		conditions := make([]string, len(spec.Search))
+		args := make([]interface{}, len(spec.Search))
+		for i, column := range spec.Search {
+			conditions[i] = qualify(column) + " ILIKE ?"
+			args[i] = "%" + search + "%"
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+
+	return query, nil
+}
+
+func parseValue(raw string, t Type, name string) (interface{}, error) {
+	switch t {
+	case Number:
+		if value, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return value, nil
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, errorf("%s must be a number", name)
+		}
+		return value, nil
+	case Date:
+		value, err := time.Parse(dateLayout, raw)
+		if err != nil {
+			return nil, errorf("%s must be a date in YYYY-MM-DD format", name)
+		}
+		return value, nil
+	case Bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errorf("%s must be true or false", name)
+		}
+		return value, nil
+	}
+	return raw, nil
+}
+
+// parseSort resolves the sort parameter to columns, always ending with the
+// primary key so the order is total and cursors are stable
+func parseSort(params url.Values, spec Spec, sch *schema.Schema, qualify func(string) string) ([]sortKey, string, error) {
+	raw := strings.TrimSpace(params.Get("sort"))
+	if raw == "" {
+		raw = spec.DefaultSort
+	}
+
+	lookup := func(column string) *schema.Field {
+		name := column
+		if i := strings.LastIndex(column, "."); i >= 0 {
+			if column[:i] != sch.Table {
+				return nil
+			}
+			name = column[i+1:]
+		}
+		return sch.LookUpField(name)
+	}
+
+	var keys []sortKey
+	var names []string
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		desc := strings.HasPrefix(part, "-")
+		name := strings.TrimPrefix(part, "-")
+
+		column, ok := spec.Sorts[name]
+		if !ok {
+			allowed := make([]string, 0, len(spec.Sorts))
+			for key := range spec.Sorts {
+				allowed = append(allowed, key)
+			}
+			sort.Strings(allowed)
+			return nil, "", errorf("invalid sort key %q, use one of %s", name, strings.Join(allowed, ", "))
+		}
+		column = qualify(column)
+		keys = append(keys, sortKey{column: column, field: lookup(column), desc: desc})
+		names = append(names, part)
+	}
+
+	if sch.PrioritizedPrimaryField != nil {
+		primary := qualify(sch.PrioritizedPrimaryField.DBName)
+		if len(keys) == 0 || keys[len(keys)-1].column != primary {
+			keys = append(keys, sortKey{column: primary, field: sch.PrioritizedPrimaryField})
+		}
+	}
+
+	return keys, strings.Join(names, ","), nil
+}
+
+func parseLimit(params url.Values, spec Spec) (int, error) {
+	limit := spec.DefaultLimit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	max := spec.MaxLimit
+	if max == 0 {
+		max = MaxLimit
+	}
+
+	if raw := params.Get("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			return 0, errorf("limit must be a positive number")
+		}
+		limit = value
+	}
+	if limit > max {
+		limit = max
+	}
+	return limit, nil
+}
+
+func parseOffset(params url.Values, limit int) (int, error) {
+	if raw := params.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return 0, errorf("offset must not be negative")
+		}
+		return offset, nil
+	}
+	if raw := params.Get("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return 0, errorf("page must be a positive number")
+		}
+		return (page - 1) * limit, nil
+	}
+	return 0, nil
+}
+
+// keysetCondition matches the rows after the cursor in the sort order. Nulls
+// sort last ascending and first descending, as in PostgreSQL.
+func keysetCondition(keys []sortKey, values []interface{}) (string, []interface{}) {
+	var alternatives []string
+	var args []interface{}
+
+	for i, key := range keys {
+		var parts []string
+		var partArgs []interface{}
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				parts = append(parts, keys[j].column+" IS NULL")
+			} else {
+				parts = append(parts, keys[j].column+" = ?")
+				partArgs = append(partArgs, values[j])
+			}
+		}
+
+		switch {
+		case !key.desc && values[i] != nil:
+			parts = append(parts, "("+key.column+" > ? OR "+key.column+" IS NULL)")
+			partArgs = append(partArgs, values[i])
+		case !key.desc:
+			continue // Nothing sorts after null
+		case values[i] != nil:
+			parts = append(parts, key.column+" < ?")
+			partArgs = append(partArgs, values[i])
+		default:
+			parts = append(parts, key.column+" IS NOT NULL")
+		}
+
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+		args = append(args, partArgs...)
+	}
+
+	if len(alternatives) == 0 {
+		return "1 = 0", nil
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+func encodeCursor(ctx context.Context, sortSpec string, keys []sortKey, row reflect.Value) (string, bool) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for row.Kind() == reflect.Ptr {
+		row = row.Elem()
+	}
+
+	c := cursor{Sort: sortSpec}
+	for _, key := range keys {
+		if key.field == nil {
+			return "", false
+		}
+		value, _ := key.field.ValueOf(ctx, row)
+		encoded, ok := encodeValue(value)
+		if !ok {
+			return "", false
+		}
+		c.Values = append(c.Values, encoded)
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", false
+	}
+	return base64.RawURLEncoding.EncodeToString(data), true
+}
+
+func encodeValue(value interface{}) (cursorValue, bool) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return cursorValue{T: "n"}, true
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return cursorValue{T: "n"}, true
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		return cursorValue{T: "t", V: t.Format(time.RFC3339Nano)}, true
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cursorValue{T: "i", V: strconv.FormatInt(v.Int(), 10)}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cursorValue{T: "u", V: strconv.FormatUint(v.Uint(), 10)}, true
+	case reflect.Float32, reflect.Float64:
+		return cursorValue{T: "f", V: strconv.FormatFloat(v.Float(), 'g', -1, 64)}, true
+	case reflect.String:
+		return cursorValue{T: "s", V: v.String()}, true
+	case reflect.Bool:
+		return cursorValue{T: "b", V: strconv.FormatBool(v.Bool())}, true
+	}
+	return cursorValue{}, false
+}
+
+func decodeCursor(raw, sortSpec string, keys []sortKey) ([]interface{}, error) {
+	invalid := errorf("invalid cursor")
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, invalid
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, invalid
+	}
+	if c.Sort != sortSpec {
+		return nil, errorf("cursor does not match the sort order")
+	}
+	if len(c.Values) != len(keys) {
+		return nil, invalid
+	}
+
+	values := make([]interface{}, len(c.Values))
+	for i, value := range c.Values {
+		var err error
+		switch value.T {
+		case "n":
+			values[i] = nil
+		case "t":
+			values[i], err = time.Parse(time.RFC3339Nano, value.V)
+		case "i":
+			values[i], err = strconv.ParseInt(value.V, 10, 64)
+		case "u":
+			values[i], err = strconv.ParseUint(value.V, 10, 64)
+		case "f":
+			values[i], err = strconv.ParseFloat(value.V, 64)
+		case "s":
+			values[i] = value.V
+		case "b":
+			values[i], err = strconv.ParseBool(value.V)
+		default:
+			return nil, invalid
+		}
+		if err != nil {
+			return nil, invalid
+		}
+	}
+	return values, nil
+}
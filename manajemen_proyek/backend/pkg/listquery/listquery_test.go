@@ -0,0 +1,241 @@
+package listquery
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// widget is the model listed in the tests
+type widget struct {
+	ID        uint
+	Name      string
+	Price     float64
+	Active    bool
+	DueDate   *time.Time
+	CreatedAt time.Time
+}
+
+var widgetSpec = Spec{
+	Filters: map[string]Filter{
+		"active":   {Column: "active", Type: Bool},
+		"price":    {Column: "price", Op: Range, Type: Number},
+		"due_date": {Column: "due_date", Op: Range, Type: Date},
+	},
+	Search: []string{"name"},
+	Sorts: map[string]string{
+		"name":       "name",
+		"price":      "price",
+		"due_date":   "due_date",
+		"created_at": "created_at",
+		"owner":      "users.name",
+	},
+	DefaultSort: "-created_at",
+}
+
+// dryRunDB builds queries without a database, so Find can be run up to the
+// point where rows would be read
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func widgetSchema(t *testing.T) *schema.Schema {
+	t.Helper()
+	sch, err := schema.Parse(&widget{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sch
+}
+
+func TestFindRejectsInvalidParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params url.Values
+	}{
+		{"unknown sort key", url.Values{"sort": {"colour"}}},
+		{"unknown descending sort key", url.Values{"sort": {"name,-colour"}}},
+		{"sort by column instead of key", url.Values{"sort": {"users.name"}}},
+		{"non boolean filter", url.Values{"active": {"maybe"}}},
+		{"non numeric range", url.Values{"price_from": {"cheap"}}},
+		{"malformed date", url.Values{"due_date_to": {"31/12/2025"}}},
+		{"zero limit", url.Values{"limit": {"0"}}},
+		{"non numeric limit", url.Values{"limit": {"all"}}},
+		{"negative offset", url.Values{"offset": {"-1"}}},
+		{"zero page", url.Values{"page": {"0"}}},
+		{"garbage cursor", url.Values{"cursor": {"not a cursor"}}},
+	}
+
+	db := dryRunDB(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows []widget
+			_, err := Find(db, tt.params, widgetSpec, &rows)
+			var listErr *Error
+			if !errors.As(err, &listErr) {
+				t.Fatalf("err = %v, want a *listquery.Error", err)
+			}
+		})
+	}
+}
+
+func TestFindIgnoresParamsOutsideTheSpec(t *testing.T) {
+	var rows []widget
+	params := url.Values{"colour": {"red"}, "name": {"x"}, "sort": {"-price,name"}}
+	if _, err := Find(dryRunDB(t), params, widgetSpec, &rows); err != nil {
+		t.Fatalf("err = %v, want params that are not filters ignored", err)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit string
+		spec  Spec
+		want  int
+	}{
+		{"default", "", Spec{}, DefaultLimit},
+		{"spec default", "", Spec{DefaultLimit: 20}, 20},
+		{"requested", "75", Spec{}, 75},
+		{"at the maximum", "200", Spec{}, MaxLimit},
+		{"clamped to MaxLimit", "5000", Spec{}, MaxLimit},
+		{"clamped to the spec maximum", "500", Spec{MaxLimit: 100}, 100},
+		{"spec default above the spec maximum", "", Spec{DefaultLimit: 300, MaxLimit: 100}, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := url.Values{}
+			if tt.limit != "" {
+				params.Set("limit", tt.limit)
+			}
+			got, err := parseLimit(params, tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("limit = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindClampsLimit(t *testing.T) {
+	var rows []widget
+	page, err := Find(dryRunDB(t), url.Values{"limit": {"10000"}}, widgetSpec, &rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Limit != MaxLimit {
+		t.Errorf("Limit = %d, want %d", page.Limit, MaxLimit)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	sch := widgetSchema(t)
+	qualify := func(column string) string { return "widgets." + column }
+	due := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	created := time.Date(2026, 4, 2, 8, 30, 15, 123456789, time.FixedZone("WIB", 7*60*60))
+
+	tests := []struct {
+		name string
+		sort string
+		row  widget
+		want []interface{}
+	}{
+		{
+			name: "time and primary key",
+			sort: "-created_at",
+			row:  widget{ID: 42, CreatedAt: created},
+			want: []interface{}{created, uint64(42)},
+		},
+		{
+			name: "float and string",
+			sort: "price,-name",
+			row:  widget{ID: 7, Name: "Semen, 50kg", Price: 0.1 + 0.2},
+			want: []interface{}{0.1 + 0.2, "Semen, 50kg", uint64(7)},
+		},
+		{
+			name: "set nullable",
+			sort: "due_date",
+			row:  widget{ID: 3, DueDate: &due},
+			want: []interface{}{due, uint64(3)},
+		},
+		{
+			name: "null",
+			sort: "due_date",
+			row:  widget{ID: 4},
+			want: []interface{}{nil, uint64(4)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, sortSpec, err := parseSort(url.Values{"sort": {tt.sort}}, widgetSpec, sch, qualify)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			raw, ok := encodeCursor(nil, sortSpec, keys, reflect.ValueOf(&tt.row))
+			if !ok {
+				t.Fatal("cursor was not encoded")
+			}
+			got, err := decodeCursor(raw, sortSpec, keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("decoded %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if wantTime, ok := tt.want[i].(time.Time); ok {
+					if gotTime, ok := got[i].(time.Time); !ok || !gotTime.Equal(wantTime) {
+						t.Errorf("value %d = %v, want %v", i, got[i], wantTime)
+					}
+					continue
+				}
+				if got[i] != tt.want[i] {
+					t.Errorf("value %d = %#v, want %#v", i, got[i], tt.want[i])
+				}
+			}
+
+			// The cursor is accepted by Find with the same sort only
+			var rows []widget
+			if _, err := Find(dryRunDB(t), url.Values{"sort": {tt.sort}, "cursor": {raw}}, widgetSpec, &rows); err != nil {
+				t.Errorf("Find with the cursor: %v", err)
+			}
+			_, err = Find(dryRunDB(t), url.Values{"sort": {"-price"}, "cursor": {raw}}, widgetSpec, &rows)
+			var listErr *Error
+			if !errors.As(err, &listErr) {
+				t.Errorf("Find with another sort: err = %v, want a *listquery.Error", err)
+			}
+		})
+	}
+}
+
+func TestNoCursorForColumnsOffTheModel(t *testing.T) {
+	keys, sortSpec, err := parseSort(url.Values{"sort": {"owner"}}, widgetSpec, widgetSchema(t), func(column string) string {
+		return column
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := encodeCursor(nil, sortSpec, keys, reflect.ValueOf(&widget{ID: 1})); ok {
+		t.Error("encoded a cursor for a joined column")
+	}
+}
@@ -0,0 +1,89 @@
+package loginguard
+
+import (
+	"errors"
+	"time"
+
+	"github.com/unipro/project-management/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DBStore keeps failure state in the login_throttles table so every
+// instance sees the same counts
+type DBStore struct {
+	DB *gorm.DB
+}
+
+// NewDBStore creates a database-backed store
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{DB: db}
+}
+
+// Get returns the state of a key
+func (s *DBStore) Get(key string) (State, error) {
+	var row models.LoginThrottle
+	if err := s.DB.Where("key = ?", key).First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return State{}, nil
+		}
+		return State{}, err
+	}
+	return stateFromRow(row), nil
+}
+
+// RecordFailure adds a failure to a key, locking the row so concurrent
+// failures from several instances are all counted
+func (s *DBStore) RecordFailure(key string, now time.Time, window time.Duration) (State, error) {
+	var row models.LoginThrottle
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Key: key}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+
+		if now.Sub(row.LastFailure) > window {
+			row.Failures = 0
+		}
+		row.Failures++
+		row.LastFailure = now
+
+		// Rows nobody has failed on for a day are no longer needed
+		if err := tx.Where("key <> ? AND last_failure < ? AND locked_until < ?", key, now.Add(-24*time.Hour), now).
+			Delete(&models.LoginThrottle{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.LoginThrottle{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"failures":     row.Failures,
+			"last_failure": row.LastFailure,
+		}).Error
+	})
+	if err != nil {
+		return State{}, err
+	}
+	return stateFromRow(row), nil
+}
+
+// Lock locks a key until the given time
+func (s *DBStore) Lock(key string, until time.Time) error {
+	return s.DB.Model(&models.LoginThrottle{}).Where("key = ?", key).Update("locked_until", until).Error
+}
+
+// Reset forgets a key
+func (s *DBStore) Reset(key string) error {
+	return s.DB.Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
+
+func stateFromRow(row models.LoginThrottle) State {
+	return State{
+		Failures:    row.Failures,
+		LastFailure: row.LastFailure,
+		LockedUntil: row.LockedUntil,
+	}
+}
@@ -0,0 +1,171 @@
+// Package loginguard throttles password guessing. Failed logins are counted
+// per account and per client IP; each failure past a free allowance adds a
+// growing delay before the next attempt is accepted, and enough failures
+// lock the key out for a while.
+package loginguard
+
+import (
+	"strings"
+	"time"
+
+	"github.com/unipro/project-management/config"
+	"gorm.io/gorm"
+)
+
+// State is the failure record kept for one key
+type State struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store keeps failure state. MemoryStore suits a single instance, DBStore is
+// shared between instances.
+type Store interface {
+	Get(key string) (State, error)
+	// RecordFailure adds a failure, first resetting the count when the last
+	// failure is older than window, and returns the new state
+	RecordFailure(key string, now time.Time, window time.Duration) (State, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// Policy holds the thresholds applied by a Guard
+type Policy struct {
+	MaxAccountFailures int           // Failures before an account is locked
+	MaxIPFailures      int           // Failures before an IP is locked
+	FreeFailures       int           // Failures allowed before delays start
+	BaseDelay          time.Duration // Delay after the first counted failure, doubled for each one after
+	MaxDelay           time.Duration
+	LockoutDuration    time.Duration
+	FailureWindow      time.Duration // Failures older than this are forgotten
+}
+
+// Decision is the outcome of checking whether a login may be attempted
+type Decision struct {
+	Allowed    bool
+	Locked     bool // True when blocked by a lockout rather than a delay
+	RetryAfter time.Duration
+}
+
+// Guard applies a Policy on top of a Store
+type Guard struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+// New creates a guard
+func New(store Store, policy Policy) *Guard {
+	return &Guard{store: store, policy: policy, now: time.Now}
+}
+
+// NewFromConfig creates a guard with the store selected by LOGIN_GUARD_STORE
+func NewFromConfig(cfg config.LoginGuardConfig, db *gorm.DB) *Guard {
+	var store Store = NewMemoryStore()
+	if cfg.Store == "database" {
+		store = NewDBStore(db)
+	}
+
+	return New(store, Policy{
+		MaxAccountFailures: cfg.MaxAccountFailures,
+		MaxIPFailures:      cfg.MaxIPFailures,
+		FreeFailures:       cfg.FreeFailures,
+		BaseDelay:          cfg.BaseDelay,
+		MaxDelay:           cfg.MaxDelay,
+		LockoutDuration:    cfg.LockoutDuration,
+		FailureWindow:      cfg.FailureWindow,
+	})
+}
+
+// AccountKey returns the store key for an account identifier
+func AccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPKey returns the store key for a client IP
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check reports whether a login for the account from the IP may proceed
+func (g *Guard) Check(email, ip string) (Decision, error) {
+	now := g.now()
+	decision := Decision{Allowed: true}
+
+	for _, key := range []string{AccountKey(email), IPKey(ip)} {
+		state, err := g.store.Get(key)
+		if err != nil {
+			return Decision{}, err
+		}
+
+		if state.LockedUntil.After(now) {
+			return Decision{Locked: true, RetryAfter: state.LockedUntil.Sub(now)}, nil
+		}
+
+		if now.Sub(state.LastFailure) > g.policy.FailureWindow {
+			continue
+		}
+		if wait := state.LastFailure.Add(g.delay(state.Failures)).Sub(now); wait > decision.RetryAfter {
+			decision = Decision{RetryAfter: wait}
+		}
+	}
+
+	return decision, nil
+}
+
+// Fail records a failed login and locks the account or IP once its
+// threshold is reached
+func (g *Guard) Fail(email, ip string) error {
+	now := g.now()
+
+	limits := map[string]int{
+		AccountKey(email): g.policy.MaxAccountFailures,
+		IPKey(ip):         g.policy.MaxIPFailures,
+	}
+	for key, max := range limits {
+		state, err := g.store.RecordFailure(key, now, g.policy.FailureWindow)
+		if err != nil {
+			return err
+		}
+		if max > 0 && state.Failures >= max {
+			if err := g.store.Lock(key, now.Add(g.policy.LockoutDuration)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Succeed clears the account's failures after a correct password. The IP
+// count is left alone so one valid account cannot launder guesses at others.
+func (g *Guard) Succeed(email string) error {
+	return g.store.Reset(AccountKey(email))
+}
+
+// Unlock clears the failures and lockout of an account
+func (g *Guard) Unlock(email string) error {
+	return g.store.Reset(AccountKey(email))
+}
+
+// Status returns the failure state of an account
+func (g *Guard) Status(email string) (State, error) {
+	return g.store.Get(AccountKey(email))
+}
+
+// delay returns the wait required after the given number of failures
+func (g *Guard) delay(failures int) time.Duration {
+	counted := failures - g.policy.FreeFailures
+	if counted <= 0 || g.policy.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := g.policy.BaseDelay
+	for i := 1; i < counted; i++ {
+		delay *= 2
+		if delay >= g.policy.MaxDelay {
+			return g.policy.MaxDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,244 @@
+package loginguard
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/unipro/project-management/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testPolicy = Policy{
+	MaxAccountFailures: 5,
+	MaxIPFailures:      20,
+	FreeFailures:       2,
+	BaseDelay:          time.Second,
+	MaxDelay:           8 * time.Second,
+	LockoutDuration:    15 * time.Minute,
+	FailureWindow:      time.Hour,
+}
+
+// clock is a settable time source for a guard
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestGuard returns a guard on the store with a fake clock
+func newTestGuard(store Store) (*Guard, *clock) {
+	clk := &clock{t: time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)}
+	guard := New(store, testPolicy)
+	guard.now = clk.now
+	return guard, clk
+}
+
+// stores returns the stores to run the guard tests against. The database
+// store is only tested when TEST_DATABASE_DSN points at a PostgreSQL
+// database.
+func stores(t *testing.T) map[string]func() Store {
+	result := map[string]func() Store{
+		"memory": func() Store { return NewMemoryStore() },
+	}
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		return result
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.LoginThrottle{}); err != nil {
+		t.Fatalf("migrate login_throttles: %v", err)
+	}
+	result["database"] = func() Store {
+		db.Exec("DELETE FROM login_throttles")
+		return NewDBStore(db)
+	}
+	return result
+}
+
+func TestDelayGrowth(t *testing.T) {
+	guard, _ := newTestGuard(NewMemoryStore())
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, 8 * time.Second},
+		{7, 8 * time.Second},
+		{30, 8 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := guard.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLockoutThreshold(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			guard, clk := newTestGuard(newStore())
+
+			for i := 1; i < testPolicy.MaxAccountFailures; i++ {
+				if err := guard.Fail("user@example.com", "10.0.0.1"); err != nil {
+					t.Fatal(err)
+				}
+				clk.advance(testPolicy.MaxDelay)
+
+				decision, err := guard.Check("user@example.com", "10.0.0.1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !decision.Allowed || decision.Locked {
+					t.Fatalf("after %d failures: %+v, want allowed once the delay passed", i, decision)
+				}
+			}
+
+			if err := guard.Fail("user@example.com", "10.0.0.1"); err != nil {
+				t.Fatal(err)
+			}
+			decision, err := guard.Check("USER@example.com ", "10.0.0.2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.Allowed || !decision.Locked {
+				t.Fatalf("after %d failures: %+v, want locked", testPolicy.MaxAccountFailures, decision)
+			}
+			if decision.RetryAfter != testPolicy.LockoutDuration {
+				t.Errorf("RetryAfter = %v, want %v", decision.RetryAfter, testPolicy.LockoutDuration)
+			}
+
+			// Other accounts from the same IP are not locked
+			decision, err = guard.Check("other@example.com", "10.0.0.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.Locked {
+				t.Error("another account was locked out")
+			}
+		})
+	}
+}
+
+func TestDelayBeforeNextAttempt(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			guard, clk := newTestGuard(newStore())
+
+			for i := 0; i < testPolicy.FreeFailures+2; i++ {
+				if err := guard.Fail("user@example.com", "10.0.0.1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Four failures with two free: 2s to wait
+			decision, err := guard.Check("user@example.com", "10.0.0.9")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.Allowed || decision.Locked || decision.RetryAfter != 2*time.Second {
+				t.Fatalf("got %+v, want a 2s delay", decision)
+			}
+
+			clk.advance(2 * time.Second)
+			decision, err = guard.Check("user@example.com", "10.0.0.9")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !decision.Allowed {
+				t.Fatalf("got %+v, want allowed once the delay passed", decision)
+			}
+		})
+	}
+}
+
+func TestLockoutExpiry(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			guard, clk := newTestGuard(newStore())
+
+			for i := 0; i < testPolicy.MaxAccountFailures; i++ {
+				if err := guard.Fail("user@example.com", "10.0.0.1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			clk.advance(testPolicy.LockoutDuration - time.Second)
+			decision, err := guard.Check("user@example.com", "10.0.0.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !decision.Locked {
+				t.Fatalf("got %+v, want still locked", decision)
+			}
+
+			clk.advance(time.Second + testPolicy.MaxDelay)
+			decision, err = guard.Check("user@example.com", "10.0.0.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !decision.Allowed {
+				t.Fatalf("got %+v, want allowed after the lockout", decision)
+			}
+
+			// Failures past the window are forgotten
+			clk.advance(testPolicy.FailureWindow + time.Second)
+			if err := guard.Fail("user@example.com", "10.0.0.1"); err != nil {
+				t.Fatal(err)
+			}
+			state, err := guard.Status("user@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state.Failures != 1 {
+				t.Errorf("Failures = %d after the window, want 1", state.Failures)
+			}
+		})
+	}
+}
+
+func TestSucceedResetsOnlyAccount(t *testing.T) {
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			guard, _ := newTestGuard(store)
+
+			for i := 0; i < 3; i++ {
+				if err := guard.Fail("user@example.com", "10.0.0.1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := guard.Succeed("user@example.com"); err != nil {
+				t.Fatal(err)
+			}
+
+			account, err := store.Get(AccountKey("user@example.com"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if account.Failures != 0 {
+				t.Errorf("account failures = %d after Succeed, want 0", account.Failures)
+			}
+
+			ip, err := store.Get(IPKey("10.0.0.1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ip.Failures != 3 {
+				t.Errorf("IP failures = %d after Succeed, want 3", ip.Failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package loginguard
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepThreshold is the number of keys above which stale entries are
+// dropped from a MemoryStore
+const sweepThreshold = 10000
+
+// MemoryStore keeps failure state in process memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]State
+	window  time.Duration
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]State{}}
+}
+
+// Get returns the state of a key
+func (s *MemoryStore) Get(key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[key], nil
+}
+
+// RecordFailure adds a failure to a key
+func (s *MemoryStore) RecordFailure(key string, now time.Time, window time.Duration) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.window = window
+	if len(s.entries) > sweepThreshold {
+		s.sweep(now)
+	}
+
+	state := s.entries[key]
+	if now.Sub(state.LastFailure) > window {
+		state.Failures = 0
+	}
+	state.Failures++
+	state.LastFailure = now
+	s.entries[key] = state
+
+	return state, nil
+}
+
+// Lock locks a key until the given time
+func (s *MemoryStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.entries[key]
+	state.LockedUntil = until
+	s.entries[key] = state
+	return nil
+}
+
+// Reset forgets a key
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops entries that are neither locked nor inside the failure window
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, state := range s.entries {
+		if state.LockedUntil.Before(now) && now.Sub(state.LastFailure) > s.window {
+			delete(s.entries, key)
+		}
+	}
+}
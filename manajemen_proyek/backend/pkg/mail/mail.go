@@ -0,0 +1,103 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/unipro/project-management/config"
+)
+
+// Message represents a plain-text email
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email messages
+type Sender interface {
+	Send(msg Message) error
+}
+
+// NewSender returns the sender selected by MAIL_DRIVER ("smtp" or "log")
+func NewSender(cfg config.MailConfig) Sender {
+	if cfg.Driver == "smtp" {
+		return &SMTPSender{
+			Host:     cfg.Host,
+			Port:     cfg.Port,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			From:     cfg.From,
+		}
+	}
+	return &LogSender{Dir: cfg.LogDir, From: cfg.From}
+}
+
+// SMTPSender sends mail through an SMTP server
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers the message via SMTP
+func (s *SMTPSender) Send(msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%s", s.Host, s.Port)
+	if err := smtp.SendMail(addr, auth, s.From, msg.To, buildMessage(s.From, msg)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// LogSender logs the recipients and subject of messages and, when Dir is
+// set, writes the full message to .eml files readable only by the server.
+// Bodies never reach the log since they carry reset and invitation tokens.
+// Intended for development and tests.
+type LogSender struct {
+	Dir  string
+	From string
+}
+
+// Send records the message instead of delivering it
+func (s *LogSender) Send(msg Message) error {
+	log.Printf("📧 Mail to %s: %s", strings.Join(msg.To, ", "), msg.Subject)
+
+	if s.Dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	filename := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+	if err := os.WriteFile(filepath.Join(s.Dir, filename), buildMessage(s.From, msg), 0600); err != nil {
+		return fmt.Errorf("failed to write mail file: %w", err)
+	}
+	return nil
+}
+
+// buildMessage renders the RFC 822 message
+func buildMessage(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + strings.Join(msg.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+	return []byte(b.String())
+}
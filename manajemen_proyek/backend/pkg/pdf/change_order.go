@@ -0,0 +1,164 @@
+package pdf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/unipro/project-management/internal/models"
+)
+
+const changeOrderOutputDir = "./uploads/change_orders"
+
+// ChangeOrderPDFGenerator generates the change order log of a project
+type ChangeOrderPDFGenerator struct{}
+
+// NewChangeOrderPDFGenerator creates a new change order PDF generator
+func NewChangeOrderPDFGenerator() *ChangeOrderPDFGenerator {
+	// Create output directory if not exists
+	if err := os.MkdirAll(changeOrderOutputDir, 0755); err != nil {
+		fmt.Printf("Warning: Could not create change order output directory: %v\n", err)
+	}
+	return &ChangeOrderPDFGenerator{}
+}
+
+// GenerateChangeOrderLogPDF generates a PDF file of a project's change order
+// log
+func (g *ChangeOrderPDFGenerator) GenerateChangeOrderLogPDF(log *models.ChangeOrderLog) (string, error) {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.AddPage()
+
+	// Title
+	pdf.SetFont("Arial", "B", 18)
+	pdf.CellFormat(0, 10, "CHANGE ORDER LOG", "0", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "", pdfFontSize)
+	pdf.CellFormat(0, 6, log.ProjectName, "0", 1, "C", false, 0, "")
+	pdf.Ln(5)
+
+	// Summary Section
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(0, 8, "Summary", "0", 1, "L", false, 0, "")
+	g.addRow(pdf, "Original Budget:", formatRupiah(log.OriginalBudget))
+	g.addRow(pdf, "Approved Changes:", fmt.Sprintf("%s (%d change orders)", formatRupiah(log.ApprovedCost), log.ApprovedCount))
+	g.addRow(pdf, "Current Budget:", formatRupiah(log.CurrentBudget))
+	g.addRow(pdf, "Original End Date:", log.OriginalEndDate.Format("02 Jan 2006"))
+	g.addRow(pdf, "Current End Date:", fmt.Sprintf("%s (%+d days)", log.CurrentEndDate.Format("02 Jan 2006"), log.ApprovedDays))
+	if log.PendingCount > 0 {
+		g.addRow(pdf, "Pending:", fmt.Sprintf("%s (%d change orders)", formatRupiah(log.PendingCost), log.PendingCount))
+	}
+	if log.RejectedCount > 0 {
+		g.addRow(pdf, "Rejected:", fmt.Sprintf("%d change orders", log.RejectedCount))
+	}
+	pdf.Ln(5)
+
+	// Change orders table
+	widths := []float64{22, 70, 28, 45, 20, 45, 47}
+	headers := []string{"CO No", "Title", "Status", "Cost Impact", "Days", "Budget After", "Approved"}
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetFillColor(200, 200, 200)
+	for i, header := range headers {
+		pdf.CellFormat(widths[i], 7, header, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Arial", "", 9)
+	for _, co := range log.ChangeOrders {
+		budgetAfter := "-"
+		if co.BudgetAfter != nil {
+			budgetAfter = formatRupiah(*co.BudgetAfter)
+		}
+		approved := "-"
+		if co.ApprovedAt != nil {
+			approved = co.ApprovedAt.Format("02 Jan 2006")
+		}
+
+		pdf.CellFormat(widths[0], 7, co.CONumber, "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 7, truncate(pdf, co.Title, widths[1]), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], 7, string(co.Status), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(widths[3], 7, formatRupiah(co.CostImpact), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 7, fmt.Sprintf("%+d", co.ScheduleImpactDays), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 7, budgetAfter, "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[6], 7, approved, "1", 1, "C", false, 0, "")
+	}
+	pdf.Ln(5)
+
+	// Reasons Section
+	if len(log.ChangeOrders) > 0 {
+		pdf.SetFont("Arial", "B", 14)
+		pdf.CellFormat(0, 8, "Details", "0", 1, "L", false, 0, "")
+		for _, co := range log.ChangeOrders {
+			pdf.SetFont("Arial", "B", pdfFontSize)
+			pdf.CellFormat(0, 6, fmt.Sprintf("%s  %s", co.CONumber, co.Title), "0", 1, "L", false, 0, "")
+			pdf.SetFont("Arial", "", 10)
+			if co.Reason != "" {
+				pdf.MultiCell(0, 5, "Reason: "+co.Reason, "0", "L", false)
+			}
+			if co.Description != "" {
+				pdf.MultiCell(0, 5, co.Description, "0", "L", false)
+			}
+			for _, item := range co.Items {
+				name := fmt.Sprintf("Material %d", item.MaterialID)
+				if item.Material != nil {
+					name = item.Material.Name
+				}
+				line := fmt.Sprintf("- %s: %+.2f x %s = %s", name, item.QuantityChange, formatRupiah(item.UnitPrice), formatRupiah(item.CostImpact))
+				pdf.CellFormat(0, 5, line, "0", 1, "L", false, 0, "")
+			}
+			for _, approval := range co.Approvals {
+				if approval.Approver == nil || approval.ApprovedAt == nil {
+					continue
+				}
+				line := fmt.Sprintf("%s %s by %s on %s", approval.Stage, approval.Status, approval.Approver.Name, approval.ApprovedAt.Format("02 Jan 2006"))
+				if approval.OnBehalfOf != nil {
+					line += " on behalf of " + approval.OnBehalfOf.Name
+				}
+				if approval.Comment != "" {
+					line += ": " + approval.Comment
+				}
+				pdf.MultiCell(0, 5, line, "0", "L", false)
+			}
+			pdf.Ln(2)
+		}
+	}
+
+	// Footer
+	pdf.Ln(5)
+	pdf.SetFont("Arial", "I", 10)
+	generatedAt := time.Now().Format("02 January 2006 15:04")
+	pdf.CellFormat(0, 5, fmt.Sprintf("Generated on: %s", generatedAt), "0", 1, "L", false, 0, "")
+
+	// Save PDF
+	filename := fmt.Sprintf("CO-LOG-%d-%s.pdf", log.ProjectID, time.Now().Format("20060102150405"))
+	pdfPath := filepath.Join(changeOrderOutputDir, filename)
+
+	if err := pdf.OutputFileAndClose(pdfPath); err != nil {
+		return "", fmt.Errorf("failed to save PDF: %v", err)
+	}
+
+	// Return relative path for storage
+	return fmt.Sprintf("/uploads/change_orders/%s", filename), nil
+}
+
+// addRow adds a key-value row to the PDF
+func (g *ChangeOrderPDFGenerator) addRow(pdf *gofpdf.Fpdf, label, value string) {
+	pdf.SetFont("Arial", "B", pdfFontSize)
+	pdf.CellFormat(50, 6, label, "0", 0, "L", false, 0, "")
+	pdf.SetFont("Arial", "", pdfFontSize)
+	pdf.CellFormat(0, 6, value, "0", 1, "L", false, 0, "")
+}
+
+// truncate shortens text to fit a cell of the given width
+func truncate(pdf *gofpdf.Fpdf, text string, width float64) string {
+	const padding = 2
+	if pdf.GetStringWidth(text) <= width-padding {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width-padding {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
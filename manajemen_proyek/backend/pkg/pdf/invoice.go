@@ -0,0 +1,181 @@
+package pdf
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/unipro/project-management/internal/models"
+)
+
+const invoiceOutputDir = "./uploads/invoices"
+
+// InvoicePDFGenerator generates PDF for customer invoices
+type InvoicePDFGenerator struct{}
+
+// NewInvoicePDFGenerator creates a new invoice PDF generator
+func NewInvoicePDFGenerator() *InvoicePDFGenerator {
+	// Create output directory if not exists
+	if err := os.MkdirAll(invoiceOutputDir, 0755); err != nil {
+		fmt.Printf("Warning: Could not create invoice output directory: %v\n", err)
+	}
+	return &InvoicePDFGenerator{}
+}
+
+// GenerateInvoicePDF generates a PDF file for an invoice billed under the
+// contract. The invoice must have its project and payments loaded.
+func (g *InvoicePDFGenerator) GenerateInvoicePDF(invoice *models.Invoice, contract *models.ProjectContract) (string, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.AddPage()
+
+	// Title
+	pdf.SetFont("Arial", "B", 20)
+	pdf.CellFormat(0, 10, "INVOICE", "0", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "", pdfFontSize)
+	pdf.CellFormat(0, 6, invoice.InvoiceNumber, "0", 1, "C", false, 0, "")
+	pdf.Ln(5)
+
+	// Customer Section
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(0, 8, "Bill To", "0", 1, "L", false, 0, "")
+	customer := contract.CustomerName
+	if customer == "" && invoice.Project != nil {
+		customer = invoice.Project.Customer
+	}
+	g.addRow(pdf, "Customer:", customer)
+	if contract.CustomerAddress != "" {
+		g.addRow(pdf, "Address:", contract.CustomerAddress)
+	}
+	if contract.CustomerTaxID != "" {
+		g.addRow(pdf, "NPWP:", contract.CustomerTaxID)
+	}
+	pdf.Ln(3)
+
+	// Invoice Info Section
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(0, 8, "Invoice Information", "0", 1, "L", false, 0, "")
+	if invoice.Project != nil {
+		g.addRow(pdf, "Project:", invoice.Project.Name)
+	}
+	if contract.ContractNumber != "" {
+		g.addRow(pdf, "Contract No:", contract.ContractNumber)
+	}
+	g.addRow(pdf, "Contract Value:", formatRupiah(contract.ContractValue))
+	if invoice.IssueDate != nil {
+		g.addRow(pdf, "Issue Date:", invoice.IssueDate.Format("02 Jan 2006"))
+	}
+	if invoice.DueDate != nil {
+		g.addRow(pdf, "Due Date:", invoice.DueDate.Format("02 Jan 2006"))
+	}
+	pdf.Ln(5)
+
+	// Amounts table
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetFillColor(200, 200, 200)
+	pdf.CellFormat(130, 7, "Description", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(60, 7, "Amount", "1", 1, "C", true, 0, "")
+
+	pdf.SetFont("Arial", "", 10)
+	g.addAmount(pdf, invoice.Description, invoice.GrossAmount)
+	if invoice.DownPaymentDeduction > 0 {
+		g.addAmount(pdf, fmt.Sprintf("Down payment recovery (%.2f%%)", contract.DownPaymentPct), -invoice.DownPaymentDeduction)
+	}
+	g.addAmount(pdf, "Tax base (DPP)", invoice.TaxBase)
+	if invoice.TaxPct > 0 {
+		g.addAmount(pdf, fmt.Sprintf("PPN (%.2f%%)", invoice.TaxPct), invoice.TaxAmount)
+	}
+	if invoice.RetentionAmount > 0 {
+		g.addAmount(pdf, fmt.Sprintf("Retention (%.2f%%)", contract.RetentionPct), -invoice.RetentionAmount)
+	}
+
+	pdf.SetFont("Arial", "B", 10)
+	g.addAmount(pdf, "TOTAL DUE", invoice.TotalAmount)
+	if invoice.PaidAmount > 0 {
+		pdf.SetFont("Arial", "", 10)
+		g.addAmount(pdf, "Paid", -invoice.PaidAmount)
+		pdf.SetFont("Arial", "B", 10)
+		g.addAmount(pdf, "Outstanding", invoice.Outstanding())
+	}
+	pdf.Ln(5)
+
+	// Payments Section
+	if len(invoice.Payments) > 0 {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.CellFormat(0, 6, "Payments Received:", "0", 1, "L", false, 0, "")
+		pdf.SetFont("Arial", "", 10)
+		for _, payment := range invoice.Payments {
+			line := fmt.Sprintf("%s  %s  %s", payment.PaymentDate.Format("02 Jan 2006"), payment.ReceiptNumber, formatRupiah(payment.Amount))
+			if payment.Reference != "" {
+				line += "  (" + payment.Reference + ")"
+			}
+			pdf.CellFormat(0, 6, line, "0", 1, "L", false, 0, "")
+		}
+		pdf.Ln(3)
+	}
+
+	// Notes Section
+	if invoice.Notes != "" {
+		pdf.SetFont("Arial", "B", 14)
+		pdf.CellFormat(0, 8, "Notes", "0", 1, "L", false, 0, "")
+		pdf.SetFont("Arial", "", pdfFontSize)
+		pdf.MultiCell(0, 5, invoice.Notes, "0", "L", false)
+	}
+
+	// Footer
+	pdf.Ln(10)
+	pdf.SetFont("Arial", "I", 10)
+	generatedAt := time.Now().Format("02 January 2006 15:04")
+	pdf.CellFormat(0, 5, fmt.Sprintf("Generated on: %s", generatedAt), "0", 1, "L", false, 0, "")
+
+	// Save PDF
+	filename := fmt.Sprintf("%s.pdf", strings.ReplaceAll(invoice.InvoiceNumber, "/", "-"))
+	pdfPath := filepath.Join(invoiceOutputDir, filename)
+
+	if err := pdf.OutputFileAndClose(pdfPath); err != nil {
+		return "", fmt.Errorf("failed to save PDF: %v", err)
+	}
+
+	// Return relative path for storage
+	return fmt.Sprintf("/uploads/invoices/%s", filename), nil
+}
+
+// addRow adds a key-value row to the PDF
+func (g *InvoicePDFGenerator) addRow(pdf *gofpdf.Fpdf, label, value string) {
+	pdf.SetFont("Arial", "B", pdfFontSize)
+	pdf.CellFormat(50, 6, label, "0", 0, "L", false, 0, "")
+	pdf.SetFont("Arial", "", pdfFontSize)
+	pdf.CellFormat(0, 6, value, "0", 1, "L", false, 0, "")
+}
+
+// addAmount adds a description and amount row to the amounts table
+func (g *InvoicePDFGenerator) addAmount(pdf *gofpdf.Fpdf, description string, amount float64) {
+	pdf.CellFormat(130, 7, description, "1", 0, "L", false, 0, "")
+	pdf.CellFormat(60, 7, formatRupiah(amount), "1", 1, "R", false, 0, "")
+}
+
+// formatRupiah formats an amount as Indonesian Rupiah, e.g. Rp 1.250.000,00
+func formatRupiah(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	cents := int64(math.Round(amount * 100))
+	whole := fmt.Sprintf("%d", cents/100)
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte('.')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	return fmt.Sprintf("%sRp %s,%02d", sign, grouped.String(), cents%100)
+}
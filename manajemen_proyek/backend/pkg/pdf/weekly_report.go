@@ -137,6 +137,9 @@ func (g *WeeklyReportPDFGenerator) GenerateWeeklyReportPDF(report *models.Weekly
 		pdf.Ln(3)
 	}
 
+	// Open issues and risks as they stood at the end of the week
+	g.addOpenRisks(pdf, report)
+
 	// Next Week Plan Section
 	if report.NextWeekPlan != "" {
 		pdf.SetFont("Arial", "B", 14)
@@ -177,3 +180,60 @@ func (g *WeeklyReportPDFGenerator) addRow(pdf *gofpdf.Fpdf, label, value string)
 	pdf.CellFormat(0, 6, value, "0", 1, "L", false, 0, "")
 }
 
+// addOpenRisks adds the register entries that were open at the end of the
+// report's week, highest score first
+func (g *WeeklyReportPDFGenerator) addOpenRisks(pdf *gofpdf.Fpdf, report *models.WeeklyReport) {
+	weekEnd := report.EndDate.AddDate(0, 0, 1)
+
+	var risks []models.Risk
+	g.db.Preload("Owner").
+		Where("project_id = ? AND created_at < ?", report.ProjectID, weekEnd).
+		Where("closed_at IS NULL OR closed_at >= ?", weekEnd).
+		Order("score DESC, due_date ASC NULLS LAST").
+		Find(&risks)
+
+	if len(risks) == 0 {
+		return
+	}
+
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(0, 8, fmt.Sprintf("Open Issues & Risks (%d)", len(risks)), "0", 1, "L", false, 0, "")
+
+	widths := []float64{18, 15, 75, 22, 35, 25}
+	headers := []string{"Code", "Type", "Title", "Score", "Owner", "Due Date"}
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetFillColor(200, 200, 200)
+	for i, header := range headers {
+		pdf.CellFormat(widths[i], 7, header, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Arial", "", 9)
+	overdue := false
+	for _, risk := range risks {
+		owner := "-"
+		if risk.Owner != nil {
+			owner = risk.Owner.Name
+		}
+		dueDate := "-"
+		if risk.DueDate != nil {
+			dueDate = risk.DueDate.Format("02 Jan 2006")
+			if risk.DueDate.Before(report.EndDate) {
+				dueDate += " !"
+				overdue = true
+			}
+		}
+
+		pdf.CellFormat(widths[0], 7, risk.Code, "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 7, string(risk.Type), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(widths[2], 7, truncate(pdf, risk.Title, widths[2]), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[3], 7, fmt.Sprintf("%d %s", risk.Score, risk.Level), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(widths[4], 7, truncate(pdf, owner, widths[4]), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[5], 7, dueDate, "1", 1, "C", false, 0, "")
+	}
+	if overdue {
+		pdf.SetFont("Arial", "I", 8)
+		pdf.CellFormat(0, 5, "! past due at the end of the week", "0", 1, "L", false, 0, "")
+	}
+	pdf.Ln(3)
+}
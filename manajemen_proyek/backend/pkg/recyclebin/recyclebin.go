@@ -0,0 +1,588 @@
+// Package recyclebin lists, restores and purges soft-deleted records. A
+// record is deleted together with the dependents it owns, all stamped with
+// the same deleted_at, so restoring it brings back exactly those rows and
+// leaves dependents that were deleted on their own in the bin. Callers pass
+// the transaction the change should be part of; Purge returns the stored
+// files of the purged rows, which the caller removes with RemoveFiles once
+// that transaction has committed.
+package recyclebin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/unipro/project-management/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotDeleted is returned when restoring or purging a live record
+	ErrNotDeleted = errors.New("record is not in the recycle bin")
+	// ErrParentDeleted is returned when a record's parent must be restored
+	// first
+	ErrParentDeleted = errors.New("parent record is deleted")
+	// ErrConflict is returned when a live record takes the place of the one
+	// being restored
+	ErrConflict = errors.New("a record with the same values already exists")
+	// ErrReferenced is returned when other records still use the record
+	// being purged
+	ErrReferenced = errors.New("record is still referenced")
+)
+
+// Reference is a column of a table pointing to another record
+type Reference struct {
+	Model  interface{}
+	Column string
+}
+
+// Dependent is a table whose rows belong to a record of another table
+type Dependent struct {
+	Model      interface{}
+	ForeignKey string
+	// Cascade deletes and restores the rows together with their parent.
+	// Other dependents are only removed when the parent is purged.
+	Cascade bool
+	// File is a column holding the path of a stored file, removed from disk
+	// when the row is purged
+	File       string
+	Dependents []Dependent
+}
+
+// Kind is a type of record kept in the recycle bin
+type Kind struct {
+	Name       string // Table of the records, also the type used by the API
+	Model      interface{}
+	Label      string      // SQL expression naming the record in listings
+	Joins      string      // Joins needed by Label
+	ProjectKey string      // Column of the owning project, empty when not project data
+	Parents    []Reference // Records that must be live to restore, by the column pointing to them
+	Unique     []string    // Columns no live record may share with a restored one
+	Dependents []Dependent // Purged in order, so list rows referenced by siblings last
+	Unlink     []Reference // Columns cleared when the record is purged
+	Blockers   []Reference // References that prevent purging
+}
+
+var (
+	Projects = Kind{
+		Name:       "projects",
+		Model:      models.Project{},
+		Label:      "projects.name",
+		ProjectKey: "id",
+		Dependents: []Dependent{
+			{Model: models.Document{}, ForeignKey: "project_id", Cascade: true, Dependents: documentDependents},
+			{Model: models.DocumentFolder{}, ForeignKey: "project_id"},
+			{Model: models.MaterialUsage{}, ForeignKey: "project_id", Cascade: true},
+			{Model: models.CostEntry{}, ForeignKey: "project_id", Cascade: true},
+			{Model: models.BudgetLine{}, ForeignKey: "project_id", Cascade: true},
+			{Model: models.ChangeOrder{}, ForeignKey: "project_id", Dependents: []Dependent{
+				{Model: models.ChangeOrderItem{}, ForeignKey: "change_order_id"},
+				{Model: models.ChangeOrderApproval{}, ForeignKey: "change_order_id"},
+			}},
+			{Model: models.BOM{}, ForeignKey: "project_id", Cascade: true},
+			{Model: models.Risk{}, ForeignKey: "project_id", Cascade: true, Dependents: riskDependents},
+			{Model: models.DailyReport{}, ForeignKey: "project_id", Cascade: true, Dependents: []Dependent{
+				{Model: models.DailyReportActivity{}, ForeignKey: "daily_report_id"},
+				{Model: models.Photo{}, ForeignKey: "daily_report_id", Cascade: true},
+			}},
+			{Model: models.WeeklyReport{}, ForeignKey: "project_id", Cascade: true},
+			{Model: models.PurchaseRequest{}, ForeignKey: "project_id", Dependents: []Dependent{
+				{Model: models.PRItem{}, ForeignKey: "purchase_request_id"},
+				{Model: models.ApprovalHistory{}, ForeignKey: "purchase_request_id"},
+				{Model: models.PRComment{}, ForeignKey: "purchase_request_id"},
+			}},
+			{Model: models.Invoice{}, ForeignKey: "project_id", Dependents: []Dependent{
+				{Model: models.InvoicePayment{}, ForeignKey: "invoice_id"},
+			}},
+			{Model: models.Subcontract{}, ForeignKey: "project_id", Dependents: []Dependent{
+				{Model: models.PaymentCertificate{}, ForeignKey: "subcontract_id", Dependents: []Dependent{
+					{Model: models.SubcontractPayment{}, ForeignKey: "certificate_id"},
+					{Model: models.PaymentCertificateApproval{}, ForeignKey: "certificate_id"},
+				}},
+			}},
+			{Model: models.BillingMilestone{}, ForeignKey: "project_id"},
+			{Model: models.ProjectContract{}, ForeignKey: "project_id"},
+			{Model: models.TaskDependency{}, ForeignKey: "project_id"},
+			{Model: models.Task{}, ForeignKey: "project_id"},
+			{Model: models.ProjectPhase{}, ForeignKey: "project_id"},
+			{Model: models.ProgressSnapshot{}, ForeignKey: "project_id"},
+			{Model: models.EVMSnapshot{}, ForeignKey: "project_id"},
+			{Model: models.ProjectMember{}, ForeignKey: "project_id"},
+			{Model: models.ProgressBreakdown{}, ForeignKey: "project_id"},
+		},
+		Unlink: []Reference{
+			{Model: models.Approval{}, Column: "project_id"},
+			{Model: models.ProjectTemplate{}, Column: "source_project_id"},
+		},
+	}
+
+	Materials = Kind{
+		Name:   "materials",
+		Model:  models.Material{},
+		Label:  "materials.name",
+		Unique: []string{"code"},
+		Blockers: []Reference{
+			{Model: models.BOM{}, Column: "material_id"},
+			{Model: models.MaterialUsage{}, Column: "material_id"},
+			{Model: models.PRItem{}, Column: "material_id"},
+			{Model: models.ChangeOrderItem{}, Column: "material_id"},
+			{Model: models.TemplateBOMItem{}, Column: "material_id"},
+		},
+	}
+
+	Subcontractors = Kind{
+		Name:   "subcontractors",
+		Model:  models.Subcontractor{},
+		Label:  "subcontractors.name",
+		Unique: []string{"code"},
+		Blockers: []Reference{
+			{Model: models.Subcontract{}, Column: "subcontractor_id"},
+		},
+	}
+
+	BOMs = Kind{
+		Name:       "boms",
+		Model:      models.BOM{},
+		Label:      "materials.name",
+		Joins:      "LEFT JOIN materials ON materials.id = boms.material_id",
+		ProjectKey: "project_id",
+		Parents: []Reference{
+			{Model: models.Project{}, Column: "project_id"},
+			{Model: models.Material{}, Column: "material_id"},
+		},
+		Unique: []string{"project_id", "material_id"},
+		Dependents: []Dependent{
+			{Model: models.BudgetLine{}, ForeignKey: "bom_id", Cascade: true},
+		},
+		Unlink: []Reference{
+			{Model: models.ChangeOrderItem{}, Column: "bom_id"},
+		},
+	}
+
+	DailyReports = Kind{
+		Name:       "daily_reports",
+		Model:      models.DailyReport{},
+		Label:      "to_char(daily_reports.date, 'YYYY-MM-DD')",
+		ProjectKey: "project_id",
+		Parents: []Reference{
+			{Model: models.Project{}, Column: "project_id"},
+		},
+		Dependents: []Dependent{
+			{Model: models.DailyReportActivity{}, ForeignKey: "daily_report_id"},
+			{Model: models.Photo{}, ForeignKey: "daily_report_id", Cascade: true},
+			{Model: models.DocumentLink{}, ForeignKey: "daily_report_id"},
+		},
+		Unlink: []Reference{
+			{Model: models.MaterialUsage{}, Column: "daily_report_id"},
+			{Model: models.Risk{}, Column: "daily_report_id"},
+		},
+	}
+
+	Documents = Kind{
+		Name:       "documents",
+		Model:      models.Document{},
+		Label:      "documents.title",
+		ProjectKey: "project_id",
+		Parents: []Reference{
+			{Model: models.Project{}, Column: "project_id"},
+		},
+		Dependents: documentDependents,
+	}
+
+	Risks = Kind{
+		Name:       "risks",
+		Model:      models.Risk{},
+		Label:      "risks.code || ' ' || risks.title",
+		ProjectKey: "project_id",
+		Parents: []Reference{
+			{Model: models.Project{}, Column: "project_id"},
+		},
+		Dependents: riskDependents,
+	}
+
+	// Kinds lists every kind, owners before what they own so expired
+	// projects take their BOM and reports with them
+	Kinds = []Kind{Projects, DailyReports, Documents, Risks, BOMs, Materials, Subcontractors}
+)
+
+// documentDependents are the rows of a document, links first as they may
+// pin a version
+var documentDependents = []Dependent{
+	{Model: models.DocumentLink{}, ForeignKey: "document_id"},
+	{Model: models.DocumentVersion{}, ForeignKey: "document_id", Cascade: true, File: "storage_path"},
+}
+
+// riskDependents are the rows of a register entry
+var riskDependents = []Dependent{
+	{Model: models.RiskUpdate{}, ForeignKey: "risk_id"},
+}
+
+// FindKind returns the kind with the given name
+func FindKind(name string) (Kind, bool) {
+	for _, kind := range Kinds {
+		if kind.Name == name {
+			return kind, true
+		}
+	}
+	return Kind{}, false
+}
+
+// Item is one record in the recycle bin
+type Item struct {
+	Type      string     `json:"type"`
+	ID        uint       `json:"id"`
+	Label     string     `json:"label"`
+	ProjectID *uint      `json:"project_id,omitempty"`
+	DeletedAt time.Time  `json:"deleted_at"`
+	DeletedBy *uint      `json:"deleted_by,omitempty"` // From the audit trail, empty for system deletes
+	PurgeAt   *time.Time `json:"purge_at,omitempty"`   // Empty when records are kept indefinitely
+}
+
+// List returns the deleted records of a kind, most recent first. Records
+// deleted together with their project are listed under the project only.
+// scope restricts the query, e.g. to the user's projects.
+func List(db *gorm.DB, kind Kind, retentionDays int, scope func(*gorm.DB) *gorm.DB) ([]Item, error) {
+	table, err := tableName(db, kind.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	projectColumn := "NULL"
+	if kind.ProjectKey != "" {
+		projectColumn = table + "." + kind.ProjectKey
+	}
+	query := db.Unscoped().Table(table).
+		Select(fmt.Sprintf("%s.id AS id, %s AS label, %s AS project_id, %s.deleted_at AS deleted_at", table, kind.Label, projectColumn, table)).
+		Where(table + ".deleted_at IS NOT NULL")
+	if kind.Joins != "" {
+		query = query.Joins(kind.Joins)
+	}
+	if kind.ProjectKey != "" && kind.ProjectKey != "id" {
+		query = query.Where(projectColumn+" IN (?)", db.Model(&models.Project{}).Select("id"))
+	}
+	if scope != nil {
+		query = query.Scopes(scope)
+	}
+
+	var items []Item
+	if err := query.Order(table + ".deleted_at DESC").Scan(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return items, nil
+	}
+
+	ids := make([]string, len(items))
+	for i := range items {
+		ids[i] = strconv.FormatUint(uint64(items[i].ID), 10)
+	}
+	var logs []models.AuditLog
+	if err := db.Select("entity_id", "actor_id").
+		Where("entity_type = ? AND action = ? AND entity_id IN ?", table, models.AuditActionDelete, ids).
+		Order("id ASC").Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	deletedBy := map[string]*uint{}
+	for _, entry := range logs {
+		deletedBy[entry.EntityID] = entry.ActorID
+	}
+
+	for i := range items {
+		items[i].Type = kind.Name
+		items[i].DeletedBy = deletedBy[ids[i]]
+		if retentionDays > 0 {
+			purgeAt := items[i].DeletedAt.AddDate(0, 0, retentionDays)
+			items[i].PurgeAt = &purgeAt
+		}
+	}
+	return items, nil
+}
+
+// Delete soft-deletes a record and the dependents it cascades to
+func Delete(tx *gorm.DB, kind Kind, id uint) error {
+	now := time.Now().Truncate(time.Microsecond)
+	stamped := tx.Session(&gorm.Session{NowFunc: func() time.Time { return now }})
+
+	result := stamped.Delete(newModel(kind.Model), id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return deleteDependents(stamped, kind.Dependents, []uint{id})
+}
+
+// Restore brings a record back together with the dependents deleted with it
+// and returns how many rows were restored
+func Restore(tx *gorm.DB, kind Kind, id uint) (int64, error) {
+	table, err := tableName(tx, kind.Model)
+	if err != nil {
+		return 0, err
+	}
+
+	var row map[string]interface{}
+	if err := tx.Unscoped().Table(table).Where("id = ?", id).Take(&row).Error; err != nil {
+		return 0, err
+	}
+	deletedAt, ok := row["deleted_at"].(time.Time)
+	if !ok {
+		return 0, ErrNotDeleted
+	}
+
+	for _, parent := range kind.Parents {
+		var count int64
+		if err := tx.Model(newModel(parent.Model)).Where("id = ?", row[parent.Column]).Count(&count).Error; err != nil {
+			return 0, err
+		}
+		if count == 0 {
+			parentTable, _ := tableName(tx, parent.Model)
+			return 0, fmt.Errorf("%w: restore it from %s first", ErrParentDeleted, parentTable)
+		}
+	}
+
+	if len(kind.Unique) > 0 {
+		query := tx.Model(newModel(kind.Model)).Where("id <> ?", id)
+		for _, column := range kind.Unique {
+			query = query.Where(column+" = ?", row[column])
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return 0, err
+		}
+		if count > 0 {
+			return 0, ErrConflict
+		}
+	}
+
+	if err := tx.Unscoped().Model(newModel(kind.Model)).Where("id = ?", id).Update("deleted_at", nil).Error; err != nil {
+		return 0, err
+	}
+	restored, err := restoreDependents(tx, kind.Dependents, []uint{id}, deletedAt)
+	return restored + 1, err
+}
+
+// Purge permanently deletes a record in the recycle bin together with every
+// row that belongs to it. It returns the paths of the stored files of those
+// rows; they are left on disk so a rolled back transaction loses nothing.
+func Purge(tx *gorm.DB, kind Kind, id uint) ([]string, error) {
+	var count int64
+	if err := tx.Unscoped().Model(newModel(kind.Model)).Where("id = ? AND deleted_at IS NOT NULL", id).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		if err := tx.Unscoped().Model(newModel(kind.Model)).Where("id = ?", id).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, ErrNotDeleted
+	}
+
+	for _, blocker := range kind.Blockers {
+		if err := tx.Unscoped().Model(newModel(blocker.Model)).Where(blocker.Column+" = ?", id).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			table, _ := tableName(tx, blocker.Model)
+			return nil, fmt.Errorf("%w by %d rows of %s", ErrReferenced, count, table)
+		}
+	}
+
+	for _, ref := range kind.Unlink {
+		if err := tx.Unscoped().Model(newModel(ref.Model)).Where(ref.Column+" = ?", id).Update(ref.Column, nil).Error; err != nil {
+			return nil, err
+		}
+	}
+	files, err := purgeDependents(tx, kind.Dependents, []uint{id})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Delete(newModel(kind.Model), id).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// PurgeExpired purges every record deleted more than retentionDays ago and
+// returns how many were purged. Records still referenced elsewhere are kept
+// and retried on the next run.
+func PurgeExpired(db *gorm.DB, retentionDays int) (int, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	purged := 0
+	for _, kind := range Kinds {
+		var ids []uint
+		if err := db.Unscoped().Model(newModel(kind.Model)).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Pluck("id", &ids).Error; err != nil {
+			return purged, err
+		}
+		for _, id := range ids {
+			var files []string
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				files, err = Purge(tx, kind, id)
+				return err
+			})
+			switch {
+			case err == nil:
+				purged++
+				RemoveFiles(files)
+			case errors.Is(err, ErrReferenced), errors.Is(err, ErrNotDeleted), errors.Is(err, gorm.ErrRecordNotFound):
+				// Referenced, restored or already purged with its owner
+			default:
+				return purged, fmt.Errorf("failed to purge %s %d: %w", kind.Name, id, err)
+			}
+		}
+	}
+	return purged, nil
+}
+
+// StartPurger purges expired records every interval, reading the retention
+// period from the settings each time. The first run is one interval after
+// startup, so a restart never hard-deletes anything by itself.
+func StartPurger(db *gorm.DB, interval time.Duration) {
+	run := func() {
+		settings := models.LoadRecycleBinSettings(db)
+		purged, err := PurgeExpired(db, settings.RetentionDays)
+		if err != nil {
+			log.Printf("⚠️  Recycle bin purge failed: %v", err)
+			return
+		}
+		if purged > 0 {
+			log.Printf("✓ Purged %d expired records from the recycle bin", purged)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			run()
+		}
+	}()
+}
+
+// deleteDependents soft-deletes the live cascading dependents of the parents
+func deleteDependents(tx *gorm.DB, dependents []Dependent, parentIDs []uint) error {
+	for _, dependent := range dependents {
+		if !dependent.Cascade {
+			continue
+		}
+		var ids []uint
+		if err := tx.Model(newModel(dependent.Model)).Where(dependent.ForeignKey+" IN ?", parentIDs).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		if err := deleteDependents(tx, dependent.Dependents, ids); err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", ids).Delete(newModel(dependent.Model)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreDependents restores the cascading dependents of the parents that
+// were deleted at the same moment as them
+func restoreDependents(tx *gorm.DB, dependents []Dependent, parentIDs []uint, deletedAt time.Time) (int64, error) {
+	var restored int64
+	for _, dependent := range dependents {
+		if !dependent.Cascade {
+			continue
+		}
+		var ids []uint
+		if err := tx.Unscoped().Model(newModel(dependent.Model)).
+			Where(dependent.ForeignKey+" IN ? AND deleted_at = ?", parentIDs, deletedAt).
+			Pluck("id", &ids).Error; err != nil {
+			return restored, err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		if err := tx.Unscoped().Model(newModel(dependent.Model)).Where("id IN ?", ids).Update("deleted_at", nil).Error; err != nil {
+			return restored, err
+		}
+		restored += int64(len(ids))
+
+		count, err := restoreDependents(tx, dependent.Dependents, ids, deletedAt)
+		restored += count
+		if err != nil {
+			return restored, err
+		}
+	}
+	return restored, nil
+}
+
+// purgeDependents permanently deletes every dependent of the parents,
+// deleted or not, children before their parents, and returns the stored
+// files of the deleted rows
+func purgeDependents(tx *gorm.DB, dependents []Dependent, parentIDs []uint) ([]string, error) {
+	var files []string
+	for _, dependent := range dependents {
+		var ids []uint
+		if err := tx.Unscoped().Model(newModel(dependent.Model)).Where(dependent.ForeignKey+" IN ?", parentIDs).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		childFiles, err := purgeDependents(tx, dependent.Dependents, ids)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, childFiles...)
+
+		if dependent.File != "" {
+			var paths []string
+			if err := tx.Unscoped().Model(newModel(dependent.Model)).Where("id IN ?", ids).Pluck(dependent.File, &paths).Error; err != nil {
+				return nil, err
+			}
+			files = append(files, paths...)
+		}
+		if err := tx.Unscoped().Where("id IN ?", ids).Delete(newModel(dependent.Model)).Error; err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// RemoveFiles deletes stored files of purged rows. Call it only after the
+// purge has committed. A file that cannot be removed only wastes space, so
+// failures are logged and skipped.
+func RemoveFiles(paths []string) {
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️  Failed to remove purged file %s: %v", path, err)
+		}
+	}
+}
+
+// newModel returns a pointer to a new zero value of the model's type
+func newModel(model interface{}) interface{} {
+	return reflect.New(reflect.TypeOf(model)).Interface()
+}
+
+// tableName returns the table of a model
+func tableName(db *gorm.DB, model interface{}) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(newModel(model)); err != nil {
+		return "", err
+	}
+	return stmt.Schema.Table, nil
+}
@@ -0,0 +1,232 @@
+// Package schedule computes early and late dates, float and the critical
+// path of a network of activities with the critical path method. Times are
+// whole days counted from the project start.
+package schedule
+
+import (
+	"errors"
+	"sort"
+)
+
+// DependencyType is the relation between a predecessor and a successor
+type DependencyType string
+
+const (
+	FinishToStart  DependencyType = "FS" // Successor starts after predecessor finishes
+	StartToStart   DependencyType = "SS" // Successor starts after predecessor starts
+	FinishToFinish DependencyType = "FF" // Successor finishes after predecessor finishes
+	StartToFinish  DependencyType = "SF" // Successor finishes after predecessor starts
+)
+
+// ErrCycle is returned when dependencies form a loop
+var ErrCycle = errors.New("schedule: dependencies form a cycle")
+
+// ErrUnknownActivity is returned when a link references a missing activity
+var ErrUnknownActivity = errors.New("schedule: link references an unknown activity")
+
+// Activity is one schedulable piece of work
+type Activity struct {
+	ID       uint
+	Duration int // Days, zero for milestones
+	MinStart int // Earliest allowed start, e.g. from a start-no-earlier-than constraint
+}
+
+// Link is a dependency between two activities. Lag is in days and may be
+// negative for lead time.
+type Link struct {
+	Predecessor uint
+	Successor   uint
+	Type        DependencyType
+	Lag         int
+}
+
+// Result holds the scheduled dates of an activity. Start is the first day
+// worked and Finish the day after the last.
+type Result struct {
+	EarlyStart  int
+	EarlyFinish int
+	LateStart   int
+	LateFinish  int
+	TotalFloat  int
+	FreeFloat   int
+	Critical    bool
+}
+
+// Schedule is the outcome of a forward and backward pass
+type Schedule struct {
+	Results      map[uint]Result
+	Finish       int    // Project finish, the latest early finish
+	CriticalPath []uint // Critical activities ordered by early start
+}
+
+// Valid checks if the dependency type is known
+func (t DependencyType) Valid() bool {
+	switch t {
+	case FinishToStart, StartToStart, FinishToFinish, StartToFinish:
+		return true
+	}
+	return false
+}
+
+// Compute runs the forward and backward passes over the activities
+func Compute(activities []Activity, links []Link) (Schedule, error) {
+	byID := make(map[uint]Activity, len(activities))
+	for _, a := range activities {
+		byID[a.ID] = a
+	}
+
+	successors := make(map[uint][]Link)
+	predecessors := make(map[uint][]Link)
+	for _, l := range links {
+		if _, ok := byID[l.Predecessor]; !ok {
+			return Schedule{}, ErrUnknownActivity
+		}
+		if _, ok := byID[l.Successor]; !ok {
+			return Schedule{}, ErrUnknownActivity
+		}
+		if l.Type == "" {
+			l.Type = FinishToStart
+		}
+		successors[l.Predecessor] = append(successors[l.Predecessor], l)
+		predecessors[l.Successor] = append(predecessors[l.Successor], l)
+	}
+
+	order, err := topologicalOrder(activities, successors, predecessors)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	results := make(map[uint]Result, len(activities))
+
+	// Forward pass: each activity starts as soon as all its predecessors allow
+	finish := 0
+	for _, id := range order {
+		a := byID[id]
+		start := a.MinStart
+		if start < 0 {
+			start = 0
+		}
+		for _, l := range predecessors[id] {
+			p := results[l.Predecessor]
+			if s := earliestStart(l, p, a.Duration); s > start {
+				start = s
+			}
+		}
+		results[id] = Result{EarlyStart: start, EarlyFinish: start + a.Duration}
+		if start+a.Duration > finish {
+			finish = start + a.Duration
+		}
+	}
+
+	// Backward pass: each activity finishes as late as its successors allow
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		a := byID[id]
+		r := results[id]
+
+		lateFinish := finish
+		freeFloat := finish - r.EarlyFinish
+		for _, l := range successors[id] {
+			s := results[l.Successor]
+			if f := latestFinish(l, s, a.Duration); f < lateFinish {
+				lateFinish = f
+			}
+			if slack := freeSlack(l, r, s); slack < freeFloat {
+				freeFloat = slack
+			}
+		}
+
+		r.LateFinish = lateFinish
+		r.LateStart = lateFinish - a.Duration
+		r.TotalFloat = r.LateStart - r.EarlyStart
+		r.FreeFloat = freeFloat
+		r.Critical = r.TotalFloat <= 0
+		results[id] = r
+	}
+
+	var critical []uint
+	for _, id := range order {
+		if results[id].Critical {
+			critical = append(critical, id)
+		}
+	}
+	sort.SliceStable(critical, func(i, j int) bool {
+		return results[critical[i]].EarlyStart < results[critical[j]].EarlyStart
+	})
+
+	return Schedule{Results: results, Finish: finish, CriticalPath: critical}, nil
+}
+
+// earliestStart returns the earliest start a link allows its successor
+func earliestStart(l Link, p Result, duration int) int {
+	switch l.Type {
+	case StartToStart:
+		return p.EarlyStart + l.Lag
+	case FinishToFinish:
+		return p.EarlyFinish + l.Lag - duration
+	case StartToFinish:
+		return p.EarlyStart + l.Lag - duration
+	default:
+		return p.EarlyFinish + l.Lag
+	}
+}
+
+// latestFinish returns the latest finish a link allows its predecessor
+func latestFinish(l Link, s Result, duration int) int {
+	switch l.Type {
+	case StartToStart:
+		return s.LateStart - l.Lag + duration
+	case FinishToFinish:
+		return s.LateFinish - l.Lag
+	case StartToFinish:
+		return s.LateFinish - l.Lag + duration
+	default:
+		return s.LateStart - l.Lag
+	}
+}
+
+// freeSlack returns how far the predecessor can slip without delaying the
+// early dates of the successor
+func freeSlack(l Link, p, s Result) int {
+	switch l.Type {
+	case StartToStart:
+		return s.EarlyStart - l.Lag - p.EarlyStart
+	case FinishToFinish:
+		return s.EarlyFinish - l.Lag - p.EarlyFinish
+	case StartToFinish:
+		return s.EarlyFinish - l.Lag - p.EarlyStart
+	default:
+		return s.EarlyStart - l.Lag - p.EarlyFinish
+	}
+}
+
+// topologicalOrder orders activities so every predecessor comes before its
+// successors, keeping the input order where dependencies allow
+func topologicalOrder(activities []Activity, successors, predecessors map[uint][]Link) ([]uint, error) {
+	pending := make(map[uint]int, len(activities))
+	var queue []uint
+	for _, a := range activities {
+		pending[a.ID] = len(predecessors[a.ID])
+		if pending[a.ID] == 0 {
+			queue = append(queue, a.ID)
+		}
+	}
+
+	order := make([]uint, 0, len(activities))
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		order = append(order, id)
+		for _, l := range successors[id] {
+			pending[l.Successor]--
+			if pending[l.Successor] == 0 {
+				queue = append(queue, l.Successor)
+			}
+		}
+	}
+
+	if len(order) != len(pending) {
+		return nil, ErrCycle
+	}
+	return order, nil
+}
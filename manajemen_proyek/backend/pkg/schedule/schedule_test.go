@@ -0,0 +1,153 @@
+package schedule
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestComputeFloatAndCriticalPath(t *testing.T) {
+	// A and B both lead into C, which leads into D. B is a day shorter
+	// than A, so it has one day of float and everything else is critical.
+	activities := []Activity{
+		{ID: 1, Duration: 3},
+		{ID: 2, Duration: 2},
+		{ID: 3, Duration: 4},
+		{ID: 4, Duration: 1},
+	}
+	links := []Link{
+		{Predecessor: 1, Successor: 3},
+		{Predecessor: 2, Successor: 3, Type: FinishToStart},
+		{Predecessor: 3, Successor: 4},
+	}
+
+	got, err := Compute(activities, links)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uint]Result{
+		1: {EarlyStart: 0, EarlyFinish: 3, LateStart: 0, LateFinish: 3, Critical: true},
+		2: {EarlyStart: 0, EarlyFinish: 2, LateStart: 1, LateFinish: 3, TotalFloat: 1, FreeFloat: 1},
+		3: {EarlyStart: 3, EarlyFinish: 7, LateStart: 3, LateFinish: 7, Critical: true},
+		4: {EarlyStart: 7, EarlyFinish: 8, LateStart: 7, LateFinish: 8, Critical: true},
+	}
+	for id, w := range want {
+		if got.Results[id] != w {
+			t.Errorf("activity %d = %+v, want %+v", id, got.Results[id], w)
+		}
+	}
+	if got.Finish != 8 {
+		t.Errorf("Finish = %d, want 8", got.Finish)
+	}
+	if !reflect.DeepEqual(got.CriticalPath, []uint{1, 3, 4}) {
+		t.Errorf("CriticalPath = %v, want [1 3 4]", got.CriticalPath)
+	}
+}
+
+func TestComputeFreeFloatDiffersFromTotalFloat(t *testing.T) {
+	// B and C are a two day chain beside the five day A. B can only slip
+	// as far as C lets it, so it has no free float but shares C's total
+	// float.
+	activities := []Activity{
+		{ID: 1, Duration: 5},
+		{ID: 2, Duration: 1},
+		{ID: 3, Duration: 1},
+	}
+	links := []Link{{Predecessor: 2, Successor: 3}}
+
+	got, err := Compute(activities, links)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id       uint
+		total    int
+		free     int
+		critical bool
+	}{
+		{1, 0, 0, true},
+		{2, 3, 0, false},
+		{3, 3, 3, false},
+	}
+	for _, tt := range tests {
+		r := got.Results[tt.id]
+		if r.TotalFloat != tt.total || r.FreeFloat != tt.free || r.Critical != tt.critical {
+			t.Errorf("activity %d: total %d free %d critical %v, want %d %d %v",
+				tt.id, r.TotalFloat, r.FreeFloat, r.Critical, tt.total, tt.free, tt.critical)
+		}
+	}
+}
+
+func TestComputeLinkTypesAndLag(t *testing.T) {
+	// Activity 1 runs days 0-3 and activity 2 lasts two days
+	tests := []struct {
+		name       string
+		link       Link
+		wantStart  int
+		wantFinish int
+	}{
+		{"finish to start", Link{Type: FinishToStart}, 3, 5},
+		{"default type is finish to start", Link{}, 3, 5},
+		{"finish to start with lag", Link{Type: FinishToStart, Lag: 2}, 5, 7},
+		{"finish to start with lead", Link{Type: FinishToStart, Lag: -1}, 2, 4},
+		{"start to start with lag", Link{Type: StartToStart, Lag: 1}, 1, 3},
+		{"finish to finish with lag", Link{Type: FinishToFinish, Lag: 1}, 2, 4},
+		{"start to finish with lag", Link{Type: StartToFinish, Lag: 4}, 2, 4},
+		{"lead never starts before the project", Link{Type: FinishToStart, Lag: -5}, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := tt.link
+			link.Predecessor, link.Successor = 1, 2
+			got, err := Compute([]Activity{{ID: 1, Duration: 3}, {ID: 2, Duration: 2}}, []Link{link})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := got.Results[2]
+			if r.EarlyStart != tt.wantStart || r.EarlyFinish != tt.wantFinish {
+				t.Errorf("successor runs %d-%d, want %d-%d", r.EarlyStart, r.EarlyFinish, tt.wantStart, tt.wantFinish)
+			}
+		})
+	}
+}
+
+func TestComputeMinStart(t *testing.T) {
+	got, err := Compute([]Activity{{ID: 1, Duration: 2, MinStart: 4}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := got.Results[1]; r.EarlyStart != 4 || got.Finish != 6 {
+		t.Errorf("got start %d finish %d, want 4 and 6", r.EarlyStart, got.Finish)
+	}
+}
+
+func TestComputeRejectsInvalidNetworks(t *testing.T) {
+	activities := []Activity{{ID: 1, Duration: 1}, {ID: 2, Duration: 1}, {ID: 3, Duration: 1}}
+
+	tests := []struct {
+		name  string
+		links []Link
+		want  error
+	}{
+		{"self loop", []Link{{Predecessor: 1, Successor: 1}}, ErrCycle},
+		{"two activity cycle", []Link{{Predecessor: 1, Successor: 2}, {Predecessor: 2, Successor: 1}}, ErrCycle},
+		{"longer cycle", []Link{
+			{Predecessor: 1, Successor: 2},
+			{Predecessor: 2, Successor: 3},
+			{Predecessor: 3, Successor: 1, Type: StartToStart},
+		}, ErrCycle},
+		{"unknown predecessor", []Link{{Predecessor: 9, Successor: 1}}, ErrUnknownActivity},
+		{"unknown successor", []Link{{Predecessor: 1, Successor: 9}}, ErrUnknownActivity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compute(activities, tt.links); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// tokenBytes is the amount of random data in a generated token
+const tokenBytes = 32
+
+// Generate creates a random opaque token and returns the plain value, which
+// is handed to the client once, together with its hash for storage
+func Generate() (plain string, hash string, err error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	plain = hex.EncodeToString(buf)
+	return plain, Hash(plain), nil
+}
+
+// Hash returns the SHA-256 hex digest used to look up a stored token
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewID returns a random 128-bit hex identifier, used for JWT IDs
+func NewID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// NewRecoveryCode returns a short human-typable code in the form xxxxx-xxxxx
+func NewRecoveryCode() (string, error) {
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+	code := hex.EncodeToString(buf)
+	return code[:5] + "-" + code[5:], nil
+}